@@ -36,21 +36,26 @@ type CollaborationProtocol struct {
 
 // AgentWorkflow represents a sequence of agent interactions to complete a complex task
 type AgentWorkflow struct {
-	ID          string                `json:"id"`
-	Name        string                `json:"name"`
-	Description string                `json:"description"`
-	Steps       []WorkflowStep        `json:"steps"`
+	ID          string                `json:"id" jsonschema:"description=Unique workflow ID; defaults to the file name in workflow files,example=release"`
+	Name        string                `json:"name" jsonschema:"required,description=Human-readable name of the workflow"`
+	Description string                `json:"description" jsonschema:"description=What the workflow achieves"`
+	Steps       []WorkflowStep        `json:"steps" jsonschema:"required,minItems=1,description=Steps of the workflow"`
+	Budget      *Budget               `json:"budget,omitempty" jsonschema:"description=Limits on what each instance of the workflow may use"`
 }
 
 // WorkflowStep represents a single step in an agent workflow
 type WorkflowStep struct {
-	StepNumber      int             `json:"step_number"`
-	ResponsibleAgent AgentRole      `json:"responsible_agent"`
-	Action          string          `json:"action"`
-	Input           string          `json:"input,omitempty"`
-	ExpectedOutput  string          `json:"expected_output"`
-	Dependencies    []int           `json:"dependencies,omitempty"` // Step numbers this step depends on
-	NextSteps       []int           `json:"next_steps,omitempty"`   // Step numbers that follow this step
+	StepNumber      int             `json:"step_number" jsonschema:"required,minimum=1,description=Number that identifies the step within the workflow"`
+	ResponsibleAgent AgentRole      `json:"responsible_agent" jsonschema:"description=Role whose agent works on the step; required for agent steps,example=senior_developer"`
+	Action          string          `json:"action" jsonschema:"required,description=What the step does"`
+	Input           string          `json:"input,omitempty" jsonschema:"description=Prompt template where {{steps.N.result}} and {{steps.N.files}} insert the work of earlier steps and {{context.key}} a context value"`
+	ExpectedOutput  string          `json:"expected_output" jsonschema:"description=What the step should produce"`
+	Dependencies    []int           `json:"dependencies,omitempty" jsonschema:"description=Step numbers this step depends on"`
+	NextSteps       []int           `json:"next_steps,omitempty" jsonschema:"description=Step numbers that follow this step"`
+	Type            WorkflowStepType `json:"type,omitempty" jsonschema:"description=Agent step unless set to approval,enum=agent,enum=approval,default=agent"`
+	Retry           *RetryPolicy    `json:"retry,omitempty" jsonschema:"description=How often a failing step is attempted"`
+	OnFailure       int             `json:"on_failure,omitempty" jsonschema:"description=Step that runs instead when this step fails"`
+	Conditions      []StepCondition `json:"conditions,omitempty" jsonschema:"description=Branches taken on the step's result"`
 }
 
 // DefaultCommunicationProtocols returns the default communication protocols for agent interactions
@@ -4,7 +4,9 @@ import (
 	"github.com/nom-nom-hub/floss/internal/config"
 )
 
-// AgentDefinition defines the configuration for a specific agent role
+// AgentDefinition defines the configuration for a specific agent role. A role
+// only gets the tools, MCP tools and LSPs it lists; leaving a list out allows
+// none.
 type AgentDefinition struct {
 	ID          string               `json:"id"`
 	Name        string               `json:"name"`
@@ -12,9 +14,12 @@ type AgentDefinition struct {
 	Role        AgentRole            `json:"role"`
 	Model       config.SelectedModelType    `json:"model"`
 	AllowedTools []string            `json:"allowed_tools,omitempty"`
+	AllowedMCP  map[string][]string  `json:"allowed_mcp,omitempty"`
+	AllowedLSP  []string             `json:"allowed_lsp,omitempty"`
 	ContextPaths []string            `json:"context_paths,omitempty"`
 	PromptTemplate string            `json:"prompt_template,omitempty"`
 	Capabilities []string            `json:"capabilities,omitempty"`
+	Budget      *Budget              `json:"budget,omitempty"`
 }
 
 // DefaultAgentDefinitions returns the default agent definitions for all roles
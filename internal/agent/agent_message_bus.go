@@ -0,0 +1,248 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/nom-nom-hub/floss/internal/csync"
+	"github.com/nom-nom-hub/floss/internal/db"
+	"github.com/nom-nom-hub/floss/internal/llm/agent"
+	"github.com/nom-nom-hub/floss/internal/message"
+	"github.com/nom-nom-hub/floss/internal/pubsub"
+	"github.com/nom-nom-hub/floss/internal/session"
+)
+
+// AgentMessage is a message sent between role agents. Participants holds the
+// roles the message was routed to.
+type AgentMessage struct {
+	AgentCommunication
+	ID        string    `json:"id"`
+	SessionID string    `json:"session_id"`
+	From      AgentRole `json:"from"`
+	ReplyTo   string    `json:"reply_to,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// MessageBus routes messages between role agents along the company
+// structure. Every message is recorded in the session it was sent from and
+// published to subscribers.
+type MessageBus struct {
+	*pubsub.Broker[AgentMessage]
+	structure      CompanyStructure
+	agentServices  *csync.Map[AgentRole, agent.Service]
+	sessionService session.Service
+	messageService message.Service
+	q              db.Querier
+}
+
+// NewMessageBus creates a message bus for the roles of a company structure
+func NewMessageBus(
+	structure CompanyStructure,
+	agentServices *csync.Map[AgentRole, agent.Service],
+	sessionService session.Service,
+	messageService message.Service,
+	q db.Querier,
+) *MessageBus {
+	return &MessageBus{
+		Broker:         pubsub.NewBroker[AgentMessage](),
+		structure:      structure,
+		agentServices:  agentServices,
+		sessionService: sessionService,
+		messageService: messageService,
+		q:              q,
+	}
+}
+
+// Send routes a message from a role and records it in the sender's session.
+// The returned message lists the roles it was routed to.
+func (b *MessageBus) Send(ctx context.Context, sessionID string, from AgentRole, communication AgentCommunication) (AgentMessage, error) {
+	recipients, err := b.structure.Route(from, communication)
+	if err != nil {
+		return AgentMessage{}, err
+	}
+	communication.Participants = recipients
+	return b.record(ctx, AgentMessage{
+		AgentCommunication: communication,
+		SessionID:          sessionID,
+		From:               from,
+	})
+}
+
+// Ask sends a direct message to another role and waits for its reply. The
+// recipient works on the message in a task session below the sender's
+// session, and its final answer is recorded as the reply.
+func (b *MessageBus) Ask(ctx context.Context, sessionID, toolCallID string, from, to AgentRole, subject, body string) (AgentMessage, error) {
+	chain := askChain(ctx)
+	if to == from || slices.Contains(chain, to) {
+		return AgentMessage{}, fmt.Errorf("%s is already waiting on this conversation, answer the request yourself instead", to)
+	}
+	communication := AgentCommunication{
+		Channel:      ChannelDirectMessage,
+		Participants: []AgentRole{to},
+		Subject:      subject,
+		Message:      body,
+	}
+	if _, err := b.structure.Route(from, communication); err != nil {
+		return AgentMessage{}, err
+	}
+	recipient, exists := b.agentServices.Get(to)
+	if !exists {
+		return AgentMessage{}, fmt.Errorf("no agent is running as %s", to)
+	}
+
+	sent, err := b.Send(ctx, sessionID, from, communication)
+	if err != nil {
+		return AgentMessage{}, err
+	}
+
+	title := subject
+	if title == "" {
+		title = body
+	}
+	taskSession, err := b.sessionService.CreateTaskSession(ctx, toolCallID, sessionID, fmt.Sprintf("Message from %s: %s", from, title))
+	if err != nil {
+		return AgentMessage{}, fmt.Errorf("failed to create session: %w", err)
+	}
+
+	var prompt strings.Builder
+	fmt.Fprintf(&prompt, "You received a message from the %s.\n\n", from)
+	if subject != "" {
+		fmt.Fprintf(&prompt, "Subject: %s\n\n", subject)
+	}
+	fmt.Fprintf(&prompt, "%s\n\nHandle the request within your role. Your final message is sent back to the %s as your reply.", body, from)
+
+	events, err := recipient.Run(withAskChain(ctx, append(chain, from)), taskSession.ID, prompt.String())
+	if err != nil {
+		return AgentMessage{}, fmt.Errorf("failed to run %s: %w", to, err)
+	}
+	if events == nil {
+		return AgentMessage{}, fmt.Errorf("%s is busy with another request", to)
+	}
+	var result agent.AgentEvent
+	for event := range events {
+		result = event
+	}
+	if result.Error != nil {
+		return AgentMessage{}, fmt.Errorf("%s failed to reply: %w", to, result.Error)
+	}
+	if result.Message.Role != message.Assistant {
+		return AgentMessage{}, errors.New("no reply")
+	}
+
+	if err := b.addCost(ctx, sessionID, taskSession.ID); err != nil {
+		return AgentMessage{}, err
+	}
+
+	return b.record(ctx, AgentMessage{
+		AgentCommunication: AgentCommunication{
+			Channel:      ChannelDirectMessage,
+			Participants: []AgentRole{from},
+			Subject:      subject,
+			Message:      result.Message.Content().String(),
+		},
+		SessionID: sessionID,
+		From:      to,
+		ReplyTo:   sent.ID,
+	})
+}
+
+// ListMessages returns the messages recorded in a session, oldest first
+func (b *MessageBus) ListMessages(ctx context.Context, sessionID string) ([]AgentMessage, error) {
+	dbMessages, err := b.q.ListAgentMessagesBySession(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	messages := make([]AgentMessage, len(dbMessages))
+	for i, dbMessage := range dbMessages {
+		messages[i], err = b.fromDBItem(dbMessage)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return messages, nil
+}
+
+func (b *MessageBus) record(ctx context.Context, msg AgentMessage) (AgentMessage, error) {
+	participants, err := json.Marshal(msg.Participants)
+	if err != nil {
+		return AgentMessage{}, err
+	}
+	dbMessage, err := b.q.CreateAgentMessage(ctx, db.CreateAgentMessageParams{
+		ID:           uuid.New().String(),
+		SessionID:    msg.SessionID,
+		Sender:       string(msg.From),
+		Channel:      string(msg.Channel),
+		Participants: string(participants),
+		Subject:      msg.Subject,
+		Body:         msg.Message,
+		Priority:     int64(msg.Priority),
+		ReplyTo:      msg.ReplyTo,
+	})
+	if err != nil {
+		return AgentMessage{}, fmt.Errorf("failed to record message: %w", err)
+	}
+	msg, err = b.fromDBItem(dbMessage)
+	if err != nil {
+		return AgentMessage{}, err
+	}
+	b.Publish(pubsub.CreatedEvent, msg)
+	return msg, nil
+}
+
+// addCost adds the cost and tokens of the recipient's task session to the
+// sender's session, as the agent tool does for its sub-agents
+func (b *MessageBus) addCost(ctx context.Context, sessionID, taskSessionID string) error {
+	taskSession, err := b.sessionService.Get(ctx, taskSessionID)
+	if err != nil {
+		return fmt.Errorf("error getting session: %w", err)
+	}
+	parentSession, err := b.sessionService.Get(ctx, sessionID)
+	if err != nil {
+		return fmt.Errorf("error getting parent session: %w", err)
+	}
+	parentSession.Cost += taskSession.Cost
+	parentSession.TotalTokens += taskSession.TotalTokens
+	if _, err := b.sessionService.Save(ctx, parentSession); err != nil {
+		return fmt.Errorf("error saving parent session: %w", err)
+	}
+	return nil
+}
+
+func (b *MessageBus) fromDBItem(item db.AgentMessage) (AgentMessage, error) {
+	var participants []AgentRole
+	if err := json.Unmarshal([]byte(item.Participants), &participants); err != nil {
+		return AgentMessage{}, fmt.Errorf("failed to unmarshal message participants: %w", err)
+	}
+	return AgentMessage{
+		AgentCommunication: AgentCommunication{
+			Channel:      CommunicationChannel(item.Channel),
+			Participants: participants,
+			Subject:      item.Subject,
+			Message:      item.Body,
+			Priority:     int(item.Priority),
+		},
+		ID:        item.ID,
+		SessionID: item.SessionID,
+		From:      AgentRole(item.Sender),
+		ReplyTo:   item.ReplyTo,
+		CreatedAt: time.Unix(item.CreatedAt, 0),
+	}, nil
+}
+
+type askChainKey struct{}
+
+// askChain returns the roles waiting for a reply further up the conversation
+func askChain(ctx context.Context) []AgentRole {
+	chain, _ := ctx.Value(askChainKey{}).([]AgentRole)
+	return chain
+}
+
+func withAskChain(ctx context.Context, chain []AgentRole) context.Context {
+	return context.WithValue(ctx, askChainKey{}, slices.Clip(chain))
+}
@@ -0,0 +1,115 @@
+package agent
+
+import (
+	"context"
+	"testing"
+
+	"github.com/nom-nom-hub/floss/internal/csync"
+	"github.com/nom-nom-hub/floss/internal/llm/agent"
+	"github.com/nom-nom-hub/floss/internal/llm/tools"
+	"github.com/nom-nom-hub/floss/internal/pubsub"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMessageBusAsk(t *testing.T) {
+	t.Parallel()
+	ctx := t.Context()
+	services := newTestServices(t)
+
+	developer := newFakeAgent(services.messages)
+	agentServices := csync.NewMap[AgentRole, agent.Service]()
+	agentServices.Set(AgentRoleSeniorDeveloper, developer)
+	bus := NewMessageBus(DefaultCompanyStructure(), agentServices, services.sessions, services.messages, services.q)
+	events := bus.Subscribe(ctx)
+
+	sess, err := services.sessions.Create(ctx, "Tech lead")
+	require.NoError(t, err)
+
+	reply, err := bus.Ask(ctx, sess.ID, "call-1", AgentRoleTechLead, AgentRoleSeniorDeveloper, "Login", "Implement the login form")
+	require.NoError(t, err)
+	require.Equal(t, AgentRoleSeniorDeveloper, reply.From)
+	require.Equal(t, []AgentRole{AgentRoleTechLead}, reply.Participants)
+	require.Equal(t, "done", reply.Message)
+
+	// The developer works in a task session below the tech lead's session.
+	runSessions := developer.runSessions()
+	require.Equal(t, []string{"call-1"}, runSessions)
+	taskSession, err := services.sessions.Get(ctx, "call-1")
+	require.NoError(t, err)
+	require.Equal(t, sess.ID, taskSession.ParentSessionID)
+	require.Contains(t, developer.runPrompts()[0], "You received a message from the tech_lead.")
+	require.Contains(t, developer.runPrompts()[0], "Implement the login form")
+
+	// Both sides of the conversation are recorded in the sender's session.
+	stored, err := bus.ListMessages(ctx, sess.ID)
+	require.NoError(t, err)
+	require.Len(t, stored, 2)
+	require.Equal(t, AgentRoleTechLead, stored[0].From)
+	require.Equal(t, ChannelDirectMessage, stored[0].Channel)
+	require.Equal(t, []AgentRole{AgentRoleSeniorDeveloper}, stored[0].Participants)
+	require.Equal(t, "Login", stored[0].Subject)
+	require.Equal(t, stored[0].ID, stored[1].ReplyTo)
+	require.Equal(t, reply.ID, stored[1].ID)
+
+	for _, msg := range stored {
+		event := <-events
+		require.Equal(t, pubsub.CreatedEvent, event.Type)
+		require.Equal(t, msg.ID, event.Payload.ID)
+	}
+}
+
+func TestMessageBusAskRejectsInvalidConversations(t *testing.T) {
+	t.Parallel()
+	ctx := t.Context()
+	services := newTestServices(t)
+
+	agentServices := csync.NewMap[AgentRole, agent.Service]()
+	agentServices.Set(AgentRoleTechLead, newFakeAgent(services.messages))
+	agentServices.Set(AgentRoleSeniorDeveloper, newFakeAgent(services.messages))
+	bus := NewMessageBus(DefaultCompanyStructure(), agentServices, services.sessions, services.messages, services.q)
+
+	sess, err := services.sessions.Create(ctx, "Developer")
+	require.NoError(t, err)
+
+	// Routing follows the company structure.
+	_, err = bus.Ask(ctx, sess.ID, "call-1", AgentRoleSeniorDeveloper, AgentRoleQAEngineer, "", "Test this")
+	require.ErrorContains(t, err, "can't message qa_engineer directly")
+
+	// A role waiting on a reply can't be asked again further down.
+	nested := withAskChain(ctx, []AgentRole{AgentRoleTechLead})
+	_, err = bus.Ask(nested, sess.ID, "call-2", AgentRoleSeniorDeveloper, AgentRoleTechLead, "", "What should I do?")
+	require.ErrorContains(t, err, "tech_lead is already waiting on this conversation")
+
+	stored, err := bus.ListMessages(ctx, sess.ID)
+	require.NoError(t, err)
+	require.Empty(t, stored)
+}
+
+func TestMessageAgentTool(t *testing.T) {
+	t.Parallel()
+	ctx := t.Context()
+	services := newTestServices(t)
+
+	agentServices := csync.NewMap[AgentRole, agent.Service]()
+	agentServices.Set(AgentRoleSeniorDeveloper, newFakeAgent(services.messages))
+	bus := NewMessageBus(DefaultCompanyStructure(), agentServices, services.sessions, services.messages, services.q)
+	tool := NewMessageAgentTool(bus, AgentRoleTechLead)
+
+	info := tool.Info()
+	require.Equal(t, MessageAgentToolName, info.Name)
+	require.Contains(t, info.Parameters["role"].(map[string]any)["enum"], string(AgentRoleSeniorDeveloper))
+
+	sess, err := services.sessions.Create(ctx, "Tech lead")
+	require.NoError(t, err)
+	ctx = context.WithValue(ctx, tools.SessionIDContextKey, sess.ID)
+
+	response, err := tool.Run(ctx, tools.ToolCall{ID: "call-1", Input: `{"role":"senior_developer","message":"Implement it"}`})
+	require.NoError(t, err)
+	require.False(t, response.IsError)
+	require.Equal(t, "Reply from senior_developer:\ndone", response.Content)
+
+	response, err = tool.Run(ctx, tools.ToolCall{ID: "call-2", Input: `{"role":"database_admin","message":"Add an index"}`})
+	require.NoError(t, err)
+	require.True(t, response.IsError)
+	require.Contains(t, response.Content, "no agent is running as database_admin")
+}
@@ -0,0 +1,96 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/nom-nom-hub/floss/internal/llm/tools"
+)
+
+// MessageAgentToolName is the name of the tool role agents use to message
+// each other
+const MessageAgentToolName = "message_agent"
+
+type MessageAgentParams struct {
+	Role    string `json:"role"`
+	Subject string `json:"subject,omitempty"`
+	Message string `json:"message"`
+}
+
+type messageAgentTool struct {
+	bus  *MessageBus
+	role AgentRole
+}
+
+// NewMessageAgentTool creates the tool a role agent uses to send a message to
+// another role and wait for its reply
+func NewMessageAgentTool(bus *MessageBus, role AgentRole) tools.BaseTool {
+	return &messageAgentTool{
+		bus:  bus,
+		role: role,
+	}
+}
+
+func (m *messageAgentTool) Name() string {
+	return MessageAgentToolName
+}
+
+func (m *messageAgentTool) Info() tools.ToolInfo {
+	contacts := m.bus.structure.Contacts(m.role)
+	roles := make([]string, len(contacts))
+	for i, role := range contacts {
+		roles[i] = string(role)
+	}
+	return tools.ToolInfo{
+		Name: MessageAgentToolName,
+		Description: fmt.Sprintf(`Send a message to another role in the company and wait for its reply.
+
+Use this to delegate work or ask a question that another role is better placed to answer. The other role works on your message with its own tools and its final report is returned to you. You can message: %s.
+
+Usage notes:
+1. Describe the task completely; the other role only sees your message
+2. Say exactly what you expect back in the reply
+3. The other role may change files in the project while handling your request`, joinRoles(contacts)),
+		Parameters: map[string]any{
+			"role": map[string]any{
+				"type":        "string",
+				"description": "The role to message",
+				"enum":        roles,
+			},
+			"subject": map[string]any{
+				"type":        "string",
+				"description": "A short subject for the message",
+			},
+			"message": map[string]any{
+				"type":        "string",
+				"description": "The message to send",
+			},
+		},
+		Required: []string{"role", "message"},
+	}
+}
+
+func (m *messageAgentTool) Run(ctx context.Context, call tools.ToolCall) (tools.ToolResponse, error) {
+	var params MessageAgentParams
+	if err := json.Unmarshal([]byte(call.Input), &params); err != nil {
+		return tools.NewTextErrorResponse(fmt.Sprintf("error parsing parameters: %s", err)), nil
+	}
+	if params.Role == "" {
+		return tools.NewTextErrorResponse("role is required"), nil
+	}
+	if params.Message == "" {
+		return tools.NewTextErrorResponse("message is required"), nil
+	}
+
+	sessionID, _ := tools.GetContextValues(ctx)
+	if sessionID == "" {
+		return tools.ToolResponse{}, fmt.Errorf("session_id is required")
+	}
+
+	reply, err := m.bus.Ask(ctx, sessionID, call.ID, m.role, AgentRole(params.Role), params.Subject, params.Message)
+	if err != nil {
+		return tools.NewTextErrorResponse(err.Error()), nil
+	}
+	return tools.NewTextResponse(fmt.Sprintf("Reply from %s:\n%s", reply.From, reply.Message)), nil
+}
@@ -4,12 +4,18 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"slices"
 	"sync"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/nom-nom-hub/floss/internal/csync"
+	"github.com/nom-nom-hub/floss/internal/db"
+	"github.com/nom-nom-hub/floss/internal/history"
 	"github.com/nom-nom-hub/floss/internal/llm/agent"
 	"github.com/nom-nom-hub/floss/internal/message"
+	"github.com/nom-nom-hub/floss/internal/permission"
+	"github.com/nom-nom-hub/floss/internal/pubsub"
 	"github.com/nom-nom-hub/floss/internal/session"
 )
 
@@ -22,66 +28,154 @@ const (
 	WorkflowStatusCompleted WorkflowStatus = "completed"
 	WorkflowStatusFailed    WorkflowStatus = "failed"
 	WorkflowStatusCancelled WorkflowStatus = "cancelled"
+	// WorkflowStatusSkipped marks steps a condition skipped and fallback
+	// steps that were not needed
+	WorkflowStatusSkipped WorkflowStatus = "skipped"
+	// WorkflowStatusWaitingApproval marks approval steps waiting for a human
+	WorkflowStatusWaitingApproval WorkflowStatus = "waiting_approval"
+	// WorkflowStatusPaused marks instances stopped by a budget until they
+	// are resumed
+	WorkflowStatusPaused WorkflowStatus = "paused"
 )
 
+// IsFinished reports whether a workflow or step with this status has stopped
+// for good
+func (s WorkflowStatus) IsFinished() bool {
+	return s == WorkflowStatusCompleted || s == WorkflowStatusFailed || s == WorkflowStatusCancelled || s == WorkflowStatusSkipped
+}
+
 // WorkflowInstance represents an instance of a workflow execution
 type WorkflowInstance struct {
-	ID            string                 `json:"id"`
-	WorkflowID    string                 `json:"workflow_id"`
-	Name          string                 `json:"name"`
-	Description   string                 `json:"description"`
-	Status        WorkflowStatus         `json:"status"`
-	CurrentStep   int                    `json:"current_step"`
-	Steps         []WorkflowStepInstance `json:"steps"`
-	CreatedAt     time.Time              `json:"created_at"`
-	StartedAt     *time.Time             `json:"started_at,omitempty"`
-	CompletedAt   *time.Time             `json:"completed_at,omitempty"`
-	SessionID     string                 `json:"session_id"`
-	Context       map[string]interface{} `json:"context,omitempty"`
+	ID          string                 `json:"id"`
+	WorkflowID  string                 `json:"workflow_id"`
+	Name        string                 `json:"name"`
+	Description string                 `json:"description"`
+	Status      WorkflowStatus         `json:"status"`
+	CurrentStep int                    `json:"current_step"`
+	Steps       []WorkflowStepInstance `json:"steps"`
+	CreatedAt   time.Time              `json:"created_at"`
+	StartedAt   *time.Time             `json:"started_at,omitempty"`
+	CompletedAt *time.Time             `json:"completed_at,omitempty"`
+	SessionID   string                 `json:"session_id"`
+	Context     map[string]interface{} `json:"context,omitempty"`
+	// StatusReason says why the instance was paused or cancelled
+	StatusReason string `json:"status_reason,omitempty"`
 }
 
 // WorkflowStepInstance represents an instance of a workflow step execution
 type WorkflowStepInstance struct {
-	StepNumber      int          `json:"step_number"`
-	ResponsibleAgent AgentRole   `json:"responsible_agent"`
-	Action          string       `json:"action"`
-	Status          WorkflowStatus `json:"status"`
-	StartedAt       *time.Time   `json:"started_at,omitempty"`
-	CompletedAt     *time.Time   `json:"completed_at,omitempty"`
-	Result          string       `json:"result,omitempty"`
-	Error           string       `json:"error,omitempty"`
-	SessionID       string       `json:"session_id,omitempty"`
+	StepNumber       int            `json:"step_number"`
+	ResponsibleAgent AgentRole      `json:"responsible_agent"`
+	Action           string         `json:"action"`
+	Status           WorkflowStatus `json:"status"`
+	StartedAt        *time.Time     `json:"started_at,omitempty"`
+	CompletedAt      *time.Time     `json:"completed_at,omitempty"`
+	Result           string         `json:"result,omitempty"`
+	Error            string         `json:"error,omitempty"`
+	SessionID        string         `json:"session_id,omitempty"`
+	Attempts         int            `json:"attempts,omitempty"`
+	Loops            int            `json:"loops,omitempty"`
+	Feedback         string         `json:"feedback,omitempty"`
 }
 
-// AgentOrchestrator manages the execution of agent workflows
+// AgentOrchestrator manages the execution of agent workflows. Every change to
+// a workflow instance is published to subscribers.
 type AgentOrchestrator struct {
-	agentServices   *csync.Map[AgentRole, agent.Service]
-	sessionService  session.Service
-	messageService  message.Service
-	workflows       *csync.Map[string, AgentWorkflow]
+	*pubsub.Broker[WorkflowInstance]
+	agentServices     *csync.Map[AgentRole, agent.Service]
+	sessionService    session.Service
+	messageService    message.Service
+	historyService    history.Service
+	workflows         *csync.Map[string, AgentWorkflow]
 	workflowInstances *csync.Map[string, WorkflowInstance]
-	mutex           sync.RWMutex
+	q                 db.Querier
+	maxParallelSteps  int
+	onStepSession     func(workflowInstanceID, sessionID string)
+	messageBus        *MessageBus
+	permissions       permission.Service
+	approvalDialog    bool
+	runs              *csync.Map[string, context.CancelFunc]
+	pollInterval      time.Duration
+	leaseOwner        string
+	leaseDuration     time.Duration
+	roleBudgets       map[AgentRole]Budget
+	mutex             sync.RWMutex
 }
 
+// DefaultMaxParallelSteps is the number of workflow steps an instance runs
+// at the same time unless configured otherwise
+const DefaultMaxParallelSteps = 4
+
+// defaultPollInterval is how often a running instance checks for
+// cancellations and approvals recorded by another floss process
+const defaultPollInterval = 2 * time.Second
+
+// defaultLeaseDuration is how long the claim of a floss process on a workflow
+// instance holds without being renewed, so the instances of a process that
+// exited without releasing them are resumed by another one after that
+const defaultLeaseDuration = time.Minute
+
 // NewAgentOrchestrator creates a new agent orchestrator
 func NewAgentOrchestrator(
 	agentServices *csync.Map[AgentRole, agent.Service],
 	sessionService session.Service,
 	messageService message.Service,
+	historyService history.Service,
+	q db.Querier,
 ) *AgentOrchestrator {
 	return &AgentOrchestrator{
+		Broker:            pubsub.NewBroker[WorkflowInstance](),
 		agentServices:     agentServices,
 		sessionService:    sessionService,
 		messageService:    messageService,
+		historyService:    historyService,
 		workflows:         csync.NewMap[string, AgentWorkflow](),
 		workflowInstances: csync.NewMap[string, WorkflowInstance](),
+		q:                 q,
+		maxParallelSteps:  DefaultMaxParallelSteps,
+		approvalDialog:    true,
+		runs:              csync.NewMap[string, context.CancelFunc](),
+		pollInterval:      defaultPollInterval,
+		leaseOwner:        uuid.New().String(),
+		leaseDuration:     defaultLeaseDuration,
 	}
 }
 
-// RegisterWorkflow registers a workflow with the orchestrator
-func (ao *AgentOrchestrator) RegisterWorkflow(workflow AgentWorkflow) {
+// SetMaxParallelSteps limits how many steps of a workflow instance run at the
+// same time. Values below one run steps one at a time.
+func (ao *AgentOrchestrator) SetMaxParallelSteps(n int) {
+	ao.maxParallelSteps = max(n, 1)
+}
+
+// MessageBus returns the bus the role agents message each other on. It is nil
+// when the orchestrator was created without one.
+func (ao *AgentOrchestrator) MessageBus() *MessageBus {
+	return ao.messageBus
+}
+
+// CompanyStructure returns the company the role agents work in. It is empty
+// when the orchestrator was created without a message bus.
+func (ao *AgentOrchestrator) CompanyStructure() CompanyStructure {
+	if ao.messageBus == nil {
+		return CompanyStructure{}
+	}
+	return ao.messageBus.structure
+}
+
+// OnStepSession registers a function that is called with the session of a
+// step before its agent starts working in it.
+func (ao *AgentOrchestrator) OnStepSession(fn func(workflowInstanceID, sessionID string)) {
+	ao.onStepSession = fn
+}
+
+// RegisterWorkflow validates a workflow and registers it with the orchestrator
+func (ao *AgentOrchestrator) RegisterWorkflow(workflow AgentWorkflow) error {
+	if err := ValidateWorkflow(workflow); err != nil {
+		return err
+	}
 	ao.workflows.Set(workflow.ID, workflow)
 	slog.Info("Registered workflow", "workflow_id", workflow.ID, "name", workflow.Name)
+	return nil
 }
 
 // StartWorkflow starts a new workflow instance
@@ -100,7 +194,7 @@ func (ao *AgentOrchestrator) StartWorkflow(ctx context.Context, workflowID strin
 
 	// Create workflow instance
 	workflowInstance := WorkflowInstance{
-		ID:          fmt.Sprintf("wf_%s_%d", workflowID, time.Now().Unix()),
+		ID:          uuid.New().String(),
 		WorkflowID:  workflowID,
 		Name:        workflow.Name,
 		Description: workflow.Description,
@@ -114,14 +208,16 @@ func (ao *AgentOrchestrator) StartWorkflow(ctx context.Context, workflowID strin
 	// Initialize step instances
 	for i, step := range workflow.Steps {
 		workflowInstance.Steps[i] = WorkflowStepInstance{
-			StepNumber:      step.StepNumber,
+			StepNumber:       step.StepNumber,
 			ResponsibleAgent: step.ResponsibleAgent,
-			Action:          step.Action,
-			Status:          WorkflowStatusPending,
+			Action:           step.Action,
+			Status:           WorkflowStatusPending,
 		}
 	}
 
-	ao.workflowInstances.Set(workflowInstance.ID, workflowInstance)
+	if err := ao.createInstance(ctx, workflowInstance); err != nil {
+		return nil, fmt.Errorf("failed to persist workflow instance: %w", err)
+	}
 	slog.Info("Started workflow instance", "instance_id", workflowInstance.ID, "workflow_id", workflowID)
 
 	// Start the workflow execution asynchronously
@@ -130,7 +226,11 @@ func (ao *AgentOrchestrator) StartWorkflow(ctx context.Context, workflowID strin
 	return &workflowInstance, nil
 }
 
-// executeWorkflow executes a workflow instance
+// executeWorkflow runs a workflow instance as a DAG. Every pending step whose
+// dependencies are done is started at once, up to the orchestrator's
+// parallel step limit, and the instance finishes when no step can run.
+// Conditions may send the workflow back to an earlier step, and a failing
+// step with a fallback lets the workflow go on with the fallback instead.
 func (ao *AgentOrchestrator) executeWorkflow(ctx context.Context, workflowInstanceID string) {
 	workflowInstance, exists := ao.workflowInstances.Get(workflowInstanceID)
 	if !exists {
@@ -144,142 +244,214 @@ func (ao *AgentOrchestrator) executeWorkflow(ctx context.Context, workflowInstan
 		return
 	}
 
-	// Update workflow status to running
-	workflowInstance.Status = WorkflowStatusRunning
-	now := time.Now()
-	workflowInstance.StartedAt = &now
-	ao.workflowInstances.Set(workflowInstanceID, workflowInstance)
+	// Only one floss process drives an instance. Losing the claim, when this
+	// one stalled for longer than the lease, interrupts the run like an exit.
+	claimed, err := ao.claimInstance(ctx, workflowInstanceID)
+	if err != nil || !claimed {
+		slog.Warn("Workflow instance is run by another floss process", "instance_id", workflowInstanceID, "error", err)
+		ao.workflowInstances.Del(workflowInstanceID)
+		return
+	}
+	defer ao.releaseInstance(workflowInstanceID)
+	ctx, loseLease := context.WithCancel(ctx)
+	defer loseLease()
+	go ao.holdLease(ctx, workflowInstanceID, loseLease)
 
-	slog.Info("Executing workflow", "instance_id", workflowInstanceID, "workflow_id", workflowInstance.WorkflowID)
+	graph, err := buildWorkflowGraph(workflow)
+	if err != nil {
+		slog.Error("Invalid workflow", "workflow_id", workflow.ID, "error", err)
+		ao.finishInstance(workflowInstanceID, WorkflowStatusFailed)
+		return
+	}
+	steps := make(map[int]WorkflowStep, len(workflow.Steps))
+	for _, step := range workflow.Steps {
+		steps[step.StepNumber] = step
+	}
 
-	// Execute steps in order
-	for i := 0; i < len(workflow.Steps); i++ {
-		step := workflow.Steps[i]
-		stepInstance := &workflowInstance.Steps[i]
-
-		// Check if step dependencies are satisfied
-		if !ao.areDependenciesSatisfied(&workflowInstance, step) {
-			// Find next step that can be executed
-			nextStepIndex := ao.findNextExecutableStep(&workflowInstance, workflow)
-			if nextStepIndex == -1 {
-				// No more steps can be executed
-				break
+	// Update workflow status to running
+	ao.updateInstance(workflowInstanceID, func(workflowInstance *WorkflowInstance) {
+		workflowInstance.Status = WorkflowStatusRunning
+		if workflowInstance.StartedAt == nil {
+			now := time.Now()
+			workflowInstance.StartedAt = &now
+		}
+		// Steps interrupted by a previous exit run again in their session
+		for i := range workflowInstance.Steps {
+			if status := workflowInstance.Steps[i].Status; status == WorkflowStatusRunning || status == WorkflowStatusWaitingApproval {
+				workflowInstance.Steps[i].Status = WorkflowStatusPending
 			}
-			// Swap current step with next executable step
-			workflowInstance.Steps[i], workflowInstance.Steps[nextStepIndex] = 
-				workflowInstance.Steps[nextStepIndex], workflowInstance.Steps[i]
-			step = workflow.Steps[i]
-			stepInstance = &workflowInstance.Steps[i]
 		}
+	})
 
-		// Execute the step
-		err := ao.executeStep(ctx, &workflowInstance, stepInstance, step)
-		if err != nil {
-			slog.Error("Failed to execute step", "error", err, "step", step.StepNumber)
-			workflowInstance.Status = WorkflowStatusFailed
-			now := time.Now()
-			workflowInstance.CompletedAt = &now
-			ao.workflowInstances.Set(workflowInstanceID, workflowInstance)
-			return
-		}
+	slog.Info("Executing workflow", "instance_id", workflowInstanceID, "workflow_id", workflowInstance.WorkflowID)
 
-		// Update workflow instance
-		ao.workflowInstances.Set(workflowInstanceID, workflowInstance)
+	// Cancelling the instance cancels runCtx, which stops its steps and any
+	// retry backoff or approval they are waiting on
+	runCtx, stopRun := context.WithCancel(ctx)
+	defer stopRun()
+	ao.runs.Set(workflowInstanceID, stopRun)
+	defer ao.runs.Del(workflowInstanceID)
+	go ao.watchForCancellation(runCtx, workflowInstanceID)
+	go ao.watchBudget(runCtx, workflowInstanceID)
+
+	type stepOutcome struct {
+		stepNumber int
+		err        error
 	}
-
-	// Mark workflow as completed
-	workflowInstance.Status = WorkflowStatusCompleted
-	now = time.Now()
-	workflowInstance.CompletedAt = &now
-	ao.workflowInstances.Set(workflowInstanceID, workflowInstance)
-
-	slog.Info("Workflow completed", "instance_id", workflowInstanceID)
-}
-
-// areDependenciesSatisfied checks if all dependencies for a step are satisfied
-func (ao *AgentOrchestrator) areDependenciesSatisfied(workflowInstance *WorkflowInstance, step WorkflowStep) bool {
-	for _, dep := range step.Dependencies {
-		// Find the dependent step
-		depStepIndex := -1
-		for i, s := range workflowInstance.Steps {
-			if s.StepNumber == dep {
-				depStepIndex = i
-				break
+	outcomes := make(chan stepOutcome)
+	running := 0
+	// again holds running steps a condition sent the workflow back past
+	again := make(map[int]bool)
+	var failure error
+	for {
+		current, _ := ao.workflowInstances.Get(workflowInstanceID)
+		stopped := failure != nil || current.Status == WorkflowStatusCancelled || current.Status == WorkflowStatusPaused || ctx.Err() != nil
+		if !stopped {
+			for _, stepNumber := range graph.readySteps(current) {
+				if running >= ao.maxParallelSteps {
+					break
+				}
+				ao.updateInstance(workflowInstanceID, func(workflowInstance *WorkflowInstance) {
+					workflowInstance.CurrentStep = stepNumber
+					stepInstance := workflowInstance.step(stepNumber)
+					stepInstance.Status = WorkflowStatusRunning
+					now := time.Now()
+					stepInstance.StartedAt = &now
+					stepInstance.CompletedAt = nil
+					stepInstance.Error = ""
+					stepInstance.Attempts++
+				})
+				running++
+				step := steps[stepNumber]
+				go func() {
+					outcomes <- stepOutcome{stepNumber: step.StepNumber, err: ao.runStep(runCtx, workflowInstanceID, step, graph.dependencies[step.StepNumber])}
+				}()
 			}
 		}
-		if depStepIndex == -1 {
-			// Dependency not found
-			return false
+		if running == 0 {
+			break
 		}
-		// Check if dependency is completed
-		if workflowInstance.Steps[depStepIndex].Status != WorkflowStatusCompleted {
-			return false
+
+		outcome := <-outcomes
+		running--
+		// The step's usage is complete now, so no more steps start if it
+		// went over budget
+		ao.enforceBudget(ctx, workflowInstanceID)
+		if again[outcome.stepNumber] && !ao.isCancelled(workflowInstanceID) && ctx.Err() == nil {
+			delete(again, outcome.stepNumber)
+			ao.updateStep(workflowInstanceID, outcome.stepNumber, func(stepInstance *WorkflowStepInstance) {
+				stepInstance.reset()
+			})
+			continue
+		}
+		step := steps[outcome.stepNumber]
+		if outcome.err == nil {
+			var stillRunning []int
+			stillRunning, outcome.err = ao.applyConditions(workflowInstanceID, step, graph)
+			for _, stepNumber := range stillRunning {
+				again[stepNumber] = true
+			}
+			if outcome.err == nil {
+				continue
+			}
+		}
+		switch {
+		case ao.isCancelled(workflowInstanceID):
+			ao.updateStep(workflowInstanceID, outcome.stepNumber, func(stepInstance *WorkflowStepInstance) {
+				stepInstance.Status = WorkflowStatusCancelled
+			})
+		case ctx.Err() != nil, ao.isPaused(workflowInstanceID):
+			// Floss is shutting down or the instance went over budget:
+			// leave the step pending so it is picked up again, in the same
+			// session, on the next start or when the instance is resumed.
+			ao.updateStep(workflowInstanceID, outcome.stepNumber, func(stepInstance *WorkflowStepInstance) {
+				stepInstance.Status = WorkflowStatusPending
+				stepInstance.Error = ""
+				stepInstance.StartedAt = nil
+				stepInstance.CompletedAt = nil
+			})
+		default:
+			slog.Error("Failed to execute step", "error", outcome.err, "step", outcome.stepNumber)
+			ao.failStep(workflowInstanceID, step, outcome.err)
+			if step.OnFailure == 0 && failure == nil {
+				failure = outcome.err
+			}
 		}
 	}
-	return true
-}
 
-// findNextExecutableStep finds the next step that can be executed
-func (ao *AgentOrchestrator) findNextExecutableStep(workflowInstance *WorkflowInstance, workflow AgentWorkflow) int {
-	for i := workflowInstance.CurrentStep + 1; i < len(workflow.Steps); i++ {
-		step := workflow.Steps[i]
-		if ao.areDependenciesSatisfied(workflowInstance, step) {
-			return i
-		}
+	switch {
+	case ao.isCancelled(workflowInstanceID):
+		slog.Info("Workflow cancelled", "instance_id", workflowInstanceID)
+	case ao.isPaused(workflowInstanceID):
+		slog.Info("Workflow paused", "instance_id", workflowInstanceID)
+	case ctx.Err() != nil:
+		slog.Info("Workflow interrupted", "instance_id", workflowInstanceID)
+	case failure != nil:
+		ao.finishInstance(workflowInstanceID, WorkflowStatusFailed)
+	default:
+		// Fallback steps that were never needed are left over
+		ao.updateInstance(workflowInstanceID, func(workflowInstance *WorkflowInstance) {
+			for i := range workflowInstance.Steps {
+				if workflowInstance.Steps[i].Status == WorkflowStatusPending {
+					workflowInstance.Steps[i].Status = WorkflowStatusSkipped
+				}
+			}
+		})
+		ao.finishInstance(workflowInstanceID, WorkflowStatusCompleted)
+		slog.Info("Workflow completed", "instance_id", workflowInstanceID)
 	}
-	return -1
 }
 
-// executeStep executes a single workflow step
-func (ao *AgentOrchestrator) executeStep(ctx context.Context, workflowInstance *WorkflowInstance, stepInstance *WorkflowStepInstance, step WorkflowStep) error {
+// executeStep executes a single attempt of a workflow step and records its
+// result on the workflow instance. Failures are returned for the scheduler to
+// record. dependencies are the steps it directly waits for.
+func (ao *AgentOrchestrator) executeStep(ctx context.Context, workflowInstanceID string, step WorkflowStep, dependencies []int) error {
+	if step.Type == StepTypeApproval {
+		return ao.awaitApproval(ctx, workflowInstanceID, step)
+	}
 	slog.Info("Executing step", "step", step.StepNumber, "agent", step.ResponsibleAgent)
 
-	// Update step status to running
-	stepInstance.Status = WorkflowStatusRunning
-	now := time.Now()
-	stepInstance.StartedAt = &now
-
 	// Get the agent service for this step
 	agentService, exists := ao.agentServices.Get(step.ResponsibleAgent)
 	if !exists {
-		stepInstance.Status = WorkflowStatusFailed
-		stepInstance.Error = fmt.Sprintf("Agent service for role %s not found", step.ResponsibleAgent)
-		now := time.Now()
-		stepInstance.CompletedAt = &now
 		return fmt.Errorf("agent service for role %s not found", step.ResponsibleAgent)
 	}
 
+	workflowInstance, _ := ao.workflowInstances.Get(workflowInstanceID)
+
 	// Create a session for this step if one doesn't exist
-	var sessionID string
-	if stepInstance.SessionID == "" {
+	sessionID := workflowInstance.step(step.StepNumber).SessionID
+	if sessionID == "" {
 		sessionTitle := fmt.Sprintf("Workflow Step: %s - %s", workflowInstance.Name, step.Action)
 		sess, err := ao.sessionService.Create(ctx, sessionTitle)
 		if err != nil {
-			stepInstance.Status = WorkflowStatusFailed
-			stepInstance.Error = fmt.Sprintf("Failed to create session: %v", err)
-			now := time.Now()
-			stepInstance.CompletedAt = &now
 			return fmt.Errorf("failed to create session: %w", err)
 		}
 		sessionID = sess.ID
-		stepInstance.SessionID = sessionID
-	} else {
-		sessionID = stepInstance.SessionID
+		// Record the step session right away so a restart reuses it
+		ao.updateStep(workflowInstanceID, step.StepNumber, func(stepInstance *WorkflowStepInstance) {
+			stepInstance.SessionID = sessionID
+		})
+	}
+
+	if ao.onStepSession != nil {
+		ao.onStepSession(workflowInstanceID, sessionID)
 	}
 
 	// Prepare the prompt for the agent
-	prompt := fmt.Sprintf("Workflow Step: %s\n\nAction: %s\n\nContext: %+v\n\nPlease complete this step and provide a detailed report of your work.", 
-		workflowInstance.Name, step.Action, workflowInstance.Context)
+	prompt, err := ao.buildStepPrompt(ctx, workflowInstance, step, dependencies)
+	if err != nil {
+		return fmt.Errorf("failed to prepare step input: %w", err)
+	}
 
 	// Run the agent
 	events, err := agentService.Run(ctx, sessionID, prompt)
 	if err != nil {
-		stepInstance.Status = WorkflowStatusFailed
-		stepInstance.Error = fmt.Sprintf("Failed to run agent: %v", err)
-		now := time.Now()
-		stepInstance.CompletedAt = &now
 		return fmt.Errorf("failed to run agent: %w", err)
 	}
+	if events == nil {
+		return fmt.Errorf("session %s is busy with another request", sessionID)
+	}
 
 	// Wait for the agent to complete
 	var finalEvent agent.AgentEvent
@@ -289,20 +461,12 @@ func (ao *AgentOrchestrator) executeStep(ctx context.Context, workflowInstance *
 
 	// Check if the agent completed successfully
 	if finalEvent.Error != nil {
-		stepInstance.Status = WorkflowStatusFailed
-		stepInstance.Error = finalEvent.Error.Error()
-		now := time.Now()
-		stepInstance.CompletedAt = &now
 		return finalEvent.Error
 	}
 
 	// Get the result from the messages
 	messages, err := ao.messageService.List(ctx, sessionID)
 	if err != nil {
-		stepInstance.Status = WorkflowStatusFailed
-		stepInstance.Error = fmt.Sprintf("Failed to get messages: %v", err)
-		now := time.Now()
-		stepInstance.CompletedAt = &now
 		return fmt.Errorf("failed to get messages: %w", err)
 	}
 
@@ -316,41 +480,136 @@ func (ao *AgentOrchestrator) executeStep(ctx context.Context, workflowInstance *
 	}
 
 	// Update step instance
-	stepInstance.Status = WorkflowStatusCompleted
-	stepInstance.Result = result
-	now = time.Now()
-	stepInstance.CompletedAt = &now
+	ao.updateStep(workflowInstanceID, step.StepNumber, func(stepInstance *WorkflowStepInstance) {
+		stepInstance.Status = WorkflowStatusCompleted
+		stepInstance.Result = result
+		now := time.Now()
+		stepInstance.CompletedAt = &now
+	})
 
 	slog.Info("Step completed", "step", step.StepNumber, "agent", step.ResponsibleAgent)
 	return nil
 }
 
+// updateInstance applies update to the current state of a workflow instance
+// and persists the result. Steps run concurrently, so every change to an
+// instance goes through here.
+func (ao *AgentOrchestrator) updateInstance(workflowInstanceID string, update func(*WorkflowInstance)) WorkflowInstance {
+	ao.mutex.Lock()
+	defer ao.mutex.Unlock()
+
+	workflowInstance, _ := ao.workflowInstances.Get(workflowInstanceID)
+	workflowInstance.Steps = slices.Clone(workflowInstance.Steps)
+	update(&workflowInstance)
+	ao.saveInstance(workflowInstance)
+	return workflowInstance
+}
+
+// updateStep applies update to a single step of a workflow instance
+func (ao *AgentOrchestrator) updateStep(workflowInstanceID string, stepNumber int, update func(*WorkflowStepInstance)) {
+	ao.updateInstance(workflowInstanceID, func(workflowInstance *WorkflowInstance) {
+		if stepInstance := workflowInstance.step(stepNumber); stepInstance != nil {
+			update(stepInstance)
+		}
+	})
+}
+
+// finishInstance marks a workflow instance as finished with the given status
+func (ao *AgentOrchestrator) finishInstance(workflowInstanceID string, status WorkflowStatus) {
+	ao.updateInstance(workflowInstanceID, func(workflowInstance *WorkflowInstance) {
+		workflowInstance.Status = status
+		now := time.Now()
+		workflowInstance.CompletedAt = &now
+	})
+}
+
+// step returns the instance of the step with the given number
+func (wi *WorkflowInstance) step(stepNumber int) *WorkflowStepInstance {
+	for i := range wi.Steps {
+		if wi.Steps[i].StepNumber == stepNumber {
+			return &wi.Steps[i]
+		}
+	}
+	return nil
+}
+
 // GetWorkflowInstance retrieves a workflow instance by ID
 func (ao *AgentOrchestrator) GetWorkflowInstance(instanceID string) (*WorkflowInstance, bool) {
-	workflowInstance, exists := ao.workflowInstances.Get(instanceID)
-	if !exists {
+	if workflowInstance, exists := ao.workflowInstances.Get(instanceID); exists {
+		return &workflowInstance, true
+	}
+	workflowInstance, err := ao.loadInstance(context.Background(), instanceID)
+	if err != nil {
 		return nil, false
 	}
 	return &workflowInstance, true
 }
 
-// ListWorkflowInstances lists all workflow instances
+// ListWorkflowInstances lists all workflow instances, including finished
+// ones from previous runs
 func (ao *AgentOrchestrator) ListWorkflowInstances() []WorkflowInstance {
-	var instances []WorkflowInstance
-	for _, instance := range ao.workflowInstances.Seq2() {
+	instances := make([]WorkflowInstance, 0, ao.workflowInstances.Len())
+	seen := make(map[string]bool)
+	for id, instance := range ao.workflowInstances.Seq2() {
 		instances = append(instances, instance)
+		seen[id] = true
+	}
+	stored, err := ao.loadInstances(context.Background())
+	if err != nil {
+		slog.Error("Failed to load workflow instances", "error", err)
+		return instances
+	}
+	for _, instance := range stored {
+		if !seen[instance.ID] {
+			instances = append(instances, instance)
+		}
 	}
 	return instances
 }
 
 // CancelWorkflow cancels a running workflow instance
 func (ao *AgentOrchestrator) CancelWorkflow(instanceID string) error {
-	workflowInstance, exists := ao.workflowInstances.Get(instanceID)
+	if err := ao.stopWorkflow(instanceID, WorkflowStatusCancelled, ""); err != nil {
+		return err
+	}
+	slog.Info("Cancelled workflow", "instance_id", instanceID)
+	return nil
+}
+
+// stopWorkflow cancels or pauses a workflow instance, recording why, and
+// interrupts the steps that are running
+func (ao *AgentOrchestrator) stopWorkflow(instanceID string, status WorkflowStatus, reason string) error {
+	current, exists := ao.GetWorkflowInstance(instanceID)
 	if !exists {
 		return fmt.Errorf("workflow instance %s not found", instanceID)
 	}
+	workflowInstance := *current
+	if workflowInstance.Status.IsFinished() {
+		return fmt.Errorf("workflow instance %s is already %s", instanceID, workflowInstance.Status)
+	}
+
+	// Mark the instance first so the executor treats the interrupted steps
+	// as cancelled or paused rather than failed
+	if _, running := ao.workflowInstances.Get(instanceID); running {
+		ao.updateInstance(instanceID, func(workflowInstance *WorkflowInstance) {
+			workflowInstance.Status = status
+			workflowInstance.StatusReason = reason
+			now := time.Now()
+			workflowInstance.CompletedAt = &now
+		})
+	} else {
+		workflowInstance.Status = status
+		workflowInstance.StatusReason = reason
+		now := time.Now()
+		workflowInstance.CompletedAt = &now
+		ao.saveInstance(workflowInstance)
+	}
 
-	// Cancel all agent services involved in the workflow
+	// Stop retries and approvals, and cancel all agent services involved
+	// in the workflow
+	if stopRun, running := ao.runs.Get(instanceID); running {
+		stopRun()
+	}
 	for _, step := range workflowInstance.Steps {
 		if step.Status == WorkflowStatusRunning {
 			agentService, exists := ao.agentServices.Get(step.ResponsibleAgent)
@@ -359,15 +618,35 @@ func (ao *AgentOrchestrator) CancelWorkflow(instanceID string) error {
 			}
 		}
 	}
+	return nil
+}
 
-	// Update workflow status
-	workflowInstance.Status = WorkflowStatusCancelled
-	now := time.Now()
-	workflowInstance.CompletedAt = &now
-	ao.workflowInstances.Set(instanceID, workflowInstance)
+// watchForCancellation cancels the instance when another floss process, such
+// as `floss agents cancel`, marks it cancelled in the database
+func (ao *AgentOrchestrator) watchForCancellation(ctx context.Context, workflowInstanceID string) {
+	ticker := time.NewTicker(ao.pollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			stored, err := ao.q.GetWorkflowInstance(ctx, workflowInstanceID)
+			if err != nil || WorkflowStatus(stored.Status) != WorkflowStatusCancelled {
+				continue
+			}
+			if err := ao.CancelWorkflow(workflowInstanceID); err != nil {
+				slog.Debug("Workflow already stopped", "instance_id", workflowInstanceID, "error", err)
+			}
+			return
+		}
+	}
+}
 
-	slog.Info("Cancelled workflow", "instance_id", instanceID)
-	return nil
+// isCancelled reports whether the instance was cancelled while it was running
+func (ao *AgentOrchestrator) isCancelled(instanceID string) bool {
+	workflowInstance, exists := ao.workflowInstances.Get(instanceID)
+	return exists && workflowInstance.Status == WorkflowStatusCancelled
 }
 
 // GetAgentService retrieves an agent service by role
@@ -379,4 +658,4 @@ func (ao *AgentOrchestrator) GetAgentService(role AgentRole) (agent.Service, boo
 func (ao *AgentOrchestrator) RegisterAgentService(role AgentRole, service agent.Service) {
 	ao.agentServices.Set(role, service)
 	slog.Info("Registered agent service", "role", role)
-}
\ No newline at end of file
+}
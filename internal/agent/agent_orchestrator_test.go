@@ -0,0 +1,570 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/catwalk/pkg/catwalk"
+	"github.com/nom-nom-hub/floss/internal/csync"
+	"github.com/nom-nom-hub/floss/internal/db"
+	"github.com/nom-nom-hub/floss/internal/history"
+	"github.com/nom-nom-hub/floss/internal/llm/agent"
+	"github.com/nom-nom-hub/floss/internal/message"
+	"github.com/nom-nom-hub/floss/internal/pubsub"
+	"github.com/nom-nom-hub/floss/internal/session"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeAgent answers every prompt with an assistant message and records the
+// sessions it was run in.
+type fakeAgent struct {
+	*pubsub.Broker[agent.AgentEvent]
+	messages message.Service
+
+	// release, when set, holds every run until a value is received
+	release chan struct{}
+	// history and files, when set, record the files as changed in every
+	// session the agent runs in
+	history history.Service
+	files   []string
+	// replies, when set, are answered in turn instead of "done". The last
+	// reply repeats.
+	replies []string
+	// failures is how many runs fail before the agent starts answering
+	failures int
+	// sessionService, when set, is charged cost and tokens for every run, as
+	// TrackUsage does
+	sessionService session.Service
+	cost           float64
+	tokens         int64
+
+	mu        sync.Mutex
+	sessions  []string
+	prompts   []string
+	active    int
+	maxActive int
+	cancels   map[string]context.CancelFunc
+}
+
+func newFakeAgent(messages message.Service) *fakeAgent {
+	return &fakeAgent{
+		Broker:   pubsub.NewBroker[agent.AgentEvent](),
+		messages: messages,
+		cancels:  make(map[string]context.CancelFunc),
+	}
+}
+
+func (f *fakeAgent) Run(ctx context.Context, sessionID string, content string, _ ...message.Attachment) (<-chan agent.AgentEvent, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	f.mu.Lock()
+	f.cancels[sessionID] = cancel
+	f.sessions = append(f.sessions, sessionID)
+	f.prompts = append(f.prompts, content)
+	f.active++
+	f.maxActive = max(f.maxActive, f.active)
+	run := len(f.prompts) - 1
+	f.mu.Unlock()
+
+	reply := "done"
+	if run < f.failures {
+		reply = ""
+	} else if len(f.replies) > 0 {
+		reply = f.replies[min(run-f.failures, len(f.replies)-1)]
+	}
+
+	events := make(chan agent.AgentEvent, 1)
+	go func() {
+		defer close(events)
+		defer cancel()
+		defer func() {
+			f.mu.Lock()
+			f.active--
+			f.mu.Unlock()
+		}()
+		if f.release != nil {
+			select {
+			case <-f.release:
+			case <-ctx.Done():
+				events <- agent.AgentEvent{Type: agent.AgentEventTypeError, Error: ctx.Err()}
+				return
+			}
+		}
+		if reply == "" {
+			events <- agent.AgentEvent{Type: agent.AgentEventTypeError, Error: errors.New("provider unavailable")}
+			return
+		}
+		if f.sessionService != nil {
+			sess, err := f.sessionService.Get(ctx, sessionID)
+			if err == nil {
+				sess.Cost += f.cost
+				sess.TotalTokens += f.tokens
+				_, err = f.sessionService.Save(ctx, sess)
+			}
+			if err != nil {
+				events <- agent.AgentEvent{Type: agent.AgentEventTypeError, Error: err}
+				return
+			}
+		}
+		for _, path := range f.files {
+			if _, err := f.history.Create(ctx, sessionID, path, "content"); err != nil {
+				events <- agent.AgentEvent{Type: agent.AgentEventTypeError, Error: err}
+				return
+			}
+		}
+		msg, err := f.messages.Create(ctx, sessionID, message.CreateMessageParams{
+			Role:  message.Assistant,
+			Parts: []message.ContentPart{message.TextContent{Text: reply}},
+		})
+		if err != nil {
+			events <- agent.AgentEvent{Type: agent.AgentEventTypeError, Error: err}
+			return
+		}
+		events <- agent.AgentEvent{Type: agent.AgentEventTypeResponse, Message: msg, Done: true}
+	}()
+	return events, nil
+}
+
+func (f *fakeAgent) runPrompts() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.prompts...)
+}
+
+func (f *fakeAgent) activeRuns() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.active
+}
+
+func (f *fakeAgent) Interject(ctx context.Context, sessionID string, content string, attachments ...message.Attachment) (<-chan agent.AgentEvent, error) {
+	return f.Run(ctx, sessionID, content, attachments...)
+}
+
+func (f *fakeAgent) Model() catwalk.Model                               { return catwalk.Model{} }
+func (f *fakeAgent) CancelAll()                                         {}
+func (f *fakeAgent) IsSessionBusy(string) bool                          { return false }
+func (f *fakeAgent) IsBusy() bool                                       { return false }
+func (f *fakeAgent) Summarize(context.Context, string) error            { return nil }
+func (f *fakeAgent) UpdateModel() error                                 { return nil }
+func (f *fakeAgent) QueuedPrompts(string) []agent.QueuedPrompt          { return nil }
+func (f *fakeAgent) UpdateQueuedPrompt(string, agent.QueuedPrompt) bool { return false }
+func (f *fakeAgent) MoveQueuedPrompt(string, string, int) bool          { return false }
+func (f *fakeAgent) RemoveQueuedPrompt(string, string) (agent.QueuedPrompt, bool) {
+	return agent.QueuedPrompt{}, false
+}
+func (f *fakeAgent) ClearQueue(string) []agent.QueuedPrompt { return nil }
+
+func (f *fakeAgent) Cancel(sessionID string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if cancel, ok := f.cancels[sessionID]; ok {
+		cancel()
+	}
+}
+
+func (f *fakeAgent) runSessions() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.sessions...)
+}
+
+type testServices struct {
+	q        db.Querier
+	sessions session.Service
+	messages message.Service
+	history  history.Service
+}
+
+func newTestServices(t *testing.T) testServices {
+	t.Helper()
+	conn, err := db.Connect(t.Context(), t.TempDir())
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	q := db.New(conn)
+	return testServices{
+		q:        q,
+		sessions: session.NewService(q),
+		messages: message.NewService(q),
+		history:  history.NewService(q, conn),
+	}
+}
+
+func (s testServices) orchestrator(agentServices *csync.Map[AgentRole, agent.Service]) *AgentOrchestrator {
+	return NewAgentOrchestrator(agentServices, s.sessions, s.messages, s.history, s.q)
+}
+
+func waitForStatus(t *testing.T, ao *AgentOrchestrator, instanceID string, status WorkflowStatus) WorkflowInstance {
+	t.Helper()
+	var instance *WorkflowInstance
+	require.Eventually(t, func() bool {
+		var ok bool
+		instance, ok = ao.GetWorkflowInstance(instanceID)
+		return ok && instance.Status == status
+	}, 5*time.Second, 10*time.Millisecond)
+	return *instance
+}
+
+func TestAgentOrchestratorResumeWorkflows(t *testing.T) {
+	t.Parallel()
+	ctx := t.Context()
+	services := newTestServices(t)
+	sessions, messages := services.sessions, services.messages
+
+	workflow := AgentWorkflow{
+		ID:   "review",
+		Name: "Review",
+		Steps: []WorkflowStep{
+			{StepNumber: 1, ResponsibleAgent: AgentRoleSeniorDeveloper, Action: "Implement"},
+			{StepNumber: 2, ResponsibleAgent: AgentRoleSeniorDeveloper, Action: "Fix"},
+			{StepNumber: 3, ResponsibleAgent: AgentRoleQAEngineer, Action: "Test", Dependencies: []int{1, 2}},
+		},
+	}
+
+	workflowSession, err := sessions.Create(ctx, "Workflow: Review")
+	require.NoError(t, err)
+	stepSession, err := sessions.Create(ctx, "Workflow Step: Review - Fix")
+	require.NoError(t, err)
+
+	// Simulate an instance left behind by a crash: step 1 finished, step 2
+	// was running in its own session.
+	started := time.Now().Add(-time.Minute)
+	first := services.orchestrator(csync.NewMap[AgentRole, agent.Service]())
+	require.NoError(t, first.RegisterWorkflow(workflow))
+	require.NoError(t, first.createInstance(ctx, WorkflowInstance{
+		ID:         "wf_review_1",
+		WorkflowID: workflow.ID,
+		Name:       workflow.Name,
+		Status:     WorkflowStatusRunning,
+		Steps: []WorkflowStepInstance{
+			{StepNumber: 1, ResponsibleAgent: AgentRoleSeniorDeveloper, Action: "Implement", Status: WorkflowStatusCompleted, Result: "implemented", SessionID: "implement-session", StartedAt: &started, CompletedAt: &started},
+			{StepNumber: 2, ResponsibleAgent: AgentRoleSeniorDeveloper, Action: "Fix", Status: WorkflowStatusRunning, SessionID: stepSession.ID, StartedAt: &started},
+			{StepNumber: 3, ResponsibleAgent: AgentRoleQAEngineer, Action: "Test", Status: WorkflowStatusPending},
+		},
+		CreatedAt: started,
+		StartedAt: &started,
+		SessionID: workflowSession.ID,
+		Context:   map[string]interface{}{"feature": "login"},
+	}))
+
+	developer := newFakeAgent(messages)
+	qa := newFakeAgent(messages)
+	agentServices := csync.NewMap[AgentRole, agent.Service]()
+	agentServices.Set(AgentRoleSeniorDeveloper, developer)
+	agentServices.Set(AgentRoleQAEngineer, qa)
+
+	second := services.orchestrator(agentServices)
+	require.NoError(t, second.RegisterWorkflow(workflow))
+	resumed, err := second.ResumeWorkflows(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 1, resumed)
+
+	instance := waitForStatus(t, second, "wf_review_1", WorkflowStatusCompleted)
+	require.Equal(t, "implemented", instance.Steps[0].Result)
+	require.Equal(t, []string{stepSession.ID}, developer.runSessions())
+	require.Len(t, qa.runSessions(), 1)
+	require.NotEqual(t, stepSession.ID, qa.runSessions()[0])
+	require.Equal(t, "login", instance.Context["feature"])
+
+	// A fresh orchestrator sees the finished instance in the database and
+	// has nothing left to resume.
+	third := services.orchestrator(csync.NewMap[AgentRole, agent.Service]())
+	require.NoError(t, third.RegisterWorkflow(workflow))
+	stored, ok := third.GetWorkflowInstance("wf_review_1")
+	require.True(t, ok)
+	require.Equal(t, WorkflowStatusCompleted, stored.Status)
+	for _, step := range stored.Steps {
+		require.Equal(t, WorkflowStatusCompleted, step.Status)
+		require.NotEmpty(t, step.SessionID)
+	}
+	require.Equal(t, stepSession.ID, stored.Steps[1].SessionID)
+	resumed, err = third.ResumeWorkflows(ctx)
+	require.NoError(t, err)
+	require.Zero(t, resumed)
+}
+
+func TestAgentOrchestratorResumeWorkflowsSkipsClaimedInstances(t *testing.T) {
+	t.Parallel()
+	ctx := t.Context()
+	services := newTestServices(t)
+
+	workflow := AgentWorkflow{
+		ID:    "review",
+		Name:  "Review",
+		Steps: []WorkflowStep{{StepNumber: 1, ResponsibleAgent: AgentRoleSeniorDeveloper, Action: "Implement"}},
+	}
+	workflowSession, err := services.sessions.Create(ctx, "Workflow: Review")
+	require.NoError(t, err)
+
+	// Another process, such as `floss agents run`, is running the instance
+	other := services.orchestrator(csync.NewMap[AgentRole, agent.Service]())
+	require.NoError(t, other.RegisterWorkflow(workflow))
+	require.NoError(t, other.createInstance(ctx, WorkflowInstance{
+		ID:         "running",
+		WorkflowID: workflow.ID,
+		Name:       workflow.Name,
+		Status:     WorkflowStatusRunning,
+		Steps:      []WorkflowStepInstance{{StepNumber: 1, ResponsibleAgent: AgentRoleSeniorDeveloper, Action: "Implement", Status: WorkflowStatusRunning}},
+		CreatedAt:  time.Now(),
+		SessionID:  workflowSession.ID,
+	}))
+	claimed, err := other.claimInstance(ctx, "running")
+	require.NoError(t, err)
+	require.True(t, claimed)
+
+	developer := newFakeAgent(services.messages)
+	agentServices := csync.NewMap[AgentRole, agent.Service]()
+	agentServices.Set(AgentRoleSeniorDeveloper, developer)
+	tui := services.orchestrator(agentServices)
+	require.NoError(t, tui.RegisterWorkflow(workflow))
+	resumed, err := tui.ResumeWorkflows(ctx)
+	require.NoError(t, err)
+	require.Zero(t, resumed, "an instance claimed by another process is not resumed")
+	require.Empty(t, developer.runSessions())
+
+	// Once the other process's lease expired, as when it was killed, the
+	// instance is resumed
+	other.leaseDuration = -time.Minute
+	claimed, err = other.claimInstance(ctx, "running")
+	require.NoError(t, err)
+	require.True(t, claimed)
+	resumed, err = tui.ResumeWorkflows(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 1, resumed)
+	waitForStatus(t, tui, "running", WorkflowStatusCompleted)
+	require.Eventually(t, func() bool {
+		claimed, err := other.claimInstance(ctx, "running")
+		return err == nil && claimed
+	}, 5*time.Second, 10*time.Millisecond, "the lease is released when the instance finishes")
+}
+
+func TestAgentOrchestratorRunsReadyStepsInParallel(t *testing.T) {
+	t.Parallel()
+	ctx := t.Context()
+	services := newTestServices(t)
+	messages := services.messages
+
+	// 1 fans out to 2, 3 and 4 through NextSteps; 5 waits for all of them.
+	workflow := AgentWorkflow{
+		ID:   "fan_out",
+		Name: "Fan out",
+		Steps: []WorkflowStep{
+			{StepNumber: 5, ResponsibleAgent: AgentRoleTechLead, Action: "Integrate", Dependencies: []int{2, 3, 4}},
+			{StepNumber: 1, ResponsibleAgent: AgentRoleTechLead, Action: "Plan", NextSteps: []int{2, 3, 4}},
+			{StepNumber: 2, ResponsibleAgent: AgentRoleSeniorDeveloper, Action: "Backend"},
+			{StepNumber: 3, ResponsibleAgent: AgentRoleSeniorDeveloper, Action: "Frontend"},
+			{StepNumber: 4, ResponsibleAgent: AgentRoleSeniorDeveloper, Action: "Mobile"},
+		},
+	}
+
+	lead := newFakeAgent(messages)
+	developer := newFakeAgent(messages)
+	developer.release = make(chan struct{})
+	agentServices := csync.NewMap[AgentRole, agent.Service]()
+	agentServices.Set(AgentRoleTechLead, lead)
+	agentServices.Set(AgentRoleSeniorDeveloper, developer)
+
+	ao := services.orchestrator(agentServices)
+	ao.SetMaxParallelSteps(2)
+	require.NoError(t, ao.RegisterWorkflow(workflow))
+
+	instance, err := ao.StartWorkflow(ctx, workflow.ID, nil)
+	require.NoError(t, err)
+
+	// Only two of the three ready steps may run at once.
+	require.Eventually(t, func() bool { return developer.activeRuns() == 2 }, 5*time.Second, 10*time.Millisecond)
+	require.Never(t, func() bool { return developer.activeRuns() > 2 }, 100*time.Millisecond, 10*time.Millisecond)
+	require.Len(t, lead.runSessions(), 1)
+	for range 3 {
+		developer.release <- struct{}{}
+	}
+
+	finished := waitForStatus(t, ao, instance.ID, WorkflowStatusCompleted)
+	require.Equal(t, 2, developer.maxActive)
+	require.Len(t, lead.runSessions(), 2)
+
+	// Step order is never rearranged.
+	var order []int
+	for _, step := range finished.Steps {
+		order = append(order, step.StepNumber)
+		require.Equal(t, WorkflowStatusCompleted, step.Status)
+	}
+	require.Equal(t, []int{5, 1, 2, 3, 4}, order)
+	require.True(t, finished.step(5).StartedAt.Compare(*finished.step(2).CompletedAt) >= 0)
+}
+
+func TestAgentOrchestratorStopsSchedulingAfterFailure(t *testing.T) {
+	t.Parallel()
+	ctx := t.Context()
+	services := newTestServices(t)
+	messages := services.messages
+
+	workflow := AgentWorkflow{
+		ID:   "failing",
+		Name: "Failing",
+		Steps: []WorkflowStep{
+			{StepNumber: 1, ResponsibleAgent: AgentRoleQAEngineer, Action: "Test"},
+			{StepNumber: 2, ResponsibleAgent: AgentRoleTechLead, Action: "Review", Dependencies: []int{1}},
+		},
+	}
+
+	// No QA agent is registered, so step 1 fails.
+	lead := newFakeAgent(messages)
+	agentServices := csync.NewMap[AgentRole, agent.Service]()
+	agentServices.Set(AgentRoleTechLead, lead)
+
+	ao := services.orchestrator(agentServices)
+	require.NoError(t, ao.RegisterWorkflow(workflow))
+	instance, err := ao.StartWorkflow(ctx, workflow.ID, nil)
+	require.NoError(t, err)
+
+	// Starting the workflow again in the same second is another instance
+	again, err := ao.StartWorkflow(ctx, workflow.ID, nil)
+	require.NoError(t, err)
+	require.NotEqual(t, instance.ID, again.ID)
+
+	finished := waitForStatus(t, ao, instance.ID, WorkflowStatusFailed)
+	require.Equal(t, WorkflowStatusFailed, finished.step(1).Status)
+	require.Contains(t, finished.step(1).Error, "agent service for role qa_engineer not found")
+	require.Equal(t, WorkflowStatusPending, finished.step(2).Status)
+	waitForStatus(t, ao, again.ID, WorkflowStatusFailed)
+	require.Empty(t, lead.runSessions())
+}
+
+func TestAgentOrchestratorHandsOffStepResults(t *testing.T) {
+	t.Parallel()
+	ctx := t.Context()
+	services := newTestServices(t)
+
+	workflow := AgentWorkflow{
+		ID:   "handoff",
+		Name: "Hand-off",
+		Steps: []WorkflowStep{
+			{StepNumber: 1, ResponsibleAgent: AgentRoleSeniorDeveloper, Action: "Implement"},
+			{StepNumber: 2, ResponsibleAgent: AgentRoleQAEngineer, Action: "Test", Dependencies: []int{1}},
+			{
+				StepNumber:       3,
+				ResponsibleAgent: AgentRoleTechLead,
+				Action:           "Review",
+				Input:            "Review {{context.feature}} on {{ context.repo.branch }}.\nReport: {{steps.1.result}}\nFiles:\n{{steps.1.files}}\nQA files: {{steps.2.files}}",
+				Dependencies:     []int{2},
+			},
+		},
+	}
+
+	developer := newFakeAgent(services.messages)
+	developer.history = services.history
+	developer.files = []string{"/repo/login.go", "/repo/login_test.go", "/repo/login.go"}
+	qa := newFakeAgent(services.messages)
+	lead := newFakeAgent(services.messages)
+	agentServices := csync.NewMap[AgentRole, agent.Service]()
+	agentServices.Set(AgentRoleSeniorDeveloper, developer)
+	agentServices.Set(AgentRoleQAEngineer, qa)
+	agentServices.Set(AgentRoleTechLead, lead)
+
+	ao := services.orchestrator(agentServices)
+	require.NoError(t, ao.RegisterWorkflow(workflow))
+	instance, err := ao.StartWorkflow(ctx, workflow.ID, map[string]interface{}{
+		"feature": "login",
+		"repo":    map[string]interface{}{"branch": "main"},
+	})
+	require.NoError(t, err)
+	waitForStatus(t, ao, instance.ID, WorkflowStatusCompleted)
+
+	// Without an input, the direct dependency's report and files are passed on.
+	qaPrompt := qa.runPrompts()[0]
+	require.Contains(t, qaPrompt, "Result of step 1 (Implement, senior_developer):\ndone")
+	require.Contains(t, qaPrompt, "Files changed in step 1:\n/repo/login.go\n/repo/login_test.go\n")
+	require.Contains(t, qaPrompt, "- feature: login")
+
+	leadPrompt := lead.runPrompts()[0]
+	require.Contains(t, leadPrompt, "Input:\nReview login on main.\nReport: done\nFiles:\n/repo/login.go\n/repo/login_test.go\nQA files: (no files changed)")
+	require.NotContains(t, leadPrompt, "Result of step 2")
+}
+
+func TestAgentOrchestratorFailsStepWithMissingContextValue(t *testing.T) {
+	t.Parallel()
+	ctx := t.Context()
+	services := newTestServices(t)
+
+	workflow := AgentWorkflow{
+		ID:   "missing_context",
+		Name: "Missing context",
+		Steps: []WorkflowStep{
+			{StepNumber: 1, ResponsibleAgent: AgentRoleTechLead, Action: "Plan", Input: "Plan {{context.feature}}"},
+		},
+	}
+	lead := newFakeAgent(services.messages)
+	agentServices := csync.NewMap[AgentRole, agent.Service]()
+	agentServices.Set(AgentRoleTechLead, lead)
+
+	ao := services.orchestrator(agentServices)
+	require.NoError(t, ao.RegisterWorkflow(workflow))
+	instance, err := ao.StartWorkflow(ctx, workflow.ID, nil)
+	require.NoError(t, err)
+
+	finished := waitForStatus(t, ao, instance.ID, WorkflowStatusFailed)
+	require.Contains(t, finished.Steps[0].Error, `context value "feature" not found`)
+	require.Empty(t, lead.runSessions())
+}
+
+func TestAgentOrchestratorCancelFromAnotherProcess(t *testing.T) {
+	t.Parallel()
+	ctx := t.Context()
+	services := newTestServices(t)
+
+	workflow := AgentWorkflow{
+		ID:   "long",
+		Name: "Long",
+		Steps: []WorkflowStep{
+			{StepNumber: 1, ResponsibleAgent: AgentRoleSeniorDeveloper, Action: "Implement"},
+			{StepNumber: 2, ResponsibleAgent: AgentRoleSeniorDeveloper, Action: "Refactor", Dependencies: []int{1}},
+		},
+	}
+	developer := newFakeAgent(services.messages)
+	developer.release = make(chan struct{})
+	agentServices := csync.NewMap[AgentRole, agent.Service]()
+	agentServices.Set(AgentRoleSeniorDeveloper, developer)
+
+	ao := services.orchestrator(agentServices)
+	ao.pollInterval = 10 * time.Millisecond
+	require.NoError(t, ao.RegisterWorkflow(workflow))
+
+	var stepSessions []string
+	ao.OnStepSession(func(workflowInstanceID, sessionID string) {
+		stepSessions = append(stepSessions, sessionID)
+	})
+	updates := ao.Subscribe(ctx)
+
+	instance, err := ao.StartWorkflow(ctx, workflow.ID, nil)
+	require.NoError(t, err)
+	require.Eventually(t, func() bool { return developer.activeRuns() == 1 }, 5*time.Second, 10*time.Millisecond)
+
+	// A second orchestrator only sees the instance through the database.
+	other := services.orchestrator(csync.NewMap[AgentRole, agent.Service]())
+	require.NoError(t, other.CancelWorkflow(instance.ID))
+
+	finished := waitForStatus(t, ao, instance.ID, WorkflowStatusCancelled)
+	require.Eventually(t, func() bool { return developer.activeRuns() == 0 }, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, developer.runSessions(), stepSessions)
+	require.Equal(t, WorkflowStatusPending, finished.step(2).Status)
+
+	// The executor never moves a cancelled instance back to running.
+	time.Sleep(50 * time.Millisecond)
+	stored, err := ao.loadInstance(ctx, instance.ID)
+	require.NoError(t, err)
+	require.Equal(t, WorkflowStatusCancelled, stored.Status)
+	require.Equal(t, WorkflowStatusCancelled, stored.step(1).Status)
+
+	event := <-updates
+	require.Equal(t, pubsub.CreatedEvent, event.Type)
+	require.Equal(t, instance.ID, event.Payload.ID)
+	for event = range updates {
+		if event.Payload.Status == WorkflowStatusCancelled {
+			break
+		}
+	}
+	require.Equal(t, pubsub.UpdatedEvent, event.Type)
+}
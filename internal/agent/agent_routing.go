@@ -0,0 +1,138 @@
+package agent
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Route returns the roles a message from a role reaches on its channel:
+//
+//   - direct messages go to their participants, who must be contacts of the
+//     sender (see Contacts)
+//   - team messages go to everyone in the sender's teams
+//   - department messages go to everyone in the sender's departments
+//   - company-wide messages go to every role
+func (c CompanyStructure) Route(from AgentRole, communication AgentCommunication) ([]AgentRole, error) {
+	var recipients []AgentRole
+	switch communication.Channel {
+	case ChannelDirectMessage:
+		if len(communication.Participants) == 0 {
+			return nil, fmt.Errorf("direct message from %s has no recipient", from)
+		}
+		contacts := c.Contacts(from)
+		for _, role := range communication.Participants {
+			if !slices.Contains(contacts, role) {
+				return nil, fmt.Errorf("%s can't message %s directly, only %s", from, role, joinRoles(contacts))
+			}
+		}
+		recipients = communication.Participants
+	case ChannelTeamChannel:
+		for _, team := range c.teams(from) {
+			recipients = append(recipients, team.LeadRole)
+			recipients = append(recipients, team.MemberRoles...)
+		}
+	case ChannelDepartment:
+		for _, department := range c.departments(from) {
+			recipients = append(recipients, department.roles()...)
+		}
+	case ChannelCompanyWide:
+		for _, department := range c.Departments {
+			recipients = append(recipients, department.roles()...)
+		}
+	default:
+		return nil, fmt.Errorf("unknown channel %q", communication.Channel)
+	}
+
+	recipients = uniqueRoles(recipients, from)
+	if len(recipients) == 0 {
+		return nil, fmt.Errorf("%s has nobody to reach on the %s", from, communication.Channel)
+	}
+	return recipients, nil
+}
+
+// Contacts returns the roles a role may message directly: the members of its
+// teams and the heads of its departments. A department head may also reach
+// everyone in its department and the other department heads.
+func (c CompanyStructure) Contacts(role AgentRole) []AgentRole {
+	var contacts []AgentRole
+	for _, team := range c.teams(role) {
+		contacts = append(contacts, team.LeadRole)
+		contacts = append(contacts, team.MemberRoles...)
+	}
+	for _, department := range c.departments(role) {
+		contacts = append(contacts, department.HeadRole)
+		if department.HeadRole == role {
+			contacts = append(contacts, department.roles()...)
+		}
+	}
+	if c.isHead(role) {
+		for _, department := range c.Departments {
+			contacts = append(contacts, department.HeadRole)
+		}
+	}
+	return uniqueRoles(contacts, role)
+}
+
+// teams returns the teams a role leads or belongs to
+func (c CompanyStructure) teams(role AgentRole) []Team {
+	var teams []Team
+	for _, department := range c.Departments {
+		for _, team := range department.Teams {
+			if team.LeadRole == role || slices.Contains(team.MemberRoles, role) {
+				teams = append(teams, team)
+			}
+		}
+	}
+	return teams
+}
+
+// departments returns the departments a role heads or belongs to
+func (c CompanyStructure) departments(role AgentRole) []Department {
+	var departments []Department
+	for _, department := range c.Departments {
+		if slices.Contains(department.roles(), role) {
+			departments = append(departments, department)
+		}
+	}
+	return departments
+}
+
+func (c CompanyStructure) isHead(role AgentRole) bool {
+	return slices.ContainsFunc(c.Departments, func(department Department) bool {
+		return department.HeadRole == role
+	})
+}
+
+// roles returns the head, team leads and team members of a department
+func (d Department) roles() []AgentRole {
+	roles := []AgentRole{d.HeadRole}
+	for _, team := range d.Teams {
+		roles = append(roles, team.LeadRole)
+		roles = append(roles, team.MemberRoles...)
+	}
+	return roles
+}
+
+// uniqueRoles removes duplicates, empty roles and the given role, keeping the
+// first occurrence of each role
+func uniqueRoles(roles []AgentRole, exclude AgentRole) []AgentRole {
+	var unique []AgentRole
+	for _, role := range roles {
+		if role != "" && role != exclude && !slices.Contains(unique, role) {
+			unique = append(unique, role)
+		}
+	}
+	return unique
+}
+
+func joinRoles(roles []AgentRole) string {
+	if len(roles) == 0 {
+		return "nobody"
+	}
+	names := make([]string, len(roles))
+	for i, role := range roles {
+		names[i] = string(role)
+	}
+	return strings.Join(names, ", ")
+}
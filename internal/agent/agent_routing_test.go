@@ -0,0 +1,103 @@
+package agent
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompanyStructureContacts(t *testing.T) {
+	t.Parallel()
+	structure := DefaultCompanyStructure()
+
+	// Developers reach their teams and the head of engineering.
+	require.ElementsMatch(t,
+		[]AgentRole{AgentRoleJuniorDeveloper, AgentRoleTechLead},
+		structure.Contacts(AgentRoleSeniorDeveloper),
+	)
+
+	// Department heads reach their department and each other.
+	techLead := structure.Contacts(AgentRoleTechLead)
+	require.Contains(t, techLead, AgentRoleSeniorDeveloper)
+	require.Contains(t, techLead, AgentRoleJuniorDeveloper)
+	require.Contains(t, techLead, AgentRoleQAEngineer)
+	require.Contains(t, techLead, AgentRoleCEO)
+	require.NotContains(t, techLead, AgentRoleTechLead)
+
+	require.NotContains(t, structure.Contacts(AgentRoleQAEngineer), AgentRoleSeniorDeveloper)
+	require.Empty(t, structure.Contacts("intern"))
+}
+
+func TestCompanyStructureRoute(t *testing.T) {
+	t.Parallel()
+	structure := DefaultCompanyStructure()
+
+	tests := []struct {
+		name          string
+		from          AgentRole
+		communication AgentCommunication
+		expected      []AgentRole
+		err           string
+	}{
+		{
+			name:          "direct message to a contact",
+			from:          AgentRoleTechLead,
+			communication: AgentCommunication{Channel: ChannelDirectMessage, Participants: []AgentRole{AgentRoleSeniorDeveloper}},
+			expected:      []AgentRole{AgentRoleSeniorDeveloper},
+		},
+		{
+			name:          "direct message outside the hierarchy",
+			from:          AgentRoleJuniorDeveloper,
+			communication: AgentCommunication{Channel: ChannelDirectMessage, Participants: []AgentRole{AgentRoleCEO}},
+			err:           "junior_developer can't message ceo directly, only senior_developer, tech_lead",
+		},
+		{
+			name:          "direct message without recipient",
+			from:          AgentRoleTechLead,
+			communication: AgentCommunication{Channel: ChannelDirectMessage},
+			err:           "has no recipient",
+		},
+		{
+			name:          "team channel",
+			from:          AgentRoleJuniorDeveloper,
+			communication: AgentCommunication{Channel: ChannelTeamChannel},
+			expected:      []AgentRole{AgentRoleSeniorDeveloper},
+		},
+		{
+			name:          "department channel",
+			from:          AgentRoleSeniorDeveloper,
+			communication: AgentCommunication{Channel: ChannelDepartment},
+			expected:      []AgentRole{AgentRoleTechLead, AgentRoleJuniorDeveloper},
+		},
+		{
+			name:          "team channel without a team",
+			from:          AgentRoleCEO,
+			communication: AgentCommunication{Channel: ChannelTeamChannel},
+			err:           "ceo has nobody to reach on the team_channel",
+		},
+		{
+			name:          "unknown channel",
+			from:          AgentRoleCEO,
+			communication: AgentCommunication{Channel: "pager"},
+			err:           `unknown channel "pager"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			recipients, err := structure.Route(tt.from, tt.communication)
+			if tt.err != "" {
+				require.ErrorContains(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, recipients)
+		})
+	}
+
+	companyWide, err := structure.Route(AgentRoleSecurityEngineer, AgentCommunication{Channel: ChannelCompanyWide})
+	require.NoError(t, err)
+	require.Len(t, companyWide, 13)
+	require.NotContains(t, companyWide, AgentRoleSecurityEngineer)
+}
@@ -4,13 +4,17 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"log/slog"
+	"maps"
 	"os"
 	"path/filepath"
 
-	"github.com/nom-nom-hub/floss/internal/csync"
 	"github.com/nom-nom-hub/floss/internal/config"
+	"github.com/nom-nom-hub/floss/internal/csync"
+	"github.com/nom-nom-hub/floss/internal/db"
 	"github.com/nom-nom-hub/floss/internal/history"
 	"github.com/nom-nom-hub/floss/internal/llm/agent"
+	"github.com/nom-nom-hub/floss/internal/llm/tools"
 	"github.com/nom-nom-hub/floss/internal/lsp"
 	"github.com/nom-nom-hub/floss/internal/message"
 	"github.com/nom-nom-hub/floss/internal/permission"
@@ -19,30 +23,69 @@ import (
 
 // AgentSystemConfig represents the configuration for the agent system
 type AgentSystemConfig struct {
-	CompanyStructure    CompanyStructure                     `json:"company_structure"`
-	AgentDefinitions    map[AgentRole]AgentDefinition       `json:"agent_definitions"`
+	CompanyStructure       CompanyStructure                 `json:"company_structure"`
+	AgentDefinitions       map[AgentRole]AgentDefinition    `json:"agent_definitions"`
 	CommunicationProtocols map[string]AgentCommunication    `json:"communication_protocols"`
 	CollaborationProtocols map[string]CollaborationProtocol `json:"collaboration_protocols"`
-	Workflows           map[string]AgentWorkflow            `json:"workflows"`
-	Enabled             bool                                `json:"enabled"`
+	Workflows              map[string]AgentWorkflow         `json:"workflows"`
+	// MaxParallelSteps limits how many steps of a workflow instance run at
+	// the same time. Zero uses DefaultMaxParallelSteps.
+	MaxParallelSteps int  `json:"max_parallel_steps,omitempty"`
+	Enabled          bool `json:"enabled"`
+	// WorkflowFiles maps the IDs of workflows loaded from workflow files to
+	// their file. These workflows are not saved to agents.json.
+	WorkflowFiles map[string]string `json:"-"`
 }
 
 // DefaultAgentSystemConfig returns the default agent system configuration
 func DefaultAgentSystemConfig() AgentSystemConfig {
 	return AgentSystemConfig{
-		CompanyStructure:    DefaultCompanyStructure(),
-		AgentDefinitions:    DefaultAgentDefinitions(),
+		CompanyStructure:       DefaultCompanyStructure(),
+		AgentDefinitions:       DefaultAgentDefinitions(),
 		CommunicationProtocols: DefaultCommunicationProtocols(),
 		CollaborationProtocols: DefaultCollaborationProtocols(),
-		Workflows:           DefaultWorkflows(),
-		Enabled:             true,
+		Workflows:              DefaultWorkflows(),
+		Enabled:                true,
 	}
 }
 
-// LoadAgentSystemConfig loads the agent system configuration from a file
+// LoadAgentSystemConfig loads the agent system configuration from a file.
+// Workflows are merged from the defaults, agents.json and the workflow files
+// in the workflows directory, in that order, so a later source replaces a
+// workflow with the same ID.
 func LoadAgentSystemConfig(configDir string) (AgentSystemConfig, error) {
-	configPath := filepath.Join(configDir, "agents.json")
-	
+	config, err := loadAgentSystemFile(filepath.Join(configDir, "agents.json"))
+	if err != nil {
+		return AgentSystemConfig{}, err
+	}
+	for role, definition := range config.AgentDefinitions {
+		if definition.Budget != nil {
+			if err := definition.Budget.validate(); err != nil {
+				return AgentSystemConfig{}, fmt.Errorf("invalid agent definition %s: %w", role, err)
+			}
+		}
+	}
+
+	workflows := DefaultWorkflows()
+	maps.Copy(workflows, config.Workflows)
+	fileWorkflows, files, err := LoadWorkflowFiles(filepath.Join(configDir, WorkflowsDir))
+	if err != nil {
+		return AgentSystemConfig{}, fmt.Errorf("invalid workflow file: %w", err)
+	}
+	for id, workflow := range fileWorkflows {
+		for _, step := range workflow.Steps {
+			if _, exists := config.AgentDefinitions[step.ResponsibleAgent]; step.ResponsibleAgent != "" && !exists {
+				return AgentSystemConfig{}, fmt.Errorf("invalid workflow file: %s: workflow %s: step %d: unknown role %s", files[id], id, step.StepNumber, step.ResponsibleAgent)
+			}
+		}
+		workflows[id] = workflow
+	}
+	config.Workflows = workflows
+	config.WorkflowFiles = files
+	return config, nil
+}
+
+func loadAgentSystemFile(configPath string) (AgentSystemConfig, error) {
 	// Check if the file exists
 	if _, err := os.Stat(configPath); os.IsNotExist(err) {
 		// Return default configuration if file doesn't exist
@@ -71,6 +114,14 @@ func SaveAgentSystemConfig(config AgentSystemConfig, configDir string) error {
 		return fmt.Errorf("failed to create config directory: %w", err)
 	}
 
+	// Workflows from workflow files stay in their files
+	if len(config.WorkflowFiles) > 0 {
+		config.Workflows = maps.Clone(config.Workflows)
+		for id := range config.WorkflowFiles {
+			delete(config.Workflows, id)
+		}
+	}
+
 	// Convert to JSON
 	data, err := json.MarshalIndent(config, "", "  ")
 	if err != nil {
@@ -86,23 +137,20 @@ func SaveAgentSystemConfig(config AgentSystemConfig, configDir string) error {
 	return nil
 }
 
-// CreateAgentServices creates agent services for each defined agent role
+// CreateAgentServices creates agent services for each agent role defined in
+// agentConfig. When bus is set, every agent gets a tool to message the other
+// roles.
 func CreateAgentServices(
-	cfg *config.Config,
+	agentConfig AgentSystemConfig,
 	permissions permission.Service,
 	sessions session.Service,
 	messages message.Service,
 	history history.Service,
 	lspClients *csync.Map[string, *lsp.Client],
+	bus *MessageBus,
 ) (*csync.Map[AgentRole, agent.Service], error) {
 	agentServices := csync.NewMap[AgentRole, agent.Service]()
 
-	// Load agent system configuration
-	agentConfig, err := LoadAgentSystemConfig(cfg.Options.DataDirectory)
-	if err != nil {
-		return nil, fmt.Errorf("failed to load agent system config: %w", err)
-	}
-
 	// If agent system is not enabled, return empty map
 	if !agentConfig.Enabled {
 		return agentServices, nil
@@ -110,19 +158,14 @@ func CreateAgentServices(
 
 	// Create agent services for each defined agent
 	for role, def := range agentConfig.AgentDefinitions {
-		// Create agent configuration
-		agentCfg := config.Agent{
-			ID:           def.ID,
-			Name:         def.Name,
-			Description:  def.Description,
-			Model:        def.Model,
-			AllowedTools: def.AllowedTools,
-			ContextPaths: def.ContextPaths,
+		var extraTools []tools.BaseTool
+		if bus != nil {
+			extraTools = append(extraTools, NewMessageAgentTool(bus, role))
 		}
 
 		// Create the agent service
 		ctx := context.Background()
-		agentService, err := agent.NewAgent(ctx, agentCfg, permissions, sessions, messages, history, lspClients)
+		agentService, err := agent.NewAgent(ctx, roleAgentConfig(def), permissions, sessions, messages, history, nil, lspClients, extraTools...)
 		if err != nil {
 			return nil, fmt.Errorf("failed to create agent service for role %s: %w", role, err)
 		}
@@ -134,6 +177,89 @@ func CreateAgentServices(
 	return agentServices, nil
 }
 
+// roleAgentConfig returns the agent configuration for a role. Unlike the
+// generic agent configuration, where a nil list allows everything, a role
+// without a list of tools, MCP tools, LSPs or context paths gets none.
+func roleAgentConfig(def AgentDefinition) config.Agent {
+	agentCfg := config.Agent{
+		ID:           def.ID,
+		Name:         def.Name,
+		Description:  def.Description,
+		Model:        def.Model,
+		AllowedTools: def.AllowedTools,
+		AllowedMCP:   def.AllowedMCP,
+		AllowedLSP:   def.AllowedLSP,
+		ContextPaths: def.ContextPaths,
+		Prompt:       def.PromptTemplate,
+	}
+	if agentCfg.AllowedTools == nil {
+		agentCfg.AllowedTools = []string{}
+	}
+	if agentCfg.AllowedMCP == nil {
+		agentCfg.AllowedMCP = map[string][]string{}
+	}
+	if agentCfg.AllowedLSP == nil {
+		agentCfg.AllowedLSP = []string{}
+	}
+	if agentCfg.ContextPaths == nil {
+		agentCfg.ContextPaths = []string{}
+	}
+	return agentCfg
+}
+
+// NewAgentSystem creates the role agents and an orchestrator with every
+// configured workflow registered. Workflow instances are persisted through q.
+// It returns nil when the agent system is disabled.
+func NewAgentSystem(
+	cfg *config.Config,
+	permissions permission.Service,
+	sessions session.Service,
+	messages message.Service,
+	history history.Service,
+	lspClients *csync.Map[string, *lsp.Client],
+	q db.Querier,
+) (*AgentOrchestrator, error) {
+	agentConfig, err := LoadAgentSystemConfig(cfg.Options.DataDirectory)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load agent system config: %w", err)
+	}
+	if !agentConfig.Enabled {
+		return nil, nil
+	}
+
+	// The message tools need the bus before the agents exist, so the bus
+	// gets the agents once they are created
+	agentServices := csync.NewMap[AgentRole, agent.Service]()
+	bus := NewMessageBus(agentConfig.CompanyStructure, agentServices, sessions, messages, q)
+	roleServices, err := CreateAgentServices(agentConfig, permissions, sessions, messages, history, lspClients, bus)
+	if err != nil {
+		return nil, err
+	}
+	for role, agentService := range roleServices.Seq2() {
+		agentServices.Set(role, agentService)
+	}
+
+	orchestrator := NewAgentOrchestrator(agentServices, sessions, messages, history, q)
+	orchestrator.messageBus = bus
+	orchestrator.permissions = permissions
+	if agentConfig.MaxParallelSteps > 0 {
+		orchestrator.SetMaxParallelSteps(agentConfig.MaxParallelSteps)
+	}
+	roleBudgets := make(map[AgentRole]Budget)
+	for role, definition := range agentConfig.AgentDefinitions {
+		if definition.Budget != nil {
+			roleBudgets[role] = *definition.Budget
+		}
+	}
+	orchestrator.SetRoleBudgets(roleBudgets)
+	for _, workflow := range agentConfig.Workflows {
+		if err := orchestrator.RegisterWorkflow(workflow); err != nil {
+			slog.Warn("Skipping invalid workflow", "workflow_id", workflow.ID, "error", err)
+		}
+	}
+	return orchestrator, nil
+}
+
 // GetAgentDefinition returns the definition for a specific agent role
 func GetAgentDefinition(cfg *config.Config, role AgentRole) (AgentDefinition, bool) {
 	// Load agent system configuration
@@ -188,4 +314,4 @@ func ListWorkflows(cfg *config.Config) []AgentWorkflow {
 	}
 
 	return workflows
-}
\ No newline at end of file
+}
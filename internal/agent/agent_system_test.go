@@ -1,10 +1,14 @@
 package agent
 
 import (
+	"context"
 	"os"
 	"path/filepath"
 	"testing"
 
+	"github.com/nom-nom-hub/floss/internal/config"
+	"github.com/nom-nom-hub/floss/internal/llm/agent"
+	"github.com/nom-nom-hub/floss/internal/llm/tools"
 	"github.com/stretchr/testify/require"
 )
 
@@ -59,3 +63,64 @@ func TestLoadAgentSystemConfig(t *testing.T) {
 	require.Error(t, err)
 	require.Contains(t, err.Error(), "failed to parse agent system config")
 }
+
+func TestNewAgentSystemDisabled(t *testing.T) {
+	t.Parallel()
+	tdir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(tdir, "agents.json"), []byte(`{"enabled": false}`), 0o644))
+
+	cfg := &config.Config{Options: &config.Options{DataDirectory: tdir}}
+	orchestrator, err := NewAgentSystem(cfg, nil, nil, nil, nil, nil, nil)
+	require.NoError(t, err)
+	require.Nil(t, orchestrator, "no workflows are registered or resumed when the agent system is disabled")
+}
+
+func TestRoleAgentConfig(t *testing.T) {
+	t.Parallel()
+
+	var available []tools.BaseTool
+	for _, name := range []string{"bash", "download", "edit", "multiedit", "fetch", "glob", "grep", "ls", "sourcegraph", "view", "write"} {
+		available = append(available, namedTool(name))
+	}
+	toolNames := func(agentCfg config.Agent) []string {
+		var names []string
+		for _, tool := range agent.FilterTools(agentCfg, available) {
+			names = append(names, tool.Name())
+		}
+		return names
+	}
+
+	definitions := DefaultAgentDefinitions()
+
+	ceo := roleAgentConfig(definitions[AgentRoleCEO])
+	require.ElementsMatch(t, []string{"view", "ls", "glob", "grep"}, toolNames(ceo))
+	require.NotContains(t, toolNames(ceo), "bash")
+	require.NotContains(t, toolNames(ceo), "write")
+	require.Empty(t, ceo.AllowedMCP)
+	require.NotNil(t, ceo.AllowedMCP)
+	require.Equal(t, definitions[AgentRoleCEO].ContextPaths, ceo.ContextPaths)
+	require.Equal(t, definitions[AgentRoleCEO].PromptTemplate, ceo.Prompt)
+
+	qa := roleAgentConfig(definitions[AgentRoleQAEngineer])
+	require.Contains(t, toolNames(qa), "bash")
+
+	// A role that lists nothing gets nothing, not everything.
+	bare := roleAgentConfig(AgentDefinition{ID: "bare", Role: "bare"})
+	require.Empty(t, toolNames(bare))
+	require.NotNil(t, bare.AllowedLSP)
+	require.NotNil(t, bare.ContextPaths)
+
+	withMCP := roleAgentConfig(AgentDefinition{
+		AllowedTools: []string{"view"},
+		AllowedMCP:   map[string][]string{"github": {"list_issues"}},
+	})
+	require.Equal(t, map[string][]string{"github": {"list_issues"}}, withMCP.AllowedMCP)
+}
+
+type namedTool string
+
+func (t namedTool) Info() tools.ToolInfo { return tools.ToolInfo{Name: string(t)} }
+func (t namedTool) Name() string         { return string(t) }
+func (t namedTool) Run(context.Context, tools.ToolCall) (tools.ToolResponse, error) {
+	return tools.NewTextResponse(string(t)), nil
+}
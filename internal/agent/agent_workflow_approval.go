@@ -0,0 +1,204 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/nom-nom-hub/floss/internal/db"
+	"github.com/nom-nom-hub/floss/internal/permission"
+)
+
+// WorkflowApprovalToolName is the tool name approval gates use in the
+// permission dialog
+const WorkflowApprovalToolName = "workflow_approval"
+
+// WorkflowApprovalParams describes an approval gate in the permission dialog
+type WorkflowApprovalParams struct {
+	InstanceID string `json:"instance_id"`
+	StepNumber int    `json:"step_number"`
+	Action     string `json:"action"`
+}
+
+const (
+	approvalApproved = "approved"
+	approvalRejected = "rejected"
+)
+
+type approvalDecision struct {
+	approved bool
+	reason   string
+	by       string
+}
+
+// SetApprovalDialog controls whether approval gates ask in the permission
+// dialog as well as waiting for `floss agents approve`. Runs without a TUI
+// turn it off: nobody answers the dialog there, and an open request holds up
+// every other permission request.
+func (ao *AgentOrchestrator) SetApprovalDialog(enabled bool) {
+	ao.approvalDialog = enabled
+}
+
+// ApproveStep records a decision for an approval gate that is waiting. It
+// works across processes: the instance picks the decision up from the
+// database.
+func (ao *AgentOrchestrator) ApproveStep(ctx context.Context, instanceID string, stepNumber int, approved bool, reason string) error {
+	workflowInstance, exists := ao.GetWorkflowInstance(instanceID)
+	if !exists {
+		return fmt.Errorf("workflow instance %s not found", instanceID)
+	}
+	stepInstance := workflowInstance.step(stepNumber)
+	if stepInstance == nil {
+		return fmt.Errorf("workflow instance %s has no step %d", instanceID, stepNumber)
+	}
+	if stepInstance.Status != WorkflowStatusWaitingApproval {
+		return fmt.Errorf("step %d of workflow instance %s is %s, not waiting for approval", stepNumber, instanceID, stepInstance.Status)
+	}
+	approval := approvalRejected
+	if approved {
+		approval = approvalApproved
+	}
+	return ao.q.SetWorkflowStepApproval(ctx, db.SetWorkflowStepApprovalParams{
+		Approval:       approval,
+		ApprovalReason: reason,
+		InstanceID:     instanceID,
+		StepNumber:     int64(stepNumber),
+	})
+}
+
+// awaitApproval pauses an approval step until it is approved or rejected,
+// either in the permission dialog or with ApproveStep
+func (ao *AgentOrchestrator) awaitApproval(ctx context.Context, workflowInstanceID string, step WorkflowStep) error {
+	// Forget the decision of an earlier pass through this gate
+	err := ao.q.SetWorkflowStepApproval(ctx, db.SetWorkflowStepApprovalParams{
+		InstanceID: workflowInstanceID,
+		StepNumber: int64(step.StepNumber),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to reset approval: %w", err)
+	}
+	ao.updateStep(workflowInstanceID, step.StepNumber, func(stepInstance *WorkflowStepInstance) {
+		stepInstance.Status = WorkflowStatusWaitingApproval
+	})
+	slog.Info("Waiting for approval", "instance_id", workflowInstanceID, "step", step.StepNumber)
+
+	decisions := make(chan approvalDecision, 1)
+	dialogCtx, closeDialog := context.WithCancel(ctx)
+	defer closeDialog()
+	if ao.permissions != nil && ao.approvalDialog {
+		workflowInstance, _ := ao.workflowInstances.Get(workflowInstanceID)
+		go ao.requestApproval(dialogCtx, workflowInstance, step, decisions)
+	}
+
+	ticker := time.NewTicker(ao.pollInterval)
+	defer ticker.Stop()
+	var decision approvalDecision
+	for decided := false; !decided; {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case decision = <-decisions:
+			decided = true
+		case <-ticker.C:
+			stored, err := ao.q.GetWorkflowStep(ctx, db.GetWorkflowStepParams{
+				InstanceID: workflowInstanceID,
+				StepNumber: int64(step.StepNumber),
+			})
+			if err != nil || stored.Approval == "" {
+				continue
+			}
+			decision = approvalDecision{
+				approved: stored.Approval == approvalApproved,
+				reason:   stored.ApprovalReason,
+				by:       "the CLI",
+			}
+			decided = true
+		}
+	}
+
+	result := fmt.Sprintf("Approved in %s", decision.by)
+	if !decision.approved {
+		result = fmt.Sprintf("Rejected in %s", decision.by)
+	}
+	if decision.reason != "" {
+		result += ": " + decision.reason
+	}
+	if !decision.approved {
+		return errors.New(result)
+	}
+	ao.updateStep(workflowInstanceID, step.StepNumber, func(stepInstance *WorkflowStepInstance) {
+		stepInstance.Status = WorkflowStatusCompleted
+		stepInstance.Result = result
+		now := time.Now()
+		stepInstance.CompletedAt = &now
+	})
+	slog.Info("Step approved", "instance_id", workflowInstanceID, "step", step.StepNumber)
+	return nil
+}
+
+// requestApproval asks for approval of a step in the permission dialog. When
+// the gate is decided elsewhere first, ctx is cancelled and the open request
+// is denied so it doesn't hold up other permission requests.
+func (ao *AgentOrchestrator) requestApproval(ctx context.Context, workflowInstance WorkflowInstance, step WorkflowStep, decisions chan<- approvalDecision) {
+	toolCallID := uuid.New().String()
+
+	// The request is only known once it is published
+	subCtx, unsubscribe := context.WithCancel(context.Background())
+	defer unsubscribe()
+	requests := ao.permissions.Subscribe(subCtx)
+
+	granted := make(chan bool, 1)
+	go func() {
+		granted <- ao.permissions.Request(permission.CreatePermissionRequest{
+			SessionID:   workflowInstance.SessionID,
+			ToolCallID:  toolCallID,
+			ToolName:    WorkflowApprovalToolName,
+			Description: approvalDescription(workflowInstance, step),
+			// A unique action keeps "allow for session" from
+			// approving later gates too
+			Action: "approve " + toolCallID,
+			Params: WorkflowApprovalParams{
+				InstanceID: workflowInstance.ID,
+				StepNumber: step.StepNumber,
+				Action:     step.Action,
+			},
+			Path: ".",
+		})
+	}()
+
+	done := ctx.Done()
+	var pending *permission.PermissionRequest
+	for {
+		select {
+		case event := <-requests:
+			if event.Payload.ToolCallID != toolCallID {
+				continue
+			}
+			pending = &event.Payload
+			if done == nil {
+				ao.permissions.Deny(*pending)
+			}
+		case <-done:
+			done = nil
+			if pending != nil {
+				ao.permissions.Deny(*pending)
+			}
+		case ok := <-granted:
+			if done != nil {
+				decisions <- approvalDecision{approved: ok, by: "the permission dialog"}
+			}
+			return
+		}
+	}
+}
+
+func approvalDescription(workflowInstance WorkflowInstance, step WorkflowStep) string {
+	description := fmt.Sprintf("Workflow %s is waiting for approval at step %d: %s", workflowInstance.Name, step.StepNumber, step.Action)
+	if step.ExpectedOutput != "" {
+		description += "\n\n" + step.ExpectedOutput
+	}
+	return fmt.Sprintf("%s\n\nApprove to continue the workflow, or deny to fail this step. You can also run `floss agents approve %s %d`.", description, workflowInstance.ID, step.StepNumber)
+}
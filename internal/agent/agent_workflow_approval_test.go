@@ -0,0 +1,138 @@
+package agent
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nom-nom-hub/floss/internal/csync"
+	"github.com/nom-nom-hub/floss/internal/llm/agent"
+	"github.com/nom-nom-hub/floss/internal/permission"
+	"github.com/stretchr/testify/require"
+)
+
+func approvalWorkflow() AgentWorkflow {
+	return AgentWorkflow{
+		ID:   "release",
+		Name: "Release",
+		Steps: []WorkflowStep{
+			{StepNumber: 1, Type: StepTypeApproval, Action: "Approve the release"},
+			{StepNumber: 2, ResponsibleAgent: AgentRoleSeniorDeveloper, Action: "Release", Dependencies: []int{1}},
+		},
+	}
+}
+
+func TestAgentOrchestratorApprovalFromAnotherProcess(t *testing.T) {
+	t.Parallel()
+	ctx := t.Context()
+	services := newTestServices(t)
+
+	developer := newFakeAgent(services.messages)
+	agentServices := csync.NewMap[AgentRole, agent.Service]()
+	agentServices.Set(AgentRoleSeniorDeveloper, developer)
+
+	ao := services.orchestrator(agentServices)
+	ao.pollInterval = 10 * time.Millisecond
+	require.NoError(t, ao.RegisterWorkflow(approvalWorkflow()))
+	instance, err := ao.StartWorkflow(ctx, "release", nil)
+	require.NoError(t, err)
+
+	other := services.orchestrator(csync.NewMap[AgentRole, agent.Service]())
+	require.Eventually(t, func() bool {
+		stored, err := other.loadInstance(ctx, instance.ID)
+		return err == nil && stored.step(1).Status == WorkflowStatusWaitingApproval
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Never(t, func() bool { return len(developer.runSessions()) > 0 }, 50*time.Millisecond, 10*time.Millisecond)
+
+	require.ErrorContains(t, other.ApproveStep(ctx, instance.ID, 2, true, ""), "is pending, not waiting for approval")
+	require.NoError(t, other.ApproveStep(ctx, instance.ID, 1, true, "ship it"))
+
+	finished := waitForStatus(t, ao, instance.ID, WorkflowStatusCompleted)
+	require.Equal(t, "Approved in the CLI: ship it", finished.step(1).Result)
+	require.Equal(t, WorkflowStatusCompleted, finished.step(2).Status)
+}
+
+func TestAgentOrchestratorRejectedApprovalFails(t *testing.T) {
+	t.Parallel()
+	ctx := t.Context()
+	services := newTestServices(t)
+
+	developer := newFakeAgent(services.messages)
+	agentServices := csync.NewMap[AgentRole, agent.Service]()
+	agentServices.Set(AgentRoleSeniorDeveloper, developer)
+
+	ao := services.orchestrator(agentServices)
+	ao.pollInterval = 10 * time.Millisecond
+	require.NoError(t, ao.RegisterWorkflow(approvalWorkflow()))
+	instance, err := ao.StartWorkflow(ctx, "release", nil)
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		return ao.ApproveStep(ctx, instance.ID, 1, false, "too risky") == nil
+	}, 5*time.Second, 10*time.Millisecond)
+
+	finished := waitForStatus(t, ao, instance.ID, WorkflowStatusFailed)
+	require.Equal(t, WorkflowStatusFailed, finished.step(1).Status)
+	require.Equal(t, "Rejected in the CLI: too risky", finished.step(1).Error)
+	require.Equal(t, WorkflowStatusPending, finished.step(2).Status)
+	require.Empty(t, developer.runSessions())
+}
+
+func TestAgentOrchestratorApprovalInPermissionDialog(t *testing.T) {
+	t.Parallel()
+	ctx := t.Context()
+	services := newTestServices(t)
+
+	developer := newFakeAgent(services.messages)
+	agentServices := csync.NewMap[AgentRole, agent.Service]()
+	agentServices.Set(AgentRoleSeniorDeveloper, developer)
+
+	permissions := permission.NewPermissionService(t.TempDir(), false, nil)
+	requests := permissions.Subscribe(ctx)
+
+	ao := services.orchestrator(agentServices)
+	ao.permissions = permissions
+	require.NoError(t, ao.RegisterWorkflow(approvalWorkflow()))
+	instance, err := ao.StartWorkflow(ctx, "release", nil)
+	require.NoError(t, err)
+
+	request := (<-requests).Payload
+	require.Equal(t, WorkflowApprovalToolName, request.ToolName)
+	require.Equal(t, instance.SessionID, request.SessionID)
+	require.Contains(t, request.Description, "floss agents approve "+instance.ID+" 1")
+	permissions.Grant(request)
+
+	finished := waitForStatus(t, ao, instance.ID, WorkflowStatusCompleted)
+	require.Equal(t, "Approved in the permission dialog", finished.step(1).Result)
+}
+
+func TestAgentOrchestratorApprovalClosesDialogWhenDecidedElsewhere(t *testing.T) {
+	t.Parallel()
+	ctx := t.Context()
+	services := newTestServices(t)
+
+	developer := newFakeAgent(services.messages)
+	agentServices := csync.NewMap[AgentRole, agent.Service]()
+	agentServices.Set(AgentRoleSeniorDeveloper, developer)
+
+	permissions := permission.NewPermissionService(t.TempDir(), false, nil)
+	requests := permissions.Subscribe(ctx)
+	notifications := permissions.SubscribeNotifications(ctx)
+
+	ao := services.orchestrator(agentServices)
+	ao.permissions = permissions
+	ao.pollInterval = 10 * time.Millisecond
+	require.NoError(t, ao.RegisterWorkflow(approvalWorkflow()))
+	instance, err := ao.StartWorkflow(ctx, "release", nil)
+	require.NoError(t, err)
+
+	request := (<-requests).Payload
+	require.NoError(t, ao.ApproveStep(ctx, instance.ID, 1, true, ""))
+	waitForStatus(t, ao, instance.ID, WorkflowStatusCompleted)
+
+	// The open request is denied so it no longer blocks other requests
+	for event := range notifications {
+		if event.Payload.ToolCallID == request.ToolCallID && event.Payload.Denied {
+			break
+		}
+	}
+}
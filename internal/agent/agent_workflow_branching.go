@@ -0,0 +1,196 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"time"
+)
+
+// WorkflowStepType selects what a workflow step does
+type WorkflowStepType string
+
+const (
+	// StepTypeAgent steps are worked on by the agent of the responsible role.
+	// Steps without a type are agent steps.
+	StepTypeAgent WorkflowStepType = "agent"
+	// StepTypeApproval steps pause the instance until a human approves it
+	StepTypeApproval WorkflowStepType = "approval"
+)
+
+// RetryPolicy controls how often a failing step is attempted. The delay
+// before each retry starts at Backoff and doubles up to MaxBackoff.
+type RetryPolicy struct {
+	MaxAttempts int    `json:"max_attempts" jsonschema:"required,minimum=1,description=Attempts including the first one"`
+	Backoff     string `json:"backoff,omitempty" jsonschema:"description=Delay before the first retry,default=10s,example=30s"`
+	MaxBackoff  string `json:"max_backoff,omitempty" jsonschema:"description=Longest delay between two attempts,default=5m"`
+}
+
+const (
+	defaultRetryBackoff    = 10 * time.Second
+	defaultRetryMaxBackoff = 5 * time.Minute
+)
+
+func (p RetryPolicy) validate() error {
+	if p.MaxAttempts < 1 {
+		return fmt.Errorf("retry needs at least 1 attempt, got %d", p.MaxAttempts)
+	}
+	for _, value := range []string{p.Backoff, p.MaxBackoff} {
+		if value == "" {
+			continue
+		}
+		if d, err := time.ParseDuration(value); err != nil || d < 0 {
+			return fmt.Errorf("invalid retry backoff %q", value)
+		}
+	}
+	return nil
+}
+
+// delay returns how long to wait after the given number of failed attempts
+func (p RetryPolicy) delay(failed int) time.Duration {
+	backoff, maxBackoff := defaultRetryBackoff, defaultRetryMaxBackoff
+	if d, err := time.ParseDuration(p.Backoff); err == nil {
+		backoff = d
+	}
+	if d, err := time.ParseDuration(p.MaxBackoff); err == nil {
+		maxBackoff = d
+	}
+	for range failed - 1 {
+		if backoff >= maxBackoff {
+			break
+		}
+		backoff *= 2
+	}
+	return min(backoff, maxBackoff)
+}
+
+// StepCondition branches on the result of a step once it completes. If is a
+// regular expression matched case-insensitively against the result. A
+// matching condition either sends the workflow back to GoTo, which runs again
+// along with every step after it, or skips the steps listed in Skip. Only the
+// first matching condition of a step is applied.
+type StepCondition struct {
+	If       string `json:"if" jsonschema:"required,description=Regular expression matched case-insensitively against the step's result"`
+	GoTo     int    `json:"go_to,omitempty" jsonschema:"description=This step or an earlier one to run again"`
+	Skip     []int  `json:"skip,omitempty" jsonschema:"description=Later steps that are not needed"`
+	MaxTimes int    `json:"max_times,omitempty" jsonschema:"minimum=0,default=1,description=How often the step may go back"`
+}
+
+func (c StepCondition) matches(result string) bool {
+	re, err := regexp.Compile("(?i)" + c.If)
+	return err == nil && re.MatchString(result)
+}
+
+func (c StepCondition) maxTimes() int {
+	if c.MaxTimes == 0 {
+		return 1
+	}
+	return c.MaxTimes
+}
+
+// runStep executes a step, retrying it as its retry policy allows
+func (ao *AgentOrchestrator) runStep(ctx context.Context, workflowInstanceID string, step WorkflowStep, dependencies []int) error {
+	for {
+		err := ao.executeStep(ctx, workflowInstanceID, step, dependencies)
+		if err == nil || step.Retry == nil || ctx.Err() != nil || ao.isCancelled(workflowInstanceID) || ao.isPaused(workflowInstanceID) {
+			return err
+		}
+		workflowInstance, _ := ao.workflowInstances.Get(workflowInstanceID)
+		attempts := workflowInstance.step(step.StepNumber).Attempts
+		if attempts >= step.Retry.MaxAttempts {
+			return fmt.Errorf("failed after %d attempts: %w", attempts, err)
+		}
+
+		delay := step.Retry.delay(attempts)
+		slog.Warn("Retrying step", "instance_id", workflowInstanceID, "step", step.StepNumber, "attempt", attempts, "delay", delay, "error", err)
+		ao.updateStep(workflowInstanceID, step.StepNumber, func(stepInstance *WorkflowStepInstance) {
+			stepInstance.Error = fmt.Sprintf("attempt %d failed, retrying in %s: %s", attempts, delay, err)
+		})
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+		ao.updateStep(workflowInstanceID, step.StepNumber, func(stepInstance *WorkflowStepInstance) {
+			stepInstance.Attempts++
+			stepInstance.Error = ""
+		})
+	}
+}
+
+// applyConditions applies the first condition of a completed step that
+// matches its result. When the condition sends the workflow back, it returns
+// the steps that have to run again but are still running; the scheduler
+// resets those once they finish. A matching condition that may not go back
+// any more fails the step.
+func (ao *AgentOrchestrator) applyConditions(workflowInstanceID string, step WorkflowStep, graph workflowGraph) ([]int, error) {
+	if len(step.Conditions) == 0 {
+		return nil, nil
+	}
+	var running []int
+	var err error
+	ao.updateInstance(workflowInstanceID, func(workflowInstance *WorkflowInstance) {
+		stepInstance := workflowInstance.step(step.StepNumber)
+		for _, condition := range step.Conditions {
+			if !condition.matches(stepInstance.Result) {
+				continue
+			}
+			now := time.Now()
+			for _, stepNumber := range condition.Skip {
+				if skipped := workflowInstance.step(stepNumber); skipped.Status == WorkflowStatusPending {
+					skipped.Status = WorkflowStatusSkipped
+					skipped.CompletedAt = &now
+				}
+			}
+			if condition.GoTo == 0 {
+				return
+			}
+			if stepInstance.Loops >= condition.maxTimes() {
+				err = fmt.Errorf("result still matches %q after going back to step %d %d times", condition.If, condition.GoTo, stepInstance.Loops)
+				return
+			}
+
+			stepInstance.Loops++
+			feedback := fmt.Sprintf("Step %d (%s, %s) sent the workflow back to this step:\n%s", step.StepNumber, stepInstance.Action, stepInstance.ResponsibleAgent, stepInstance.Result)
+			slog.Info("Workflow going back", "instance_id", workflowInstanceID, "from", step.StepNumber, "to", condition.GoTo, "loop", stepInstance.Loops)
+			for _, stepNumber := range graph.from(condition.GoTo) {
+				again := workflowInstance.step(stepNumber)
+				if again.Status == WorkflowStatusRunning || again.Status == WorkflowStatusWaitingApproval {
+					running = append(running, stepNumber)
+					continue
+				}
+				again.reset()
+			}
+			workflowInstance.step(condition.GoTo).Feedback = feedback
+			return
+		}
+	})
+	return running, err
+}
+
+// failStep marks a step as failed and hands the error to its fallback step
+func (ao *AgentOrchestrator) failStep(workflowInstanceID string, step WorkflowStep, err error) {
+	ao.updateInstance(workflowInstanceID, func(workflowInstance *WorkflowInstance) {
+		stepInstance := workflowInstance.step(step.StepNumber)
+		stepInstance.Status = WorkflowStatusFailed
+		stepInstance.Error = err.Error()
+		now := time.Now()
+		stepInstance.CompletedAt = &now
+		if step.OnFailure != 0 {
+			workflowInstance.step(step.OnFailure).Feedback = fmt.Sprintf("Step %d (%s, %s) failed, this step runs in its place:\n%s", step.StepNumber, stepInstance.Action, stepInstance.ResponsibleAgent, err)
+		}
+	})
+}
+
+// reset returns a step to pending so it runs again. The step keeps its
+// session and how often it went back.
+func (s *WorkflowStepInstance) reset() {
+	s.Status = WorkflowStatusPending
+	s.Result = ""
+	s.Error = ""
+	s.Feedback = ""
+	s.Attempts = 0
+	s.StartedAt = nil
+	s.CompletedAt = nil
+}
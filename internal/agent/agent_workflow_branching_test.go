@@ -0,0 +1,223 @@
+package agent
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nom-nom-hub/floss/internal/csync"
+	"github.com/nom-nom-hub/floss/internal/llm/agent"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetryPolicyDelay(t *testing.T) {
+	t.Parallel()
+
+	policy := RetryPolicy{MaxAttempts: 5, Backoff: "1s", MaxBackoff: "3s"}
+	require.Equal(t, time.Second, policy.delay(1))
+	require.Equal(t, 2*time.Second, policy.delay(2))
+	require.Equal(t, 3*time.Second, policy.delay(3))
+	require.Equal(t, 3*time.Second, policy.delay(10))
+
+	require.Equal(t, defaultRetryBackoff, RetryPolicy{MaxAttempts: 2}.delay(1))
+}
+
+func TestAgentOrchestratorRetriesFailingStep(t *testing.T) {
+	t.Parallel()
+	ctx := t.Context()
+	services := newTestServices(t)
+
+	workflow := AgentWorkflow{
+		ID:   "flaky",
+		Name: "Flaky",
+		Steps: []WorkflowStep{
+			{StepNumber: 1, ResponsibleAgent: AgentRoleSeniorDeveloper, Action: "Implement", Retry: &RetryPolicy{MaxAttempts: 3, Backoff: "1ms"}},
+			{StepNumber: 2, ResponsibleAgent: AgentRoleQAEngineer, Action: "Test", Dependencies: []int{1}, Retry: &RetryPolicy{MaxAttempts: 2, Backoff: "1ms"}},
+		},
+	}
+	developer := newFakeAgent(services.messages)
+	developer.failures = 2
+	qa := newFakeAgent(services.messages)
+	qa.failures = 2
+	agentServices := csync.NewMap[AgentRole, agent.Service]()
+	agentServices.Set(AgentRoleSeniorDeveloper, developer)
+	agentServices.Set(AgentRoleQAEngineer, qa)
+
+	ao := services.orchestrator(agentServices)
+	require.NoError(t, ao.RegisterWorkflow(workflow))
+	instance, err := ao.StartWorkflow(ctx, workflow.ID, nil)
+	require.NoError(t, err)
+
+	finished := waitForStatus(t, ao, instance.ID, WorkflowStatusFailed)
+	require.Equal(t, WorkflowStatusCompleted, finished.step(1).Status)
+	require.Equal(t, 3, finished.step(1).Attempts)
+	require.Empty(t, finished.step(1).Error)
+	// Every attempt continues in the same session
+	require.Len(t, developer.runSessions(), 3)
+	require.Equal(t, developer.runSessions()[0], developer.runSessions()[2])
+
+	require.Equal(t, WorkflowStatusFailed, finished.step(2).Status)
+	require.Equal(t, 2, finished.step(2).Attempts)
+	require.Equal(t, "failed after 2 attempts: provider unavailable", finished.step(2).Error)
+}
+
+func TestAgentOrchestratorRunsFallbackStep(t *testing.T) {
+	t.Parallel()
+	ctx := t.Context()
+	services := newTestServices(t)
+
+	// No QA agent is registered, so step 2 fails and the tech lead tests
+	// instead. Step 4 is the fallback of step 1, which succeeds.
+	workflow := AgentWorkflow{
+		ID:   "fallback",
+		Name: "Fallback",
+		Steps: []WorkflowStep{
+			{StepNumber: 1, ResponsibleAgent: AgentRoleSeniorDeveloper, Action: "Implement", OnFailure: 4},
+			{StepNumber: 2, ResponsibleAgent: AgentRoleQAEngineer, Action: "Test", Dependencies: []int{1}, OnFailure: 3},
+			{StepNumber: 3, ResponsibleAgent: AgentRoleTechLead, Action: "Test manually"},
+			{StepNumber: 4, ResponsibleAgent: AgentRoleTechLead, Action: "Implement instead"},
+			{StepNumber: 5, ResponsibleAgent: AgentRoleSeniorDeveloper, Action: "Release", Dependencies: []int{2}},
+		},
+	}
+	developer := newFakeAgent(services.messages)
+	lead := newFakeAgent(services.messages)
+	agentServices := csync.NewMap[AgentRole, agent.Service]()
+	agentServices.Set(AgentRoleSeniorDeveloper, developer)
+	agentServices.Set(AgentRoleTechLead, lead)
+
+	ao := services.orchestrator(agentServices)
+	require.NoError(t, ao.RegisterWorkflow(workflow))
+	instance, err := ao.StartWorkflow(ctx, workflow.ID, nil)
+	require.NoError(t, err)
+
+	finished := waitForStatus(t, ao, instance.ID, WorkflowStatusCompleted)
+	require.Equal(t, WorkflowStatusFailed, finished.step(2).Status)
+	require.Equal(t, WorkflowStatusCompleted, finished.step(3).Status)
+	require.Equal(t, WorkflowStatusSkipped, finished.step(4).Status)
+	require.Equal(t, WorkflowStatusCompleted, finished.step(5).Status)
+
+	require.Len(t, lead.runPrompts(), 1)
+	require.Contains(t, lead.runPrompts()[0], "Feedback:\nStep 2 (Test, qa_engineer) failed, this step runs in its place:\nagent service for role qa_engineer not found")
+}
+
+func TestAgentOrchestratorGoesBackOnCondition(t *testing.T) {
+	t.Parallel()
+	ctx := t.Context()
+	services := newTestServices(t)
+
+	workflow := AgentWorkflow{
+		ID:   "qa_loop",
+		Name: "QA loop",
+		Steps: []WorkflowStep{
+			{StepNumber: 1, ResponsibleAgent: AgentRoleSeniorDeveloper, Action: "Implement"},
+			{
+				StepNumber:       2,
+				ResponsibleAgent: AgentRoleQAEngineer,
+				Action:           "Test",
+				Dependencies:     []int{1},
+				Conditions:       []StepCondition{{If: `\bFAIL`, GoTo: 1, MaxTimes: 3}},
+			},
+			{StepNumber: 3, ResponsibleAgent: AgentRoleTechLead, Action: "Release", Dependencies: []int{2}},
+		},
+	}
+	developer := newFakeAgent(services.messages)
+	qa := newFakeAgent(services.messages)
+	qa.replies = []string{"2 tests fail", "1 test fails", "all tests pass"}
+	lead := newFakeAgent(services.messages)
+	agentServices := csync.NewMap[AgentRole, agent.Service]()
+	agentServices.Set(AgentRoleSeniorDeveloper, developer)
+	agentServices.Set(AgentRoleQAEngineer, qa)
+	agentServices.Set(AgentRoleTechLead, lead)
+
+	ao := services.orchestrator(agentServices)
+	require.NoError(t, ao.RegisterWorkflow(workflow))
+	instance, err := ao.StartWorkflow(ctx, workflow.ID, nil)
+	require.NoError(t, err)
+
+	finished := waitForStatus(t, ao, instance.ID, WorkflowStatusCompleted)
+	require.Equal(t, "all tests pass", finished.step(2).Result)
+	require.Equal(t, 2, finished.step(2).Loops)
+	require.Len(t, lead.runSessions(), 1)
+
+	// The developer keeps its session and is told what QA found
+	prompts := developer.runPrompts()
+	require.Len(t, prompts, 3)
+	require.NotContains(t, prompts[0], "Feedback:")
+	require.Contains(t, prompts[1], "Feedback:\nStep 2 (Test, qa_engineer) sent the workflow back to this step:\n2 tests fail")
+	require.Contains(t, prompts[2], "1 test fails")
+	sessions := developer.runSessions()
+	require.Equal(t, sessions[0], sessions[2])
+}
+
+func TestAgentOrchestratorFailsWhenConditionKeepsMatching(t *testing.T) {
+	t.Parallel()
+	ctx := t.Context()
+	services := newTestServices(t)
+
+	workflow := AgentWorkflow{
+		ID:   "endless_qa",
+		Name: "Endless QA",
+		Steps: []WorkflowStep{
+			{StepNumber: 1, ResponsibleAgent: AgentRoleSeniorDeveloper, Action: "Implement"},
+			{
+				StepNumber:       2,
+				ResponsibleAgent: AgentRoleQAEngineer,
+				Action:           "Test",
+				Dependencies:     []int{1},
+				Conditions:       []StepCondition{{If: "fail", GoTo: 1, MaxTimes: 2}},
+			},
+		},
+	}
+	developer := newFakeAgent(services.messages)
+	qa := newFakeAgent(services.messages)
+	qa.replies = []string{"tests fail"}
+	agentServices := csync.NewMap[AgentRole, agent.Service]()
+	agentServices.Set(AgentRoleSeniorDeveloper, developer)
+	agentServices.Set(AgentRoleQAEngineer, qa)
+
+	ao := services.orchestrator(agentServices)
+	require.NoError(t, ao.RegisterWorkflow(workflow))
+	instance, err := ao.StartWorkflow(ctx, workflow.ID, nil)
+	require.NoError(t, err)
+
+	finished := waitForStatus(t, ao, instance.ID, WorkflowStatusFailed)
+	require.Len(t, developer.runSessions(), 3)
+	require.Equal(t, WorkflowStatusFailed, finished.step(2).Status)
+	require.Equal(t, `result still matches "fail" after going back to step 1 2 times`, finished.step(2).Error)
+}
+
+func TestAgentOrchestratorSkipsStepsOnCondition(t *testing.T) {
+	t.Parallel()
+	ctx := t.Context()
+	services := newTestServices(t)
+
+	workflow := AgentWorkflow{
+		ID:   "triage",
+		Name: "Triage",
+		Steps: []WorkflowStep{
+			{
+				StepNumber:       1,
+				ResponsibleAgent: AgentRoleTechLead,
+				Action:           "Triage",
+				Conditions:       []StepCondition{{If: "not a bug", Skip: []int{2}}},
+			},
+			{StepNumber: 2, ResponsibleAgent: AgentRoleSeniorDeveloper, Action: "Fix", Dependencies: []int{1}},
+			{StepNumber: 3, ResponsibleAgent: AgentRoleTechLead, Action: "Reply", Dependencies: []int{2}},
+		},
+	}
+	lead := newFakeAgent(services.messages)
+	lead.replies = []string{"Not a bug, works as intended", "replied"}
+	developer := newFakeAgent(services.messages)
+	agentServices := csync.NewMap[AgentRole, agent.Service]()
+	agentServices.Set(AgentRoleTechLead, lead)
+	agentServices.Set(AgentRoleSeniorDeveloper, developer)
+
+	ao := services.orchestrator(agentServices)
+	require.NoError(t, ao.RegisterWorkflow(workflow))
+	instance, err := ao.StartWorkflow(ctx, workflow.ID, nil)
+	require.NoError(t, err)
+
+	finished := waitForStatus(t, ao, instance.ID, WorkflowStatusCompleted)
+	require.Equal(t, WorkflowStatusSkipped, finished.step(2).Status)
+	require.Equal(t, "replied", finished.step(3).Result)
+	require.Empty(t, developer.runSessions())
+}
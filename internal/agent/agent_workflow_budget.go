@@ -0,0 +1,225 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// BudgetAction is what happens to a workflow instance that exceeds a budget
+type BudgetAction string
+
+const (
+	// BudgetActionPause stops the instance so it can be resumed with
+	// `floss agents resume` once the budget is raised
+	BudgetActionPause BudgetAction = "pause"
+	// BudgetActionCancel cancels the instance for good
+	BudgetActionCancel BudgetAction = "cancel"
+)
+
+// Budget limits what a workflow instance, or the steps of one role within an
+// instance, may use. Limits left at zero are not enforced.
+type Budget struct {
+	MaxCost     float64      `json:"max_cost,omitempty" jsonschema:"minimum=0,description=Most US dollars the step sessions may cost"`
+	MaxTokens   int64        `json:"max_tokens,omitempty" jsonschema:"minimum=0,description=Most tokens the step sessions may use"`
+	MaxDuration string       `json:"max_duration,omitempty" jsonschema:"description=Longest time since the instance started or total time the steps of the role may run,example=2h"`
+	OnExceeded  BudgetAction `json:"on_exceeded,omitempty" jsonschema:"description=Whether an instance over budget is paused or cancelled,enum=pause,enum=cancel,default=pause"`
+}
+
+func (b Budget) validate() error {
+	if b.MaxCost < 0 || b.MaxTokens < 0 {
+		return fmt.Errorf("budget limits can't be negative")
+	}
+	if b.MaxDuration != "" {
+		if d, err := time.ParseDuration(b.MaxDuration); err != nil || d <= 0 {
+			return fmt.Errorf("invalid budget max_duration %q", b.MaxDuration)
+		}
+	}
+	switch b.OnExceeded {
+	case "", BudgetActionPause, BudgetActionCancel:
+		return nil
+	default:
+		return fmt.Errorf("invalid budget on_exceeded %q, expected pause or cancel", b.OnExceeded)
+	}
+}
+
+func (b Budget) action() BudgetAction {
+	if b.OnExceeded == "" {
+		return BudgetActionPause
+	}
+	return b.OnExceeded
+}
+
+// exceeded describes the first limit usage has reached, or returns "" while
+// usage is within the budget
+func (b Budget) exceeded(usage WorkflowUsage) string {
+	switch {
+	case b.MaxCost > 0 && usage.Cost >= b.MaxCost:
+		return fmt.Sprintf("cost $%.2f reached max_cost $%.2f", usage.Cost, b.MaxCost)
+	case b.MaxTokens > 0 && usage.Tokens >= b.MaxTokens:
+		return fmt.Sprintf("%d tokens reached max_tokens %d", usage.Tokens, b.MaxTokens)
+	}
+	if d, err := time.ParseDuration(b.MaxDuration); err == nil && d > 0 && usage.Duration >= d {
+		return fmt.Sprintf("wall time %s reached max_duration %s", usage.Duration.Round(time.Second), d)
+	}
+	return ""
+}
+
+// WorkflowUsage is what a workflow instance, or the steps of one role within
+// it, used so far. Cost and tokens include the agents the step agents
+// messaged.
+type WorkflowUsage struct {
+	Cost     float64       `json:"cost"`
+	Tokens   int64         `json:"tokens"`
+	Duration time.Duration `json:"duration"`
+}
+
+// SetRoleBudgets sets the budgets of the steps of each role within a
+// workflow instance
+func (ao *AgentOrchestrator) SetRoleBudgets(budgets map[AgentRole]Budget) {
+	ao.roleBudgets = budgets
+}
+
+// Usage adds up what a workflow instance used, in total and per role
+func (ao *AgentOrchestrator) Usage(ctx context.Context, workflowInstance WorkflowInstance) (WorkflowUsage, map[AgentRole]WorkflowUsage) {
+	now := time.Now()
+	var total WorkflowUsage
+	if workflowInstance.StartedAt != nil {
+		end := now
+		if workflowInstance.CompletedAt != nil {
+			end = *workflowInstance.CompletedAt
+		}
+		total.Duration = end.Sub(*workflowInstance.StartedAt)
+	}
+
+	roles := make(map[AgentRole]WorkflowUsage)
+	for _, step := range workflowInstance.Steps {
+		if step.ResponsibleAgent == "" {
+			continue
+		}
+		usage := roles[step.ResponsibleAgent]
+		if step.SessionID != "" {
+			sess, err := ao.sessionService.Get(ctx, step.SessionID)
+			if err != nil {
+				slog.Warn("Failed to get step session", "session_id", step.SessionID, "error", err)
+			} else {
+				usage.Cost += sess.Cost
+				usage.Tokens += sess.TotalTokens
+				total.Cost += sess.Cost
+				total.Tokens += sess.TotalTokens
+			}
+		}
+		if step.StartedAt != nil {
+			end := now
+			if step.CompletedAt != nil {
+				end = *step.CompletedAt
+			}
+			usage.Duration += end.Sub(*step.StartedAt)
+		}
+		roles[step.ResponsibleAgent] = usage
+	}
+	return total, roles
+}
+
+// checkBudget returns why a workflow instance is over its workflow's budget
+// or the budget of one of its roles, and what to do about it. The reason is
+// empty while the instance is within its budgets.
+func (ao *AgentOrchestrator) checkBudget(ctx context.Context, workflowInstance WorkflowInstance) (string, BudgetAction) {
+	workflow, _ := ao.workflows.Get(workflowInstance.WorkflowID)
+	if workflow.Budget == nil && len(ao.roleBudgets) == 0 {
+		return "", ""
+	}
+	total, roles := ao.Usage(ctx, workflowInstance)
+	if workflow.Budget != nil {
+		if reason := workflow.Budget.exceeded(total); reason != "" {
+			return "workflow budget exceeded: " + reason, workflow.Budget.action()
+		}
+	}
+	for role, usage := range roles {
+		budget, exists := ao.roleBudgets[role]
+		if !exists {
+			continue
+		}
+		if reason := budget.exceeded(usage); reason != "" {
+			return fmt.Sprintf("budget of role %s exceeded: %s", role, reason), budget.action()
+		}
+	}
+	return "", ""
+}
+
+// enforceBudget pauses or cancels a running workflow instance that is over
+// budget, and reports whether it did
+func (ao *AgentOrchestrator) enforceBudget(ctx context.Context, workflowInstanceID string) bool {
+	workflowInstance, exists := ao.workflowInstances.Get(workflowInstanceID)
+	if !exists || workflowInstance.Status != WorkflowStatusRunning {
+		return false
+	}
+	reason, action := ao.checkBudget(ctx, workflowInstance)
+	if reason == "" {
+		return false
+	}
+	slog.Warn("Workflow over budget", "instance_id", workflowInstanceID, "action", action, "reason", reason)
+	status := WorkflowStatusPaused
+	if action == BudgetActionCancel {
+		status = WorkflowStatusCancelled
+	}
+	if err := ao.stopWorkflow(workflowInstanceID, status, reason); err != nil {
+		slog.Debug("Workflow already stopped", "instance_id", workflowInstanceID, "error", err)
+	}
+	return true
+}
+
+// watchBudget checks the budgets of a running workflow instance while its
+// steps run
+func (ao *AgentOrchestrator) watchBudget(ctx context.Context, workflowInstanceID string) {
+	ticker := time.NewTicker(ao.pollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if ao.enforceBudget(ctx, workflowInstanceID) {
+				return
+			}
+		}
+	}
+}
+
+// ResumeWorkflow continues a workflow instance that was paused because it
+// went over budget. The instance has to be within its budgets again, so
+// raise the budget in the configuration first.
+func (ao *AgentOrchestrator) ResumeWorkflow(ctx context.Context, instanceID string) (*WorkflowInstance, error) {
+	current, exists := ao.GetWorkflowInstance(instanceID)
+	if !exists {
+		return nil, fmt.Errorf("workflow instance %s not found", instanceID)
+	}
+	workflowInstance := *current
+	if workflowInstance.Status != WorkflowStatusPaused {
+		return nil, fmt.Errorf("workflow instance %s is %s, not paused", instanceID, workflowInstance.Status)
+	}
+	if _, exists := ao.workflows.Get(workflowInstance.WorkflowID); !exists {
+		return nil, fmt.Errorf("workflow %s not found", workflowInstance.WorkflowID)
+	}
+
+	// The wall time keeps counting while the instance is paused
+	workflowInstance.CompletedAt = nil
+	if reason, _ := ao.checkBudget(ctx, workflowInstance); reason != "" {
+		return nil, fmt.Errorf("workflow instance %s is still over budget: %s", instanceID, reason)
+	}
+
+	workflowInstance.Status = WorkflowStatusPending
+	workflowInstance.StatusReason = ""
+	ao.workflowInstances.Set(instanceID, workflowInstance)
+	ao.saveInstance(workflowInstance)
+	slog.Info("Resuming paused workflow instance", "instance_id", instanceID)
+	go ao.executeWorkflow(ctx, instanceID)
+	return &workflowInstance, nil
+}
+
+// isPaused reports whether the instance was paused while it was running
+func (ao *AgentOrchestrator) isPaused(instanceID string) bool {
+	workflowInstance, exists := ao.workflowInstances.Get(instanceID)
+	return exists && workflowInstance.Status == WorkflowStatusPaused
+}
@@ -0,0 +1,166 @@
+package agent
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nom-nom-hub/floss/internal/csync"
+	"github.com/nom-nom-hub/floss/internal/llm/agent"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBudgetExceeded(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		budget Budget
+		usage  WorkflowUsage
+		reason string
+	}{
+		{
+			name:   "no limits",
+			budget: Budget{},
+			usage:  WorkflowUsage{Cost: 100, Tokens: 1_000_000, Duration: time.Hour},
+		},
+		{
+			name:   "within budget",
+			budget: Budget{MaxCost: 2, MaxTokens: 1000, MaxDuration: "1h"},
+			usage:  WorkflowUsage{Cost: 1.5, Tokens: 999, Duration: time.Minute},
+		},
+		{
+			name:   "cost",
+			budget: Budget{MaxCost: 2, MaxTokens: 1000},
+			usage:  WorkflowUsage{Cost: 2.5, Tokens: 1500},
+			reason: "cost $2.50 reached max_cost $2.00",
+		},
+		{
+			name:   "tokens",
+			budget: Budget{MaxTokens: 1000},
+			usage:  WorkflowUsage{Tokens: 1000},
+			reason: "1000 tokens reached max_tokens 1000",
+		},
+		{
+			name:   "duration",
+			budget: Budget{MaxDuration: "30m"},
+			usage:  WorkflowUsage{Duration: 45*time.Minute + 500*time.Millisecond},
+			reason: "wall time 45m1s reached max_duration 30m0s",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			require.Equal(t, tt.reason, tt.budget.exceeded(tt.usage))
+		})
+	}
+}
+
+func TestBudgetValidate(t *testing.T) {
+	t.Parallel()
+
+	require.NoError(t, Budget{MaxCost: 5, MaxDuration: "2h", OnExceeded: BudgetActionCancel}.validate())
+	require.EqualError(t, Budget{MaxCost: -1}.validate(), "budget limits can't be negative")
+	require.EqualError(t, Budget{MaxDuration: "soon"}.validate(), `invalid budget max_duration "soon"`)
+	require.EqualError(t, Budget{OnExceeded: "stop"}.validate(), `invalid budget on_exceeded "stop", expected pause or cancel`)
+
+	err := ValidateWorkflow(AgentWorkflow{
+		ID:     "release",
+		Steps:  []WorkflowStep{{StepNumber: 1, ResponsibleAgent: AgentRoleDevOpsEngineer, Action: "Deploy"}},
+		Budget: &Budget{MaxDuration: "0s"},
+	})
+	require.EqualError(t, err, `workflow release: invalid budget max_duration "0s"`)
+}
+
+func TestAgentOrchestratorPausesWorkflowOverBudget(t *testing.T) {
+	t.Parallel()
+	ctx := t.Context()
+	services := newTestServices(t)
+
+	workflow := AgentWorkflow{
+		ID:   "feature",
+		Name: "Feature",
+		Steps: []WorkflowStep{
+			{StepNumber: 1, ResponsibleAgent: AgentRoleSeniorDeveloper, Action: "Implement"},
+			{StepNumber: 2, ResponsibleAgent: AgentRoleSeniorDeveloper, Action: "Refactor", Dependencies: []int{1}},
+		},
+		Budget: &Budget{MaxCost: 1},
+	}
+	developer := newFakeAgent(services.messages)
+	developer.sessionService = services.sessions
+	developer.cost = 1
+	developer.tokens = 500
+	agentServices := csync.NewMap[AgentRole, agent.Service]()
+	agentServices.Set(AgentRoleSeniorDeveloper, developer)
+
+	ao := services.orchestrator(agentServices)
+	require.NoError(t, ao.RegisterWorkflow(workflow))
+	instance, err := ao.StartWorkflow(ctx, workflow.ID, nil)
+	require.NoError(t, err)
+
+	paused := waitForStatus(t, ao, instance.ID, WorkflowStatusPaused)
+	require.Equal(t, "workflow budget exceeded: cost $1.00 reached max_cost $1.00", paused.StatusReason)
+	require.Equal(t, WorkflowStatusCompleted, paused.step(1).Status)
+	require.Equal(t, WorkflowStatusPending, paused.step(2).Status)
+	require.Len(t, developer.runSessions(), 1)
+
+	stored, err := ao.loadInstance(ctx, instance.ID)
+	require.NoError(t, err)
+	require.Equal(t, WorkflowStatusPaused, stored.Status)
+	require.Equal(t, paused.StatusReason, stored.StatusReason)
+
+	_, err = ao.ResumeWorkflow(ctx, instance.ID)
+	require.EqualError(t, err, "workflow instance "+instance.ID+" is still over budget: workflow budget exceeded: cost $1.00 reached max_cost $1.00")
+
+	// Raising the budget lets the instance finish
+	workflow.Budget = &Budget{MaxCost: 5}
+	require.NoError(t, ao.RegisterWorkflow(workflow))
+	_, err = ao.ResumeWorkflow(ctx, instance.ID)
+	require.NoError(t, err)
+
+	finished := waitForStatus(t, ao, instance.ID, WorkflowStatusCompleted)
+	require.Empty(t, finished.StatusReason)
+	require.Len(t, developer.runSessions(), 2)
+
+	usage, roles := ao.Usage(ctx, finished)
+	require.Equal(t, 2.0, usage.Cost)
+	require.Equal(t, int64(1000), usage.Tokens)
+	require.Equal(t, usage.Cost, roles[AgentRoleSeniorDeveloper].Cost)
+
+	_, err = ao.ResumeWorkflow(ctx, instance.ID)
+	require.EqualError(t, err, "workflow instance "+instance.ID+" is completed, not paused")
+}
+
+func TestAgentOrchestratorCancelsRoleOverBudget(t *testing.T) {
+	t.Parallel()
+	ctx := t.Context()
+	services := newTestServices(t)
+
+	workflow := AgentWorkflow{
+		ID:   "long",
+		Name: "Long",
+		Steps: []WorkflowStep{
+			{StepNumber: 1, ResponsibleAgent: AgentRoleSeniorDeveloper, Action: "Implement"},
+		},
+	}
+	developer := newFakeAgent(services.messages)
+	developer.release = make(chan struct{})
+	agentServices := csync.NewMap[AgentRole, agent.Service]()
+	agentServices.Set(AgentRoleSeniorDeveloper, developer)
+
+	ao := services.orchestrator(agentServices)
+	ao.pollInterval = 10 * time.Millisecond
+	ao.SetRoleBudgets(map[AgentRole]Budget{
+		AgentRoleSeniorDeveloper: {MaxDuration: "50ms", OnExceeded: BudgetActionCancel},
+	})
+	require.NoError(t, ao.RegisterWorkflow(workflow))
+	instance, err := ao.StartWorkflow(ctx, workflow.ID, nil)
+	require.NoError(t, err)
+
+	// The step never finishes on its own, so the budget watcher stops it
+	cancelled := waitForStatus(t, ao, instance.ID, WorkflowStatusCancelled)
+	require.Contains(t, cancelled.StatusReason, "budget of role senior_developer exceeded: wall time")
+	require.Eventually(t, func() bool { return developer.activeRuns() == 0 }, 5*time.Second, 10*time.Millisecond)
+
+	_, err = ao.ResumeWorkflow(ctx, instance.ID)
+	require.EqualError(t, err, "workflow instance "+instance.ID+" is cancelled, not paused")
+}
@@ -0,0 +1,159 @@
+package agent
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/invopop/jsonschema"
+	"gopkg.in/yaml.v3"
+)
+
+// WorkflowsDir is the directory below the data directory that holds
+// workflow files
+const WorkflowsDir = "workflows"
+
+// WorkflowFile is the content of a workflow file: a single workflow, written
+// as YAML or JSON
+type WorkflowFile struct {
+	Schema string `json:"$schema,omitempty" jsonschema:"description=The schema URI for validation"`
+	AgentWorkflow
+}
+
+// WorkflowSchema returns the JSON schema of workflow files
+func WorkflowSchema() *jsonschema.Schema {
+	reflector := &jsonschema.Reflector{RequiredFromJSONSchemaTags: true}
+	schema := reflector.Reflect(&WorkflowFile{})
+	schema.Title = "Floss Workflow"
+	schema.Description = "A workflow for the Floss agent system, stored in .floss/workflows."
+	return schema
+}
+
+// LoadWorkflowFiles reads every .yaml, .yml and .json workflow file in dir.
+// It returns the workflows and the file each came from, keyed by workflow ID.
+// A workflow without an ID takes the file name. A missing directory has no
+// workflows. Errors name the file and, where they concern a step, the step.
+func LoadWorkflowFiles(dir string) (map[string]AgentWorkflow, map[string]string, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read workflows directory: %w", err)
+	}
+
+	workflows := make(map[string]AgentWorkflow)
+	files := make(map[string]string)
+	for _, entry := range entries {
+		ext := filepath.Ext(entry.Name())
+		if entry.IsDir() || !slices.Contains([]string{".yaml", ".yml", ".json"}, ext) {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		workflow, err := loadWorkflowFile(path)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", path, err)
+		}
+		if workflow.ID == "" {
+			workflow.ID = strings.TrimSuffix(entry.Name(), ext)
+		}
+		if other, exists := files[workflow.ID]; exists {
+			return nil, nil, fmt.Errorf("%s: workflow %s is already defined in %s", path, workflow.ID, other)
+		}
+		if err := validateWorkflowFile(workflow); err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", path, err)
+		}
+		workflows[workflow.ID] = workflow
+		files[workflow.ID] = path
+	}
+	return workflows, files, nil
+}
+
+func loadWorkflowFile(path string) (AgentWorkflow, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return AgentWorkflow{}, err
+	}
+	if filepath.Ext(path) != ".json" {
+		// Go through JSON so both formats share the json tags and checks
+		var content any
+		if err := yaml.Unmarshal(data, &content); err != nil {
+			return AgentWorkflow{}, err
+		}
+		if data, err = json.Marshal(content); err != nil {
+			return AgentWorkflow{}, err
+		}
+	}
+
+	// Steps are decoded one by one so errors can name the step
+	var file struct {
+		Schema      string            `json:"$schema"`
+		ID          string            `json:"id"`
+		Name        string            `json:"name"`
+		Description string            `json:"description"`
+		Steps       []json.RawMessage `json:"steps"`
+		Budget      *Budget           `json:"budget"`
+	}
+	if err := decodeStrict(data, &file); err != nil {
+		return AgentWorkflow{}, err
+	}
+	workflow := AgentWorkflow{
+		ID:          file.ID,
+		Name:        file.Name,
+		Description: file.Description,
+		Steps:       make([]WorkflowStep, len(file.Steps)),
+		Budget:      file.Budget,
+	}
+	for i, data := range file.Steps {
+		if err := decodeStrict(data, &workflow.Steps[i]); err != nil {
+			return AgentWorkflow{}, fmt.Errorf("%s: %w", stepLabel(i, data), err)
+		}
+	}
+	return workflow, nil
+}
+
+// validateWorkflowFile checks what the schema requires and the workflow
+// graph. Whether the responsible roles exist is checked once the workflow is
+// merged into the configuration.
+func validateWorkflowFile(workflow AgentWorkflow) error {
+	if workflow.Name == "" {
+		return fmt.Errorf("workflow %s has no name", workflow.ID)
+	}
+	for i, step := range workflow.Steps {
+		label := fmt.Sprintf("step %d", step.StepNumber)
+		if step.StepNumber < 1 {
+			label = fmt.Sprintf("steps[%d]", i)
+		}
+		switch {
+		case step.StepNumber < 1:
+			return fmt.Errorf("workflow %s: %s: step_number must be at least 1", workflow.ID, label)
+		case step.Action == "":
+			return fmt.Errorf("workflow %s: %s: action is required", workflow.ID, label)
+		case step.ResponsibleAgent == "" && step.Type != StepTypeApproval:
+			return fmt.Errorf("workflow %s: %s: responsible_agent is required", workflow.ID, label)
+		}
+	}
+	return ValidateWorkflow(workflow)
+}
+
+func decodeStrict(data []byte, v any) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	return decoder.Decode(v)
+}
+
+// stepLabel names a step by its number, or by its position when the number
+// can't be read
+func stepLabel(i int, data json.RawMessage) string {
+	var step struct {
+		StepNumber int `json:"step_number"`
+	}
+	if json.Unmarshal(data, &step) == nil && step.StepNumber > 0 {
+		return fmt.Sprintf("step %d", step.StepNumber)
+	}
+	return fmt.Sprintf("steps[%d]", i)
+}
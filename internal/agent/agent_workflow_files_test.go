@@ -0,0 +1,279 @@
+package agent
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func writeWorkflowFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	workflowsDir := filepath.Join(dir, WorkflowsDir)
+	require.NoError(t, os.MkdirAll(workflowsDir, 0o755))
+	path := filepath.Join(workflowsDir, name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	return path
+}
+
+func TestLoadWorkflowFiles(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+
+	yamlPath := writeWorkflowFile(t, dir, "hotfix.yaml", `
+$schema: ../../workflow-schema.json
+name: Hotfix
+description: Ship a fix for a production bug
+budget:
+  max_cost: 2.5
+  max_duration: 1h
+  on_exceeded: cancel
+steps:
+  - step_number: 1
+    responsible_agent: senior_developer
+    action: Fix the bug
+    retry:
+      max_attempts: 2
+  - step_number: 2
+    responsible_agent: qa_engineer
+    action: Verify the fix
+    dependencies: [1]
+    conditions:
+      - if: fail
+        go_to: 1
+        max_times: 3
+  - step_number: 3
+    type: approval
+    action: Approve the release
+    dependencies: [2]
+`)
+	jsonPath := writeWorkflowFile(t, dir, "docs.json", `{
+  "id": "write_docs",
+  "name": "Write docs",
+  "budget": {"max_tokens": 100000},
+  "steps": [{"step_number": 1, "responsible_agent": "technical_writer", "action": "Write the docs"}]
+}`)
+	writeWorkflowFile(t, dir, "notes.txt", "not a workflow")
+
+	workflows, files, err := LoadWorkflowFiles(filepath.Join(dir, WorkflowsDir))
+	require.NoError(t, err)
+	require.Len(t, workflows, 2)
+	require.Equal(t, map[string]string{"hotfix": yamlPath, "write_docs": jsonPath}, files)
+
+	hotfix := workflows["hotfix"]
+	require.Equal(t, "hotfix", hotfix.ID)
+	require.Equal(t, "Ship a fix for a production bug", hotfix.Description)
+	require.Equal(t, &RetryPolicy{MaxAttempts: 2}, hotfix.Steps[0].Retry)
+	require.Equal(t, []StepCondition{{If: "fail", GoTo: 1, MaxTimes: 3}}, hotfix.Steps[1].Conditions)
+	require.Equal(t, StepTypeApproval, hotfix.Steps[2].Type)
+	require.Equal(t, &Budget{MaxCost: 2.5, MaxDuration: "1h", OnExceeded: BudgetActionCancel}, hotfix.Budget)
+	require.Equal(t, AgentRoleTechnicalWriter, workflows["write_docs"].Steps[0].ResponsibleAgent)
+	require.Equal(t, &Budget{MaxTokens: 100000}, workflows["write_docs"].Budget)
+
+	workflows, files, err = LoadWorkflowFiles(filepath.Join(dir, "missing"))
+	require.NoError(t, err)
+	require.Empty(t, workflows)
+	require.Empty(t, files)
+}
+
+func TestLoadWorkflowFilesErrors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		err     string
+	}{
+		{
+			name: "unknown step field",
+			file: "release.yaml",
+			content: `
+name: Release
+steps:
+  - step_number: 1
+    responsible_agent: devops_engineer
+    action: Deploy
+  - step_number: 2
+    responsible_agent: devops_engineer
+    action: Monitor
+    depends_on: [1]
+`,
+			err: `release.yaml: step 2: json: unknown field "depends_on"`,
+		},
+		{
+			name: "unknown retry field",
+			file: "release.yaml",
+			content: `
+name: Release
+steps:
+  - step_number: 4
+    responsible_agent: devops_engineer
+    action: Deploy
+    retry: {attempts: 3}
+`,
+			err: `release.yaml: step 4: json: unknown field "attempts"`,
+		},
+		{
+			name:    "unknown workflow field",
+			file:    "release.json",
+			content: `{"name": "Release", "stages": []}`,
+			err:     `release.json: json: unknown field "stages"`,
+		},
+		{
+			name: "unknown budget field",
+			file: "release.yaml",
+			content: `
+name: Release
+budget: {max_dollars: 5}
+steps:
+  - step_number: 1
+    responsible_agent: devops_engineer
+    action: Deploy
+`,
+			err: `release.yaml: json: unknown field "max_dollars"`,
+		},
+		{
+			name: "invalid budget",
+			file: "release.yaml",
+			content: `
+name: Release
+budget: {on_exceeded: stop}
+steps:
+  - step_number: 1
+    responsible_agent: devops_engineer
+    action: Deploy
+`,
+			err: `release.yaml: workflow release: invalid budget on_exceeded "stop", expected pause or cancel`,
+		},
+		{
+			name: "missing action",
+			file: "release.yml",
+			content: `
+name: Release
+steps:
+  - step_number: 3
+    responsible_agent: devops_engineer
+`,
+			err: "release.yml: workflow release: step 3: action is required",
+		},
+		{
+			name: "missing step number",
+			file: "release.yml",
+			content: `
+name: Release
+steps:
+  - action: Deploy
+    responsible_agent: devops_engineer
+`,
+			err: "release.yml: workflow release: steps[0]: step_number must be at least 1",
+		},
+		{
+			name: "invalid graph",
+			file: "release.yaml",
+			content: `
+name: Release
+steps:
+  - step_number: 1
+    responsible_agent: devops_engineer
+    action: Deploy
+    dependencies: [2]
+`,
+			err: "release.yaml: workflow release: step 1 depends on missing step 2",
+		},
+		{
+			name:    "invalid yaml",
+			file:    "release.yaml",
+			content: "name: [Release",
+			err:     "release.yaml: yaml:",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			dir := t.TempDir()
+			writeWorkflowFile(t, dir, tt.file, tt.content)
+			_, _, err := LoadWorkflowFiles(filepath.Join(dir, WorkflowsDir))
+			require.ErrorContains(t, err, filepath.Join(dir, WorkflowsDir, tt.err))
+		})
+	}
+}
+
+func TestLoadAgentSystemConfigMergesWorkflowFiles(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+
+	path := writeWorkflowFile(t, dir, "incident_response.yaml", `
+name: Short incident response
+steps:
+  - step_number: 1
+    responsible_agent: devops_engineer
+    action: Roll back
+`)
+	writeWorkflowFile(t, dir, "retro.yaml", `
+name: Retrospective
+steps:
+  - step_number: 1
+    responsible_agent: project_manager
+    action: Run the retrospective
+`)
+
+	config, err := LoadAgentSystemConfig(dir)
+	require.NoError(t, err)
+	require.Equal(t, "Short incident response", config.Workflows["incident_response"].Name)
+	require.Contains(t, config.Workflows, "retro")
+	require.Contains(t, config.Workflows, "product_development")
+	require.Equal(t, path, config.WorkflowFiles["incident_response"])
+
+	// Saving keeps workflow files out of agents.json
+	require.NoError(t, SaveAgentSystemConfig(config, dir))
+	saved, err := loadAgentSystemFile(filepath.Join(dir, "agents.json"))
+	require.NoError(t, err)
+	require.NotContains(t, saved.Workflows, "retro")
+	require.NotContains(t, saved.Workflows, "incident_response")
+	require.Contains(t, saved.Workflows, "product_development")
+	require.Contains(t, config.Workflows, "retro")
+
+	config, err = LoadAgentSystemConfig(dir)
+	require.NoError(t, err)
+	require.Equal(t, "Short incident response", config.Workflows["incident_response"].Name)
+	require.Contains(t, config.Workflows, "retro")
+}
+
+func TestLoadAgentSystemConfigRejectsUnknownRole(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+
+	path := writeWorkflowFile(t, dir, "launch.yaml", `
+name: Launch
+steps:
+  - step_number: 1
+    responsible_agent: product_manager
+    action: Plan the launch
+  - step_number: 2
+    responsible_agent: growth_hacker
+    action: Go viral
+    dependencies: [1]
+`)
+
+	_, err := LoadAgentSystemConfig(dir)
+	require.EqualError(t, err, "invalid workflow file: "+path+": workflow launch: step 2: unknown role growth_hacker")
+}
+
+func TestWorkflowSchema(t *testing.T) {
+	t.Parallel()
+
+	schema := WorkflowSchema()
+	file, ok := schema.Definitions["WorkflowFile"]
+	require.True(t, ok)
+	require.Equal(t, []string{"name", "steps"}, file.Required)
+	_, ok = file.Properties.Get("$schema")
+	require.True(t, ok)
+
+	step := schema.Definitions["WorkflowStep"]
+	require.Equal(t, []string{"step_number", "action"}, step.Required)
+	stepType, ok := step.Properties.Get("type")
+	require.True(t, ok)
+	require.Equal(t, []any{"agent", "approval"}, stepType.Enum)
+}
@@ -0,0 +1,305 @@
+package agent
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// workflowGraph is the dependency graph of a workflow. A step depends on
+// every step listed in its Dependencies and on every step that lists it in
+// NextSteps.
+type workflowGraph struct {
+	// dependencies maps a step number to the step numbers it waits for
+	dependencies map[int][]int
+	// order lists the step numbers in declaration order
+	order []int
+	// onFailure maps a step number to the step that runs when it fails
+	onFailure map[int]int
+	// fallbacks maps a fallback step to the steps it stands in for
+	fallbacks map[int][]int
+}
+
+// ValidateWorkflow checks that a workflow forms a valid DAG: step numbers
+// are unique, every dependency and next step refers to an existing step, and
+// there are no cycles. Step inputs may only reference upstream steps,
+// conditions may only go back to upstream steps and skip downstream ones, and
+// no step may depend on a fallback step. The budget, if any, must be valid
+// too.
+func ValidateWorkflow(workflow AgentWorkflow) error {
+	_, err := buildWorkflowGraph(workflow)
+	return err
+}
+
+func buildWorkflowGraph(workflow AgentWorkflow) (workflowGraph, error) {
+	graph := workflowGraph{
+		dependencies: make(map[int][]int, len(workflow.Steps)),
+		order:        make([]int, 0, len(workflow.Steps)),
+		onFailure:    make(map[int]int),
+		fallbacks:    make(map[int][]int),
+	}
+	if len(workflow.Steps) == 0 {
+		return graph, fmt.Errorf("workflow %s has no steps", workflow.ID)
+	}
+	if workflow.Budget != nil {
+		if err := workflow.Budget.validate(); err != nil {
+			return graph, fmt.Errorf("workflow %s: %w", workflow.ID, err)
+		}
+	}
+
+	for _, step := range workflow.Steps {
+		if _, exists := graph.dependencies[step.StepNumber]; exists {
+			return graph, fmt.Errorf("workflow %s: duplicate step number %d", workflow.ID, step.StepNumber)
+		}
+		graph.dependencies[step.StepNumber] = []int{}
+		graph.order = append(graph.order, step.StepNumber)
+	}
+
+	addEdge := func(from, to int) {
+		if !slices.Contains(graph.dependencies[to], from) {
+			graph.dependencies[to] = append(graph.dependencies[to], from)
+		}
+	}
+	for _, step := range workflow.Steps {
+		for _, dep := range step.Dependencies {
+			if _, exists := graph.dependencies[dep]; !exists {
+				return graph, fmt.Errorf("workflow %s: step %d depends on missing step %d", workflow.ID, step.StepNumber, dep)
+			}
+			addEdge(dep, step.StepNumber)
+		}
+		for _, next := range step.NextSteps {
+			if _, exists := graph.dependencies[next]; !exists {
+				return graph, fmt.Errorf("workflow %s: step %d is followed by missing step %d", workflow.ID, step.StepNumber, next)
+			}
+			addEdge(step.StepNumber, next)
+		}
+	}
+
+	if cycle := graph.findCycle(); cycle != nil {
+		parts := make([]string, len(cycle))
+		for i, stepNumber := range cycle {
+			parts[i] = fmt.Sprint(stepNumber)
+		}
+		return graph, fmt.Errorf("workflow %s: dependency cycle between steps %s", workflow.ID, strings.Join(parts, " -> "))
+	}
+
+	for _, step := range workflow.Steps {
+		if err := graph.validateBranches(step); err != nil {
+			return graph, fmt.Errorf("workflow %s: step %d: %w", workflow.ID, step.StepNumber, err)
+		}
+		if step.OnFailure != 0 {
+			graph.onFailure[step.StepNumber] = step.OnFailure
+			graph.fallbacks[step.OnFailure] = append(graph.fallbacks[step.OnFailure], step.StepNumber)
+		}
+	}
+	for _, stepNumber := range graph.order {
+		for _, dep := range graph.dependencies[stepNumber] {
+			if owners, isFallback := graph.fallbacks[dep]; isFallback {
+				return graph, fmt.Errorf("workflow %s: step %d depends on step %d, which only runs when step %d fails", workflow.ID, stepNumber, dep, owners[0])
+			}
+		}
+	}
+	for _, stepNumber := range graph.order {
+		seen := map[int]bool{stepNumber: true}
+		for next := graph.onFailure[stepNumber]; next != 0; next = graph.onFailure[next] {
+			if seen[next] {
+				return graph, fmt.Errorf("workflow %s: step %d falls back to itself through step %d", workflow.ID, stepNumber, next)
+			}
+			seen[next] = true
+		}
+	}
+
+	// Step inputs may only use the results of steps that finish before them
+	for _, step := range workflow.Steps {
+		variables, err := parseStepInput(step.Input)
+		if err != nil {
+			return graph, fmt.Errorf("workflow %s: step %d: %w", workflow.ID, step.StepNumber, err)
+		}
+		for _, variable := range variables {
+			if variable.contextPath != nil {
+				continue
+			}
+			if _, exists := graph.dependencies[variable.stepNumber]; !exists {
+				return graph, fmt.Errorf("workflow %s: step %d input references missing step %d", workflow.ID, step.StepNumber, variable.stepNumber)
+			}
+			if !graph.dependsOn(step.StepNumber, variable.stepNumber) {
+				return graph, fmt.Errorf("workflow %s: step %d input references step %d, which it does not depend on", workflow.ID, step.StepNumber, variable.stepNumber)
+			}
+		}
+	}
+	return graph, nil
+}
+
+// validateBranches checks the type, retry policy, fallback and conditions of
+// a step against the graph
+func (g workflowGraph) validateBranches(step WorkflowStep) error {
+	switch step.Type {
+	case "", StepTypeAgent:
+	case StepTypeApproval:
+		if step.Retry != nil {
+			return fmt.Errorf("approval steps can't be retried")
+		}
+	default:
+		return fmt.Errorf("unknown step type %q", step.Type)
+	}
+	if step.Retry != nil {
+		if err := step.Retry.validate(); err != nil {
+			return err
+		}
+	}
+	if step.OnFailure != 0 {
+		if _, exists := g.dependencies[step.OnFailure]; !exists {
+			return fmt.Errorf("falls back to missing step %d", step.OnFailure)
+		}
+		if step.OnFailure == step.StepNumber || g.dependsOn(step.OnFailure, step.StepNumber) {
+			return fmt.Errorf("falls back to step %d, which runs after it", step.OnFailure)
+		}
+	}
+	for _, condition := range step.Conditions {
+		if _, err := regexp.Compile("(?i)" + condition.If); err != nil {
+			return fmt.Errorf("invalid condition %q: %w", condition.If, err)
+		}
+		if (condition.GoTo == 0) == (len(condition.Skip) == 0) {
+			return fmt.Errorf("condition %q needs either go_to or skip", condition.If)
+		}
+		if condition.MaxTimes < 0 {
+			return fmt.Errorf("condition %q has a negative max_times", condition.If)
+		}
+		if condition.GoTo != 0 {
+			if _, exists := g.dependencies[condition.GoTo]; !exists {
+				return fmt.Errorf("condition %q goes to missing step %d", condition.If, condition.GoTo)
+			}
+			if condition.GoTo != step.StepNumber && !g.dependsOn(step.StepNumber, condition.GoTo) {
+				return fmt.Errorf("condition %q can only go back to step %d or a step before it, not step %d", condition.If, step.StepNumber, condition.GoTo)
+			}
+		}
+		for _, skip := range condition.Skip {
+			if _, exists := g.dependencies[skip]; !exists {
+				return fmt.Errorf("condition %q skips missing step %d", condition.If, skip)
+			}
+			if !g.dependsOn(skip, step.StepNumber) {
+				return fmt.Errorf("condition %q can only skip steps after step %d, not step %d", condition.If, step.StepNumber, skip)
+			}
+		}
+	}
+	return nil
+}
+
+// from returns, in declaration order, a step and every step that
+// transitively depends on it
+func (g workflowGraph) from(stepNumber int) []int {
+	var steps []int
+	for _, other := range g.order {
+		if other == stepNumber || g.dependsOn(other, stepNumber) {
+			steps = append(steps, other)
+		}
+	}
+	return steps
+}
+
+// dependsOn reports whether a step transitively depends on another step
+func (g workflowGraph) dependsOn(stepNumber, upstream int) bool {
+	seen := make(map[int]bool)
+	pending := slices.Clone(g.dependencies[stepNumber])
+	for len(pending) > 0 {
+		dep := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		if dep == upstream {
+			return true
+		}
+		if seen[dep] {
+			continue
+		}
+		seen[dep] = true
+		pending = append(pending, g.dependencies[dep]...)
+	}
+	return false
+}
+
+// findCycle returns the step numbers of a dependency cycle, or nil if the
+// graph is acyclic.
+func (g workflowGraph) findCycle() []int {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make(map[int]int, len(g.order))
+	var path []int
+
+	var visit func(stepNumber int) []int
+	visit = func(stepNumber int) []int {
+		switch state[stepNumber] {
+		case visiting:
+			start := slices.Index(path, stepNumber)
+			return append(slices.Clone(path[start:]), stepNumber)
+		case visited:
+			return nil
+		}
+		state[stepNumber] = visiting
+		path = append(path, stepNumber)
+		for _, dep := range g.dependencies[stepNumber] {
+			if cycle := visit(dep); cycle != nil {
+				return cycle
+			}
+		}
+		path = path[:len(path)-1]
+		state[stepNumber] = visited
+		return nil
+	}
+
+	for _, stepNumber := range g.order {
+		if cycle := visit(stepNumber); cycle != nil {
+			slices.Reverse(cycle)
+			return cycle
+		}
+	}
+	return nil
+}
+
+// readySteps returns, in declaration order, the pending steps whose
+// dependencies are all done. Fallback steps are only ready once a step they
+// stand in for has failed.
+func (g workflowGraph) readySteps(workflowInstance WorkflowInstance) []int {
+	status := make(map[int]WorkflowStatus, len(workflowInstance.Steps))
+	for _, step := range workflowInstance.Steps {
+		status[step.StepNumber] = step.Status
+	}
+
+	var ready []int
+	for _, stepNumber := range g.order {
+		if status[stepNumber] != WorkflowStatusPending {
+			continue
+		}
+		if owners, isFallback := g.fallbacks[stepNumber]; isFallback && !slices.ContainsFunc(owners, func(owner int) bool {
+			return status[owner] == WorkflowStatusFailed
+		}) {
+			continue
+		}
+		satisfied := true
+		for _, dep := range g.dependencies[stepNumber] {
+			if !g.done(status, dep) {
+				satisfied = false
+				break
+			}
+		}
+		if satisfied {
+			ready = append(ready, stepNumber)
+		}
+	}
+	return ready
+}
+
+// done reports whether a step no longer holds up the steps after it: it
+// completed, was skipped, or failed and its fallback is done
+func (g workflowGraph) done(status map[int]WorkflowStatus, stepNumber int) bool {
+	switch status[stepNumber] {
+	case WorkflowStatusCompleted, WorkflowStatusSkipped:
+		return true
+	case WorkflowStatusFailed:
+		fallback, exists := g.onFailure[stepNumber]
+		return exists && g.done(status, fallback)
+	}
+	return false
+}
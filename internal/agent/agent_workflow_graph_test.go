@@ -0,0 +1,277 @@
+package agent
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateWorkflow(t *testing.T) {
+	t.Parallel()
+
+	for id, workflow := range DefaultWorkflows() {
+		require.NoError(t, ValidateWorkflow(workflow), id)
+	}
+
+	tests := []struct {
+		name  string
+		steps []WorkflowStep
+		err   string
+	}{
+		{
+			name: "missing dependency",
+			steps: []WorkflowStep{
+				{StepNumber: 1},
+				{StepNumber: 2, Dependencies: []int{3}},
+			},
+			err: "step 2 depends on missing step 3",
+		},
+		{
+			name: "missing next step",
+			steps: []WorkflowStep{
+				{StepNumber: 1, NextSteps: []int{4}},
+			},
+			err: "step 1 is followed by missing step 4",
+		},
+		{
+			name: "duplicate step number",
+			steps: []WorkflowStep{
+				{StepNumber: 1},
+				{StepNumber: 1},
+			},
+			err: "duplicate step number 1",
+		},
+		{
+			name: "cycle through dependencies",
+			steps: []WorkflowStep{
+				{StepNumber: 1, Dependencies: []int{3}},
+				{StepNumber: 2, Dependencies: []int{1}},
+				{StepNumber: 3, Dependencies: []int{2}},
+			},
+			err: "dependency cycle",
+		},
+		{
+			name: "cycle through next steps",
+			steps: []WorkflowStep{
+				{StepNumber: 1, NextSteps: []int{2}},
+				{StepNumber: 2, NextSteps: []int{1}},
+			},
+			err: "dependency cycle",
+		},
+		{
+			name: "self dependency",
+			steps: []WorkflowStep{
+				{StepNumber: 1, Dependencies: []int{1}},
+			},
+			err: "dependency cycle between steps 1 -> 1",
+		},
+		{
+			name: "input references step that is not upstream",
+			steps: []WorkflowStep{
+				{StepNumber: 1},
+				{StepNumber: 2, Input: "{{steps.1.result}}"},
+			},
+			err: "step 2 input references step 1, which it does not depend on",
+		},
+		{
+			name: "input references missing step",
+			steps: []WorkflowStep{
+				{StepNumber: 1, Input: "{{steps.7.files}}"},
+			},
+			err: "step 1 input references missing step 7",
+		},
+		{
+			name: "input uses unknown step field",
+			steps: []WorkflowStep{
+				{StepNumber: 1},
+				{StepNumber: 2, Dependencies: []int{1}, Input: "{{steps.1.output}}"},
+			},
+			err: `unknown step field "output"`,
+		},
+		{
+			name: "input uses unknown variable",
+			steps: []WorkflowStep{
+				{StepNumber: 1, Input: "{{workflow.name}}"},
+			},
+			err: `unknown variable "workflow"`,
+		},
+		{
+			name: "unknown step type",
+			steps: []WorkflowStep{
+				{StepNumber: 1, Type: "manual"},
+			},
+			err: `step 1: unknown step type "manual"`,
+		},
+		{
+			name: "retry without attempts",
+			steps: []WorkflowStep{
+				{StepNumber: 1, Retry: &RetryPolicy{Backoff: "1s"}},
+			},
+			err: "retry needs at least 1 attempt",
+		},
+		{
+			name: "invalid retry backoff",
+			steps: []WorkflowStep{
+				{StepNumber: 1, Retry: &RetryPolicy{MaxAttempts: 2, Backoff: "soon"}},
+			},
+			err: `invalid retry backoff "soon"`,
+		},
+		{
+			name: "retried approval",
+			steps: []WorkflowStep{
+				{StepNumber: 1, Type: StepTypeApproval, Retry: &RetryPolicy{MaxAttempts: 2}},
+			},
+			err: "approval steps can't be retried",
+		},
+		{
+			name: "missing fallback",
+			steps: []WorkflowStep{
+				{StepNumber: 1, OnFailure: 2},
+			},
+			err: "step 1: falls back to missing step 2",
+		},
+		{
+			name: "fallback after the step",
+			steps: []WorkflowStep{
+				{StepNumber: 1, OnFailure: 2},
+				{StepNumber: 2, Dependencies: []int{1}},
+			},
+			err: "falls back to step 2, which runs after it",
+		},
+		{
+			name: "dependency on fallback",
+			steps: []WorkflowStep{
+				{StepNumber: 1, OnFailure: 2},
+				{StepNumber: 2},
+				{StepNumber: 3, Dependencies: []int{2}},
+			},
+			err: "step 3 depends on step 2, which only runs when step 1 fails",
+		},
+		{
+			name: "fallback loop",
+			steps: []WorkflowStep{
+				{StepNumber: 1, OnFailure: 2},
+				{StepNumber: 2, OnFailure: 1},
+			},
+			err: "falls back to itself",
+		},
+		{
+			name: "condition goes forward",
+			steps: []WorkflowStep{
+				{StepNumber: 1, Conditions: []StepCondition{{If: "fail", GoTo: 2}}},
+				{StepNumber: 2, Dependencies: []int{1}},
+			},
+			err: `condition "fail" can only go back to step 1 or a step before it, not step 2`,
+		},
+		{
+			name: "condition skips earlier step",
+			steps: []WorkflowStep{
+				{StepNumber: 1},
+				{StepNumber: 2, Dependencies: []int{1}, Conditions: []StepCondition{{If: "done", Skip: []int{1}}}},
+			},
+			err: `condition "done" can only skip steps after step 2, not step 1`,
+		},
+		{
+			name: "condition without branch",
+			steps: []WorkflowStep{
+				{StepNumber: 1, Conditions: []StepCondition{{If: "fail"}}},
+			},
+			err: `condition "fail" needs either go_to or skip`,
+		},
+		{
+			name: "invalid condition",
+			steps: []WorkflowStep{
+				{StepNumber: 1, Conditions: []StepCondition{{If: "(", GoTo: 1}}},
+			},
+			err: `invalid condition "("`,
+		},
+		{
+			name: "no steps",
+			err:  "has no steps",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateWorkflow(AgentWorkflow{ID: "test", Steps: tt.steps})
+			require.ErrorContains(t, err, tt.err)
+		})
+	}
+}
+
+func TestValidateWorkflowAcceptsTransitiveInputReferences(t *testing.T) {
+	t.Parallel()
+
+	err := ValidateWorkflow(AgentWorkflow{
+		ID: "test",
+		Steps: []WorkflowStep{
+			{StepNumber: 1, NextSteps: []int{2}},
+			{StepNumber: 2},
+			{StepNumber: 3, Dependencies: []int{2}, Input: "{{steps.1.result}} {{steps.2.files}} {{context.anything}}"},
+		},
+	})
+	require.NoError(t, err)
+}
+
+func TestWorkflowGraphReadySteps(t *testing.T) {
+	t.Parallel()
+
+	graph, err := buildWorkflowGraph(AgentWorkflow{
+		ID: "test",
+		Steps: []WorkflowStep{
+			{StepNumber: 1, NextSteps: []int{3}},
+			{StepNumber: 2},
+			{StepNumber: 3, Dependencies: []int{2}},
+			{StepNumber: 4, Dependencies: []int{1}},
+		},
+	})
+	require.NoError(t, err)
+
+	instance := WorkflowInstance{Steps: []WorkflowStepInstance{
+		{StepNumber: 1, Status: WorkflowStatusPending},
+		{StepNumber: 2, Status: WorkflowStatusPending},
+		{StepNumber: 3, Status: WorkflowStatusPending},
+		{StepNumber: 4, Status: WorkflowStatusPending},
+	}}
+	require.Equal(t, []int{1, 2}, graph.readySteps(instance))
+
+	instance.Steps[0].Status = WorkflowStatusCompleted
+	instance.Steps[1].Status = WorkflowStatusRunning
+	require.Equal(t, []int{4}, graph.readySteps(instance))
+
+	instance.Steps[1].Status = WorkflowStatusCompleted
+	require.Equal(t, []int{3, 4}, graph.readySteps(instance))
+}
+
+func TestWorkflowGraphReadyStepsWithFallback(t *testing.T) {
+	t.Parallel()
+
+	graph, err := buildWorkflowGraph(AgentWorkflow{
+		ID: "test",
+		Steps: []WorkflowStep{
+			{StepNumber: 1, OnFailure: 3},
+			{StepNumber: 2, Dependencies: []int{1}},
+			{StepNumber: 3},
+			{StepNumber: 4, Dependencies: []int{2}},
+		},
+	})
+	require.NoError(t, err)
+
+	instance := WorkflowInstance{Steps: []WorkflowStepInstance{
+		{StepNumber: 1, Status: WorkflowStatusPending},
+		{StepNumber: 2, Status: WorkflowStatusPending},
+		{StepNumber: 3, Status: WorkflowStatusPending},
+		{StepNumber: 4, Status: WorkflowStatusPending},
+	}}
+	// The fallback only runs once the step it stands in for failed
+	require.Equal(t, []int{1}, graph.readySteps(instance))
+
+	instance.Steps[0].Status = WorkflowStatusFailed
+	require.Equal(t, []int{3}, graph.readySteps(instance))
+
+	instance.Steps[2].Status = WorkflowStatusCompleted
+	require.Equal(t, []int{2}, graph.readySteps(instance))
+
+	// Skipped steps don't hold up the steps after them
+	instance.Steps[1].Status = WorkflowStatusSkipped
+	require.Equal(t, []int{4}, graph.readySteps(instance))
+}
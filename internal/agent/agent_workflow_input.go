@@ -0,0 +1,207 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Step inputs are templates that can reference the outcome of earlier steps
+// and the values the instance was started with:
+//
+//	{{steps.N.result}}  the final report of step N
+//	{{steps.N.files}}   the files changed in step N's session, one per line
+//	{{context.key}}     a value from the instance context; nested maps are
+//	                    reached with further dots, e.g. {{context.repo.branch}}
+//
+// Steps referenced this way must be upstream of the step using them.
+var stepInputVariablePattern = regexp.MustCompile(`\{\{\s*([A-Za-z0-9_.\-]+)\s*\}\}`)
+
+const (
+	stepFieldResult = "result"
+	stepFieldFiles  = "files"
+)
+
+// stepInputVariable is a parsed template variable
+type stepInputVariable struct {
+	raw string
+	// stepNumber and field are set for steps.N.field variables
+	stepNumber int
+	field      string
+	// contextPath is set for context.key variables
+	contextPath []string
+}
+
+// parseStepInput returns the variables referenced by a step input template
+func parseStepInput(input string) ([]stepInputVariable, error) {
+	var variables []stepInputVariable
+	for _, match := range stepInputVariablePattern.FindAllStringSubmatch(input, -1) {
+		parts := strings.Split(match[1], ".")
+		variable := stepInputVariable{raw: match[0]}
+		switch parts[0] {
+		case "steps":
+			if len(parts) != 3 {
+				return nil, fmt.Errorf("invalid variable %s: expected {{steps.N.result}} or {{steps.N.files}}", match[0])
+			}
+			stepNumber, err := strconv.Atoi(parts[1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid variable %s: %q is not a step number", match[0], parts[1])
+			}
+			if parts[2] != stepFieldResult && parts[2] != stepFieldFiles {
+				return nil, fmt.Errorf("invalid variable %s: unknown step field %q", match[0], parts[2])
+			}
+			variable.stepNumber = stepNumber
+			variable.field = parts[2]
+		case "context":
+			if len(parts) < 2 || slices.Contains(parts[1:], "") {
+				return nil, fmt.Errorf("invalid variable %s: expected {{context.key}}", match[0])
+			}
+			variable.contextPath = parts[1:]
+		default:
+			return nil, fmt.Errorf("invalid variable %s: unknown variable %q", match[0], parts[0])
+		}
+		variables = append(variables, variable)
+	}
+	return variables, nil
+}
+
+// renderStepInput replaces the variables in a step input with values from the
+// workflow instance
+func (ao *AgentOrchestrator) renderStepInput(ctx context.Context, input string, workflowInstance WorkflowInstance) (string, error) {
+	variables, err := parseStepInput(input)
+	if err != nil {
+		return "", err
+	}
+
+	values := make(map[string]string, len(variables))
+	for _, variable := range variables {
+		if _, done := values[variable.raw]; done {
+			continue
+		}
+		var value string
+		if variable.contextPath != nil {
+			value, err = lookupContextValue(workflowInstance.Context, variable.contextPath)
+		} else {
+			value, err = ao.stepValue(ctx, workflowInstance, variable.stepNumber, variable.field)
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to resolve %s: %w", variable.raw, err)
+		}
+		values[variable.raw] = value
+	}
+
+	return stepInputVariablePattern.ReplaceAllStringFunc(input, func(raw string) string {
+		return values[raw]
+	}), nil
+}
+
+func (ao *AgentOrchestrator) stepValue(ctx context.Context, workflowInstance WorkflowInstance, stepNumber int, field string) (string, error) {
+	stepInstance := workflowInstance.step(stepNumber)
+	if stepInstance == nil {
+		return "", fmt.Errorf("step %d not found", stepNumber)
+	}
+	if stepInstance.Status != WorkflowStatusCompleted {
+		return "", fmt.Errorf("step %d has not completed", stepNumber)
+	}
+
+	switch field {
+	case stepFieldResult:
+		return stepInstance.Result, nil
+	case stepFieldFiles:
+		files, err := ao.changedFiles(ctx, stepInstance.SessionID)
+		if err != nil {
+			return "", err
+		}
+		if len(files) == 0 {
+			return "(no files changed)", nil
+		}
+		return strings.Join(files, "\n"), nil
+	}
+	return "", fmt.Errorf("unknown step field %q", field)
+}
+
+// changedFiles lists the paths of the files changed in a session
+func (ao *AgentOrchestrator) changedFiles(ctx context.Context, sessionID string) ([]string, error) {
+	if sessionID == "" {
+		return nil, nil
+	}
+	files, err := ao.historyService.ListBySession(ctx, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list changed files: %w", err)
+	}
+	paths := make(map[string]struct{}, len(files))
+	for _, file := range files {
+		paths[file.Path] = struct{}{}
+	}
+	return slices.Sorted(maps.Keys(paths)), nil
+}
+
+func lookupContextValue(contextData map[string]interface{}, path []string) (string, error) {
+	var value interface{} = contextData
+	for _, key := range path {
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return "", fmt.Errorf("context value %q not found", strings.Join(path, "."))
+		}
+		value, ok = m[key]
+		if !ok {
+			return "", fmt.Errorf("context value %q not found", strings.Join(path, "."))
+		}
+	}
+	return fmt.Sprint(value), nil
+}
+
+// buildStepPrompt prepares the prompt sent to the agent responsible for a
+// step. Without an explicit input, the results of the step's direct
+// dependencies are handed over so every role sees the work it builds on. A
+// step that runs again because a later step sent the workflow back, or in
+// place of a failed step, also gets that step's feedback.
+func (ao *AgentOrchestrator) buildStepPrompt(ctx context.Context, workflowInstance WorkflowInstance, step WorkflowStep, dependencies []int) (string, error) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Workflow Step: %s\n\nAction: %s\n\n", workflowInstance.Name, step.Action)
+	if step.ExpectedOutput != "" {
+		fmt.Fprintf(&b, "Expected Output: %s\n\n", step.ExpectedOutput)
+	}
+
+	if step.Input != "" {
+		input, err := ao.renderStepInput(ctx, step.Input, workflowInstance)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&b, "Input:\n%s\n\n", input)
+	} else {
+		for _, dep := range dependencies {
+			depInstance := workflowInstance.step(dep)
+			if depInstance == nil || depInstance.Status != WorkflowStatusCompleted {
+				continue
+			}
+			fmt.Fprintf(&b, "Result of step %d (%s, %s):\n%s\n\n", dep, depInstance.Action, depInstance.ResponsibleAgent, depInstance.Result)
+			files, err := ao.changedFiles(ctx, depInstance.SessionID)
+			if err != nil {
+				return "", err
+			}
+			if len(files) > 0 {
+				fmt.Fprintf(&b, "Files changed in step %d:\n%s\n\n", dep, strings.Join(files, "\n"))
+			}
+		}
+	}
+
+	if stepInstance := workflowInstance.step(step.StepNumber); stepInstance != nil && stepInstance.Feedback != "" {
+		fmt.Fprintf(&b, "Feedback:\n%s\n\n", stepInstance.Feedback)
+	}
+
+	if len(workflowInstance.Context) > 0 {
+		b.WriteString("Context:\n")
+		for _, key := range slices.Sorted(maps.Keys(workflowInstance.Context)) {
+			fmt.Fprintf(&b, "- %s: %v\n", key, workflowInstance.Context[key])
+		}
+		b.WriteString("\n")
+	}
+
+	b.WriteString("Please complete this step and provide a detailed report of your work.")
+	return b.String(), nil
+}
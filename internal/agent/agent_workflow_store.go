@@ -0,0 +1,296 @@
+package agent
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/nom-nom-hub/floss/internal/db"
+	"github.com/nom-nom-hub/floss/internal/pubsub"
+)
+
+// ResumeWorkflows reloads every workflow instance that was still pending or
+// running when floss last exited and continues each one from its last
+// completed step. Steps that already have a session keep using it. Instances
+// another floss process is running are left to it.
+func (ao *AgentOrchestrator) ResumeWorkflows(ctx context.Context) (int, error) {
+	dbInstances, err := ao.q.ListUnfinishedWorkflowInstances(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list unfinished workflow instances: %w", err)
+	}
+
+	resumed := 0
+	for _, dbInstance := range dbInstances {
+		if _, running := ao.workflowInstances.Get(dbInstance.ID); running {
+			continue
+		}
+		claimed, err := ao.claimInstance(ctx, dbInstance.ID)
+		if err != nil {
+			slog.Error("Failed to claim workflow instance", "instance_id", dbInstance.ID, "error", err)
+			continue
+		}
+		if !claimed {
+			slog.Debug("Workflow instance is run by another floss process", "instance_id", dbInstance.ID)
+			continue
+		}
+		workflowInstance, err := ao.fromDBItem(ctx, dbInstance)
+		if err != nil {
+			slog.Error("Failed to load workflow instance", "instance_id", dbInstance.ID, "error", err)
+			continue
+		}
+		if _, exists := ao.workflows.Get(workflowInstance.WorkflowID); !exists {
+			slog.Warn("Workflow template for unfinished instance not found", "instance_id", workflowInstance.ID, "workflow_id", workflowInstance.WorkflowID)
+			workflowInstance.Status = WorkflowStatusFailed
+			now := time.Now()
+			workflowInstance.CompletedAt = &now
+			ao.saveInstance(workflowInstance)
+			ao.releaseInstance(workflowInstance.ID)
+			continue
+		}
+
+		ao.workflowInstances.Set(workflowInstance.ID, workflowInstance)
+		slog.Info("Resuming workflow instance", "instance_id", workflowInstance.ID, "workflow_id", workflowInstance.WorkflowID)
+		go ao.executeWorkflow(ctx, workflowInstance.ID)
+		resumed++
+	}
+	return resumed, nil
+}
+
+// claimInstance takes the lease on a workflow instance for this orchestrator,
+// or renews it. It fails while another floss process holds an unexpired one.
+func (ao *AgentOrchestrator) claimInstance(ctx context.Context, instanceID string) (bool, error) {
+	now := time.Now()
+	claimed, err := ao.q.ClaimWorkflowInstance(ctx, db.ClaimWorkflowInstanceParams{
+		Owner:     ao.leaseOwner,
+		ExpiresAt: now.Add(ao.leaseDuration).Unix(),
+		ID:        instanceID,
+		Now:       now.Unix(),
+	})
+	return claimed > 0, err
+}
+
+// releaseInstance gives up the lease on a workflow instance, so it can be
+// resumed by another floss process right away
+func (ao *AgentOrchestrator) releaseInstance(instanceID string) {
+	err := ao.q.ReleaseWorkflowInstance(context.Background(), db.ReleaseWorkflowInstanceParams{
+		ID:         instanceID,
+		LeaseOwner: ao.leaseOwner,
+	})
+	if err != nil {
+		slog.Error("Failed to release workflow instance", "instance_id", instanceID, "error", err)
+	}
+}
+
+// holdLease renews the lease on a running workflow instance, and calls
+// lost when another floss process took the instance over
+func (ao *AgentOrchestrator) holdLease(ctx context.Context, instanceID string, lost func()) {
+	ticker := time.NewTicker(ao.leaseDuration / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			claimed, err := ao.claimInstance(ctx, instanceID)
+			if err != nil {
+				slog.Warn("Failed to renew workflow instance lease", "instance_id", instanceID, "error", err)
+				continue
+			}
+			if !claimed {
+				slog.Error("Workflow instance was taken over by another floss process", "instance_id", instanceID)
+				lost()
+				return
+			}
+		}
+	}
+}
+
+// createInstance stores a new workflow instance and its steps
+func (ao *AgentOrchestrator) createInstance(ctx context.Context, workflowInstance WorkflowInstance) error {
+	contextData, err := marshalWorkflowContext(workflowInstance.Context)
+	if err != nil {
+		return err
+	}
+	_, err = ao.q.CreateWorkflowInstance(ctx, db.CreateWorkflowInstanceParams{
+		ID:          workflowInstance.ID,
+		WorkflowID:  workflowInstance.WorkflowID,
+		Name:        workflowInstance.Name,
+		Description: workflowInstance.Description,
+		Status:      string(workflowInstance.Status),
+		CurrentStep: int64(workflowInstance.CurrentStep),
+		SessionID:   workflowInstance.SessionID,
+		Context:     contextData,
+		StartedAt:   toNullUnix(workflowInstance.StartedAt),
+		CompletedAt: toNullUnix(workflowInstance.CompletedAt),
+		CreatedAt:   workflowInstance.CreatedAt.Unix(),
+	})
+	if err != nil {
+		return err
+	}
+	for _, step := range workflowInstance.Steps {
+		if err := ao.q.UpsertWorkflowStep(ctx, toDBStep(workflowInstance.ID, step)); err != nil {
+			return err
+		}
+	}
+	ao.workflowInstances.Set(workflowInstance.ID, workflowInstance)
+	ao.Publish(pubsub.CreatedEvent, workflowInstance)
+	return nil
+}
+
+// saveInstance updates the in-memory copy of a workflow instance and writes
+// it through to the database. A cancellation recorded in the meantime is
+// never overwritten by a stale copy from a running executor.
+func (ao *AgentOrchestrator) saveInstance(workflowInstance WorkflowInstance) {
+	if current, exists := ao.workflowInstances.Get(workflowInstance.ID); exists &&
+		current.Status == WorkflowStatusCancelled && workflowInstance.Status != WorkflowStatusCancelled {
+		workflowInstance.Status = current.Status
+		workflowInstance.CompletedAt = current.CompletedAt
+	}
+	ao.workflowInstances.Set(workflowInstance.ID, workflowInstance)
+	ao.Publish(pubsub.UpdatedEvent, workflowInstance)
+
+	// Persist even if the caller's context is already cancelled
+	ctx := context.Background()
+	contextData, err := marshalWorkflowContext(workflowInstance.Context)
+	if err != nil {
+		slog.Error("Failed to marshal workflow context", "instance_id", workflowInstance.ID, "error", err)
+		return
+	}
+	err = ao.q.UpdateWorkflowInstance(ctx, db.UpdateWorkflowInstanceParams{
+		ID:           workflowInstance.ID,
+		Status:       string(workflowInstance.Status),
+		CurrentStep:  int64(workflowInstance.CurrentStep),
+		Context:      contextData,
+		StartedAt:    toNullUnix(workflowInstance.StartedAt),
+		CompletedAt:  toNullUnix(workflowInstance.CompletedAt),
+		StatusReason: workflowInstance.StatusReason,
+	})
+	if err != nil {
+		slog.Error("Failed to persist workflow instance", "instance_id", workflowInstance.ID, "error", err)
+		return
+	}
+	for _, step := range workflowInstance.Steps {
+		if err := ao.q.UpsertWorkflowStep(ctx, toDBStep(workflowInstance.ID, step)); err != nil {
+			slog.Error("Failed to persist workflow step", "instance_id", workflowInstance.ID, "step", step.StepNumber, "error", err)
+		}
+	}
+}
+
+// loadInstance reads a single workflow instance from the database
+func (ao *AgentOrchestrator) loadInstance(ctx context.Context, instanceID string) (WorkflowInstance, error) {
+	dbInstance, err := ao.q.GetWorkflowInstance(ctx, instanceID)
+	if err != nil {
+		return WorkflowInstance{}, err
+	}
+	return ao.fromDBItem(ctx, dbInstance)
+}
+
+// loadInstances reads every stored workflow instance, newest first
+func (ao *AgentOrchestrator) loadInstances(ctx context.Context) ([]WorkflowInstance, error) {
+	dbInstances, err := ao.q.ListWorkflowInstances(ctx)
+	if err != nil {
+		return nil, err
+	}
+	instances := make([]WorkflowInstance, 0, len(dbInstances))
+	for _, dbInstance := range dbInstances {
+		workflowInstance, err := ao.fromDBItem(ctx, dbInstance)
+		if err != nil {
+			return nil, err
+		}
+		instances = append(instances, workflowInstance)
+	}
+	return instances, nil
+}
+
+func (ao *AgentOrchestrator) fromDBItem(ctx context.Context, item db.WorkflowInstance) (WorkflowInstance, error) {
+	dbSteps, err := ao.q.ListWorkflowSteps(ctx, item.ID)
+	if err != nil {
+		return WorkflowInstance{}, fmt.Errorf("failed to list workflow steps: %w", err)
+	}
+	var contextData map[string]interface{}
+	if item.Context != "" {
+		if err := json.Unmarshal([]byte(item.Context), &contextData); err != nil {
+			return WorkflowInstance{}, fmt.Errorf("failed to unmarshal workflow context: %w", err)
+		}
+	}
+
+	workflowInstance := WorkflowInstance{
+		ID:           item.ID,
+		WorkflowID:   item.WorkflowID,
+		Name:         item.Name,
+		Description:  item.Description,
+		Status:       WorkflowStatus(item.Status),
+		CurrentStep:  int(item.CurrentStep),
+		Steps:        make([]WorkflowStepInstance, len(dbSteps)),
+		CreatedAt:    time.Unix(item.CreatedAt, 0),
+		StartedAt:    fromNullUnix(item.StartedAt),
+		CompletedAt:  fromNullUnix(item.CompletedAt),
+		SessionID:    item.SessionID,
+		Context:      contextData,
+		StatusReason: item.StatusReason,
+	}
+	for i, step := range dbSteps {
+		workflowInstance.Steps[i] = WorkflowStepInstance{
+			StepNumber:       int(step.StepNumber),
+			ResponsibleAgent: AgentRole(step.ResponsibleAgent),
+			Action:           step.Action,
+			Status:           WorkflowStatus(step.Status),
+			StartedAt:        fromNullUnix(step.StartedAt),
+			CompletedAt:      fromNullUnix(step.CompletedAt),
+			Result:           step.Result,
+			Error:            step.Error,
+			SessionID:        step.SessionID,
+			Attempts:         int(step.Attempts),
+			Loops:            int(step.Loops),
+			Feedback:         step.Feedback,
+		}
+	}
+	return workflowInstance, nil
+}
+
+func toDBStep(instanceID string, step WorkflowStepInstance) db.UpsertWorkflowStepParams {
+	return db.UpsertWorkflowStepParams{
+		InstanceID:       instanceID,
+		StepNumber:       int64(step.StepNumber),
+		ResponsibleAgent: string(step.ResponsibleAgent),
+		Action:           step.Action,
+		Status:           string(step.Status),
+		Result:           step.Result,
+		Error:            step.Error,
+		SessionID:        step.SessionID,
+		StartedAt:        toNullUnix(step.StartedAt),
+		CompletedAt:      toNullUnix(step.CompletedAt),
+		Attempts:         int64(step.Attempts),
+		Loops:            int64(step.Loops),
+		Feedback:         step.Feedback,
+	}
+}
+
+func marshalWorkflowContext(contextData map[string]interface{}) (string, error) {
+	if contextData == nil {
+		return "{}", nil
+	}
+	data, err := json.Marshal(contextData)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal workflow context: %w", err)
+	}
+	return string(data), nil
+}
+
+func toNullUnix(t *time.Time) sql.NullInt64 {
+	if t == nil {
+		return sql.NullInt64{}
+	}
+	return sql.NullInt64{Int64: t.Unix(), Valid: true}
+}
+
+func fromNullUnix(v sql.NullInt64) *time.Time {
+	if !v.Valid {
+		return nil
+	}
+	t := time.Unix(v.Int64, 0)
+	return &t
+}
@@ -6,10 +6,13 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"os"
 	"sync"
 	"time"
 
 	tea "github.com/charmbracelet/bubbletea/v2"
+	agentsystem "github.com/nom-nom-hub/floss/internal/agent"
+	"github.com/nom-nom-hub/floss/internal/checkpoint"
 	"github.com/nom-nom-hub/floss/internal/config"
 	"github.com/nom-nom-hub/floss/internal/csync"
 	"github.com/nom-nom-hub/floss/internal/db"
@@ -29,10 +32,15 @@ type App struct {
 	Sessions    session.Service
 	Messages    message.Service
 	History     history.Service
+	Checkpoints checkpoint.Service
 	Permissions permission.Service
 
 	CoderAgent agent.Service
 
+	// Orchestrator runs the virtual company workflows. It is nil when the
+	// agent system is disabled or could not be initialized.
+	Orchestrator *agentsystem.AgentOrchestrator
+
 	LSPClients *csync.Map[string, *lsp.Client]
 
 	config *config.Config
@@ -63,6 +71,7 @@ func New(ctx context.Context, conn *sql.DB, cfg *config.Config) (*App, error) {
 		Sessions:    sessions,
 		Messages:    messages,
 		History:     files,
+		Checkpoints: checkpoint.NewService(q, sessions, messages, files),
 		Permissions: permission.NewPermissionService(cfg.WorkingDir(), skipPermissionsRequests, allowedTools),
 		LSPClients:  csync.NewMap[string, *lsp.Client](),
 
@@ -88,6 +97,7 @@ func New(ctx context.Context, conn *sql.DB, cfg *config.Config) (*App, error) {
 		if err := app.InitCoderAgent(); err != nil {
 			return nil, fmt.Errorf("failed to initialize coder agent: %w", err)
 		}
+		app.initAgentSystem(q)
 	} else {
 		slog.Warn("No agent configuration found")
 	}
@@ -100,13 +110,16 @@ func (app *App) Config() *config.Config {
 }
 
 // RunNonInteractive handles the execution flow when a prompt is provided via
-// CLI flag.
-func (app *App) RunNonInteractive(ctx context.Context, prompt string, quiet bool) error {
-	slog.Info("Running in non-interactive mode")
+// CLI flag. Outputs other than text are printed as JSON.
+func (app *App) RunNonInteractive(ctx context.Context, prompt string, quiet bool, outputFormat OutputFormat) error {
+	slog.Info("Running in non-interactive mode", "output_format", outputFormat)
 
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
+	// The spinner would get in the way of structured output
+	quiet = quiet || outputFormat != OutputFormatText
+
 	// Start spinner if not in quiet mode.
 	var spinner *format.Spinner
 	if !quiet {
@@ -143,7 +156,12 @@ func (app *App) RunNonInteractive(ctx context.Context, prompt string, quiet bool
 	// Automatically approve all permission requests for this non-interactive session
 	app.Permissions.AutoApproveSession(sess.ID)
 
-	done, err := app.CoderAgent.Run(ctx, sess.ID, prompt)
+	if outputFormat != OutputFormatText {
+		return app.runStructured(ctx, sess.ID, prompt, outputFormat)
+	}
+
+	// Nobody can confirm going over budget
+	done, err := app.CoderAgent.Run(agent.WithNonInteractive(ctx), sess.ID, prompt)
 	if err != nil {
 		return fmt.Errorf("failed to start agent processing stream: %w", err)
 	}
@@ -202,6 +220,96 @@ func (app *App) RunNonInteractive(ctx context.Context, prompt string, quiet bool
 	}
 }
 
+// runStructured runs the prompt in the session, printing its output in the
+// format as JSON
+func (app *App) runStructured(ctx context.Context, sessionID, prompt string, outputFormat OutputFormat) error {
+	out := newRunOutput(os.Stdout, outputFormat, sessionID)
+
+	// Subscribe before running to miss none of the events
+	messageEvents := app.Messages.Subscribe(ctx)
+	permissionEvents := app.Permissions.SubscribeNotifications(ctx)
+	sessionEvents := app.Sessions.Subscribe(ctx)
+	reconcile := func() error {
+		msgs, err := app.Messages.List(ctx, sessionID)
+		if err != nil {
+			return fmt.Errorf("failed to list messages: %w", err)
+		}
+		return out.reconcile(msgs)
+	}
+	handle := func(event any) error {
+		switch event := event.(type) {
+		case pubsub.Event[message.Message]:
+			if err := out.message(event.Payload); err != nil {
+				return err
+			}
+			if event.Payload.SessionID == sessionID && event.Payload.IsFinished() {
+				return reconcile()
+			}
+		case pubsub.Event[permission.PermissionNotification]:
+			return out.permission(event.Payload)
+		case pubsub.Event[session.Session]:
+			return out.session(event.Payload)
+		}
+		return nil
+	}
+
+	// Nobody can confirm going over budget
+	done, err := app.CoderAgent.Run(agent.WithNonInteractive(ctx), sessionID, prompt)
+	if err != nil {
+		err = fmt.Errorf("failed to start agent processing stream: %w", err)
+		return errors.Join(err, out.result(RunStatusError, "", err))
+	}
+
+	for {
+		var event any
+		select {
+		case result := <-done:
+			// Print what the run published before it finished
+			for len(messageEvents) > 0 || len(permissionEvents) > 0 || len(sessionEvents) > 0 {
+				select {
+				case event = <-messageEvents:
+				case event = <-permissionEvents:
+				case event = <-sessionEvents:
+				}
+				if err := handle(event); err != nil {
+					return err
+				}
+			}
+			if err := reconcile(); err != nil {
+				return err
+			}
+			if err := out.message(result.Message); err != nil {
+				return err
+			}
+			if sess, err := app.Sessions.Get(ctx, sessionID); err == nil {
+				if err := out.session(sess); err != nil {
+					return err
+				}
+			}
+
+			switch {
+			case result.Error == nil:
+				slog.Info("Non-interactive: run completed", "session_id", sessionID)
+				return out.result(RunStatusSuccess, result.Message.Content().Text, nil)
+			case errors.Is(result.Error, context.Canceled) || errors.Is(result.Error, agent.ErrRequestCancelled):
+				slog.Info("Non-interactive: agent processing cancelled", "session_id", sessionID)
+				return out.result(RunStatusCancelled, "", result.Error)
+			default:
+				err := fmt.Errorf("agent processing failed: %w", result.Error)
+				return errors.Join(err, out.result(RunStatusError, "", err))
+			}
+		case event = <-messageEvents:
+		case event = <-permissionEvents:
+		case event = <-sessionEvents:
+		case <-ctx.Done():
+			return errors.Join(ctx.Err(), out.result(RunStatusCancelled, "", ctx.Err()))
+		}
+		if err := handle(event); err != nil {
+			return err
+		}
+	}
+}
+
 func (app *App) UpdateAgentModel() error {
 	return app.CoderAgent.UpdateModel()
 }
@@ -270,6 +378,7 @@ func (app *App) InitCoderAgent() error {
 		app.Sessions,
 		app.Messages,
 		app.History,
+		app.Checkpoints,
 		app.LSPClients,
 	)
 	if err != nil {
@@ -284,6 +393,117 @@ func (app *App) InitCoderAgent() error {
 	return nil
 }
 
+func (app *App) initAgentSystem(q db.Querier) {
+	orchestrator, err := agentsystem.NewAgentSystem(
+		app.config,
+		app.Permissions,
+		app.Sessions,
+		app.Messages,
+		app.History,
+		app.LSPClients,
+		q,
+	)
+	if err != nil {
+		slog.Warn("Agent system not available", "error", err)
+		return
+	}
+	if orchestrator == nil {
+		slog.Debug("Agent system is disabled")
+		return
+	}
+	app.Orchestrator = orchestrator
+
+	setupSubscriber(app.eventsCtx, app.serviceEventsWG, "workflows", orchestrator.Subscribe, app.events)
+}
+
+// ResumeWorkflows continues the agent workflows that were still running when
+// floss last exited. They are interrupted again when the app shuts down.
+func (app *App) ResumeWorkflows() {
+	if app.Orchestrator == nil {
+		return
+	}
+	ctx, cancel := context.WithCancel(app.globalCtx)
+	app.cleanupFuncs = append(app.cleanupFuncs, func() error {
+		cancel()
+		return nil
+	})
+	resumed, err := app.Orchestrator.ResumeWorkflows(ctx)
+	if err != nil {
+		slog.Error("Failed to resume workflows", "error", err)
+		return
+	}
+	if resumed > 0 {
+		slog.Info("Resumed workflows", "count", resumed)
+	}
+}
+
+// RunWorkflow starts a workflow instance and waits for it to finish or pause,
+// calling onUpdate with the instance whenever it changes. As in
+// RunNonInteractive, permission requests from the workflow steps are approved
+// automatically. Approval gates are the exception: they wait for `floss agents
+// approve`. Nobody can confirm going over budget, so steps stop there. If ctx
+// is cancelled first, the instance is left to resume on the next start.
+func (app *App) RunWorkflow(ctx context.Context, workflowID string, contextData map[string]any, onUpdate func(agentsystem.WorkflowInstance)) (agentsystem.WorkflowInstance, error) {
+	ctx = agent.WithNonInteractive(ctx)
+	return app.runWorkflow(ctx, func() (*agentsystem.WorkflowInstance, error) {
+		return app.Orchestrator.StartWorkflow(ctx, workflowID, contextData)
+	}, onUpdate)
+}
+
+// ResumeWorkflow resumes a workflow instance paused by a budget and, like
+// RunWorkflow, waits for it to finish or pause again.
+func (app *App) ResumeWorkflow(ctx context.Context, instanceID string, onUpdate func(agentsystem.WorkflowInstance)) (agentsystem.WorkflowInstance, error) {
+	ctx = agent.WithNonInteractive(ctx)
+	return app.runWorkflow(ctx, func() (*agentsystem.WorkflowInstance, error) {
+		return app.Orchestrator.ResumeWorkflow(ctx, instanceID)
+	}, onUpdate)
+}
+
+func (app *App) runWorkflow(ctx context.Context, start func() (*agentsystem.WorkflowInstance, error), onUpdate func(agentsystem.WorkflowInstance)) (agentsystem.WorkflowInstance, error) {
+	if app.Orchestrator == nil {
+		return agentsystem.WorkflowInstance{}, errors.New("agent system is not available")
+	}
+
+	app.Orchestrator.OnStepSession(func(_, sessionID string) {
+		app.Permissions.AutoApproveSession(sessionID)
+	})
+	app.Orchestrator.SetApprovalDialog(false)
+	updates := app.Orchestrator.Subscribe(ctx)
+
+	started, err := start()
+	if err != nil {
+		return agentsystem.WorkflowInstance{}, err
+	}
+	instance := *started
+	onUpdate(instance)
+
+	// Updates are dropped for slow subscribers, so poll as well
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case event, ok := <-updates:
+			if !ok {
+				return instance, ctx.Err()
+			}
+			if event.Payload.ID != instance.ID {
+				continue
+			}
+			instance = event.Payload
+		case <-ticker.C:
+			if latest, ok := app.Orchestrator.GetWorkflowInstance(instance.ID); ok {
+				instance = *latest
+			}
+		case <-ctx.Done():
+			return instance, ctx.Err()
+		}
+		onUpdate(instance)
+		if instance.Status.IsFinished() || instance.Status == agentsystem.WorkflowStatusPaused {
+			return instance, nil
+		}
+	}
+}
+
 // Subscribe sends events to the TUI as tea.Msgs.
 func (app *App) Subscribe(program *tea.Program) {
 	defer log.RecoverPanic("app.Subscribe", func() {
@@ -0,0 +1,235 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/nom-nom-hub/floss/internal/message"
+	"github.com/nom-nom-hub/floss/internal/permission"
+	"github.com/nom-nom-hub/floss/internal/session"
+)
+
+// OutputFormat is how a non-interactive run prints its output
+type OutputFormat string
+
+const (
+	// OutputFormatText prints the answer as it streams
+	OutputFormatText OutputFormat = "text"
+	// OutputFormatJSON prints a single RunEvent of type result once done
+	OutputFormatJSON OutputFormat = "json"
+	// OutputFormatStreamJSON prints a RunEvent per line as the run goes,
+	// ending with the result
+	OutputFormatStreamJSON OutputFormat = "stream-json"
+)
+
+// ParseOutputFormat returns the output format named s
+func ParseOutputFormat(s string) (OutputFormat, error) {
+	switch format := OutputFormat(s); format {
+	case OutputFormatText, OutputFormatJSON, OutputFormatStreamJSON:
+		return format, nil
+	}
+	return "", fmt.Errorf("unknown output format %q, expected text, json or stream-json", s)
+}
+
+// Types of RunEvent
+const (
+	RunEventAssistantDelta = "assistant_delta"
+	RunEventToolCall       = "tool_call"
+	RunEventToolResult     = "tool_result"
+	RunEventPermission     = "permission"
+	RunEventUsage          = "usage"
+	RunEventResult         = "result"
+)
+
+// Statuses of the result of a run
+const (
+	RunStatusSuccess   = "success"
+	RunStatusError     = "error"
+	RunStatusCancelled = "cancelled"
+)
+
+// RunEvent is a line of the structured output of a non-interactive run.
+// Type tells which of its fields are set.
+type RunEvent struct {
+	Type      string `json:"type"`
+	SessionID string `json:"session_id"`
+	MessageID string `json:"message_id,omitempty"`
+	// Text is the new text of assistant deltas and the answer of results
+	Text       string         `json:"text,omitempty"`
+	ToolCall   *RunToolCall   `json:"tool_call,omitempty"`
+	ToolResult *RunToolResult `json:"tool_result,omitempty"`
+	Permission *RunPermission `json:"permission,omitempty"`
+	// Usage is the usage of the session so far, or in all for results
+	Usage *RunUsage `json:"usage,omitempty"`
+
+	Status     string `json:"status,omitempty"`
+	Error      string `json:"error,omitempty"`
+	DurationMS int64  `json:"duration_ms,omitempty"`
+}
+
+type RunToolCall struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Input string `json:"input"`
+}
+
+type RunToolResult struct {
+	ToolCallID string `json:"tool_call_id"`
+	Name       string `json:"name"`
+	Content    string `json:"content"`
+	IsError    bool   `json:"is_error"`
+}
+
+type RunPermission struct {
+	ToolCallID string `json:"tool_call_id"`
+	ToolName   string `json:"tool_name"`
+	Action     string `json:"action"`
+	Granted    bool   `json:"granted"`
+}
+
+type RunUsage struct {
+	TotalTokens int64   `json:"total_tokens"`
+	Cost        float64 `json:"cost"`
+}
+
+// runOutput turns the events of a session into the structured output of
+// its run, printing each of them once
+type runOutput struct {
+	enc       *json.Encoder
+	format    OutputFormat
+	sessionID string
+	started   time.Time
+
+	textBytes   map[string]int
+	toolCalls   map[string]bool
+	toolResults map[string]bool
+	usage       RunUsage
+}
+
+func newRunOutput(w io.Writer, format OutputFormat, sessionID string) *runOutput {
+	return &runOutput{
+		enc:         json.NewEncoder(w),
+		format:      format,
+		sessionID:   sessionID,
+		started:     time.Now(),
+		textBytes:   make(map[string]int),
+		toolCalls:   make(map[string]bool),
+		toolResults: make(map[string]bool),
+	}
+}
+
+// emit prints the event unless only the result is printed
+func (o *runOutput) emit(event RunEvent) error {
+	if o.format != OutputFormatStreamJSON {
+		return nil
+	}
+	event.SessionID = o.sessionID
+	return o.enc.Encode(event)
+}
+
+// message prints what is new in the message: the text the assistant added
+// since, its finished tool calls and the results of tool calls
+func (o *runOutput) message(msg message.Message) error {
+	if msg.SessionID != o.sessionID {
+		return nil
+	}
+	switch msg.Role {
+	case message.Assistant:
+		text := msg.Content().Text
+		if read := o.textBytes[msg.ID]; len(text) > read {
+			o.textBytes[msg.ID] = len(text)
+			if err := o.emit(RunEvent{Type: RunEventAssistantDelta, MessageID: msg.ID, Text: text[read:]}); err != nil {
+				return err
+			}
+		}
+		for _, call := range msg.ToolCalls() {
+			if !call.Finished || o.toolCalls[call.ID] {
+				continue
+			}
+			o.toolCalls[call.ID] = true
+			if err := o.emit(RunEvent{
+				Type:      RunEventToolCall,
+				MessageID: msg.ID,
+				ToolCall:  &RunToolCall{ID: call.ID, Name: call.Name, Input: call.Input},
+			}); err != nil {
+				return err
+			}
+		}
+	case message.Tool:
+		for _, result := range msg.ToolResults() {
+			if o.toolResults[result.ToolCallID] {
+				continue
+			}
+			o.toolResults[result.ToolCallID] = true
+			if err := o.emit(RunEvent{
+				Type:      RunEventToolResult,
+				MessageID: msg.ID,
+				ToolResult: &RunToolResult{
+					ToolCallID: result.ToolCallID,
+					Name:       result.Name,
+					Content:    result.Content,
+					IsError:    result.IsError,
+				},
+			}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// reconcile prints what the messages of the session hold that wasn't
+// printed yet. The message events of slow subscribers are dropped, so it is
+// called with the stored messages whenever a message finishes.
+func (o *runOutput) reconcile(msgs []message.Message) error {
+	for _, msg := range msgs {
+		if err := o.message(msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// permission prints permission decisions, leaving out the requests
+func (o *runOutput) permission(notification permission.PermissionNotification) error {
+	if notification.SessionID != o.sessionID || (!notification.Granted && !notification.Denied) {
+		return nil
+	}
+	return o.emit(RunEvent{
+		Type: RunEventPermission,
+		Permission: &RunPermission{
+			ToolCallID: notification.ToolCallID,
+			ToolName:   notification.ToolName,
+			Action:     notification.Action,
+			Granted:    notification.Granted,
+		},
+	})
+}
+
+// session prints the usage of the session when it changed
+func (o *runOutput) session(sess session.Session) error {
+	usage := RunUsage{TotalTokens: sess.TotalTokens, Cost: sess.Cost}
+	if sess.ID != o.sessionID || usage == o.usage {
+		return nil
+	}
+	o.usage = usage
+	return o.emit(RunEvent{Type: RunEventUsage, Usage: &usage})
+}
+
+// result prints the summary of the run, whatever the format
+func (o *runOutput) result(status, text string, runErr error) error {
+	event := RunEvent{
+		Type:       RunEventResult,
+		SessionID:  o.sessionID,
+		Text:       text,
+		Usage:      &o.usage,
+		Status:     status,
+		DurationMS: time.Since(o.started).Milliseconds(),
+	}
+	if runErr != nil {
+		event.Error = runErr.Error()
+	}
+	return o.enc.Encode(event)
+}
@@ -0,0 +1,147 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/nom-nom-hub/floss/internal/message"
+	"github.com/nom-nom-hub/floss/internal/permission"
+	"github.com/nom-nom-hub/floss/internal/session"
+	"github.com/stretchr/testify/require"
+)
+
+func decodeRunEvents(t *testing.T, out *bytes.Buffer) []RunEvent {
+	t.Helper()
+	var events []RunEvent
+	dec := json.NewDecoder(out)
+	for dec.More() {
+		var event RunEvent
+		require.NoError(t, dec.Decode(&event))
+		events = append(events, event)
+	}
+	return events
+}
+
+func TestParseOutputFormat(t *testing.T) {
+	t.Parallel()
+
+	format, err := ParseOutputFormat("stream-json")
+	require.NoError(t, err)
+	require.Equal(t, OutputFormatStreamJSON, format)
+	_, err = ParseOutputFormat("yaml")
+	require.EqualError(t, err, `unknown output format "yaml", expected text, json or stream-json`)
+}
+
+func TestRunOutputStreamJSON(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	out := newRunOutput(&buf, OutputFormatStreamJSON, "s1")
+	assistant := message.Message{ID: "m1", SessionID: "s1", Role: message.Assistant, Parts: []message.ContentPart{
+		message.TextContent{Text: "Let me"},
+		message.ToolCall{ID: "call_1", Name: "ls", Input: "{}"},
+	}}
+	require.NoError(t, out.message(assistant))
+	assistant.Parts = []message.ContentPart{
+		message.TextContent{Text: "Let me look"},
+		message.ToolCall{ID: "call_1", Name: "ls", Input: "{}", Finished: true},
+	}
+	require.NoError(t, out.message(assistant))
+	require.NoError(t, out.message(assistant))
+	require.NoError(t, out.permission(permission.PermissionNotification{SessionID: "s1", ToolCallID: "call_1", ToolName: "ls"}))
+	require.NoError(t, out.permission(permission.PermissionNotification{SessionID: "s1", ToolCallID: "call_1", ToolName: "ls", Granted: true}))
+	require.NoError(t, out.message(message.Message{ID: "m2", SessionID: "s1", Role: message.Tool, Parts: []message.ContentPart{
+		message.ToolResult{ToolCallID: "call_1", Name: "ls", Content: "- main.go"},
+	}}))
+	// Task sessions are left out
+	require.NoError(t, out.message(message.Message{ID: "m3", SessionID: "task", Role: message.Assistant, Parts: []message.ContentPart{
+		message.TextContent{Text: "Elsewhere"},
+	}}))
+	require.NoError(t, out.session(session.Session{ID: "s1", TotalTokens: 120, Cost: 0.01}))
+	require.NoError(t, out.session(session.Session{ID: "s1", TotalTokens: 120, Cost: 0.01}))
+	require.NoError(t, out.result(RunStatusSuccess, "Let me look", nil))
+
+	events := decodeRunEvents(t, &buf)
+	var types []string
+	for _, event := range events {
+		require.Equal(t, "s1", event.SessionID)
+		types = append(types, event.Type)
+	}
+	require.Equal(t, []string{
+		RunEventAssistantDelta,
+		RunEventAssistantDelta,
+		RunEventToolCall,
+		RunEventPermission,
+		RunEventToolResult,
+		RunEventUsage,
+		RunEventResult,
+	}, types)
+	require.Equal(t, " look", events[1].Text)
+	require.Equal(t, &RunToolCall{ID: "call_1", Name: "ls", Input: "{}"}, events[2].ToolCall)
+	require.True(t, events[3].Permission.Granted)
+	require.Equal(t, "- main.go", events[4].ToolResult.Content)
+	result := events[6]
+	require.Equal(t, RunStatusSuccess, result.Status)
+	require.Equal(t, &RunUsage{TotalTokens: 120, Cost: 0.01}, result.Usage)
+}
+
+func TestRunOutputJSON(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	out := newRunOutput(&buf, OutputFormatJSON, "s1")
+	require.NoError(t, out.message(message.Message{ID: "m1", SessionID: "s1", Role: message.Assistant, Parts: []message.ContentPart{
+		message.TextContent{Text: "Partial"},
+	}}))
+	require.NoError(t, out.result(RunStatusError, "", errors.New("agent processing failed: boom")))
+
+	events := decodeRunEvents(t, &buf)
+	require.Len(t, events, 1)
+	require.Equal(t, RunEventResult, events[0].Type)
+	require.Equal(t, RunStatusError, events[0].Status)
+	require.Equal(t, "agent processing failed: boom", events[0].Error)
+}
+
+func TestRunOutputReconcile(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	out := newRunOutput(&buf, OutputFormatStreamJSON, "s1")
+	assistant := message.Message{ID: "m1", SessionID: "s1", Role: message.Assistant, Parts: []message.ContentPart{
+		message.ToolCall{ID: "call_1", Name: "ls", Input: "{}", Finished: true},
+		message.ToolCall{ID: "call_2", Name: "view", Input: `{"file_path":"main.go"}`, Finished: true},
+		message.Finish{Reason: message.FinishReasonToolUse},
+	}}
+	results := message.Message{ID: "m2", SessionID: "s1", Role: message.Tool, Parts: []message.ContentPart{
+		message.ToolResult{ToolCallID: "call_1", Name: "ls", Content: "- main.go"},
+		message.ToolResult{ToolCallID: "call_2", Name: "view", Content: "package main"},
+	}}
+	answer := message.Message{ID: "m3", SessionID: "s1", Role: message.Assistant, Parts: []message.ContentPart{
+		message.TextContent{Text: "Done"},
+		message.Finish{Reason: message.FinishReasonEndTurn},
+	}}
+
+	// The events of the tool results and the answer were dropped
+	require.NoError(t, out.message(assistant))
+	require.NoError(t, out.reconcile([]message.Message{assistant, results, answer}))
+	require.NoError(t, out.reconcile([]message.Message{assistant, results, answer}))
+	require.NoError(t, out.message(answer))
+
+	var calls, toolResults []string
+	var text string
+	for _, event := range decodeRunEvents(t, &buf) {
+		switch event.Type {
+		case RunEventToolCall:
+			calls = append(calls, event.ToolCall.ID)
+		case RunEventToolResult:
+			toolResults = append(toolResults, event.ToolResult.ToolCallID)
+		case RunEventAssistantDelta:
+			text += event.Text
+		}
+	}
+	require.Equal(t, []string{"call_1", "call_2"}, calls)
+	require.Equal(t, []string{"call_1", "call_2"}, toolResults)
+	require.Equal(t, "Done", text)
+}
@@ -0,0 +1,264 @@
+package checkpoint
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	"github.com/google/uuid"
+	"github.com/nom-nom-hub/floss/internal/db"
+	"github.com/nom-nom-hub/floss/internal/history"
+	"github.com/nom-nom-hub/floss/internal/message"
+	"github.com/nom-nom-hub/floss/internal/session"
+)
+
+// Checkpoint is a point of a session to go back to: its last message and
+// the latest version of each file it changed
+type Checkpoint struct {
+	ID        string
+	SessionID string
+	Name      string
+	// MessageID is the last message of the session, or empty when it had
+	// none yet
+	MessageID    string
+	FileVersions map[string]int64
+	CreatedAt    int64
+}
+
+type Service interface {
+	// Create records where the session is now
+	Create(ctx context.Context, sessionID, name string) (Checkpoint, error)
+	Get(ctx context.Context, id string) (Checkpoint, error)
+	// List returns the checkpoints of the session, newest first
+	List(ctx context.Context, sessionID string) ([]Checkpoint, error)
+	Delete(ctx context.Context, id string) error
+	// Restore writes back the files of the session as they were at the
+	// checkpoint and deletes the messages and checkpoints after it. Unless
+	// force is set, it fails with history.ErrFileModified when a file was
+	// modified outside floss.
+	Restore(ctx context.Context, id string, force bool) error
+	// Fork creates a session going on from the checkpoint, leaving the
+	// session it was taken in as it is
+	Fork(ctx context.Context, id string, force bool) (session.Session, error)
+}
+
+type service struct {
+	q        db.Querier
+	sessions session.Service
+	messages message.Service
+	history  history.Service
+}
+
+func NewService(q db.Querier, sessions session.Service, messages message.Service, history history.Service) Service {
+	return &service{
+		q:        q,
+		sessions: sessions,
+		messages: messages,
+		history:  history,
+	}
+}
+
+func (s *service) Create(ctx context.Context, sessionID, name string) (Checkpoint, error) {
+	messages, err := s.messages.List(ctx, sessionID)
+	if err != nil {
+		return Checkpoint{}, err
+	}
+	var messageID string
+	if len(messages) > 0 {
+		messageID = messages[len(messages)-1].ID
+	}
+	versions, err := s.history.LatestVersions(ctx, sessionID)
+	if err != nil {
+		return Checkpoint{}, err
+	}
+	versionsJSON, err := json.Marshal(versions)
+	if err != nil {
+		return Checkpoint{}, err
+	}
+	dbCheckpoint, err := s.q.CreateCheckpoint(ctx, db.CreateCheckpointParams{
+		ID:           uuid.New().String(),
+		SessionID:    sessionID,
+		Name:         name,
+		MessageID:    sql.NullString{String: messageID, Valid: messageID != ""},
+		FileVersions: string(versionsJSON),
+	})
+	if err != nil {
+		return Checkpoint{}, err
+	}
+	return fromDBItem(dbCheckpoint)
+}
+
+func (s *service) Get(ctx context.Context, id string) (Checkpoint, error) {
+	dbCheckpoint, err := s.q.GetCheckpoint(ctx, id)
+	if err != nil {
+		return Checkpoint{}, err
+	}
+	return fromDBItem(dbCheckpoint)
+}
+
+func (s *service) List(ctx context.Context, sessionID string) ([]Checkpoint, error) {
+	dbCheckpoints, err := s.q.ListCheckpointsBySession(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	checkpoints := make([]Checkpoint, len(dbCheckpoints))
+	for i, dbCheckpoint := range dbCheckpoints {
+		checkpoints[i], err = fromDBItem(dbCheckpoint)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return checkpoints, nil
+}
+
+func (s *service) Delete(ctx context.Context, id string) error {
+	return s.q.DeleteCheckpoint(ctx, id)
+}
+
+func (s *service) Restore(ctx context.Context, id string, force bool) error {
+	checkpoint, err := s.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	messages, err := s.messages.List(ctx, checkpoint.SessionID)
+	if err != nil {
+		return err
+	}
+	kept, err := keptMessages(checkpoint, messages)
+	if err != nil {
+		return err
+	}
+	if _, err := s.history.RevertTo(ctx, checkpoint.SessionID, checkpoint.FileVersions, force); err != nil {
+		return err
+	}
+
+	sess, err := s.sessions.Get(ctx, checkpoint.SessionID)
+	if err != nil {
+		return err
+	}
+	for _, msg := range messages[kept:] {
+		if err := s.messages.Delete(ctx, msg.ID); err != nil {
+			return err
+		}
+		// The summary goes along with the messages after the checkpoint
+		if msg.ID == sess.SummaryMessageID {
+			sess.SummaryMessageID = ""
+			sess.FirstKeptMessageID = ""
+			if _, err := s.sessions.Save(ctx, sess); err != nil {
+				return err
+			}
+		}
+	}
+	return s.q.DeleteCheckpointsAfter(ctx, db.DeleteCheckpointsAfterParams{
+		SessionID: checkpoint.SessionID,
+		ID:        checkpoint.ID,
+	})
+}
+
+func (s *service) Fork(ctx context.Context, id string, force bool) (session.Session, error) {
+	checkpoint, err := s.Get(ctx, id)
+	if err != nil {
+		return session.Session{}, err
+	}
+	parent, err := s.sessions.Get(ctx, checkpoint.SessionID)
+	if err != nil {
+		return session.Session{}, err
+	}
+	messages, err := s.messages.List(ctx, checkpoint.SessionID)
+	if err != nil {
+		return session.Session{}, err
+	}
+	kept, err := keptMessages(checkpoint, messages)
+	if err != nil {
+		return session.Session{}, err
+	}
+
+	fork, err := s.sessions.CreateForkSession(ctx, parent.ID, checkpoint.ID, "Fork of "+parent.Title)
+	if err != nil {
+		return session.Session{}, err
+	}
+	// The fork takes over the file history of the parent, so that it can
+	// go back to where the parent started too
+	if err := s.history.CopySession(ctx, parent.ID, fork.ID); err != nil {
+		return session.Session{}, s.abortFork(ctx, fork.ID, err)
+	}
+	if _, err := s.history.RevertTo(ctx, fork.ID, checkpoint.FileVersions, force); err != nil {
+		return session.Session{}, s.abortFork(ctx, fork.ID, err)
+	}
+
+	copied := make(map[string]string)
+	for _, msg := range messages[:kept] {
+		forked, err := s.messages.Create(ctx, fork.ID, message.CreateMessageParams{
+			Role:     msg.Role,
+			Parts:    msg.Parts,
+			Model:    msg.Model,
+			Provider: msg.Provider,
+		})
+		if err != nil {
+			return session.Session{}, err
+		}
+		// Create adds a finish part to the messages that aren't from the
+		// assistant, while they already have theirs
+		forked.Parts = msg.Parts
+		if err := s.messages.Update(ctx, forked); err != nil {
+			return session.Session{}, err
+		}
+		if msg.Pinned {
+			if err := s.messages.SetPinned(ctx, forked.ID, true); err != nil {
+				return session.Session{}, err
+			}
+		}
+		copied[msg.ID] = forked.ID
+	}
+
+	if summaryID, ok := copied[parent.SummaryMessageID]; ok {
+		fork, err = s.sessions.Get(ctx, fork.ID)
+		if err != nil {
+			return session.Session{}, err
+		}
+		fork.SummaryMessageID = summaryID
+		fork.FirstKeptMessageID = copied[parent.FirstKeptMessageID]
+		return s.sessions.Save(ctx, fork)
+	}
+	return s.sessions.Get(ctx, fork.ID)
+}
+
+// abortFork deletes the fork that could not be set up
+func (s *service) abortFork(ctx context.Context, forkID string, err error) error {
+	if deleteErr := s.sessions.Delete(ctx, forkID); deleteErr != nil {
+		return fmt.Errorf("%w (failed to delete the fork: %v)", err, deleteErr)
+	}
+	return err
+}
+
+// keptMessages returns how many of the session messages came up to the
+// checkpoint
+func keptMessages(checkpoint Checkpoint, messages []message.Message) (int, error) {
+	if checkpoint.MessageID == "" {
+		return 0, nil
+	}
+	i := slices.IndexFunc(messages, func(msg message.Message) bool {
+		return msg.ID == checkpoint.MessageID
+	})
+	if i == -1 {
+		return 0, fmt.Errorf("the last message of checkpoint %q no longer exists", checkpoint.Name)
+	}
+	return i + 1, nil
+}
+
+func fromDBItem(item db.Checkpoint) (Checkpoint, error) {
+	var versions map[string]int64
+	if err := json.Unmarshal([]byte(item.FileVersions), &versions); err != nil {
+		return Checkpoint{}, fmt.Errorf("failed to unmarshal file versions: %w", err)
+	}
+	return Checkpoint{
+		ID:           item.ID,
+		SessionID:    item.SessionID,
+		Name:         item.Name,
+		MessageID:    item.MessageID.String,
+		FileVersions: versions,
+		CreatedAt:    item.CreatedAt,
+	}, nil
+}
@@ -0,0 +1,166 @@
+package checkpoint
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/nom-nom-hub/floss/internal/db"
+	"github.com/nom-nom-hub/floss/internal/history"
+	"github.com/nom-nom-hub/floss/internal/message"
+	"github.com/nom-nom-hub/floss/internal/session"
+	"github.com/stretchr/testify/require"
+)
+
+type testServices struct {
+	checkpoints Service
+	sessions    session.Service
+	messages    message.Service
+	files       history.Service
+	session     session.Session
+}
+
+func newTestServices(t *testing.T) testServices {
+	t.Helper()
+	conn, err := db.Connect(t.Context(), t.TempDir())
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	queries := db.New(conn)
+	s := testServices{
+		sessions: session.NewService(queries),
+		messages: message.NewService(queries),
+		files:    history.NewService(queries, conn),
+	}
+	s.checkpoints = NewService(queries, s.sessions, s.messages, s.files)
+	s.session, err = s.sessions.Create(t.Context(), "checkpoints")
+	require.NoError(t, err)
+	return s
+}
+
+// turn adds a prompt and an answer to the session and writes the content
+// to the file the way the tools record it
+func (s testServices) turn(t *testing.T, prompt, path, content string) {
+	t.Helper()
+	_, err := s.messages.Create(t.Context(), s.session.ID, message.CreateMessageParams{
+		Role:  message.User,
+		Parts: []message.ContentPart{message.TextContent{Text: prompt}},
+	})
+	require.NoError(t, err)
+	old, err := os.ReadFile(path)
+	existed := err == nil
+	if err != nil && !os.IsNotExist(err) {
+		require.NoError(t, err)
+	}
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	if _, err := s.files.GetByPathAndSession(t.Context(), path, s.session.ID); err != nil {
+		if existed {
+			_, err = s.files.Create(t.Context(), s.session.ID, path, string(old))
+		} else {
+			_, err = s.files.CreateNew(t.Context(), s.session.ID, path)
+		}
+		require.NoError(t, err)
+	}
+	_, err = s.files.CreateVersion(t.Context(), s.session.ID, path, content)
+	require.NoError(t, err)
+	_, err = s.messages.Create(t.Context(), s.session.ID, message.CreateMessageParams{
+		Role:  message.Assistant,
+		Parts: []message.ContentPart{message.TextContent{Text: "Done"}, message.Finish{Reason: message.FinishReasonEndTurn}},
+	})
+	require.NoError(t, err)
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	return string(data)
+}
+
+func prompts(t *testing.T, messages message.Service, sessionID string) []string {
+	t.Helper()
+	msgs, err := messages.List(t.Context(), sessionID)
+	require.NoError(t, err)
+	var texts []string
+	for _, msg := range msgs {
+		if msg.Role == message.User {
+			texts = append(texts, msg.Content().Text)
+		}
+	}
+	return texts
+}
+
+func TestRestore(t *testing.T) {
+	t.Parallel()
+
+	s := newTestServices(t)
+	dir := t.TempDir()
+	main := filepath.Join(dir, "main.go")
+	created := filepath.Join(dir, "new.go")
+	require.NoError(t, os.WriteFile(main, []byte("v1"), 0o644))
+
+	start, err := s.checkpoints.Create(t.Context(), s.session.ID, "start")
+	require.NoError(t, err)
+	s.turn(t, "first", main, "v2")
+	checkpoint, err := s.checkpoints.Create(t.Context(), s.session.ID, "after first")
+	require.NoError(t, err)
+	s.turn(t, "second", main, "v3")
+	s.turn(t, "third", created, "package main")
+	_, err = s.checkpoints.Create(t.Context(), s.session.ID, "after third")
+	require.NoError(t, err)
+
+	// Nothing is restored while a file was modified outside floss
+	require.NoError(t, os.WriteFile(main, []byte("edited by hand"), 0o644))
+	err = s.checkpoints.Restore(t.Context(), checkpoint.ID, false)
+	require.ErrorIs(t, err, history.ErrFileModified)
+	require.Equal(t, []string{"first", "second", "third"}, prompts(t, s.messages, s.session.ID))
+
+	require.NoError(t, s.checkpoints.Restore(t.Context(), checkpoint.ID, true))
+	require.Equal(t, "v2", readFile(t, main))
+	require.NoFileExists(t, created)
+	require.Equal(t, []string{"first"}, prompts(t, s.messages, s.session.ID))
+	checkpoints, err := s.checkpoints.List(t.Context(), s.session.ID)
+	require.NoError(t, err)
+	require.Len(t, checkpoints, 2)
+	require.Equal(t, "after first", checkpoints[0].Name)
+
+	require.NoError(t, s.checkpoints.Restore(t.Context(), start.ID, false))
+	require.Equal(t, "v1", readFile(t, main))
+	require.Empty(t, prompts(t, s.messages, s.session.ID))
+}
+
+func TestFork(t *testing.T) {
+	t.Parallel()
+
+	s := newTestServices(t)
+	main := filepath.Join(t.TempDir(), "main.go")
+	require.NoError(t, os.WriteFile(main, []byte("v1"), 0o644))
+	s.turn(t, "first", main, "v2")
+	checkpoint, err := s.checkpoints.Create(t.Context(), s.session.ID, "after first")
+	require.NoError(t, err)
+	s.turn(t, "second", main, "v3")
+
+	fork, err := s.checkpoints.Fork(t.Context(), checkpoint.ID, false)
+	require.NoError(t, err)
+	require.Equal(t, s.session.ID, fork.ParentSessionID)
+	require.Equal(t, checkpoint.ID, fork.CheckpointID)
+	require.Equal(t, "v2", readFile(t, main))
+	require.Equal(t, []string{"first"}, prompts(t, s.messages, fork.ID))
+	msgs, err := s.messages.List(t.Context(), fork.ID)
+	require.NoError(t, err)
+	require.Len(t, msgs[0].Parts, 2, "the copied prompt keeps a single finish part")
+
+	// The original session is left as it was
+	require.Equal(t, []string{"first", "second"}, prompts(t, s.messages, s.session.ID))
+	latest, err := s.files.GetByPathAndSession(t.Context(), main, s.session.ID)
+	require.NoError(t, err)
+	require.Equal(t, "v3", latest.Content)
+
+	// The fork can go back to before the parent session started
+	_, err = s.files.RevertSession(t.Context(), fork.ID, false)
+	require.NoError(t, err)
+	require.Equal(t, "v1", readFile(t, main))
+
+	sessions, err := s.sessions.List(t.Context())
+	require.NoError(t, err)
+	require.Len(t, sessions, 2, "forks are listed")
+}
@@ -1,9 +1,17 @@
 package cmd
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strconv"
 	"strings"
+	"text/tabwriter"
+	"time"
 
 	"github.com/nom-nom-hub/floss/internal/agent"
 	"github.com/spf13/cobra"
@@ -154,6 +162,9 @@ var agentsWorkflowsCmd = &cobra.Command{
 			fmt.Printf("Workflow: %s\n", workflow.Name)
 			fmt.Printf("  ID: %s\n", workflow.ID)
 			fmt.Printf("  Description: %s\n", workflow.Description)
+			if file, ok := agentConfig.WorkflowFiles[workflow.ID]; ok {
+				fmt.Printf("  File: %s\n", file)
+			}
 			fmt.Printf("  Steps: %d\n\n", len(workflow.Steps))
 		}
 
@@ -283,6 +294,469 @@ var agentsDisableCmd = &cobra.Command{
 	},
 }
 
+// agentsRunCmd represents the agents run command
+var agentsRunCmd = &cobra.Command{
+	Use:   "run [workflow-id]",
+	Short: "Run a workflow",
+	Long: `Start an instance of a workflow and follow its progress until it finishes.
+Permission requests from the workflow steps are approved automatically.
+Approval steps wait until they are approved with 'floss agents approve'.`,
+	Example: `
+# Run the product development workflow
+floss agents run product_development --set feature="Password reset"
+
+# Print progress as JSON lines
+floss agents run incident_response --set service=api --json
+  `,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		jsonOutput, _ := cmd.Flags().GetBool("json")
+		values, _ := cmd.Flags().GetStringArray("set")
+
+		contextData, err := parseWorkflowValues(values)
+		if err != nil {
+			return err
+		}
+
+		appInstance, err := SetupApp(cmd)
+		if err != nil {
+			return err
+		}
+		defer appInstance.Shutdown()
+
+		if !appInstance.Config().IsConfigured() {
+			return fmt.Errorf("no providers configured - please run 'floss' to set up a provider interactively")
+		}
+
+		progress := newWorkflowProgress(jsonOutput)
+		instance, err := appInstance.RunWorkflow(cmd.Context(), args[0], contextData, progress.update)
+		if err != nil {
+			if instance.ID != "" {
+				fmt.Fprintf(os.Stderr, "Workflow instance %s was interrupted and will resume the next time floss starts\n", instance.ID)
+			}
+			return err
+		}
+		return workflowRunResult(instance)
+	},
+}
+
+// agentsResumeCmd represents the agents resume command
+var agentsResumeCmd = &cobra.Command{
+	Use:   "resume [instance-id]",
+	Short: "Resume a workflow instance paused by a budget",
+	Long: `Resume a workflow instance that was paused because it went over its budget,
+and follow its progress like 'floss agents run'. Raise the budget in the
+configuration first: an instance that is still over budget isn't resumed.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		jsonOutput, _ := cmd.Flags().GetBool("json")
+
+		appInstance, err := SetupApp(cmd)
+		if err != nil {
+			return err
+		}
+		defer appInstance.Shutdown()
+
+		if !appInstance.Config().IsConfigured() {
+			return fmt.Errorf("no providers configured - please run 'floss' to set up a provider interactively")
+		}
+
+		progress := newWorkflowProgress(jsonOutput)
+		instance, err := appInstance.ResumeWorkflow(cmd.Context(), args[0], progress.update)
+		if err != nil {
+			if instance.ID != "" {
+				fmt.Fprintf(os.Stderr, "Workflow instance %s was interrupted and will resume the next time floss starts\n", instance.ID)
+			}
+			return err
+		}
+		return workflowRunResult(instance)
+	},
+}
+
+// workflowRunResult turns a workflow instance that didn't complete into an
+// error
+func workflowRunResult(instance agent.WorkflowInstance) error {
+	switch instance.Status {
+	case agent.WorkflowStatusCompleted:
+		return nil
+	case agent.WorkflowStatusPaused, agent.WorkflowStatusCancelled:
+		if instance.StatusReason != "" {
+			return fmt.Errorf("workflow instance %s %s: %s", instance.ID, instance.Status, instance.StatusReason)
+		}
+	}
+	return fmt.Errorf("workflow instance %s %s", instance.ID, instance.Status)
+}
+
+// agentsStatusCmd represents the agents status command
+var agentsStatusCmd = &cobra.Command{
+	Use:   "status [instance-id]",
+	Short: "Show the status of workflow instances",
+	Long:  `List all workflow instances, or show the steps of a single instance.`,
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		jsonOutput, _ := cmd.Flags().GetBool("json")
+
+		appInstance, err := SetupApp(cmd)
+		if err != nil {
+			return err
+		}
+		defer appInstance.Shutdown()
+
+		if appInstance.Orchestrator == nil {
+			return errors.New("agent system is not available")
+		}
+
+		if len(args) == 1 {
+			instance, exists := appInstance.Orchestrator.GetWorkflowInstance(args[0])
+			if !exists {
+				return fmt.Errorf("workflow instance %s not found", args[0])
+			}
+			if jsonOutput {
+				return printJSON(instance)
+			}
+			printWorkflowInstance(cmd.Context(), appInstance.Orchestrator, *instance)
+			return nil
+		}
+
+		instances := appInstance.Orchestrator.ListWorkflowInstances()
+		slices.SortFunc(instances, func(a, b agent.WorkflowInstance) int {
+			return b.CreatedAt.Compare(a.CreatedAt)
+		})
+		if jsonOutput {
+			return printJSON(instances)
+		}
+		if len(instances) == 0 {
+			fmt.Println("No workflow instances")
+			return nil
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tWORKFLOW\tSTATUS\tSTEPS\tCOST\tCREATED")
+		for _, instance := range instances {
+			usage, _ := appInstance.Orchestrator.Usage(cmd.Context(), instance)
+			fmt.Fprintf(w, "%s\t%s\t%s\t%d/%d\t$%.2f\t%s\n",
+				instance.ID,
+				instance.WorkflowID,
+				instance.Status,
+				completedSteps(instance),
+				len(instance.Steps),
+				usage.Cost,
+				instance.CreatedAt.Format(time.DateTime),
+			)
+		}
+		return w.Flush()
+	},
+}
+
+// agentsCancelCmd represents the agents cancel command
+var agentsCancelCmd = &cobra.Command{
+	Use:   "cancel [instance-id]",
+	Short: "Cancel a workflow instance",
+	Long: `Cancel a pending or running workflow instance. Instances running in another
+floss process stop within a few seconds.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		jsonOutput, _ := cmd.Flags().GetBool("json")
+
+		appInstance, err := SetupApp(cmd)
+		if err != nil {
+			return err
+		}
+		defer appInstance.Shutdown()
+
+		if appInstance.Orchestrator == nil {
+			return errors.New("agent system is not available")
+		}
+
+		if err := appInstance.Orchestrator.CancelWorkflow(args[0]); err != nil {
+			return err
+		}
+		if jsonOutput {
+			instance, _ := appInstance.Orchestrator.GetWorkflowInstance(args[0])
+			return printJSON(instance)
+		}
+		fmt.Printf("Workflow instance %s cancelled\n", args[0])
+		return nil
+	},
+}
+
+// agentsApproveCmd represents the agents approve command
+var agentsApproveCmd = &cobra.Command{
+	Use:   "approve [instance-id] [step]",
+	Short: "Approve a workflow step that is waiting for approval",
+	Long: `Approve an approval step of a workflow instance so the instance continues.
+The step can be left out when only one step is waiting.`,
+	Example: `
+# Approve the step that is waiting
+floss agents approve 3f2b8c1e-6a4d-4e7b-9c2a-5d8e1f0b7a64
+
+# Approve step 9
+floss agents approve 3f2b8c1e-6a4d-4e7b-9c2a-5d8e1f0b7a64 9 --reason "Looks good"
+  `,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return decideWorkflowApproval(cmd, args, true)
+	},
+}
+
+// agentsRejectCmd represents the agents reject command
+var agentsRejectCmd = &cobra.Command{
+	Use:   "reject [instance-id] [step]",
+	Short: "Reject a workflow step that is waiting for approval",
+	Long: `Reject an approval step of a workflow instance. The step fails, and the
+instance goes on with the step's fallback or fails.`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return decideWorkflowApproval(cmd, args, false)
+	},
+}
+
+// decideWorkflowApproval records the decision for the approval step named in
+// args, or the only step of the instance that is waiting
+func decideWorkflowApproval(cmd *cobra.Command, args []string, approved bool) error {
+	reason, _ := cmd.Flags().GetString("reason")
+
+	appInstance, err := SetupApp(cmd)
+	if err != nil {
+		return err
+	}
+	defer appInstance.Shutdown()
+
+	if appInstance.Orchestrator == nil {
+		return errors.New("agent system is not available")
+	}
+
+	instance, exists := appInstance.Orchestrator.GetWorkflowInstance(args[0])
+	if !exists {
+		return fmt.Errorf("workflow instance %s not found", args[0])
+	}
+	var stepNumber int
+	if len(args) == 2 {
+		stepNumber, err = strconv.Atoi(args[1])
+		if err != nil {
+			return fmt.Errorf("invalid step %q", args[1])
+		}
+	} else {
+		var waiting []int
+		for _, step := range instance.Steps {
+			if step.Status == agent.WorkflowStatusWaitingApproval {
+				waiting = append(waiting, step.StepNumber)
+			}
+		}
+		switch len(waiting) {
+		case 0:
+			return fmt.Errorf("workflow instance %s has no step waiting for approval", instance.ID)
+		case 1:
+			stepNumber = waiting[0]
+		default:
+			return fmt.Errorf("workflow instance %s has %d steps waiting for approval, pass the step number", instance.ID, len(waiting))
+		}
+	}
+
+	if err := appInstance.Orchestrator.ApproveStep(cmd.Context(), instance.ID, stepNumber, approved, reason); err != nil {
+		return err
+	}
+	decision := "rejected"
+	if approved {
+		decision = "approved"
+	}
+	fmt.Printf("Step %d of workflow instance %s %s\n", stepNumber, instance.ID, decision)
+	return nil
+}
+
+// parseWorkflowValues turns key=value pairs into workflow context. Dotted
+// keys create nested values, so repo.branch=main can be read with
+// {{context.repo.branch}}.
+func parseWorkflowValues(values []string) (map[string]any, error) {
+	contextData := make(map[string]any, len(values))
+	for _, value := range values {
+		key, val, ok := strings.Cut(value, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid value %q: expected key=value", value)
+		}
+		parts := strings.Split(key, ".")
+		m := contextData
+		for _, part := range parts[:len(parts)-1] {
+			nested, ok := m[part].(map[string]any)
+			if !ok {
+				if _, exists := m[part]; exists {
+					return nil, fmt.Errorf("invalid value %q: %s is already set", value, part)
+				}
+				nested = make(map[string]any)
+				m[part] = nested
+			}
+			m = nested
+		}
+		m[parts[len(parts)-1]] = val
+	}
+	return contextData, nil
+}
+
+// workflowRunEvent is a line of `floss agents run --json` output
+type workflowRunEvent struct {
+	Type       string                      `json:"type"`
+	InstanceID string                      `json:"instance_id"`
+	Instance   *agent.WorkflowInstance     `json:"instance,omitempty"`
+	Step       *agent.WorkflowStepInstance `json:"step,omitempty"`
+}
+
+// workflowProgress prints the changes between successive states of a
+// workflow instance
+type workflowProgress struct {
+	json    bool
+	started bool
+	steps   map[int]workflowStepState
+}
+
+// workflowStepState is what a progress line is printed for: a step changing
+// status or starting another attempt
+type workflowStepState struct {
+	status   agent.WorkflowStatus
+	attempts int
+}
+
+func newWorkflowProgress(jsonOutput bool) *workflowProgress {
+	return &workflowProgress{
+		json:  jsonOutput,
+		steps: make(map[int]workflowStepState),
+	}
+}
+
+func (p *workflowProgress) update(instance agent.WorkflowInstance) {
+	if !p.started {
+		p.started = true
+		if p.json {
+			p.printEvent(workflowRunEvent{Type: "started", InstanceID: instance.ID, Instance: &instance})
+		} else {
+			fmt.Printf("Started %s (%s)\n", instance.ID, instance.Name)
+		}
+	}
+
+	for _, step := range instance.Steps {
+		state := workflowStepState{status: step.Status, attempts: step.Attempts}
+		if p.steps[step.StepNumber] == state {
+			continue
+		}
+		p.steps[step.StepNumber] = state
+		if step.Status == agent.WorkflowStatusPending {
+			continue
+		}
+		if p.json {
+			p.printEvent(workflowRunEvent{Type: "step", InstanceID: instance.ID, Step: &step})
+			continue
+		}
+		fmt.Printf("%s %s\n", time.Now().Format(time.TimeOnly), formatWorkflowStep(step))
+		if step.Error != "" {
+			fmt.Printf("  Error: %s\n", step.Error)
+		}
+		if step.Status == agent.WorkflowStatusWaitingApproval {
+			fmt.Printf("  Approve with: floss agents approve %s %d\n", instance.ID, step.StepNumber)
+		}
+	}
+
+	if instance.Status == agent.WorkflowStatusPaused {
+		if p.json {
+			p.printEvent(workflowRunEvent{Type: "paused", InstanceID: instance.ID, Instance: &instance})
+			return
+		}
+		fmt.Printf("Workflow %s paused: %s\n", instance.ID, instance.StatusReason)
+		fmt.Printf("  Raise the budget and resume with: floss agents resume %s\n", instance.ID)
+		return
+	}
+	if !instance.Status.IsFinished() {
+		return
+	}
+	if p.json {
+		p.printEvent(workflowRunEvent{Type: "finished", InstanceID: instance.ID, Instance: &instance})
+		return
+	}
+	fmt.Printf("Workflow %s %s", instance.ID, instance.Status)
+	if instance.StartedAt != nil && instance.CompletedAt != nil {
+		fmt.Printf(" in %s", instance.CompletedAt.Sub(*instance.StartedAt).Round(time.Second))
+	}
+	if instance.StatusReason != "" {
+		fmt.Printf(": %s", instance.StatusReason)
+	}
+	fmt.Println()
+}
+
+func (p *workflowProgress) printEvent(event workflowRunEvent) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+	fmt.Printf("%s\n", data)
+}
+
+func printWorkflowInstance(ctx context.Context, orchestrator *agent.AgentOrchestrator, instance agent.WorkflowInstance) {
+	fmt.Printf("Instance: %s\n", instance.ID)
+	fmt.Printf("Workflow: %s (%s)\n", instance.Name, instance.WorkflowID)
+	fmt.Printf("Status: %s\n", instance.Status)
+	if instance.StatusReason != "" {
+		fmt.Printf("Reason: %s\n", instance.StatusReason)
+	}
+	if instance.Status == agent.WorkflowStatusPaused {
+		fmt.Printf("Resume with: floss agents resume %s\n", instance.ID)
+	}
+	fmt.Printf("Created: %s\n", instance.CreatedAt.Format(time.DateTime))
+	usage, roles := orchestrator.Usage(ctx, instance)
+	fmt.Printf("Usage: $%.2f, %d tokens, %s\n", usage.Cost, usage.Tokens, usage.Duration.Round(time.Second))
+	for _, role := range slices.Sorted(maps.Keys(roles)) {
+		fmt.Printf("  %s: $%.2f, %d tokens, %s\n", role, roles[role].Cost, roles[role].Tokens, roles[role].Duration.Round(time.Second))
+	}
+	if len(instance.Context) > 0 {
+		fmt.Printf("Context:\n")
+		for _, key := range slices.Sorted(maps.Keys(instance.Context)) {
+			fmt.Printf("  %s: %v\n", key, instance.Context[key])
+		}
+	}
+	fmt.Printf("Steps (%d/%d completed):\n", completedSteps(instance), len(instance.Steps))
+	for _, step := range instance.Steps {
+		fmt.Printf("  %s\n", formatWorkflowStep(step))
+		if step.SessionID != "" {
+			fmt.Printf("    Session: %s\n", step.SessionID)
+		}
+		if step.Error != "" {
+			fmt.Printf("    Error: %s\n", step.Error)
+		}
+	}
+}
+
+func formatWorkflowStep(step agent.WorkflowStepInstance) string {
+	line := fmt.Sprintf("Step %d [%s] %s", step.StepNumber, step.Status, step.Action)
+	if step.ResponsibleAgent != "" {
+		line += fmt.Sprintf(" (%s)", step.ResponsibleAgent)
+	}
+	if step.Attempts > 1 {
+		line += fmt.Sprintf(" attempt %d", step.Attempts)
+	}
+	if step.Loops > 0 {
+		line += fmt.Sprintf(", went back %d times", step.Loops)
+	}
+	if step.StartedAt != nil && step.CompletedAt != nil {
+		line += fmt.Sprintf(" in %s", step.CompletedAt.Sub(*step.StartedAt).Round(time.Second))
+	}
+	return line
+}
+
+func completedSteps(instance agent.WorkflowInstance) int {
+	completed := 0
+	for _, step := range instance.Steps {
+		if step.Status == agent.WorkflowStatusCompleted {
+			completed++
+		}
+	}
+	return completed
+}
+
+func printJSON(v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal output: %w", err)
+	}
+	fmt.Printf("%s\n", data)
+	return nil
+}
+
 func init() {
 	// Add subcommands to agents command
 	agentsCmd.AddCommand(agentsListCmd)
@@ -292,6 +766,20 @@ func init() {
 	agentsCmd.AddCommand(agentsConfigCmd)
 	agentsCmd.AddCommand(agentsEnableCmd)
 	agentsCmd.AddCommand(agentsDisableCmd)
+	agentsCmd.AddCommand(agentsRunCmd)
+	agentsCmd.AddCommand(agentsResumeCmd)
+	agentsCmd.AddCommand(agentsStatusCmd)
+	agentsCmd.AddCommand(agentsCancelCmd)
+	agentsCmd.AddCommand(agentsApproveCmd)
+	agentsCmd.AddCommand(agentsRejectCmd)
+
+	agentsRunCmd.Flags().StringArray("set", nil, "Set a workflow context value (key=value, repeatable)")
+	agentsRunCmd.Flags().Bool("json", false, "Print progress as JSON lines")
+	agentsResumeCmd.Flags().Bool("json", false, "Print progress as JSON lines")
+	agentsStatusCmd.Flags().Bool("json", false, "Print status as JSON")
+	agentsCancelCmd.Flags().Bool("json", false, "Print the cancelled instance as JSON")
+	agentsApproveCmd.Flags().String("reason", "", "Note recorded with the approval")
+	agentsRejectCmd.Flags().String("reason", "", "Why the step is rejected")
 
 	// Add agents command to root command
 	rootCmd.AddCommand(agentsCmd)
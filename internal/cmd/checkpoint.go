@@ -0,0 +1,149 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var checkpointCmd = &cobra.Command{
+	Use:   "checkpoint",
+	Short: "Manage session checkpoints",
+	Long: `Manage the checkpoints of sessions. A checkpoint is taken before every prompt
+and records the messages and file changes of the session so far. Restoring one
+goes back to it, forking one starts a new session from it.`,
+}
+
+var checkpointListCmd = &cobra.Command{
+	Use:   "list [session-id]",
+	Short: "List the checkpoints of a session",
+	Long:  `List the checkpoints of a session, newest first. Without a session ID the latest session is used.`,
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		appInstance, err := SetupApp(cmd)
+		if err != nil {
+			return err
+		}
+		defer appInstance.Shutdown()
+
+		sessionID, err := sessionArg(cmd.Context(), appInstance, args)
+		if err != nil {
+			return err
+		}
+		checkpoints, err := appInstance.Checkpoints.List(cmd.Context(), sessionID)
+		if err != nil {
+			return err
+		}
+		if len(checkpoints) == 0 {
+			fmt.Println("The session has no checkpoints")
+			return nil
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tNAME\tFILES\tCREATED")
+		for _, checkpoint := range checkpoints {
+			fmt.Fprintf(w, "%s\t%s\t%d\t%s\n",
+				checkpoint.ID,
+				checkpoint.Name,
+				len(checkpoint.FileVersions),
+				time.Unix(checkpoint.CreatedAt, 0).Format(time.DateTime),
+			)
+		}
+		return w.Flush()
+	},
+}
+
+var checkpointCreateCmd = &cobra.Command{
+	Use:   "create [session-id]",
+	Short: "Create a checkpoint of a session",
+	Long:  `Create a checkpoint of where a session is now. Without a session ID the latest session is used.`,
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name, _ := cmd.Flags().GetString("name")
+
+		appInstance, err := SetupApp(cmd)
+		if err != nil {
+			return err
+		}
+		defer appInstance.Shutdown()
+
+		sessionID, err := sessionArg(cmd.Context(), appInstance, args)
+		if err != nil {
+			return err
+		}
+		checkpoint, err := appInstance.Checkpoints.Create(cmd.Context(), sessionID, name)
+		if err != nil {
+			return err
+		}
+		fmt.Println(checkpoint.ID)
+		return nil
+	},
+}
+
+var checkpointRestoreCmd = &cobra.Command{
+	Use:   "restore <checkpoint-id>",
+	Short: "Restore a session to a checkpoint",
+	Long: `Write back the files the session changed as they were at the checkpoint and
+delete the messages and checkpoints after it. Files modified outside floss since
+are left alone unless --force is set.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		force, _ := cmd.Flags().GetBool("force")
+
+		appInstance, err := SetupApp(cmd)
+		if err != nil {
+			return err
+		}
+		defer appInstance.Shutdown()
+
+		checkpoint, err := appInstance.Checkpoints.Get(cmd.Context(), args[0])
+		if err != nil {
+			return fmt.Errorf("failed to get checkpoint %s: %w", args[0], err)
+		}
+		if err := appInstance.Checkpoints.Restore(cmd.Context(), checkpoint.ID, force); err != nil {
+			return err
+		}
+		fmt.Printf("Restored %q\n", checkpoint.Name)
+		return nil
+	},
+}
+
+var checkpointForkCmd = &cobra.Command{
+	Use:   "fork <checkpoint-id>",
+	Short: "Start a new session from a checkpoint",
+	Long: `Create a session with the messages up to the checkpoint and write back the
+files as they were at it, leaving the session it was taken in as it is. The ID
+of the new session is printed.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		force, _ := cmd.Flags().GetBool("force")
+
+		appInstance, err := SetupApp(cmd)
+		if err != nil {
+			return err
+		}
+		defer appInstance.Shutdown()
+
+		fork, err := appInstance.Checkpoints.Fork(cmd.Context(), args[0], force)
+		if err != nil {
+			return err
+		}
+		fmt.Println(fork.ID)
+		return nil
+	},
+}
+
+func init() {
+	checkpointCmd.AddCommand(checkpointListCmd)
+	checkpointCmd.AddCommand(checkpointCreateCmd)
+	checkpointCmd.AddCommand(checkpointRestoreCmd)
+	checkpointCmd.AddCommand(checkpointForkCmd)
+
+	checkpointCreateCmd.Flags().String("name", "Checkpoint", "Name of the checkpoint")
+	checkpointRestoreCmd.Flags().Bool("force", false, "Overwrite files modified outside floss")
+	checkpointForkCmd.Flags().Bool("force", false, "Overwrite files modified outside floss")
+
+	rootCmd.AddCommand(checkpointCmd)
+}
@@ -0,0 +1,136 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+
+	"github.com/nom-nom-hub/floss/internal/app"
+	"github.com/nom-nom-hub/floss/internal/history"
+	"github.com/spf13/cobra"
+)
+
+var revertCmd = &cobra.Command{
+	Use:   "revert [session-id] [file]",
+	Short: "Revert the file changes of a session",
+	Long: `Write back the files a session changed as they were before it.
+Without a session ID the latest session is used. With a file only that file is
+reverted. Files modified outside floss since are left alone unless --force is set.`,
+	Example: `
+# Revert everything the latest session changed
+floss revert
+
+# Undo the last change of a session
+floss revert 3f2a9c1e-... --last
+
+# Revert a file to how it was after the first change of the session
+floss revert 3f2a9c1e-... main.go --change 1
+  `,
+	Args: cobra.MaximumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		last, _ := cmd.Flags().GetBool("last")
+		force, _ := cmd.Flags().GetBool("force")
+		change, _ := cmd.Flags().GetInt("change")
+		if last && len(args) > 1 {
+			return fmt.Errorf("--last undoes the last change of any file, leave the file out")
+		}
+		if cmd.Flags().Changed("change") && len(args) < 2 {
+			return fmt.Errorf("--change needs a file")
+		}
+
+		appInstance, err := SetupApp(cmd)
+		if err != nil {
+			return err
+		}
+		defer appInstance.Shutdown()
+		ctx := cmd.Context()
+
+		sessionID, err := sessionArg(ctx, appInstance, args)
+		if err != nil {
+			return err
+		}
+
+		var reverted []history.File
+		switch {
+		case last:
+			file, err := appInstance.History.Undo(ctx, sessionID, force)
+			if errors.Is(err, history.ErrNothingToUndo) {
+				fmt.Println("Nothing to undo")
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			reverted = append(reverted, file)
+		case len(args) > 1:
+			path := args[1]
+			if !filepath.IsAbs(path) {
+				path = filepath.Join(appInstance.Config().WorkingDir(), path)
+			}
+			files, err := appInstance.History.ListBySession(ctx, sessionID)
+			if err != nil {
+				return err
+			}
+			// The first version is from before the session, then one
+			// follows each change
+			versions := slices.DeleteFunc(files, func(file history.File) bool {
+				return file.Path != path
+			})
+			if len(versions) == 0 {
+				return fmt.Errorf("the session didn't change %s", args[1])
+			}
+			if change < 0 || change >= len(versions) {
+				return fmt.Errorf("the session changed %s %d times", args[1], len(versions)-1)
+			}
+			file, err := appInstance.History.Revert(ctx, sessionID, path, versions[change].Version, force)
+			if err != nil {
+				return err
+			}
+			reverted = append(reverted, file)
+		default:
+			reverted, err = appInstance.History.RevertSession(ctx, sessionID, force)
+			if err != nil {
+				return err
+			}
+		}
+
+		for _, file := range reverted {
+			fmt.Printf("Reverted %s\n", file.Path)
+		}
+		if len(reverted) == 0 {
+			fmt.Println("The session changed no files")
+		}
+		return nil
+	},
+}
+
+// sessionArg returns the session ID given as the first argument, or the
+// latest session without one
+func sessionArg(ctx context.Context, appInstance *app.App, args []string) (string, error) {
+	var sessionID string
+	if len(args) > 0 {
+		sessionID = args[0]
+	} else {
+		sessions, err := appInstance.Sessions.List(ctx)
+		if err != nil {
+			return "", fmt.Errorf("failed to list sessions: %w", err)
+		}
+		if len(sessions) == 0 {
+			return "", fmt.Errorf("there is no session yet")
+		}
+		sessionID = sessions[0].ID
+	}
+	if _, err := appInstance.Sessions.Get(ctx, sessionID); err != nil {
+		return "", fmt.Errorf("failed to get session %s: %w", sessionID, err)
+	}
+	return sessionID, nil
+}
+
+func init() {
+	revertCmd.Flags().Bool("last", false, "Undo only the last change")
+	revertCmd.Flags().Int("change", 0, "Revert the file to how it was after this change of the session, 0 for before it")
+	revertCmd.Flags().Bool("force", false, "Overwrite files modified outside floss")
+	rootCmd.AddCommand(revertCmd)
+}
@@ -78,6 +78,9 @@ floss -y
 
 		go appInstance.Subscribe(program)
 
+		// Pick up agent workflows interrupted by a previous exit.
+		appInstance.ResumeWorkflows()
+
 		if _, err := program.Run(); err != nil {
 			slog.Error("TUI run error", "error", err)
 			return fmt.Errorf("TUI error: %v", err)
@@ -5,6 +5,7 @@ import (
 	"log/slog"
 	"strings"
 
+	"github.com/nom-nom-hub/floss/internal/app"
 	"github.com/spf13/cobra"
 )
 
@@ -22,9 +23,17 @@ echo "What is this code doing?" | floss run
 
 # Run with quiet mode (no spinner)
 floss run -q "Generate a README for this project"
+
+# Print the events of the run as newline-delimited JSON
+floss run --output-format stream-json "Fix the failing tests"
   `,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		quiet, _ := cmd.Flags().GetBool("quiet")
+		outputFormatName, _ := cmd.Flags().GetString("output-format")
+		outputFormat, err := app.ParseOutputFormat(outputFormatName)
+		if err != nil {
+			return err
+		}
 
 		appInstance, err := SetupApp(cmd)
 		if err != nil {
@@ -49,10 +58,11 @@ floss run -q "Generate a README for this project"
 		}
 
 		// Run non-interactive flow using the App method
-		return appInstance.RunNonInteractive(cmd.Context(), prompt, quiet)
+		return appInstance.RunNonInteractive(cmd.Context(), prompt, quiet, outputFormat)
 	},
 }
 
 func init() {
 	runCmd.Flags().BoolP("quiet", "q", false, "Hide spinner")
+	runCmd.Flags().String("output-format", string(app.OutputFormatText), "Output format: text, json or stream-json")
 }
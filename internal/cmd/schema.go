@@ -5,6 +5,7 @@ import (
 	"fmt"
 
 	"github.com/invopop/jsonschema"
+	"github.com/nom-nom-hub/floss/internal/agent"
 	"github.com/nom-nom-hub/floss/internal/config"
 	"github.com/spf13/cobra"
 )
@@ -12,11 +13,17 @@ import (
 var schemaCmd = &cobra.Command{
 	Use:    "schema",
 	Short:  "Generate JSON schema for configuration",
-	Long:   "Generate JSON schema for the crush configuration file",
+	Long:   "Generate JSON schema for the crush configuration file, or with --workflow for agent workflow files",
 	Hidden: true,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		reflector := new(jsonschema.Reflector)
-		bts, err := json.MarshalIndent(reflector.Reflect(&config.Config{}), "", "  ")
+		var schema *jsonschema.Schema
+		if workflow, _ := cmd.Flags().GetBool("workflow"); workflow {
+			schema = agent.WorkflowSchema()
+		} else {
+			reflector := new(jsonschema.Reflector)
+			schema = reflector.Reflect(&config.Config{})
+		}
+		bts, err := json.MarshalIndent(schema, "", "  ")
 		if err != nil {
 			return fmt.Errorf("failed to marshal schema: %w", err)
 		}
@@ -26,5 +33,6 @@ var schemaCmd = &cobra.Command{
 }
 
 func init() {
+	schemaCmd.Flags().Bool("workflow", false, "Generate the schema for workflow files")
 	rootCmd.AddCommand(schemaCmd)
 }
@@ -41,6 +41,14 @@ var defaultContextPaths = []string{
 	"Agents.md",
 }
 
+// ProviderTypeOllama is the type of providers that talk to the native API of
+// Ollama, which lists the local models by itself
+const ProviderTypeOllama catwalk.Type = "ollama"
+
+// ProviderTypeReplay is the type of providers that replay the responses
+// recorded to the fixture file at their base URL, as for tests
+const ProviderTypeReplay catwalk.Type = "replay"
+
 type SelectedModelType string
 
 const (
@@ -64,6 +72,10 @@ type SelectedModel struct {
 
 	// Used by anthropic models that can reason to indicate if the model should think.
 	Think bool `json:"think,omitempty" jsonschema:"description=Enable thinking mode for Anthropic models that support reasoning"`
+
+	// Models tried in order when the model fails, for example because its
+	// provider is down or rate limited.
+	Fallbacks []SelectedModel `json:"fallbacks,omitempty" jsonschema:"description=Models tried in order when this model fails or is rate limited"`
 }
 
 type ProviderConfig struct {
@@ -74,7 +86,7 @@ type ProviderConfig struct {
 	// The provider's API endpoint.
 	BaseURL string `json:"base_url,omitempty" jsonschema:"description=Base URL for the provider's API,format=uri,example=https://api.openai.com/v1"`
 	// The provider type, e.g. "openai", "anthropic", etc. if empty it defaults to openai.
-	Type catwalk.Type `json:"type,omitempty" jsonschema:"description=Provider type that determines the API format,enum=openai,enum=anthropic,enum=gemini,enum=azure,enum=vertexai,default=openai"`
+	Type catwalk.Type `json:"type,omitempty" jsonschema:"description=Provider type that determines the API format,enum=openai,enum=anthropic,enum=gemini,enum=azure,enum=vertexai,enum=ollama,enum=replay,default=openai"`
 	// The provider's API key.
 	APIKey string `json:"api_key,omitempty" jsonschema:"description=API key for authentication with the provider,example=$OPENAI_API_KEY"`
 	// Marks the provider as disabled.
@@ -125,6 +137,16 @@ type LSPConfig struct {
 	RootMarkers []string          `json:"root_markers,omitempty" jsonschema:"description=Files or directories that indicate the project root,example=go.mod,example=package.json,example=Cargo.toml"`
 	InitOptions map[string]any    `json:"init_options,omitempty" jsonschema:"description=Initialization options passed to the LSP server during initialize request"`
 	Options     map[string]any    `json:"options,omitempty" jsonschema:"description=LSP server-specific settings passed during initialization"`
+	Format      bool              `json:"format,omitempty" jsonschema:"description=Format the files this server handles after the agent edits them,default=false"`
+}
+
+// FormatterConfig is a command formatting the files the agent edits. It reads
+// the content on stdin and writes the formatted content to stdout.
+type FormatterConfig struct {
+	Disabled  bool     `json:"disabled,omitempty" jsonschema:"description=Whether this formatter is disabled,default=false"`
+	Command   string   `json:"command" jsonschema:"required,description=Command formatting stdin to stdout,example=gofmt,example=prettier"`
+	Args      []string `json:"args,omitempty" jsonschema:"description=Arguments to pass to the formatter command ($FILE is replaced with the path of the file),example=--stdin-filepath,example=$FILE"`
+	FileTypes []string `json:"filetypes" jsonschema:"required,description=File types this formatter handles,example=go,example=ts,example=tsx"`
 }
 
 type TUIOptions struct {
@@ -143,6 +165,93 @@ type Attribution struct {
 	GeneratedWith bool `json:"generated_with,omitempty" jsonschema:"description=Add Generated with Crush line to commit messages and issues and PRs,default=true"`
 }
 
+// Budgets limit what sessions may use. Past a limit the agent asks whether
+// to continue, and non-interactive runs stop.
+type Budgets struct {
+	Session UsageLimit `json:"session,omitempty" jsonschema:"description=Limits of each session including its task sessions"`
+	Daily   UsageLimit `json:"daily,omitempty" jsonschema:"description=Limits of all the requests made since midnight"`
+	// WarnAt is the fraction of a limit at which the agent warns
+	WarnAt float64 `json:"warn_at,omitempty" jsonschema:"description=Fraction of a limit at which to warn,minimum=0,maximum=1,default=0.8"`
+}
+
+// UsageLimit caps cost and tokens. Limits left at zero are not enforced.
+type UsageLimit struct {
+	MaxCost   float64 `json:"max_cost,omitempty" jsonschema:"description=Most US dollars to spend,minimum=0"`
+	MaxTokens int64   `json:"max_tokens,omitempty" jsonschema:"description=Most tokens to use,minimum=0"`
+}
+
+// defaultBudgetWarnAt is the fraction of a limit at which the agent warns
+// unless configured otherwise
+const defaultBudgetWarnAt = 0.8
+
+// WarnFraction returns the fraction of a limit at which to warn
+func (b *Budgets) WarnFraction() float64 {
+	if b.WarnAt <= 0 || b.WarnAt > 1 {
+		return defaultBudgetWarnAt
+	}
+	return b.WarnAt
+}
+
+// LoopGuard pauses requests that look like runaway tool-use loops to ask
+// whether to continue, and stops non-interactive runs. Limits left at zero
+// get the defaults and negative limits are not enforced.
+type LoopGuard struct {
+	MaxIterations    int `json:"max_iterations,omitempty" jsonschema:"description=Tool-use iterations a request may run before pausing (negative to disable),default=100"`
+	MaxRepeatedCalls int `json:"max_repeated_calls,omitempty" jsonschema:"description=Times a request may call a tool with the same input before pausing (negative to disable),default=8"`
+}
+
+const (
+	defaultLoopGuardMaxIterations    = 100
+	defaultLoopGuardMaxRepeatedCalls = 8
+)
+
+// IterationLimit returns the tool-use iterations a request may run, or 0
+// when they are not limited
+func (g *LoopGuard) IterationLimit() int {
+	if g == nil || g.MaxIterations == 0 {
+		return defaultLoopGuardMaxIterations
+	}
+	return max(g.MaxIterations, 0)
+}
+
+// RepeatLimit returns the times a request may make the same tool call, or 0
+// when they are not limited
+func (g *LoopGuard) RepeatLimit() int {
+	if g == nil || g.MaxRepeatedCalls == 0 {
+		return defaultLoopGuardMaxRepeatedCalls
+	}
+	return max(g.MaxRepeatedCalls, 0)
+}
+
+// Compaction controls how the history of a session gets summarized as it
+// nears the context window of the model
+type Compaction struct {
+	KeepTurns int     `json:"keep_turns,omitempty" jsonschema:"description=Latest turns to keep verbatim when compacting (negative to keep none),default=2"`
+	Threshold float64 `json:"threshold,omitempty" jsonschema:"description=Fraction of the context window the estimated history may fill before it gets compacted,minimum=0,maximum=1,default=0.8"`
+}
+
+const (
+	defaultCompactionKeepTurns = 2
+	defaultCompactionThreshold = 0.8
+)
+
+// TurnsToKeep returns the latest turns to keep verbatim when compacting
+func (c *Compaction) TurnsToKeep() int {
+	if c == nil || c.KeepTurns == 0 {
+		return defaultCompactionKeepTurns
+	}
+	return max(c.KeepTurns, 0)
+}
+
+// ThresholdFraction returns the fraction of the context window at which
+// the history gets compacted
+func (c *Compaction) ThresholdFraction() float64 {
+	if c == nil || c.Threshold <= 0 || c.Threshold > 1 {
+		return defaultCompactionThreshold
+	}
+	return c.Threshold
+}
+
 type Options struct {
 	ContextPaths              []string     `json:"context_paths,omitempty" jsonschema:"description=Paths to files containing context information for the AI,example=.cursorrules,example=CRUSH.md"`
 	TUI                       *TUIOptions  `json:"tui,omitempty" jsonschema:"description=Terminal user interface options"`
@@ -153,6 +262,10 @@ type Options struct {
 	DisabledTools             []string     `json:"disabled_tools" jsonschema:"description=Tools to disable"`
 	DisableProviderAutoUpdate bool         `json:"disable_provider_auto_update,omitempty" jsonschema:"description=Disable providers auto-update,default=false"`
 	Attribution               *Attribution `json:"attribution,omitempty" jsonschema:"description=Attribution settings for generated content"`
+	Budgets                   *Budgets     `json:"budgets,omitempty" jsonschema:"description=Cost and token budgets of sessions"`
+	Compaction                *Compaction  `json:"compaction,omitempty" jsonschema:"description=How session histories get compacted"`
+	LoopGuard                 *LoopGuard   `json:"loop_guard,omitempty" jsonschema:"description=Limits that pause runaway tool-use loops"`
+	RecordDirectory           string       `json:"record_directory,omitempty" jsonschema:"description=Directory to record the provider responses of agents to as fixtures for the replay provider type,example=testdata/recordings"`
 }
 
 type MCPs map[string]MCPConfig
@@ -197,6 +310,27 @@ func (l LSPs) Sorted() []LSP {
 	return sorted
 }
 
+type Formatters map[string]FormatterConfig
+
+type Formatter struct {
+	Name      string          `json:"name"`
+	Formatter FormatterConfig `json:"formatter"`
+}
+
+func (f Formatters) Sorted() []Formatter {
+	sorted := make([]Formatter, 0, len(f))
+	for k, v := range f {
+		sorted = append(sorted, Formatter{
+			Name:      k,
+			Formatter: v,
+		})
+	}
+	slices.SortFunc(sorted, func(a, b Formatter) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return sorted
+}
+
 func (l LSPConfig) ResolvedEnv() []string {
 	return resolveEnvs(l.Env)
 }
@@ -232,7 +366,7 @@ type Agent struct {
 	AllowedTools []string `json:"allowed_tools,omitempty"`
 
 	// this tells us which MCPs are available for this agent
-	//  if this is empty all mcps are available
+	//  if this is nil all mcps are available, if it is empty none are
 	//  the string array is the list of tools from the AllowedMCP the agent has available
 	//  if the string array is nil, all tools from the AllowedMCP are available
 	AllowedMCP map[string][]string `json:"allowed_mcp,omitempty"`
@@ -243,6 +377,9 @@ type Agent struct {
 
 	// Overrides the context paths for this agent
 	ContextPaths []string `json:"context_paths,omitempty"`
+
+	// Overrides the system prompt for this agent
+	Prompt string `json:"prompt,omitempty"`
 }
 
 // Config holds the configuration for crush.
@@ -259,6 +396,8 @@ type Config struct {
 
 	LSP LSPs `json:"lsp,omitempty" jsonschema:"description=Language Server Protocol configurations"`
 
+	Formatters Formatters `json:"formatters,omitempty" jsonschema:"description=Commands formatting the files the agent edits, taking precedence over language servers"`
+
 	Options *Options `json:"options,omitempty" jsonschema:"description=General application options"`
 
 	Permissions *Permissions `json:"permissions,omitempty" jsonschema:"description=Permission settings for tool usage"`
@@ -354,8 +493,14 @@ func (c *Config) Resolve(key string) (string, error) {
 }
 
 func (c *Config) UpdatePreferredModel(modelType SelectedModelType, model SelectedModel) error {
-	c.Models[modelType] = model
-	if err := c.SetConfigField(fmt.Sprintf("models.%s", modelType), model); err != nil {
+	// The fallbacks stay with the model type when another model is picked,
+	// and are saved with it so they are used after a restart too
+	selected := model
+	if selected.Fallbacks == nil {
+		selected.Fallbacks = c.Models[modelType].Fallbacks
+	}
+	c.Models[modelType] = selected
+	if err := c.SetConfigField(fmt.Sprintf("models.%s", modelType), selected); err != nil {
 		return fmt.Errorf("failed to update preferred model: %w", err)
 	}
 	return nil
@@ -438,6 +583,13 @@ func allToolNames() []string {
 		"sourcegraph",
 		"view",
 		"write",
+		"definition",
+		"references",
+		"hover",
+		"document_symbols",
+		"workspace_symbols",
+		"rename",
+		"code_action",
 	}
 }
 
@@ -1,17 +1,20 @@
 package config
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log/slog"
 	"maps"
+	"net/http"
 	"os"
 	"path/filepath"
 	"runtime"
 	"slices"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/charmbracelet/catwalk/pkg/catwalk"
 	powernapConfig "github.com/charmbracelet/x/powernap/pkg/config"
@@ -20,10 +23,15 @@ import (
 	"github.com/nom-nom-hub/floss/internal/fsext"
 	"github.com/nom-nom-hub/floss/internal/home"
 	"github.com/nom-nom-hub/floss/internal/log"
+	"github.com/nom-nom-hub/floss/internal/ollama"
 )
 
 const defaultCatwalkURL = "https://catwalk.charm.sh"
 
+// ollamaDiscoveryTimeout bounds listing the models of an Ollama server at
+// startup
+const ollamaDiscoveryTimeout = 5 * time.Second
+
 // LoadReader config via io.Reader.
 func LoadReader(fd io.Reader) (*Config, error) {
 	data, err := io.ReadAll(fd)
@@ -267,7 +275,12 @@ func (c *Config) configureProviders(env env.Env, resolver VariableResolver, know
 			c.Providers.Del(id)
 			continue
 		}
-		if providerConfig.APIKey == "" {
+		if providerConfig.Type == ProviderTypeOllama {
+			if providerConfig.BaseURL == "" {
+				providerConfig.BaseURL = ollama.DefaultBaseURL
+			}
+			providerConfig.Models = discoverOllamaModels(providerConfig, resolver)
+		} else if providerConfig.APIKey == "" && providerConfig.Type != ProviderTypeReplay {
 			slog.Warn("Provider is missing API key, this might be OK for local providers", "provider", id)
 		}
 		if providerConfig.BaseURL == "" {
@@ -280,14 +293,14 @@ func (c *Config) configureProviders(env env.Env, resolver VariableResolver, know
 			c.Providers.Del(id)
 			continue
 		}
-		if providerConfig.Type != catwalk.TypeOpenAI && providerConfig.Type != catwalk.TypeAnthropic && providerConfig.Type != "qwen" {
+		if providerConfig.Type != catwalk.TypeOpenAI && providerConfig.Type != catwalk.TypeAnthropic && providerConfig.Type != "qwen" && providerConfig.Type != ProviderTypeOllama && providerConfig.Type != ProviderTypeReplay {
 			slog.Warn("Skipping custom provider because the provider type is not supported", "provider", id, "type", providerConfig.Type)
 			c.Providers.Del(id)
 			continue
 		}
 
 		apiKey, err := resolver.ResolveValue(providerConfig.APIKey)
-		if apiKey == "" || err != nil {
+		if (apiKey == "" || err != nil) && providerConfig.Type != ProviderTypeOllama && providerConfig.Type != ProviderTypeReplay {
 			slog.Warn("Provider is missing API key, this might be OK for local providers", "provider", id)
 		}
 		baseURL, err := resolver.ResolveValue(providerConfig.BaseURL)
@@ -302,6 +315,50 @@ func (c *Config) configureProviders(env env.Env, resolver VariableResolver, know
 	return nil
 }
 
+// discoverOllamaModels adds the models pulled to the Ollama server of a
+// provider to its configured models, which take precedence. When the server
+// can't be reached only the configured models are kept.
+func discoverOllamaModels(providerConfig ProviderConfig, resolver VariableResolver) []catwalk.Model {
+	baseURL, err := resolver.ResolveValue(providerConfig.BaseURL)
+	if err != nil {
+		slog.Warn("Failed to resolve Ollama base URL", "provider", providerConfig.ID, "error", err)
+		return providerConfig.Models
+	}
+	client := ollama.NewClient(baseURL, &http.Client{Timeout: ollamaDiscoveryTimeout})
+	for key, value := range providerConfig.ExtraHeaders {
+		if resolved, err := resolver.ResolveValue(value); err == nil {
+			client.Header.Set(key, resolved)
+		}
+	}
+	if apiKey, err := resolver.ResolveValue(providerConfig.APIKey); err == nil && apiKey != "" {
+		client.Header.Set("Authorization", "Bearer "+apiKey)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), ollamaDiscoveryTimeout)
+	defer cancel()
+	discovered, err := client.Models(ctx)
+	if err != nil {
+		slog.Warn("Failed to list Ollama models", "provider", providerConfig.ID, "base_url", baseURL, "error", err)
+		return providerConfig.Models
+	}
+
+	models := make([]catwalk.Model, 0, len(providerConfig.Models)+len(discovered))
+	seen := make(map[string]bool)
+	for _, model := range providerConfig.Models {
+		seen[model.ID] = true
+		if model.Name == "" {
+			model.Name = model.ID
+		}
+		models = append(models, model)
+	}
+	for _, model := range discovered {
+		if !seen[model.ID] {
+			models = append(models, model)
+		}
+	}
+	return models
+}
+
 func (c *Config) setDefaults(workingDir, dataDir string) {
 	c.workingDir = workingDir
 	if c.Options == nil {
@@ -481,6 +538,7 @@ func (c *Config) configureSelectedModels(knownProviders []catwalk.Provider) erro
 			}
 			large.Think = largeModelSelected.Think
 		}
+		large.Fallbacks = c.configureFallbacks(largeModelSelected.Fallbacks)
 	}
 	smallModelSelected, smallModelConfigured := c.Models[SelectedModelTypeSmall]
 	if smallModelConfigured {
@@ -508,12 +566,32 @@ func (c *Config) configureSelectedModels(knownProviders []catwalk.Provider) erro
 			small.ReasoningEffort = smallModelSelected.ReasoningEffort
 			small.Think = smallModelSelected.Think
 		}
+		small.Fallbacks = c.configureFallbacks(smallModelSelected.Fallbacks)
 	}
 	c.Models[SelectedModelTypeLarge] = large
 	c.Models[SelectedModelTypeSmall] = small
 	return nil
 }
 
+// configureFallbacks keeps the fallback models of configured providers, in
+// order, with their max tokens defaulted like those of the selected models
+func (c *Config) configureFallbacks(fallbacks []SelectedModel) []SelectedModel {
+	var configured []SelectedModel
+	for _, fallback := range fallbacks {
+		model := c.GetModel(fallback.Provider, fallback.Model)
+		if model == nil {
+			slog.Warn("Skipping fallback model that is not configured", "provider", fallback.Provider, "model", fallback.Model)
+			continue
+		}
+		if fallback.MaxTokens == 0 {
+			fallback.MaxTokens = model.DefaultMaxTokens
+		}
+		fallback.Fallbacks = nil
+		configured = append(configured, fallback)
+	}
+	return configured
+}
+
 func loadFromConfigPaths(configPaths []string) (*Config, error) {
 	var configs []io.Reader
 
@@ -1,8 +1,11 @@
 package config
 
 import (
+	"encoding/json"
 	"io"
 	"log/slog"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"strings"
@@ -485,7 +488,7 @@ func TestConfig_setupAgentsWithDisabledTools(t *testing.T) {
 	cfg.SetupAgents()
 	coderAgent, ok := cfg.Agents["coder"]
 	require.True(t, ok)
-	assert.Equal(t, []string{"bash", "multiedit", "fetch", "glob", "ls", "sourcegraph", "view", "write"}, coderAgent.AllowedTools)
+	assert.Equal(t, []string{"bash", "multiedit", "fetch", "glob", "ls", "sourcegraph", "view", "write", "definition", "references", "hover", "document_symbols", "workspace_symbols", "rename", "code_action"}, coderAgent.AllowedTools)
 
 	taskAgent, ok := cfg.Agents["task"]
 	require.True(t, ok)
@@ -680,6 +683,69 @@ func TestConfig_configureProvidersCustomProviderValidation(t *testing.T) {
 		require.Equal(t, catwalk.TypeAnthropic, customProvider.Type)
 	})
 
+	t.Run("ollama provider lists the models of the server", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/api/tags":
+				w.Write([]byte(`{"models":[{"name":"llama3.2:3b"},{"name":"qwen3:8b"}]}`))
+			case "/api/show":
+				w.Write([]byte(`{"details":{"parameter_size":"3.2B"},"model_info":{"llama.context_length":131072},"capabilities":["completion","tools"]}`))
+			}
+		}))
+		defer server.Close()
+
+		cfg := &Config{
+			Providers: csync.NewMapFrom(map[string]ProviderConfig{
+				"ollama": {
+					BaseURL: server.URL + "/v1",
+					Type:    ProviderTypeOllama,
+					Models: []catwalk.Model{{
+						ID:            "qwen3:8b",
+						ContextWindow: 32768,
+					}},
+				},
+			}),
+		}
+		cfg.setDefaults("/tmp", "")
+
+		env := env.NewFromMap(map[string]string{})
+		resolver := NewEnvironmentVariableResolver(env)
+		err := cfg.configureProviders(env, resolver, []catwalk.Provider{})
+		require.NoError(t, err)
+
+		ollamaProvider, exists := cfg.Providers.Get("ollama")
+		require.True(t, exists)
+		require.Len(t, ollamaProvider.Models, 2)
+		// Configured models take precedence over the listed ones
+		require.Equal(t, "qwen3:8b", ollamaProvider.Models[0].ID)
+		require.Equal(t, int64(32768), ollamaProvider.Models[0].ContextWindow)
+		require.Equal(t, "llama3.2:3b", ollamaProvider.Models[1].ID)
+		require.Equal(t, int64(131072), ollamaProvider.Models[1].ContextWindow)
+	})
+
+	t.Run("unreachable ollama provider without models is removed", func(t *testing.T) {
+		server := httptest.NewServer(http.NotFoundHandler())
+		server.Close()
+
+		cfg := &Config{
+			Providers: csync.NewMapFrom(map[string]ProviderConfig{
+				"ollama": {
+					BaseURL: server.URL,
+					Type:    ProviderTypeOllama,
+				},
+			}),
+		}
+		cfg.setDefaults("/tmp", "")
+
+		env := env.NewFromMap(map[string]string{})
+		resolver := NewEnvironmentVariableResolver(env)
+		err := cfg.configureProviders(env, resolver, []catwalk.Provider{})
+		require.NoError(t, err)
+
+		_, exists := cfg.Providers.Get("ollama")
+		require.False(t, exists)
+	})
+
 	t.Run("disabled custom provider is removed", func(t *testing.T) {
 		cfg := &Config{
 			Providers: csync.NewMapFrom(map[string]ProviderConfig{
@@ -1222,4 +1288,90 @@ func TestConfig_configureSelectedModels(t *testing.T) {
 		require.Equal(t, "openai", large.Provider)
 		require.Equal(t, int64(100), large.MaxTokens)
 	})
+	t.Run("should keep the configured fallbacks", func(t *testing.T) {
+		knownProviders := []catwalk.Provider{
+			{
+				ID:                  "openai",
+				APIKey:              "abc",
+				DefaultLargeModelID: "large-model",
+				DefaultSmallModelID: "small-model",
+				Models: []catwalk.Model{
+					{
+						ID:               "large-model",
+						DefaultMaxTokens: 1000,
+					},
+					{
+						ID:               "small-model",
+						DefaultMaxTokens: 500,
+					},
+				},
+			},
+			{
+				ID:                  "anthropic",
+				APIKey:              "abc",
+				DefaultLargeModelID: "a-large-model",
+				DefaultSmallModelID: "a-small-model",
+				Models: []catwalk.Model{
+					{
+						ID:               "a-large-model",
+						DefaultMaxTokens: 2000,
+					},
+					{
+						ID:               "a-small-model",
+						DefaultMaxTokens: 300,
+					},
+				},
+			},
+		}
+
+		cfg := &Config{
+			Models: map[SelectedModelType]SelectedModel{
+				"large": {
+					Model:    "large-model",
+					Provider: "openai",
+					Fallbacks: []SelectedModel{
+						{Model: "a-large-model", Provider: "anthropic", Think: true},
+						{Model: "missing-model", Provider: "anthropic"},
+						{Model: "small-model", Provider: "openai", MaxTokens: 200},
+					},
+				},
+			},
+		}
+		cfg.setDefaults("/tmp", "")
+		env := env.NewFromMap(map[string]string{})
+		resolver := NewEnvironmentVariableResolver(env)
+		err := cfg.configureProviders(env, resolver, knownProviders)
+		require.NoError(t, err)
+
+		err = cfg.configureSelectedModels(knownProviders)
+		require.NoError(t, err)
+		large := cfg.Models[SelectedModelTypeLarge]
+		require.Equal(t, []SelectedModel{
+			{Model: "a-large-model", Provider: "anthropic", Think: true, MaxTokens: 2000},
+			{Model: "small-model", Provider: "openai", MaxTokens: 200},
+		}, large.Fallbacks)
+		require.Empty(t, cfg.Models[SelectedModelTypeSmall].Fallbacks)
+	})
+}
+
+func TestConfig_UpdatePreferredModelKeepsFallbacks(t *testing.T) {
+	t.Parallel()
+
+	fallbacks := []SelectedModel{{Model: "gpt-4o", Provider: "openai"}}
+	cfg := &Config{
+		Models: map[SelectedModelType]SelectedModel{
+			SelectedModelTypeLarge: {Model: "claude-sonnet-4", Provider: "anthropic", Fallbacks: fallbacks},
+		},
+		dataConfigDir: filepath.Join(t.TempDir(), "floss.json"),
+	}
+
+	require.NoError(t, cfg.UpdatePreferredModel(SelectedModelTypeLarge, SelectedModel{Model: "claude-opus-4", Provider: "anthropic"}))
+	require.Equal(t, fallbacks, cfg.Models[SelectedModelTypeLarge].Fallbacks)
+
+	// The fallbacks are saved with the model
+	data, err := os.ReadFile(cfg.dataConfigDir)
+	require.NoError(t, err)
+	var saved Config
+	require.NoError(t, json.Unmarshal(data, &saved))
+	require.Equal(t, cfg.Models[SelectedModelTypeLarge], saved.Models[SelectedModelTypeLarge])
 }
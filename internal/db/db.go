@@ -24,6 +24,15 @@ func New(db DBTX) *Queries {
 func Prepare(ctx context.Context, db DBTX) (*Queries, error) {
 	q := Queries{db: db}
 	var err error
+	if q.claimWorkflowInstanceStmt, err = db.PrepareContext(ctx, claimWorkflowInstance); err != nil {
+		return nil, fmt.Errorf("error preparing query ClaimWorkflowInstance: %w", err)
+	}
+	if q.createAgentMessageStmt, err = db.PrepareContext(ctx, createAgentMessage); err != nil {
+		return nil, fmt.Errorf("error preparing query CreateAgentMessage: %w", err)
+	}
+	if q.createCheckpointStmt, err = db.PrepareContext(ctx, createCheckpoint); err != nil {
+		return nil, fmt.Errorf("error preparing query CreateCheckpoint: %w", err)
+	}
 	if q.createFileStmt, err = db.PrepareContext(ctx, createFile); err != nil {
 		return nil, fmt.Errorf("error preparing query CreateFile: %w", err)
 	}
@@ -33,6 +42,18 @@ func Prepare(ctx context.Context, db DBTX) (*Queries, error) {
 	if q.createSessionStmt, err = db.PrepareContext(ctx, createSession); err != nil {
 		return nil, fmt.Errorf("error preparing query CreateSession: %w", err)
 	}
+	if q.createSessionUsageStmt, err = db.PrepareContext(ctx, createSessionUsage); err != nil {
+		return nil, fmt.Errorf("error preparing query CreateSessionUsage: %w", err)
+	}
+	if q.createWorkflowInstanceStmt, err = db.PrepareContext(ctx, createWorkflowInstance); err != nil {
+		return nil, fmt.Errorf("error preparing query CreateWorkflowInstance: %w", err)
+	}
+	if q.deleteCheckpointStmt, err = db.PrepareContext(ctx, deleteCheckpoint); err != nil {
+		return nil, fmt.Errorf("error preparing query DeleteCheckpoint: %w", err)
+	}
+	if q.deleteCheckpointsAfterStmt, err = db.PrepareContext(ctx, deleteCheckpointsAfter); err != nil {
+		return nil, fmt.Errorf("error preparing query DeleteCheckpointsAfter: %w", err)
+	}
 	if q.deleteFileStmt, err = db.PrepareContext(ctx, deleteFile); err != nil {
 		return nil, fmt.Errorf("error preparing query DeleteFile: %w", err)
 	}
@@ -48,18 +69,42 @@ func Prepare(ctx context.Context, db DBTX) (*Queries, error) {
 	if q.deleteSessionMessagesStmt, err = db.PrepareContext(ctx, deleteSessionMessages); err != nil {
 		return nil, fmt.Errorf("error preparing query DeleteSessionMessages: %w", err)
 	}
+	if q.deleteWorkflowInstanceStmt, err = db.PrepareContext(ctx, deleteWorkflowInstance); err != nil {
+		return nil, fmt.Errorf("error preparing query DeleteWorkflowInstance: %w", err)
+	}
+	if q.getCheckpointStmt, err = db.PrepareContext(ctx, getCheckpoint); err != nil {
+		return nil, fmt.Errorf("error preparing query GetCheckpoint: %w", err)
+	}
 	if q.getFileStmt, err = db.PrepareContext(ctx, getFile); err != nil {
 		return nil, fmt.Errorf("error preparing query GetFile: %w", err)
 	}
 	if q.getFileByPathAndSessionStmt, err = db.PrepareContext(ctx, getFileByPathAndSession); err != nil {
 		return nil, fmt.Errorf("error preparing query GetFileByPathAndSession: %w", err)
 	}
+	if q.getLatestSessionFileChangeStmt, err = db.PrepareContext(ctx, getLatestSessionFileChange); err != nil {
+		return nil, fmt.Errorf("error preparing query GetLatestSessionFileChange: %w", err)
+	}
 	if q.getMessageStmt, err = db.PrepareContext(ctx, getMessage); err != nil {
 		return nil, fmt.Errorf("error preparing query GetMessage: %w", err)
 	}
 	if q.getSessionByIDStmt, err = db.PrepareContext(ctx, getSessionByID); err != nil {
 		return nil, fmt.Errorf("error preparing query GetSessionByID: %w", err)
 	}
+	if q.getUsageSinceStmt, err = db.PrepareContext(ctx, getUsageSince); err != nil {
+		return nil, fmt.Errorf("error preparing query GetUsageSince: %w", err)
+	}
+	if q.getWorkflowInstanceStmt, err = db.PrepareContext(ctx, getWorkflowInstance); err != nil {
+		return nil, fmt.Errorf("error preparing query GetWorkflowInstance: %w", err)
+	}
+	if q.getWorkflowStepStmt, err = db.PrepareContext(ctx, getWorkflowStep); err != nil {
+		return nil, fmt.Errorf("error preparing query GetWorkflowStep: %w", err)
+	}
+	if q.listAgentMessagesBySessionStmt, err = db.PrepareContext(ctx, listAgentMessagesBySession); err != nil {
+		return nil, fmt.Errorf("error preparing query ListAgentMessagesBySession: %w", err)
+	}
+	if q.listCheckpointsBySessionStmt, err = db.PrepareContext(ctx, listCheckpointsBySession); err != nil {
+		return nil, fmt.Errorf("error preparing query ListCheckpointsBySession: %w", err)
+	}
 	if q.listFilesByPathStmt, err = db.PrepareContext(ctx, listFilesByPath); err != nil {
 		return nil, fmt.Errorf("error preparing query ListFilesByPath: %w", err)
 	}
@@ -75,20 +120,62 @@ func Prepare(ctx context.Context, db DBTX) (*Queries, error) {
 	if q.listNewFilesStmt, err = db.PrepareContext(ctx, listNewFiles); err != nil {
 		return nil, fmt.Errorf("error preparing query ListNewFiles: %w", err)
 	}
+	if q.listSessionFilesInOrderStmt, err = db.PrepareContext(ctx, listSessionFilesInOrder); err != nil {
+		return nil, fmt.Errorf("error preparing query ListSessionFilesInOrder: %w", err)
+	}
 	if q.listSessionsStmt, err = db.PrepareContext(ctx, listSessions); err != nil {
 		return nil, fmt.Errorf("error preparing query ListSessions: %w", err)
 	}
+	if q.listUnfinishedWorkflowInstancesStmt, err = db.PrepareContext(ctx, listUnfinishedWorkflowInstances); err != nil {
+		return nil, fmt.Errorf("error preparing query ListUnfinishedWorkflowInstances: %w", err)
+	}
+	if q.listWorkflowInstancesStmt, err = db.PrepareContext(ctx, listWorkflowInstances); err != nil {
+		return nil, fmt.Errorf("error preparing query ListWorkflowInstances: %w", err)
+	}
+	if q.listWorkflowStepsStmt, err = db.PrepareContext(ctx, listWorkflowSteps); err != nil {
+		return nil, fmt.Errorf("error preparing query ListWorkflowSteps: %w", err)
+	}
+	if q.releaseWorkflowInstanceStmt, err = db.PrepareContext(ctx, releaseWorkflowInstance); err != nil {
+		return nil, fmt.Errorf("error preparing query ReleaseWorkflowInstance: %w", err)
+	}
+	if q.setWorkflowStepApprovalStmt, err = db.PrepareContext(ctx, setWorkflowStepApproval); err != nil {
+		return nil, fmt.Errorf("error preparing query SetWorkflowStepApproval: %w", err)
+	}
 	if q.updateMessageStmt, err = db.PrepareContext(ctx, updateMessage); err != nil {
 		return nil, fmt.Errorf("error preparing query UpdateMessage: %w", err)
 	}
+	if q.updateMessagePinnedStmt, err = db.PrepareContext(ctx, updateMessagePinned); err != nil {
+		return nil, fmt.Errorf("error preparing query UpdateMessagePinned: %w", err)
+	}
 	if q.updateSessionStmt, err = db.PrepareContext(ctx, updateSession); err != nil {
 		return nil, fmt.Errorf("error preparing query UpdateSession: %w", err)
 	}
+	if q.updateWorkflowInstanceStmt, err = db.PrepareContext(ctx, updateWorkflowInstance); err != nil {
+		return nil, fmt.Errorf("error preparing query UpdateWorkflowInstance: %w", err)
+	}
+	if q.upsertWorkflowStepStmt, err = db.PrepareContext(ctx, upsertWorkflowStep); err != nil {
+		return nil, fmt.Errorf("error preparing query UpsertWorkflowStep: %w", err)
+	}
 	return &q, nil
 }
 
 func (q *Queries) Close() error {
 	var err error
+	if q.claimWorkflowInstanceStmt != nil {
+		if cerr := q.claimWorkflowInstanceStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing claimWorkflowInstanceStmt: %w", cerr)
+		}
+	}
+	if q.createAgentMessageStmt != nil {
+		if cerr := q.createAgentMessageStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing createAgentMessageStmt: %w", cerr)
+		}
+	}
+	if q.createCheckpointStmt != nil {
+		if cerr := q.createCheckpointStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing createCheckpointStmt: %w", cerr)
+		}
+	}
 	if q.createFileStmt != nil {
 		if cerr := q.createFileStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing createFileStmt: %w", cerr)
@@ -104,6 +191,26 @@ func (q *Queries) Close() error {
 			err = fmt.Errorf("error closing createSessionStmt: %w", cerr)
 		}
 	}
+	if q.createSessionUsageStmt != nil {
+		if cerr := q.createSessionUsageStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing createSessionUsageStmt: %w", cerr)
+		}
+	}
+	if q.createWorkflowInstanceStmt != nil {
+		if cerr := q.createWorkflowInstanceStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing createWorkflowInstanceStmt: %w", cerr)
+		}
+	}
+	if q.deleteCheckpointStmt != nil {
+		if cerr := q.deleteCheckpointStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing deleteCheckpointStmt: %w", cerr)
+		}
+	}
+	if q.deleteCheckpointsAfterStmt != nil {
+		if cerr := q.deleteCheckpointsAfterStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing deleteCheckpointsAfterStmt: %w", cerr)
+		}
+	}
 	if q.deleteFileStmt != nil {
 		if cerr := q.deleteFileStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing deleteFileStmt: %w", cerr)
@@ -129,6 +236,16 @@ func (q *Queries) Close() error {
 			err = fmt.Errorf("error closing deleteSessionMessagesStmt: %w", cerr)
 		}
 	}
+	if q.deleteWorkflowInstanceStmt != nil {
+		if cerr := q.deleteWorkflowInstanceStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing deleteWorkflowInstanceStmt: %w", cerr)
+		}
+	}
+	if q.getCheckpointStmt != nil {
+		if cerr := q.getCheckpointStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing getCheckpointStmt: %w", cerr)
+		}
+	}
 	if q.getFileStmt != nil {
 		if cerr := q.getFileStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing getFileStmt: %w", cerr)
@@ -139,6 +256,11 @@ func (q *Queries) Close() error {
 			err = fmt.Errorf("error closing getFileByPathAndSessionStmt: %w", cerr)
 		}
 	}
+	if q.getLatestSessionFileChangeStmt != nil {
+		if cerr := q.getLatestSessionFileChangeStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing getLatestSessionFileChangeStmt: %w", cerr)
+		}
+	}
 	if q.getMessageStmt != nil {
 		if cerr := q.getMessageStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing getMessageStmt: %w", cerr)
@@ -149,6 +271,31 @@ func (q *Queries) Close() error {
 			err = fmt.Errorf("error closing getSessionByIDStmt: %w", cerr)
 		}
 	}
+	if q.getUsageSinceStmt != nil {
+		if cerr := q.getUsageSinceStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing getUsageSinceStmt: %w", cerr)
+		}
+	}
+	if q.getWorkflowInstanceStmt != nil {
+		if cerr := q.getWorkflowInstanceStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing getWorkflowInstanceStmt: %w", cerr)
+		}
+	}
+	if q.getWorkflowStepStmt != nil {
+		if cerr := q.getWorkflowStepStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing getWorkflowStepStmt: %w", cerr)
+		}
+	}
+	if q.listAgentMessagesBySessionStmt != nil {
+		if cerr := q.listAgentMessagesBySessionStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listAgentMessagesBySessionStmt: %w", cerr)
+		}
+	}
+	if q.listCheckpointsBySessionStmt != nil {
+		if cerr := q.listCheckpointsBySessionStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listCheckpointsBySessionStmt: %w", cerr)
+		}
+	}
 	if q.listFilesByPathStmt != nil {
 		if cerr := q.listFilesByPathStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing listFilesByPathStmt: %w", cerr)
@@ -174,21 +321,66 @@ func (q *Queries) Close() error {
 			err = fmt.Errorf("error closing listNewFilesStmt: %w", cerr)
 		}
 	}
+	if q.listSessionFilesInOrderStmt != nil {
+		if cerr := q.listSessionFilesInOrderStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listSessionFilesInOrderStmt: %w", cerr)
+		}
+	}
 	if q.listSessionsStmt != nil {
 		if cerr := q.listSessionsStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing listSessionsStmt: %w", cerr)
 		}
 	}
+	if q.listUnfinishedWorkflowInstancesStmt != nil {
+		if cerr := q.listUnfinishedWorkflowInstancesStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listUnfinishedWorkflowInstancesStmt: %w", cerr)
+		}
+	}
+	if q.listWorkflowInstancesStmt != nil {
+		if cerr := q.listWorkflowInstancesStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listWorkflowInstancesStmt: %w", cerr)
+		}
+	}
+	if q.listWorkflowStepsStmt != nil {
+		if cerr := q.listWorkflowStepsStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listWorkflowStepsStmt: %w", cerr)
+		}
+	}
+	if q.releaseWorkflowInstanceStmt != nil {
+		if cerr := q.releaseWorkflowInstanceStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing releaseWorkflowInstanceStmt: %w", cerr)
+		}
+	}
+	if q.setWorkflowStepApprovalStmt != nil {
+		if cerr := q.setWorkflowStepApprovalStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing setWorkflowStepApprovalStmt: %w", cerr)
+		}
+	}
 	if q.updateMessageStmt != nil {
 		if cerr := q.updateMessageStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing updateMessageStmt: %w", cerr)
 		}
 	}
+	if q.updateMessagePinnedStmt != nil {
+		if cerr := q.updateMessagePinnedStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing updateMessagePinnedStmt: %w", cerr)
+		}
+	}
 	if q.updateSessionStmt != nil {
 		if cerr := q.updateSessionStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing updateSessionStmt: %w", cerr)
 		}
 	}
+	if q.updateWorkflowInstanceStmt != nil {
+		if cerr := q.updateWorkflowInstanceStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing updateWorkflowInstanceStmt: %w", cerr)
+		}
+	}
+	if q.upsertWorkflowStepStmt != nil {
+		if cerr := q.upsertWorkflowStepStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing upsertWorkflowStepStmt: %w", cerr)
+		}
+	}
 	return err
 }
 
@@ -226,53 +418,101 @@ func (q *Queries) queryRow(ctx context.Context, stmt *sql.Stmt, query string, ar
 }
 
 type Queries struct {
-	db                          DBTX
-	tx                          *sql.Tx
-	createFileStmt              *sql.Stmt
-	createMessageStmt           *sql.Stmt
-	createSessionStmt           *sql.Stmt
-	deleteFileStmt              *sql.Stmt
-	deleteMessageStmt           *sql.Stmt
-	deleteSessionStmt           *sql.Stmt
-	deleteSessionFilesStmt      *sql.Stmt
-	deleteSessionMessagesStmt   *sql.Stmt
-	getFileStmt                 *sql.Stmt
-	getFileByPathAndSessionStmt *sql.Stmt
-	getMessageStmt              *sql.Stmt
-	getSessionByIDStmt          *sql.Stmt
-	listFilesByPathStmt         *sql.Stmt
-	listFilesBySessionStmt      *sql.Stmt
-	listLatestSessionFilesStmt  *sql.Stmt
-	listMessagesBySessionStmt   *sql.Stmt
-	listNewFilesStmt            *sql.Stmt
-	listSessionsStmt            *sql.Stmt
-	updateMessageStmt           *sql.Stmt
-	updateSessionStmt           *sql.Stmt
+	db                                  DBTX
+	tx                                  *sql.Tx
+	claimWorkflowInstanceStmt           *sql.Stmt
+	createAgentMessageStmt              *sql.Stmt
+	createCheckpointStmt                *sql.Stmt
+	createFileStmt                      *sql.Stmt
+	createMessageStmt                   *sql.Stmt
+	createSessionStmt                   *sql.Stmt
+	createSessionUsageStmt              *sql.Stmt
+	createWorkflowInstanceStmt          *sql.Stmt
+	deleteCheckpointStmt                *sql.Stmt
+	deleteCheckpointsAfterStmt          *sql.Stmt
+	deleteFileStmt                      *sql.Stmt
+	deleteMessageStmt                   *sql.Stmt
+	deleteSessionStmt                   *sql.Stmt
+	deleteSessionFilesStmt              *sql.Stmt
+	deleteSessionMessagesStmt           *sql.Stmt
+	deleteWorkflowInstanceStmt          *sql.Stmt
+	getCheckpointStmt                   *sql.Stmt
+	getFileStmt                         *sql.Stmt
+	getFileByPathAndSessionStmt         *sql.Stmt
+	getLatestSessionFileChangeStmt      *sql.Stmt
+	getMessageStmt                      *sql.Stmt
+	getSessionByIDStmt                  *sql.Stmt
+	getUsageSinceStmt                   *sql.Stmt
+	getWorkflowInstanceStmt             *sql.Stmt
+	getWorkflowStepStmt                 *sql.Stmt
+	listAgentMessagesBySessionStmt      *sql.Stmt
+	listCheckpointsBySessionStmt        *sql.Stmt
+	listFilesByPathStmt                 *sql.Stmt
+	listFilesBySessionStmt              *sql.Stmt
+	listLatestSessionFilesStmt          *sql.Stmt
+	listMessagesBySessionStmt           *sql.Stmt
+	listNewFilesStmt                    *sql.Stmt
+	listSessionFilesInOrderStmt         *sql.Stmt
+	listSessionsStmt                    *sql.Stmt
+	listUnfinishedWorkflowInstancesStmt *sql.Stmt
+	listWorkflowInstancesStmt           *sql.Stmt
+	listWorkflowStepsStmt               *sql.Stmt
+	releaseWorkflowInstanceStmt         *sql.Stmt
+	setWorkflowStepApprovalStmt         *sql.Stmt
+	updateMessageStmt                   *sql.Stmt
+	updateMessagePinnedStmt             *sql.Stmt
+	updateSessionStmt                   *sql.Stmt
+	updateWorkflowInstanceStmt          *sql.Stmt
+	upsertWorkflowStepStmt              *sql.Stmt
 }
 
 func (q *Queries) WithTx(tx *sql.Tx) *Queries {
 	return &Queries{
-		db:                          tx,
-		tx:                          tx,
-		createFileStmt:              q.createFileStmt,
-		createMessageStmt:           q.createMessageStmt,
-		createSessionStmt:           q.createSessionStmt,
-		deleteFileStmt:              q.deleteFileStmt,
-		deleteMessageStmt:           q.deleteMessageStmt,
-		deleteSessionStmt:           q.deleteSessionStmt,
-		deleteSessionFilesStmt:      q.deleteSessionFilesStmt,
-		deleteSessionMessagesStmt:   q.deleteSessionMessagesStmt,
-		getFileStmt:                 q.getFileStmt,
-		getFileByPathAndSessionStmt: q.getFileByPathAndSessionStmt,
-		getMessageStmt:              q.getMessageStmt,
-		getSessionByIDStmt:          q.getSessionByIDStmt,
-		listFilesByPathStmt:         q.listFilesByPathStmt,
-		listFilesBySessionStmt:      q.listFilesBySessionStmt,
-		listLatestSessionFilesStmt:  q.listLatestSessionFilesStmt,
-		listMessagesBySessionStmt:   q.listMessagesBySessionStmt,
-		listNewFilesStmt:            q.listNewFilesStmt,
-		listSessionsStmt:            q.listSessionsStmt,
-		updateMessageStmt:           q.updateMessageStmt,
-		updateSessionStmt:           q.updateSessionStmt,
+		db:                                  tx,
+		tx:                                  tx,
+		claimWorkflowInstanceStmt:           q.claimWorkflowInstanceStmt,
+		createAgentMessageStmt:              q.createAgentMessageStmt,
+		createCheckpointStmt:                q.createCheckpointStmt,
+		createFileStmt:                      q.createFileStmt,
+		createMessageStmt:                   q.createMessageStmt,
+		createSessionStmt:                   q.createSessionStmt,
+		createSessionUsageStmt:              q.createSessionUsageStmt,
+		createWorkflowInstanceStmt:          q.createWorkflowInstanceStmt,
+		deleteCheckpointStmt:                q.deleteCheckpointStmt,
+		deleteCheckpointsAfterStmt:          q.deleteCheckpointsAfterStmt,
+		deleteFileStmt:                      q.deleteFileStmt,
+		deleteMessageStmt:                   q.deleteMessageStmt,
+		deleteSessionStmt:                   q.deleteSessionStmt,
+		deleteSessionFilesStmt:              q.deleteSessionFilesStmt,
+		deleteSessionMessagesStmt:           q.deleteSessionMessagesStmt,
+		deleteWorkflowInstanceStmt:          q.deleteWorkflowInstanceStmt,
+		getCheckpointStmt:                   q.getCheckpointStmt,
+		getFileStmt:                         q.getFileStmt,
+		getFileByPathAndSessionStmt:         q.getFileByPathAndSessionStmt,
+		getLatestSessionFileChangeStmt:      q.getLatestSessionFileChangeStmt,
+		getMessageStmt:                      q.getMessageStmt,
+		getSessionByIDStmt:                  q.getSessionByIDStmt,
+		getUsageSinceStmt:                   q.getUsageSinceStmt,
+		getWorkflowInstanceStmt:             q.getWorkflowInstanceStmt,
+		getWorkflowStepStmt:                 q.getWorkflowStepStmt,
+		listAgentMessagesBySessionStmt:      q.listAgentMessagesBySessionStmt,
+		listCheckpointsBySessionStmt:        q.listCheckpointsBySessionStmt,
+		listFilesByPathStmt:                 q.listFilesByPathStmt,
+		listFilesBySessionStmt:              q.listFilesBySessionStmt,
+		listLatestSessionFilesStmt:          q.listLatestSessionFilesStmt,
+		listMessagesBySessionStmt:           q.listMessagesBySessionStmt,
+		listNewFilesStmt:                    q.listNewFilesStmt,
+		listSessionFilesInOrderStmt:         q.listSessionFilesInOrderStmt,
+		listSessionsStmt:                    q.listSessionsStmt,
+		listUnfinishedWorkflowInstancesStmt: q.listUnfinishedWorkflowInstancesStmt,
+		listWorkflowInstancesStmt:           q.listWorkflowInstancesStmt,
+		listWorkflowStepsStmt:               q.listWorkflowStepsStmt,
+		releaseWorkflowInstanceStmt:         q.releaseWorkflowInstanceStmt,
+		setWorkflowStepApprovalStmt:         q.setWorkflowStepApprovalStmt,
+		updateMessageStmt:                   q.updateMessageStmt,
+		updateMessagePinnedStmt:             q.updateMessagePinnedStmt,
+		updateSessionStmt:                   q.updateSessionStmt,
+		updateWorkflowInstanceStmt:          q.updateWorkflowInstanceStmt,
+		upsertWorkflowStepStmt:              q.upsertWorkflowStepStmt,
 	}
 }
@@ -23,7 +23,7 @@ INSERT INTO messages (
 ) VALUES (
     ?, ?, ?, ?, ?, ?, strftime('%s', 'now'), strftime('%s', 'now')
 )
-RETURNING id, session_id, role, parts, model, created_at, updated_at, finished_at, provider
+RETURNING id, session_id, role, parts, model, created_at, updated_at, finished_at, provider, pinned
 `
 
 type CreateMessageParams struct {
@@ -55,6 +55,7 @@ func (q *Queries) CreateMessage(ctx context.Context, arg CreateMessageParams) (M
 		&i.UpdatedAt,
 		&i.FinishedAt,
 		&i.Provider,
+		&i.Pinned,
 	)
 	return i, err
 }
@@ -80,7 +81,7 @@ func (q *Queries) DeleteSessionMessages(ctx context.Context, sessionID string) e
 }
 
 const getMessage = `-- name: GetMessage :one
-SELECT id, session_id, role, parts, model, created_at, updated_at, finished_at, provider
+SELECT id, session_id, role, parts, model, created_at, updated_at, finished_at, provider, pinned
 FROM messages
 WHERE id = ? LIMIT 1
 `
@@ -98,12 +99,13 @@ func (q *Queries) GetMessage(ctx context.Context, id string) (Message, error) {
 		&i.UpdatedAt,
 		&i.FinishedAt,
 		&i.Provider,
+		&i.Pinned,
 	)
 	return i, err
 }
 
 const listMessagesBySession = `-- name: ListMessagesBySession :many
-SELECT id, session_id, role, parts, model, created_at, updated_at, finished_at, provider
+SELECT id, session_id, role, parts, model, created_at, updated_at, finished_at, provider, pinned
 FROM messages
 WHERE session_id = ?
 ORDER BY created_at ASC
@@ -128,6 +130,7 @@ func (q *Queries) ListMessagesBySession(ctx context.Context, sessionID string) (
 			&i.UpdatedAt,
 			&i.FinishedAt,
 			&i.Provider,
+			&i.Pinned,
 		); err != nil {
 			return nil, err
 		}
@@ -142,22 +145,48 @@ func (q *Queries) ListMessagesBySession(ctx context.Context, sessionID string) (
 	return items, nil
 }
 
+const updateMessagePinned = `-- name: UpdateMessagePinned :exec
+UPDATE messages
+SET pinned = ?
+WHERE id = ?
+`
+
+type UpdateMessagePinnedParams struct {
+	Pinned int64  `json:"pinned"`
+	ID     string `json:"id"`
+}
+
+func (q *Queries) UpdateMessagePinned(ctx context.Context, arg UpdateMessagePinnedParams) error {
+	_, err := q.exec(ctx, q.updateMessagePinnedStmt, updateMessagePinned, arg.Pinned, arg.ID)
+	return err
+}
+
 const updateMessage = `-- name: UpdateMessage :exec
 UPDATE messages
 SET
     parts = ?,
     finished_at = ?,
+    model = ?,
+    provider = ?,
     updated_at = strftime('%s', 'now')
 WHERE id = ?
 `
 
 type UpdateMessageParams struct {
-	Parts      string        `json:"parts"`
-	FinishedAt sql.NullInt64 `json:"finished_at"`
-	ID         string        `json:"id"`
+	Parts      string         `json:"parts"`
+	FinishedAt sql.NullInt64  `json:"finished_at"`
+	Model      sql.NullString `json:"model"`
+	Provider   sql.NullString `json:"provider"`
+	ID         string         `json:"id"`
 }
 
 func (q *Queries) UpdateMessage(ctx context.Context, arg UpdateMessageParams) error {
-	_, err := q.exec(ctx, q.updateMessageStmt, updateMessage, arg.Parts, arg.FinishedAt, arg.ID)
+	_, err := q.exec(ctx, q.updateMessageStmt, updateMessage,
+		arg.Parts,
+		arg.FinishedAt,
+		arg.Model,
+		arg.Provider,
+		arg.ID,
+	)
 	return err
 }
@@ -0,0 +1,56 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Workflow instances
+CREATE TABLE IF NOT EXISTS workflow_instances (
+    id TEXT PRIMARY KEY,
+    workflow_id TEXT NOT NULL,
+    name TEXT NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    status TEXT NOT NULL,
+    current_step INTEGER NOT NULL DEFAULT 0,
+    session_id TEXT NOT NULL,
+    context TEXT NOT NULL DEFAULT '{}',
+    started_at INTEGER,  -- Unix timestamp in seconds
+    completed_at INTEGER,  -- Unix timestamp in seconds
+    updated_at INTEGER NOT NULL,  -- Unix timestamp in seconds
+    created_at INTEGER NOT NULL,  -- Unix timestamp in seconds
+    FOREIGN KEY (session_id) REFERENCES sessions (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_workflow_instances_status ON workflow_instances (status);
+CREATE INDEX IF NOT EXISTS idx_workflow_instances_created_at ON workflow_instances (created_at);
+
+CREATE TRIGGER IF NOT EXISTS update_workflow_instances_updated_at
+AFTER UPDATE ON workflow_instances
+BEGIN
+UPDATE workflow_instances SET updated_at = strftime('%s', 'now')
+WHERE id = new.id;
+END;
+
+-- Workflow steps
+CREATE TABLE IF NOT EXISTS workflow_steps (
+    instance_id TEXT NOT NULL,
+    step_number INTEGER NOT NULL,
+    responsible_agent TEXT NOT NULL,
+    action TEXT NOT NULL,
+    status TEXT NOT NULL,
+    result TEXT NOT NULL DEFAULT '',
+    error TEXT NOT NULL DEFAULT '',
+    session_id TEXT NOT NULL DEFAULT '',
+    started_at INTEGER,  -- Unix timestamp in seconds
+    completed_at INTEGER,  -- Unix timestamp in seconds
+    PRIMARY KEY (instance_id, step_number),
+    FOREIGN KEY (instance_id) REFERENCES workflow_instances (id) ON DELETE CASCADE
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TRIGGER IF EXISTS update_workflow_instances_updated_at;
+
+DROP INDEX IF EXISTS idx_workflow_instances_status;
+DROP INDEX IF EXISTS idx_workflow_instances_created_at;
+
+DROP TABLE IF EXISTS workflow_steps;
+DROP TABLE IF EXISTS workflow_instances;
+-- +goose StatementEnd
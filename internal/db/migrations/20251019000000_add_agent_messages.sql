@@ -0,0 +1,26 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Messages sent between role agents
+CREATE TABLE IF NOT EXISTS agent_messages (
+    id TEXT PRIMARY KEY,
+    session_id TEXT NOT NULL,
+    sender TEXT NOT NULL,
+    channel TEXT NOT NULL,
+    participants TEXT NOT NULL DEFAULT '[]',
+    subject TEXT NOT NULL DEFAULT '',
+    body TEXT NOT NULL,
+    priority INTEGER NOT NULL DEFAULT 0,
+    reply_to TEXT NOT NULL DEFAULT '',
+    created_at INTEGER NOT NULL,  -- Unix timestamp in seconds
+    FOREIGN KEY (session_id) REFERENCES sessions (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_agent_messages_session_id ON agent_messages (session_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_agent_messages_session_id;
+
+DROP TABLE IF EXISTS agent_messages;
+-- +goose StatementEnd
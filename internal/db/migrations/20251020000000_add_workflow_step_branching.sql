@@ -0,0 +1,18 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Track retries, condition loops and approvals of workflow steps
+ALTER TABLE workflow_steps ADD COLUMN attempts INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE workflow_steps ADD COLUMN loops INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE workflow_steps ADD COLUMN feedback TEXT NOT NULL DEFAULT '';
+ALTER TABLE workflow_steps ADD COLUMN approval TEXT NOT NULL DEFAULT '';
+ALTER TABLE workflow_steps ADD COLUMN approval_reason TEXT NOT NULL DEFAULT '';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE workflow_steps DROP COLUMN approval_reason;
+ALTER TABLE workflow_steps DROP COLUMN approval;
+ALTER TABLE workflow_steps DROP COLUMN feedback;
+ALTER TABLE workflow_steps DROP COLUMN loops;
+ALTER TABLE workflow_steps DROP COLUMN attempts;
+-- +goose StatementEnd
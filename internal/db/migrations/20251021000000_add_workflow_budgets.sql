@@ -0,0 +1,13 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Count every token a session used, not only those of its last request, and
+-- record why a workflow instance was paused or cancelled
+ALTER TABLE sessions ADD COLUMN total_tokens INTEGER NOT NULL DEFAULT 0 CHECK (total_tokens >= 0);
+ALTER TABLE workflow_instances ADD COLUMN status_reason TEXT NOT NULL DEFAULT '';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE workflow_instances DROP COLUMN status_reason;
+ALTER TABLE sessions DROP COLUMN total_tokens;
+-- +goose StatementEnd
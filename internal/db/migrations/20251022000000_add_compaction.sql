@@ -0,0 +1,13 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Pinned messages survive compaction verbatim
+ALTER TABLE messages ADD COLUMN pinned INTEGER NOT NULL DEFAULT 0;
+-- The first message a summary kept verbatim
+ALTER TABLE sessions ADD COLUMN first_kept_message_id TEXT;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE sessions DROP COLUMN first_kept_message_id;
+ALTER TABLE messages DROP COLUMN pinned;
+-- +goose StatementEnd
@@ -0,0 +1,25 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Checkpoints
+CREATE TABLE IF NOT EXISTS checkpoints (
+    id TEXT PRIMARY KEY,
+    session_id TEXT NOT NULL,
+    name TEXT NOT NULL,
+    message_id TEXT,  -- The last message of the session, NULL before the first
+    file_versions TEXT NOT NULL DEFAULT '{}',  -- JSON object of the latest version of each file
+    created_at INTEGER NOT NULL,  -- Unix timestamp in milliseconds
+    FOREIGN KEY (session_id) REFERENCES sessions (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_checkpoints_session_id ON checkpoints (session_id);
+
+-- The checkpoint a session was forked from
+ALTER TABLE sessions ADD COLUMN checkpoint_id TEXT;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE sessions DROP COLUMN checkpoint_id;
+DROP INDEX IF EXISTS idx_checkpoints_session_id;
+DROP TABLE IF EXISTS checkpoints;
+-- +goose StatementEnd
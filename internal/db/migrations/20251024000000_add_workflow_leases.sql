@@ -0,0 +1,12 @@
+-- +goose Up
+-- +goose StatementBegin
+-- The floss process running a workflow instance, and until when its claim holds
+ALTER TABLE workflow_instances ADD COLUMN lease_owner TEXT NOT NULL DEFAULT '';
+ALTER TABLE workflow_instances ADD COLUMN lease_expires_at INTEGER NOT NULL DEFAULT 0;  -- Unix timestamp in seconds
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE workflow_instances DROP COLUMN lease_expires_at;
+ALTER TABLE workflow_instances DROP COLUMN lease_owner;
+-- +goose StatementEnd
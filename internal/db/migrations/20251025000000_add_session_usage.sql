@@ -0,0 +1,19 @@
+-- +goose Up
+-- +goose StatementBegin
+-- What each request to a model cost, to add up the usage of a day
+CREATE TABLE IF NOT EXISTS session_usage (
+    id INTEGER PRIMARY KEY,
+    session_id TEXT NOT NULL,  -- Kept after the session is deleted, as the usage happened
+    cost REAL NOT NULL DEFAULT 0.0,
+    tokens INTEGER NOT NULL DEFAULT 0,
+    created_at INTEGER NOT NULL  -- Unix timestamp in seconds
+);
+
+CREATE INDEX IF NOT EXISTS idx_session_usage_created_at ON session_usage (created_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_session_usage_created_at;
+DROP TABLE IF EXISTS session_usage;
+-- +goose StatementEnd
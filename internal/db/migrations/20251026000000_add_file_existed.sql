@@ -0,0 +1,12 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Whether the file existed when its first version was taken. Earlier
+-- histories only tell by an empty first version.
+ALTER TABLE files ADD COLUMN existed INTEGER NOT NULL DEFAULT 1;
+UPDATE files SET existed = 0 WHERE version = 0 AND content = '';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE files DROP COLUMN existed;
+-- +goose StatementEnd
@@ -8,6 +8,28 @@ import (
 	"database/sql"
 )
 
+type AgentMessage struct {
+	ID           string `json:"id"`
+	SessionID    string `json:"session_id"`
+	Sender       string `json:"sender"`
+	Channel      string `json:"channel"`
+	Participants string `json:"participants"`
+	Subject      string `json:"subject"`
+	Body         string `json:"body"`
+	Priority     int64  `json:"priority"`
+	ReplyTo      string `json:"reply_to"`
+	CreatedAt    int64  `json:"created_at"`
+}
+
+type Checkpoint struct {
+	ID           string         `json:"id"`
+	SessionID    string         `json:"session_id"`
+	Name         string         `json:"name"`
+	MessageID    sql.NullString `json:"message_id"`
+	FileVersions string         `json:"file_versions"`
+	CreatedAt    int64          `json:"created_at"`
+}
+
 type File struct {
 	ID        string `json:"id"`
 	SessionID string `json:"session_id"`
@@ -16,6 +38,7 @@ type File struct {
 	Version   int64  `json:"version"`
 	CreatedAt int64  `json:"created_at"`
 	UpdatedAt int64  `json:"updated_at"`
+	Existed   int64  `json:"existed"`
 }
 
 type Message struct {
@@ -28,17 +51,65 @@ type Message struct {
 	UpdatedAt  int64          `json:"updated_at"`
 	FinishedAt sql.NullInt64  `json:"finished_at"`
 	Provider   sql.NullString `json:"provider"`
+	Pinned     int64          `json:"pinned"`
 }
 
 type Session struct {
-	ID               string         `json:"id"`
-	ParentSessionID  sql.NullString `json:"parent_session_id"`
-	Title            string         `json:"title"`
-	MessageCount     int64          `json:"message_count"`
-	PromptTokens     int64          `json:"prompt_tokens"`
-	CompletionTokens int64          `json:"completion_tokens"`
-	Cost             float64        `json:"cost"`
-	UpdatedAt        int64          `json:"updated_at"`
-	CreatedAt        int64          `json:"created_at"`
-	SummaryMessageID sql.NullString `json:"summary_message_id"`
+	ID                 string         `json:"id"`
+	ParentSessionID    sql.NullString `json:"parent_session_id"`
+	Title              string         `json:"title"`
+	MessageCount       int64          `json:"message_count"`
+	PromptTokens       int64          `json:"prompt_tokens"`
+	CompletionTokens   int64          `json:"completion_tokens"`
+	Cost               float64        `json:"cost"`
+	UpdatedAt          int64          `json:"updated_at"`
+	CreatedAt          int64          `json:"created_at"`
+	SummaryMessageID   sql.NullString `json:"summary_message_id"`
+	TotalTokens        int64          `json:"total_tokens"`
+	FirstKeptMessageID sql.NullString `json:"first_kept_message_id"`
+	CheckpointID       sql.NullString `json:"checkpoint_id"`
+}
+
+type SessionUsage struct {
+	ID        int64   `json:"id"`
+	SessionID string  `json:"session_id"`
+	Cost      float64 `json:"cost"`
+	Tokens    int64   `json:"tokens"`
+	CreatedAt int64   `json:"created_at"`
+}
+
+type WorkflowInstance struct {
+	ID             string        `json:"id"`
+	WorkflowID     string        `json:"workflow_id"`
+	Name           string        `json:"name"`
+	Description    string        `json:"description"`
+	Status         string        `json:"status"`
+	CurrentStep    int64         `json:"current_step"`
+	SessionID      string        `json:"session_id"`
+	Context        string        `json:"context"`
+	StartedAt      sql.NullInt64 `json:"started_at"`
+	CompletedAt    sql.NullInt64 `json:"completed_at"`
+	UpdatedAt      int64         `json:"updated_at"`
+	CreatedAt      int64         `json:"created_at"`
+	StatusReason   string        `json:"status_reason"`
+	LeaseOwner     string        `json:"lease_owner"`
+	LeaseExpiresAt int64         `json:"lease_expires_at"`
+}
+
+type WorkflowStep struct {
+	InstanceID       string        `json:"instance_id"`
+	StepNumber       int64         `json:"step_number"`
+	ResponsibleAgent string        `json:"responsible_agent"`
+	Action           string        `json:"action"`
+	Status           string        `json:"status"`
+	Result           string        `json:"result"`
+	Error            string        `json:"error"`
+	SessionID        string        `json:"session_id"`
+	StartedAt        sql.NullInt64 `json:"started_at"`
+	CompletedAt      sql.NullInt64 `json:"completed_at"`
+	Attempts         int64         `json:"attempts"`
+	Loops            int64         `json:"loops"`
+	Feedback         string        `json:"feedback"`
+	Approval         string        `json:"approval"`
+	ApprovalReason   string        `json:"approval_reason"`
 }
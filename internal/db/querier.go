@@ -9,26 +9,50 @@ import (
 )
 
 type Querier interface {
+	ClaimWorkflowInstance(ctx context.Context, arg ClaimWorkflowInstanceParams) (int64, error)
+	CreateAgentMessage(ctx context.Context, arg CreateAgentMessageParams) (AgentMessage, error)
+	CreateCheckpoint(ctx context.Context, arg CreateCheckpointParams) (Checkpoint, error)
 	CreateFile(ctx context.Context, arg CreateFileParams) (File, error)
 	CreateMessage(ctx context.Context, arg CreateMessageParams) (Message, error)
 	CreateSession(ctx context.Context, arg CreateSessionParams) (Session, error)
+	CreateSessionUsage(ctx context.Context, arg CreateSessionUsageParams) error
+	CreateWorkflowInstance(ctx context.Context, arg CreateWorkflowInstanceParams) (WorkflowInstance, error)
+	DeleteCheckpoint(ctx context.Context, id string) error
+	DeleteCheckpointsAfter(ctx context.Context, arg DeleteCheckpointsAfterParams) error
 	DeleteFile(ctx context.Context, id string) error
 	DeleteMessage(ctx context.Context, id string) error
 	DeleteSession(ctx context.Context, id string) error
 	DeleteSessionFiles(ctx context.Context, sessionID string) error
 	DeleteSessionMessages(ctx context.Context, sessionID string) error
+	DeleteWorkflowInstance(ctx context.Context, id string) error
+	GetCheckpoint(ctx context.Context, id string) (Checkpoint, error)
 	GetFile(ctx context.Context, id string) (File, error)
 	GetFileByPathAndSession(ctx context.Context, arg GetFileByPathAndSessionParams) (File, error)
+	GetLatestSessionFileChange(ctx context.Context, sessionID string) (File, error)
 	GetMessage(ctx context.Context, id string) (Message, error)
 	GetSessionByID(ctx context.Context, id string) (Session, error)
+	GetUsageSince(ctx context.Context, createdAt int64) (GetUsageSinceRow, error)
+	GetWorkflowInstance(ctx context.Context, id string) (WorkflowInstance, error)
+	GetWorkflowStep(ctx context.Context, arg GetWorkflowStepParams) (WorkflowStep, error)
+	ListAgentMessagesBySession(ctx context.Context, sessionID string) ([]AgentMessage, error)
+	ListCheckpointsBySession(ctx context.Context, sessionID string) ([]Checkpoint, error)
 	ListFilesByPath(ctx context.Context, path string) ([]File, error)
 	ListFilesBySession(ctx context.Context, sessionID string) ([]File, error)
 	ListLatestSessionFiles(ctx context.Context, sessionID string) ([]File, error)
 	ListMessagesBySession(ctx context.Context, sessionID string) ([]Message, error)
 	ListNewFiles(ctx context.Context) ([]File, error)
+	ListSessionFilesInOrder(ctx context.Context, sessionID string) ([]File, error)
 	ListSessions(ctx context.Context) ([]Session, error)
+	ListUnfinishedWorkflowInstances(ctx context.Context) ([]WorkflowInstance, error)
+	ListWorkflowInstances(ctx context.Context) ([]WorkflowInstance, error)
+	ListWorkflowSteps(ctx context.Context, instanceID string) ([]WorkflowStep, error)
+	ReleaseWorkflowInstance(ctx context.Context, arg ReleaseWorkflowInstanceParams) error
+	SetWorkflowStepApproval(ctx context.Context, arg SetWorkflowStepApprovalParams) error
 	UpdateMessage(ctx context.Context, arg UpdateMessageParams) error
+	UpdateMessagePinned(ctx context.Context, arg UpdateMessagePinnedParams) error
 	UpdateSession(ctx context.Context, arg UpdateSessionParams) (Session, error)
+	UpdateWorkflowInstance(ctx context.Context, arg UpdateWorkflowInstanceParams) error
+	UpsertWorkflowStep(ctx context.Context, arg UpsertWorkflowStepParams) error
 }
 
 var _ Querier = (*Queries)(nil)
@@ -20,6 +20,7 @@ INSERT INTO sessions (
     completion_tokens,
     cost,
     summary_message_id,
+    checkpoint_id,
     updated_at,
     created_at
 ) VALUES (
@@ -31,9 +32,10 @@ INSERT INTO sessions (
     ?,
     ?,
     null,
+    ?,
     strftime('%s', 'now'),
     strftime('%s', 'now')
-) RETURNING id, parent_session_id, title, message_count, prompt_tokens, completion_tokens, cost, updated_at, created_at, summary_message_id
+) RETURNING id, parent_session_id, title, message_count, prompt_tokens, completion_tokens, cost, updated_at, created_at, summary_message_id, total_tokens, first_kept_message_id, checkpoint_id
 `
 
 type CreateSessionParams struct {
@@ -44,6 +46,7 @@ type CreateSessionParams struct {
 	PromptTokens     int64          `json:"prompt_tokens"`
 	CompletionTokens int64          `json:"completion_tokens"`
 	Cost             float64        `json:"cost"`
+	CheckpointID     sql.NullString `json:"checkpoint_id"`
 }
 
 func (q *Queries) CreateSession(ctx context.Context, arg CreateSessionParams) (Session, error) {
@@ -55,6 +58,7 @@ func (q *Queries) CreateSession(ctx context.Context, arg CreateSessionParams) (S
 		arg.PromptTokens,
 		arg.CompletionTokens,
 		arg.Cost,
+		arg.CheckpointID,
 	)
 	var i Session
 	err := row.Scan(
@@ -68,10 +72,44 @@ func (q *Queries) CreateSession(ctx context.Context, arg CreateSessionParams) (S
 		&i.UpdatedAt,
 		&i.CreatedAt,
 		&i.SummaryMessageID,
+		&i.TotalTokens,
+		&i.FirstKeptMessageID,
+		&i.CheckpointID,
 	)
 	return i, err
 }
 
+const createSessionUsage = `-- name: CreateSessionUsage :exec
+INSERT INTO session_usage (
+    session_id,
+    cost,
+    tokens,
+    created_at
+) VALUES (
+    ?,
+    ?,
+    ?,
+    ?
+)
+`
+
+type CreateSessionUsageParams struct {
+	SessionID string  `json:"session_id"`
+	Cost      float64 `json:"cost"`
+	Tokens    int64   `json:"tokens"`
+	CreatedAt int64   `json:"created_at"`
+}
+
+func (q *Queries) CreateSessionUsage(ctx context.Context, arg CreateSessionUsageParams) error {
+	_, err := q.exec(ctx, q.createSessionUsageStmt, createSessionUsage,
+		arg.SessionID,
+		arg.Cost,
+		arg.Tokens,
+		arg.CreatedAt,
+	)
+	return err
+}
+
 const deleteSession = `-- name: DeleteSession :exec
 DELETE FROM sessions
 WHERE id = ?
@@ -83,7 +121,7 @@ func (q *Queries) DeleteSession(ctx context.Context, id string) error {
 }
 
 const getSessionByID = `-- name: GetSessionByID :one
-SELECT id, parent_session_id, title, message_count, prompt_tokens, completion_tokens, cost, updated_at, created_at, summary_message_id
+SELECT id, parent_session_id, title, message_count, prompt_tokens, completion_tokens, cost, updated_at, created_at, summary_message_id, total_tokens, first_kept_message_id, checkpoint_id
 FROM sessions
 WHERE id = ? LIMIT 1
 `
@@ -102,14 +140,37 @@ func (q *Queries) GetSessionByID(ctx context.Context, id string) (Session, error
 		&i.UpdatedAt,
 		&i.CreatedAt,
 		&i.SummaryMessageID,
+		&i.TotalTokens,
+		&i.FirstKeptMessageID,
+		&i.CheckpointID,
 	)
 	return i, err
 }
 
+const getUsageSince = `-- name: GetUsageSince :one
+SELECT
+    CAST(COALESCE(SUM(cost), 0.0) AS REAL) AS cost,
+    CAST(COALESCE(SUM(tokens), 0) AS INTEGER) AS tokens
+FROM session_usage
+WHERE created_at >= ?
+`
+
+type GetUsageSinceRow struct {
+	Cost   float64 `json:"cost"`
+	Tokens int64   `json:"tokens"`
+}
+
+func (q *Queries) GetUsageSince(ctx context.Context, createdAt int64) (GetUsageSinceRow, error) {
+	row := q.queryRow(ctx, q.getUsageSinceStmt, getUsageSince, createdAt)
+	var i GetUsageSinceRow
+	err := row.Scan(&i.Cost, &i.Tokens)
+	return i, err
+}
+
 const listSessions = `-- name: ListSessions :many
-SELECT id, parent_session_id, title, message_count, prompt_tokens, completion_tokens, cost, updated_at, created_at, summary_message_id
+SELECT id, parent_session_id, title, message_count, prompt_tokens, completion_tokens, cost, updated_at, created_at, summary_message_id, total_tokens, first_kept_message_id, checkpoint_id
 FROM sessions
-WHERE parent_session_id is NULL
+WHERE parent_session_id is NULL OR checkpoint_id IS NOT NULL
 ORDER BY created_at DESC
 `
 
@@ -133,6 +194,9 @@ func (q *Queries) ListSessions(ctx context.Context) ([]Session, error) {
 			&i.UpdatedAt,
 			&i.CreatedAt,
 			&i.SummaryMessageID,
+			&i.TotalTokens,
+			&i.FirstKeptMessageID,
+			&i.CheckpointID,
 		); err != nil {
 			return nil, err
 		}
@@ -154,18 +218,22 @@ SET
     prompt_tokens = ?,
     completion_tokens = ?,
     summary_message_id = ?,
-    cost = ?
+    first_kept_message_id = ?,
+    cost = ?,
+    total_tokens = ?
 WHERE id = ?
-RETURNING id, parent_session_id, title, message_count, prompt_tokens, completion_tokens, cost, updated_at, created_at, summary_message_id
+RETURNING id, parent_session_id, title, message_count, prompt_tokens, completion_tokens, cost, updated_at, created_at, summary_message_id, total_tokens, first_kept_message_id, checkpoint_id
 `
 
 type UpdateSessionParams struct {
-	Title            string         `json:"title"`
-	PromptTokens     int64          `json:"prompt_tokens"`
-	CompletionTokens int64          `json:"completion_tokens"`
-	SummaryMessageID sql.NullString `json:"summary_message_id"`
-	Cost             float64        `json:"cost"`
-	ID               string         `json:"id"`
+	Title              string         `json:"title"`
+	PromptTokens       int64          `json:"prompt_tokens"`
+	CompletionTokens   int64          `json:"completion_tokens"`
+	SummaryMessageID   sql.NullString `json:"summary_message_id"`
+	FirstKeptMessageID sql.NullString `json:"first_kept_message_id"`
+	Cost               float64        `json:"cost"`
+	TotalTokens        int64          `json:"total_tokens"`
+	ID                 string         `json:"id"`
 }
 
 func (q *Queries) UpdateSession(ctx context.Context, arg UpdateSessionParams) (Session, error) {
@@ -174,7 +242,9 @@ func (q *Queries) UpdateSession(ctx context.Context, arg UpdateSessionParams) (S
 		arg.PromptTokens,
 		arg.CompletionTokens,
 		arg.SummaryMessageID,
+		arg.FirstKeptMessageID,
 		arg.Cost,
+		arg.TotalTokens,
 		arg.ID,
 	)
 	var i Session
@@ -189,6 +259,9 @@ func (q *Queries) UpdateSession(ctx context.Context, arg UpdateSessionParams) (S
 		&i.UpdatedAt,
 		&i.CreatedAt,
 		&i.SummaryMessageID,
+		&i.TotalTokens,
+		&i.FirstKeptMessageID,
+		&i.CheckpointID,
 	)
 	return i, err
 }
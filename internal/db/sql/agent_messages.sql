@@ -0,0 +1,30 @@
+-- name: CreateAgentMessage :one
+INSERT INTO agent_messages (
+    id,
+    session_id,
+    sender,
+    channel,
+    participants,
+    subject,
+    body,
+    priority,
+    reply_to,
+    created_at
+) VALUES (
+    ?,
+    ?,
+    ?,
+    ?,
+    ?,
+    ?,
+    ?,
+    ?,
+    ?,
+    strftime('%s', 'now')
+) RETURNING *;
+
+-- name: ListAgentMessagesBySession :many
+SELECT *
+FROM agent_messages
+WHERE session_id = ?
+ORDER BY created_at ASC, rowid ASC;
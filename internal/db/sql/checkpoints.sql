@@ -0,0 +1,33 @@
+-- name: CreateCheckpoint :one
+INSERT INTO checkpoints (
+    id,
+    session_id,
+    name,
+    message_id,
+    file_versions,
+    created_at
+) VALUES (
+    ?, ?, ?, ?, ?, strftime('%s', 'now')
+)
+RETURNING *;
+
+-- name: GetCheckpoint :one
+SELECT *
+FROM checkpoints
+WHERE id = ? LIMIT 1;
+
+-- name: ListCheckpointsBySession :many
+SELECT *
+FROM checkpoints
+WHERE session_id = ?
+ORDER BY created_at DESC, rowid DESC;
+
+-- name: DeleteCheckpoint :exec
+DELETE FROM checkpoints
+WHERE id = ?;
+
+-- name: DeleteCheckpointsAfter :exec
+DELETE FROM checkpoints
+WHERE session_id = ? AND rowid > (
+    SELECT c.rowid FROM checkpoints c WHERE c.id = ?
+);
@@ -10,12 +10,25 @@ WHERE path = ? AND session_id = ?
 ORDER BY version DESC, created_at DESC
 LIMIT 1;
 
+-- name: GetLatestSessionFileChange :one
+SELECT *
+FROM files
+WHERE session_id = ? AND version > 0
+ORDER BY rowid DESC
+LIMIT 1;
+
 -- name: ListFilesBySession :many
 SELECT *
 FROM files
 WHERE session_id = ?
 ORDER BY version ASC, created_at ASC;
 
+-- name: ListSessionFilesInOrder :many
+SELECT *
+FROM files
+WHERE session_id = ?
+ORDER BY rowid ASC;
+
 -- name: ListFilesByPath :many
 SELECT *
 FROM files
@@ -29,10 +42,11 @@ INSERT INTO files (
     path,
     content,
     version,
+    existed,
     created_at,
     updated_at
 ) VALUES (
-    ?, ?, ?, ?, ?, strftime('%s', 'now'), strftime('%s', 'now')
+    ?, ?, ?, ?, ?, ?, strftime('%s', 'now'), strftime('%s', 'now')
 )
 RETURNING *;
 
@@ -29,9 +29,16 @@ UPDATE messages
 SET
     parts = ?,
     finished_at = ?,
+    model = ?,
+    provider = ?,
     updated_at = strftime('%s', 'now')
 WHERE id = ?;
 
+-- name: UpdateMessagePinned :exec
+UPDATE messages
+SET pinned = ?
+WHERE id = ?;
+
 
 -- name: DeleteMessage :exec
 DELETE FROM messages
@@ -8,6 +8,7 @@ INSERT INTO sessions (
     completion_tokens,
     cost,
     summary_message_id,
+    checkpoint_id,
     updated_at,
     created_at
 ) VALUES (
@@ -19,19 +20,40 @@ INSERT INTO sessions (
     ?,
     ?,
     null,
+    ?,
     strftime('%s', 'now'),
     strftime('%s', 'now')
 ) RETURNING *;
 
+-- name: CreateSessionUsage :exec
+INSERT INTO session_usage (
+    session_id,
+    cost,
+    tokens,
+    created_at
+) VALUES (
+    ?,
+    ?,
+    ?,
+    ?
+);
+
 -- name: GetSessionByID :one
 SELECT *
 FROM sessions
 WHERE id = ? LIMIT 1;
 
+-- name: GetUsageSince :one
+SELECT
+    CAST(COALESCE(SUM(cost), 0.0) AS REAL) AS cost,
+    CAST(COALESCE(SUM(tokens), 0) AS INTEGER) AS tokens
+FROM session_usage
+WHERE created_at >= ?;
+
 -- name: ListSessions :many
 SELECT *
 FROM sessions
-WHERE parent_session_id is NULL
+WHERE parent_session_id is NULL OR checkpoint_id IS NOT NULL
 ORDER BY created_at DESC;
 
 -- name: UpdateSession :one
@@ -41,7 +63,9 @@ SET
     prompt_tokens = ?,
     completion_tokens = ?,
     summary_message_id = ?,
-    cost = ?
+    first_kept_message_id = ?,
+    cost = ?,
+    total_tokens = ?
 WHERE id = ?
 RETURNING *;
 
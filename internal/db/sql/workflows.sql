@@ -0,0 +1,124 @@
+-- name: CreateWorkflowInstance :one
+INSERT INTO workflow_instances (
+    id,
+    workflow_id,
+    name,
+    description,
+    status,
+    current_step,
+    session_id,
+    context,
+    started_at,
+    completed_at,
+    updated_at,
+    created_at
+) VALUES (
+    ?,
+    ?,
+    ?,
+    ?,
+    ?,
+    ?,
+    ?,
+    ?,
+    ?,
+    ?,
+    strftime('%s', 'now'),
+    ?
+) RETURNING *;
+
+-- name: GetWorkflowInstance :one
+SELECT *
+FROM workflow_instances
+WHERE id = ? LIMIT 1;
+
+-- name: ListWorkflowInstances :many
+SELECT *
+FROM workflow_instances
+ORDER BY created_at DESC;
+
+-- name: ListUnfinishedWorkflowInstances :many
+SELECT *
+FROM workflow_instances
+WHERE status IN ('pending', 'running')
+ORDER BY created_at ASC;
+
+-- name: UpdateWorkflowInstance :exec
+UPDATE workflow_instances
+SET
+    status = ?,
+    current_step = ?,
+    context = ?,
+    started_at = ?,
+    completed_at = ?,
+    status_reason = ?
+WHERE id = ? AND status != 'cancelled';
+
+-- name: DeleteWorkflowInstance :exec
+DELETE FROM workflow_instances
+WHERE id = ?;
+
+-- name: ClaimWorkflowInstance :execrows
+UPDATE workflow_instances
+SET
+    lease_owner = sqlc.arg(owner),
+    lease_expires_at = sqlc.arg(expires_at)
+WHERE id = sqlc.arg(id)
+    AND (lease_owner = '' OR lease_owner = sqlc.arg(owner) OR lease_expires_at < sqlc.arg(now));
+
+-- name: ReleaseWorkflowInstance :exec
+UPDATE workflow_instances
+SET
+    lease_owner = '',
+    lease_expires_at = 0
+WHERE id = ? AND lease_owner = ?;
+
+-- name: UpsertWorkflowStep :exec
+INSERT INTO workflow_steps (
+    instance_id,
+    step_number,
+    responsible_agent,
+    action,
+    status,
+    result,
+    error,
+    session_id,
+    started_at,
+    completed_at,
+    attempts,
+    loops,
+    feedback
+) VALUES (
+    ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?
+)
+ON CONFLICT (instance_id, step_number) DO UPDATE SET
+    responsible_agent = excluded.responsible_agent,
+    action = excluded.action,
+    status = excluded.status,
+    result = excluded.result,
+    error = excluded.error,
+    session_id = excluded.session_id,
+    started_at = excluded.started_at,
+    completed_at = excluded.completed_at,
+    attempts = excluded.attempts,
+    loops = excluded.loops,
+    feedback = excluded.feedback;
+
+-- name: ListWorkflowSteps :many
+SELECT *
+FROM workflow_steps
+WHERE instance_id = ?
+ORDER BY step_number ASC;
+
+
+-- name: GetWorkflowStep :one
+SELECT *
+FROM workflow_steps
+WHERE instance_id = ? AND step_number = ? LIMIT 1;
+
+-- name: SetWorkflowStepApproval :exec
+UPDATE workflow_steps
+SET
+    approval = ?,
+    approval_reason = ?
+WHERE instance_id = ? AND step_number = ?;
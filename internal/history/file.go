@@ -21,6 +21,9 @@ type File struct {
 	Path      string
 	Content   string
 	Version   int64
+	// Existed tells whether the file existed when the first version was
+	// taken, so that reverting to it removes the files the session created
+	Existed   bool
 	CreatedAt int64
 	UpdatedAt int64
 }
@@ -28,6 +31,8 @@ type File struct {
 type Service interface {
 	pubsub.Suscriber[File]
 	Create(ctx context.Context, sessionID, path, content string) (File, error)
+	// CreateNew records the first version of a file that didn't exist yet
+	CreateNew(ctx context.Context, sessionID, path string) (File, error)
 	CreateVersion(ctx context.Context, sessionID, path, content string) (File, error)
 	Get(ctx context.Context, id string) (File, error)
 	GetByPathAndSession(ctx context.Context, path, sessionID string) (File, error)
@@ -35,6 +40,20 @@ type Service interface {
 	ListLatestSessionFiles(ctx context.Context, sessionID string) ([]File, error)
 	Delete(ctx context.Context, id string) error
 	DeleteSessionFiles(ctx context.Context, sessionID string) error
+	// Undo reverts the last change of the session to a file
+	Undo(ctx context.Context, sessionID string, force bool) (File, error)
+	// Revert writes back a version of a file the session recorded
+	Revert(ctx context.Context, sessionID, path string, version int64, force bool) (File, error)
+	// RevertSession writes back every file the session changed as it was
+	// before
+	RevertSession(ctx context.Context, sessionID string, force bool) ([]File, error)
+	// RevertTo writes back the versions of the files the session changed
+	RevertTo(ctx context.Context, sessionID string, versions map[string]int64, force bool) ([]File, error)
+	// LatestVersions returns the latest version of each file the session
+	// changed
+	LatestVersions(ctx context.Context, sessionID string) (map[string]int64, error)
+	// CopySession records the file versions of a session for another one
+	CopySession(ctx context.Context, fromSessionID, toSessionID string) error
 }
 
 type service struct {
@@ -52,7 +71,11 @@ func NewService(q *db.Queries, db *sql.DB) Service {
 }
 
 func (s *service) Create(ctx context.Context, sessionID, path, content string) (File, error) {
-	return s.createWithVersion(ctx, sessionID, path, content, InitialVersion)
+	return s.createWithVersion(ctx, sessionID, path, content, InitialVersion, true)
+}
+
+func (s *service) CreateNew(ctx context.Context, sessionID, path string) (File, error) {
+	return s.createWithVersion(ctx, sessionID, path, "", InitialVersion, false)
 }
 
 func (s *service) CreateVersion(ctx context.Context, sessionID, path, content string) (File, error) {
@@ -71,14 +94,18 @@ func (s *service) CreateVersion(ctx context.Context, sessionID, path, content st
 	latestFile := files[0] // Files are ordered by version DESC, created_at DESC
 	nextVersion := latestFile.Version + 1
 
-	return s.createWithVersion(ctx, sessionID, path, content, nextVersion)
+	return s.createWithVersion(ctx, sessionID, path, content, nextVersion, true)
 }
 
-func (s *service) createWithVersion(ctx context.Context, sessionID, path, content string, version int64) (File, error) {
+func (s *service) createWithVersion(ctx context.Context, sessionID, path, content string, version int64, existed bool) (File, error) {
 	// Maximum number of retries for transaction conflicts
 	const maxRetries = 3
 	var file File
 	var err error
+	var existedValue int64
+	if existed {
+		existedValue = 1
+	}
 
 	// Retry loop for transaction conflicts
 	for attempt := range maxRetries {
@@ -98,6 +125,7 @@ func (s *service) createWithVersion(ctx context.Context, sessionID, path, conten
 			Path:      path,
 			Content:   content,
 			Version:   version,
+			Existed:   existedValue,
 		})
 		if txErr != nil {
 			// Rollback the transaction
@@ -204,6 +232,7 @@ func (s *service) fromDBItem(item db.File) File {
 		Path:      item.Path,
 		Content:   item.Content,
 		Version:   item.Version,
+		Existed:   item.Existed != 0,
 		CreatedAt: item.CreatedAt,
 		UpdatedAt: item.UpdatedAt,
 	}
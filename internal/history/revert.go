@@ -0,0 +1,218 @@
+package history
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/nom-nom-hub/floss/internal/db"
+	"github.com/nom-nom-hub/floss/internal/pubsub"
+)
+
+var (
+	// ErrFileModified means a file changed outside floss since its latest
+	// version, so writing back an earlier one would lose those changes
+	ErrFileModified = errors.New("file modified outside floss")
+	// ErrNothingToUndo means the session changed no file, or all its
+	// changes were undone
+	ErrNothingToUndo = errors.New("nothing to undo")
+)
+
+// Undo writes back the version of the file the session changed last from
+// before that change
+func (s *service) Undo(ctx context.Context, sessionID string, force bool) (File, error) {
+	latest, err := s.q.GetLatestSessionFileChange(ctx, sessionID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return File{}, ErrNothingToUndo
+	}
+	if err != nil {
+		return File{}, err
+	}
+	versions, err := s.sessionVersions(ctx, sessionID, latest.Path)
+	if err != nil {
+		return File{}, err
+	}
+	i := slices.IndexFunc(versions, func(file File) bool {
+		return file.Version == latest.Version
+	})
+	if i < 1 {
+		return File{}, ErrNothingToUndo
+	}
+	return s.Revert(ctx, sessionID, latest.Path, versions[i-1].Version, force)
+}
+
+// Revert writes back a version of the file the session recorded and drops
+// the versions after it, so that undoing goes on from there. Unless force is
+// set, it fails with ErrFileModified when the file no longer has the content
+// of its latest version.
+func (s *service) Revert(ctx context.Context, sessionID, path string, version int64, force bool) (File, error) {
+	versions, err := s.sessionVersions(ctx, sessionID, path)
+	if err != nil {
+		return File{}, err
+	}
+	i := slices.IndexFunc(versions, func(file File) bool {
+		return file.Version == version
+	})
+	if i == -1 {
+		return File{}, fmt.Errorf("the session has no version %d of %s", version, path)
+	}
+	if !force {
+		if err := checkUnmodified(versions[len(versions)-1]); err != nil {
+			return File{}, err
+		}
+	}
+	if err := restore(versions[i], i == 0); err != nil {
+		return File{}, fmt.Errorf("failed to revert %s: %w", path, err)
+	}
+	for _, file := range versions[i+1:] {
+		if err := s.Delete(ctx, file.ID); err != nil {
+			return File{}, err
+		}
+	}
+	return versions[i], nil
+}
+
+// RevertSession writes back every file the session changed as it was
+// before. It checks that none was modified outside floss before writing
+// any, unless force is set.
+func (s *service) RevertSession(ctx context.Context, sessionID string, force bool) ([]File, error) {
+	return s.RevertTo(ctx, sessionID, nil, force)
+}
+
+// RevertTo writes back the versions of the files the session changed, as
+// LatestVersions returned them. Files left out go back to how they were
+// before the session. Like RevertSession it writes none of them when one
+// was modified outside floss, unless force is set.
+func (s *service) RevertTo(ctx context.Context, sessionID string, targets map[string]int64, force bool) ([]File, error) {
+	files, err := s.ListBySession(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	versions := make(map[string][]File)
+	for _, file := range files {
+		versions[file.Path] = append(versions[file.Path], file)
+	}
+	target := make(map[string]int64)
+	var changed []string
+	for path, pathVersions := range versions {
+		version, ok := targets[path]
+		if !ok {
+			version = pathVersions[0].Version
+		}
+		if pathVersions[len(pathVersions)-1].Version != version {
+			target[path] = version
+			changed = append(changed, path)
+		}
+	}
+	slices.Sort(changed)
+
+	if !force {
+		var modified []string
+		for _, path := range changed {
+			pathVersions := versions[path]
+			if err := checkUnmodified(pathVersions[len(pathVersions)-1]); errors.Is(err, ErrFileModified) {
+				modified = append(modified, path)
+			} else if err != nil {
+				return nil, err
+			}
+		}
+		if len(modified) > 0 {
+			return nil, fmt.Errorf("%w: %s", ErrFileModified, strings.Join(modified, ", "))
+		}
+	}
+
+	var reverted []File
+	for _, path := range changed {
+		file, err := s.Revert(ctx, sessionID, path, target[path], true)
+		if err != nil {
+			return reverted, err
+		}
+		reverted = append(reverted, file)
+	}
+	return reverted, nil
+}
+
+// LatestVersions returns the latest version of each file the session
+// changed
+func (s *service) LatestVersions(ctx context.Context, sessionID string) (map[string]int64, error) {
+	files, err := s.ListBySession(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	versions := make(map[string]int64)
+	for _, file := range files {
+		versions[file.Path] = max(versions[file.Path], file.Version)
+	}
+	return versions, nil
+}
+
+// CopySession records the versions of the files one session changed for
+// another one too, in the same order
+func (s *service) CopySession(ctx context.Context, fromSessionID, toSessionID string) error {
+	files, err := s.q.ListSessionFilesInOrder(ctx, fromSessionID)
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		dbFile, err := s.q.CreateFile(ctx, db.CreateFileParams{
+			ID:        uuid.New().String(),
+			SessionID: toSessionID,
+			Path:      file.Path,
+			Content:   file.Content,
+			Version:   file.Version,
+			Existed:   file.Existed,
+		})
+		if err != nil {
+			return err
+		}
+		s.Publish(pubsub.CreatedEvent, s.fromDBItem(dbFile))
+	}
+	return nil
+}
+
+// sessionVersions returns the versions of the file the session recorded,
+// oldest first
+func (s *service) sessionVersions(ctx context.Context, sessionID, path string) ([]File, error) {
+	files, err := s.ListBySession(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	return slices.DeleteFunc(files, func(file File) bool {
+		return file.Path != path
+	}), nil
+}
+
+// checkUnmodified fails with ErrFileModified when the file on disk differs
+// from its latest version
+func checkUnmodified(latest File) error {
+	data, err := os.ReadFile(latest.Path)
+	if errors.Is(err, fs.ErrNotExist) && latest.Content == "" {
+		return nil
+	}
+	if errors.Is(err, fs.ErrNotExist) || (err == nil && string(data) != latest.Content) {
+		return fmt.Errorf("%w: %s", ErrFileModified, latest.Path)
+	}
+	return err
+}
+
+// restore writes the version back to disk. The first version of a file the
+// session created is removed instead.
+func restore(file File, first bool) error {
+	if first && !file.Existed {
+		if err := os.Remove(file.Path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(file.Path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(file.Path, []byte(file.Content), 0o644)
+}
@@ -0,0 +1,149 @@
+package history
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/nom-nom-hub/floss/internal/db"
+	"github.com/nom-nom-hub/floss/internal/session"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestService(t *testing.T) (Service, string) {
+	t.Helper()
+	conn, err := db.Connect(t.Context(), t.TempDir())
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	queries := db.New(conn)
+	sess, err := session.NewService(queries).Create(t.Context(), "revert")
+	require.NoError(t, err)
+	return NewService(queries, conn), sess.ID
+}
+
+// change writes the content to the file and records it as the tools do
+func change(t *testing.T, files Service, sessionID, path, content string) {
+	t.Helper()
+	old, err := os.ReadFile(path)
+	existed := err == nil
+	if err != nil && !os.IsNotExist(err) {
+		require.NoError(t, err)
+	}
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	if _, err := files.GetByPathAndSession(t.Context(), path, sessionID); err != nil {
+		if existed {
+			_, err = files.Create(t.Context(), sessionID, path, string(old))
+		} else {
+			_, err = files.CreateNew(t.Context(), sessionID, path)
+		}
+		require.NoError(t, err)
+	}
+	_, err = files.CreateVersion(t.Context(), sessionID, path, content)
+	require.NoError(t, err)
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	return string(data)
+}
+
+func TestUndo(t *testing.T) {
+	t.Parallel()
+
+	files, sessionID := newTestService(t)
+	dir := t.TempDir()
+	main := filepath.Join(dir, "main.go")
+	readme := filepath.Join(dir, "README.md")
+	require.NoError(t, os.WriteFile(main, []byte("v1"), 0o644))
+	change(t, files, sessionID, main, "v2")
+	change(t, files, sessionID, readme, "docs")
+	change(t, files, sessionID, main, "v3")
+
+	file, err := files.Undo(t.Context(), sessionID, false)
+	require.NoError(t, err)
+	require.Equal(t, main, file.Path)
+	require.Equal(t, "v2", readFile(t, main))
+
+	// The file created by the session is removed
+	file, err = files.Undo(t.Context(), sessionID, false)
+	require.NoError(t, err)
+	require.Equal(t, readme, file.Path)
+	require.NoFileExists(t, readme)
+
+	require.NoError(t, os.WriteFile(main, []byte("edited by hand"), 0o644))
+	_, err = files.Undo(t.Context(), sessionID, false)
+	require.ErrorIs(t, err, ErrFileModified)
+	require.Equal(t, "edited by hand", readFile(t, main))
+	_, err = files.Undo(t.Context(), sessionID, true)
+	require.NoError(t, err)
+	require.Equal(t, "v1", readFile(t, main))
+
+	_, err = files.Undo(t.Context(), sessionID, false)
+	require.ErrorIs(t, err, ErrNothingToUndo)
+}
+
+func TestRevert(t *testing.T) {
+	t.Parallel()
+
+	files, sessionID := newTestService(t)
+	main := filepath.Join(t.TempDir(), "main.go")
+	require.NoError(t, os.WriteFile(main, []byte("v1"), 0o644))
+	change(t, files, sessionID, main, "v2")
+	change(t, files, sessionID, main, "v3")
+
+	file, err := files.Revert(t.Context(), sessionID, main, 1, false)
+	require.NoError(t, err)
+	require.Equal(t, "v2", file.Content)
+	require.Equal(t, "v2", readFile(t, main))
+	latest, err := files.GetByPathAndSession(t.Context(), main, sessionID)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), latest.Version)
+
+	_, err = files.Revert(t.Context(), sessionID, main, 2, false)
+	require.EqualError(t, err, "the session has no version 2 of "+main)
+}
+
+func TestRevertKeepsEmptyFile(t *testing.T) {
+	t.Parallel()
+
+	files, sessionID := newTestService(t)
+	empty := filepath.Join(t.TempDir(), "__init__.py")
+	require.NoError(t, os.WriteFile(empty, nil, 0o644))
+	change(t, files, sessionID, empty, "import os\n")
+
+	_, err := files.Undo(t.Context(), sessionID, false)
+	require.NoError(t, err)
+	require.FileExists(t, empty)
+	require.Empty(t, readFile(t, empty))
+}
+
+func TestRevertSession(t *testing.T) {
+	t.Parallel()
+
+	files, sessionID := newTestService(t)
+	dir := t.TempDir()
+	main := filepath.Join(dir, "main.go")
+	created := filepath.Join(dir, "new.go")
+	require.NoError(t, os.WriteFile(main, []byte("v1"), 0o644))
+	change(t, files, sessionID, main, "v2")
+	change(t, files, sessionID, main, "v3")
+	change(t, files, sessionID, created, "package main")
+
+	// Nothing is written while a file was modified outside floss
+	require.NoError(t, os.Remove(created))
+	_, err := files.RevertSession(t.Context(), sessionID, false)
+	require.ErrorIs(t, err, ErrFileModified)
+	require.EqualError(t, err, "file modified outside floss: "+created)
+	require.Equal(t, "v3", readFile(t, main))
+
+	reverted, err := files.RevertSession(t.Context(), sessionID, true)
+	require.NoError(t, err)
+	require.Len(t, reverted, 2)
+	require.Equal(t, "v1", readFile(t, main))
+	require.NoFileExists(t, created)
+
+	_, err = files.Undo(t.Context(), sessionID, false)
+	require.ErrorIs(t, err, ErrNothingToUndo)
+}
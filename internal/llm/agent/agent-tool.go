@@ -85,6 +85,7 @@ func (b *agentTool) Run(ctx context.Context, call tools.ToolCall) (tools.ToolRes
 	}
 
 	parentSession.Cost += updatedSession.Cost
+	parentSession.TotalTokens += updatedSession.TotalTokens
 
 	_, err = b.sessions.Save(ctx, parentSession)
 	if err != nil {
@@ -5,11 +5,14 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"path/filepath"
 	"slices"
 	"strings"
 	"time"
 
 	"github.com/charmbracelet/catwalk/pkg/catwalk"
+	"github.com/google/uuid"
+	"github.com/nom-nom-hub/floss/internal/checkpoint"
 	"github.com/nom-nom-hub/floss/internal/config"
 	"github.com/nom-nom-hub/floss/internal/csync"
 	"github.com/nom-nom-hub/floss/internal/history"
@@ -22,7 +25,6 @@ import (
 	"github.com/nom-nom-hub/floss/internal/permission"
 	"github.com/nom-nom-hub/floss/internal/pubsub"
 	"github.com/nom-nom-hub/floss/internal/session"
-	"github.com/nom-nom-hub/floss/internal/shell"
 )
 
 // Common errors
@@ -31,12 +33,19 @@ var (
 	ErrSessionBusy      = errors.New("session is currently processing another request")
 )
 
+// maxParallelToolCalls limits how many read-only tool calls from one
+// assistant message run at once
+const maxParallelToolCalls = 8
+
 type AgentEventType string
 
 const (
 	AgentEventTypeError     AgentEventType = "error"
 	AgentEventTypeResponse  AgentEventType = "response"
 	AgentEventTypeSummarize AgentEventType = "summarize"
+	// AgentEventTypeWarning reports in Progress that the provider is
+	// retrying or falling back to another model
+	AgentEventTypeWarning AgentEventType = "warning"
 )
 
 type AgentEvent struct {
@@ -60,8 +69,21 @@ type Service interface {
 	IsBusy() bool
 	Summarize(ctx context.Context, sessionID string) error
 	UpdateModel() error
-	QueuedPrompts(sessionID string) int
-	ClearQueue(sessionID string)
+	// Interject runs the prompt like Run, except that while the session is
+	// busy the prompt goes in before the next request to the model instead
+	// of waiting for the agent to end its turn
+	Interject(ctx context.Context, sessionID string, content string, attachments ...message.Attachment) (<-chan AgentEvent, error)
+	// QueuedPrompts returns the prompts waiting for the session in order
+	QueuedPrompts(sessionID string) []QueuedPrompt
+	// UpdateQueuedPrompt replaces the queued prompt with the ID of prompt,
+	// and reports whether it was still queued
+	UpdateQueuedPrompt(sessionID string, prompt QueuedPrompt) bool
+	// MoveQueuedPrompt moves the queued prompt by offset places
+	MoveQueuedPrompt(sessionID, promptID string, offset int) bool
+	// RemoveQueuedPrompt takes the prompt out of the queue
+	RemoveQueuedPrompt(sessionID, promptID string) (QueuedPrompt, bool)
+	// ClearQueue takes every prompt out of the queue of the session
+	ClearQueue(sessionID string) []QueuedPrompt
 }
 
 type agent struct {
@@ -71,6 +93,8 @@ type agent struct {
 	messages message.Service
 	mcpTools []McpTool
 
+	checkpoints checkpoint.Service
+
 	tools *csync.LazySlice[tools.BaseTool]
 	// We need this to be able to update it when model changes
 	agentToolFn func() (tools.BaseTool, error)
@@ -83,7 +107,12 @@ type agent struct {
 	summarizeProviderID string
 
 	activeRequests *csync.Map[string, context.CancelFunc]
-	promptQueue    *csync.Map[string, []string]
+	promptQueue    *promptQueue
+
+	permissions permission.Service
+	// budgetWarnings holds the session and budget names the agent warned
+	// about
+	budgetWarnings *csync.Map[string, bool]
 }
 
 var agentPromptMap = map[string]prompt.PromptID{
@@ -99,7 +128,11 @@ func NewAgent(
 	sessions session.Service,
 	messages message.Service,
 	history history.Service,
+	// checkpoints records a checkpoint before each prompt, unless it is nil
+	checkpoints checkpoint.Service,
 	lspClients *csync.Map[string, *lsp.Client],
+	// Tools the agent always has, regardless of its allowed tools
+	extraTools ...tools.BaseTool,
 ) (Service, error) {
 	cfg := config.Get()
 
@@ -110,7 +143,7 @@ func NewAgent(
 			if taskAgentCfg.ID == "" {
 				return nil, fmt.Errorf("task agent not found in config")
 			}
-			taskAgent, err := NewAgent(ctx, taskAgentCfg, permissions, sessions, messages, history, lspClients)
+			taskAgent, err := NewAgent(ctx, taskAgentCfg, permissions, sessions, messages, history, nil, lspClients)
 			if err != nil {
 				return nil, fmt.Errorf("failed to create task agent: %w", err)
 			}
@@ -128,18 +161,23 @@ func NewAgent(
 		return nil, fmt.Errorf("model not found for agent %s", agentCfg.Name)
 	}
 
-	promptID := agentPromptMap[agentCfg.ID]
-	if promptID == "" {
-		promptID = prompt.PromptDefault
-	}
 	opts := []provider.ProviderClientOption{
 		provider.WithModel(agentCfg.Model),
-		provider.WithSystemMessage(prompt.GetPrompt(promptID, providerCfg.ID, config.Get().Options.ContextPaths...)),
+		provider.WithSystemPrompt(func(providerID string) string {
+			return systemPrompt(agentCfg, providerID)
+		}),
 	}
 	agentProvider, err := provider.NewProvider(*providerCfg, opts...)
 	if err != nil {
 		return nil, err
 	}
+	if dir := cfg.Options.RecordDirectory; dir != "" {
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(cfg.WorkingDir(), dir)
+		}
+		path := filepath.Join(dir, fmt.Sprintf("%s-%s.json", agentCfg.ID, time.Now().Format("20060102-150405.000000000")))
+		agentProvider = provider.NewRecordingProvider(agentProvider, path)
+	}
 
 	smallModelCfg := cfg.Models[config.SelectedModelTypeSmall]
 	var smallModelProviderCfg *config.ProviderConfig
@@ -159,7 +197,9 @@ func NewAgent(
 
 	titleOpts := []provider.ProviderClientOption{
 		provider.WithModel(config.SelectedModelTypeSmall),
-		provider.WithSystemMessage(prompt.GetPrompt(prompt.PromptTitle, smallModelProviderCfg.ID)),
+		provider.WithSystemPrompt(func(providerID string) string {
+			return prompt.GetPrompt(prompt.PromptTitle, providerID)
+		}),
 	}
 	titleProvider, err := provider.NewProvider(*smallModelProviderCfg, titleOpts...)
 	if err != nil {
@@ -168,7 +208,9 @@ func NewAgent(
 
 	summarizeOpts := []provider.ProviderClientOption{
 		provider.WithModel(config.SelectedModelTypeLarge),
-		provider.WithSystemMessage(prompt.GetPrompt(prompt.PromptSummarizer, providerCfg.ID)),
+		provider.WithSystemPrompt(func(providerID string) string {
+			return prompt.GetPrompt(prompt.PromptSummarizer, providerID)
+		}),
 	}
 	summarizeProvider, err := provider.NewProvider(*providerCfg, summarizeOpts...)
 	if err != nil {
@@ -185,42 +227,35 @@ func NewAgent(
 		allTools := []tools.BaseTool{
 			tools.NewBashTool(permissions, cwd, cfg.Options.Attribution),
 			tools.NewDownloadTool(permissions, cwd),
-			tools.NewEditTool(lspClients, permissions, history, cwd),
-			tools.NewMultiEditTool(lspClients, permissions, history, cwd),
+			tools.NewEditTool(lspClients, permissions, history, cfg.Formatters, cwd),
+			tools.NewMultiEditTool(lspClients, permissions, history, cfg.Formatters, cwd),
 			tools.NewFetchTool(permissions, cwd),
 			tools.NewGlobTool(cwd),
 			tools.NewGrepTool(cwd),
 			tools.NewLsTool(permissions, cwd),
 			tools.NewSourcegraphTool(),
 			tools.NewViewTool(lspClients, permissions, cwd),
-			tools.NewWriteTool(lspClients, permissions, history, cwd),
+			tools.NewWriteTool(lspClients, permissions, history, cfg.Formatters, cwd),
 		}
 
 		mcpToolsOnce.Do(func() {
 			mcpTools = doGetMCPTools(ctx, permissions, cfg)
 		})
 
-		withCoderTools := func(t []tools.BaseTool) []tools.BaseTool {
-			if agentCfg.ID == "coder" {
-				t = append(t, mcpTools...)
-				if lspClients.Len() > 0 {
-					t = append(t, tools.NewDiagnosticsTool(lspClients))
-				}
-			}
-			return t
-		}
-
-		if agentCfg.AllowedTools == nil {
-			return withCoderTools(allTools)
-		}
-
-		var filteredTools []tools.BaseTool
-		for _, tool := range allTools {
-			if slices.Contains(agentCfg.AllowedTools, tool.Name()) {
-				filteredTools = append(filteredTools, tool)
-			}
+		agentTools := FilterTools(agentCfg, append(allTools, mcpTools...))
+		if clients := allowedLSPClients(agentCfg.AllowedLSP, lspClients); clients.Len() > 0 {
+			agentTools = append(agentTools, tools.NewDiagnosticsTool(clients))
+			agentTools = append(agentTools, FilterTools(agentCfg, []tools.BaseTool{
+				tools.NewDefinitionTool(clients, cwd),
+				tools.NewReferencesTool(clients, cwd),
+				tools.NewHoverTool(clients, cwd),
+				tools.NewDocumentSymbolsTool(clients, cwd),
+				tools.NewWorkspaceSymbolsTool(clients, cwd),
+				tools.NewRenameTool(clients, permissions, history, cwd),
+				tools.NewCodeActionTool(clients, permissions, history, cwd),
+			})...)
 		}
-		return withCoderTools(filteredTools)
+		return append(agentTools, extraTools...)
 	}
 
 	return &agent{
@@ -230,13 +265,16 @@ func NewAgent(
 		providerID:          string(providerCfg.ID),
 		messages:            messages,
 		sessions:            sessions,
+		checkpoints:         checkpoints,
 		titleProvider:       titleProvider,
 		summarizeProvider:   summarizeProvider,
 		summarizeProviderID: string(providerCfg.ID),
 		agentToolFn:         agentToolFn,
 		activeRequests:      csync.NewMap[string, context.CancelFunc](),
 		tools:               csync.NewLazySlice(toolFn),
-		promptQueue:         csync.NewMap[string, []string](),
+		promptQueue:         newPromptQueue(),
+		permissions:         permissions,
+		budgetWarnings:      csync.NewMap[string, bool](),
 	}, nil
 }
 
@@ -257,9 +295,8 @@ func (a *agent) Cancel(sessionID string) {
 		cancel()
 	}
 
-	if a.QueuedPrompts(sessionID) > 0 {
-		slog.Info("Clearing queued prompts", "session_id", sessionID)
-		a.promptQueue.Del(sessionID)
+	if len(a.promptQueue.clear(sessionID)) > 0 {
+		slog.Info("Cleared queued prompts", "session_id", sessionID)
 	}
 }
 
@@ -279,12 +316,20 @@ func (a *agent) IsSessionBusy(sessionID string) bool {
 	return busy
 }
 
-func (a *agent) QueuedPrompts(sessionID string) int {
-	l, ok := a.promptQueue.Get(sessionID)
-	if !ok {
-		return 0
-	}
-	return len(l)
+func (a *agent) QueuedPrompts(sessionID string) []QueuedPrompt {
+	return a.promptQueue.list(sessionID)
+}
+
+func (a *agent) UpdateQueuedPrompt(sessionID string, prompt QueuedPrompt) bool {
+	return a.promptQueue.update(sessionID, prompt)
+}
+
+func (a *agent) MoveQueuedPrompt(sessionID, promptID string, offset int) bool {
+	return a.promptQueue.move(sessionID, promptID, offset)
+}
+
+func (a *agent) RemoveQueuedPrompt(sessionID, promptID string) (QueuedPrompt, bool) {
+	return a.promptQueue.remove(sessionID, promptID)
 }
 
 func (a *agent) generateTitle(ctx context.Context, sessionID string, content string) error {
@@ -344,17 +389,27 @@ func (a *agent) err(err error) AgentEvent {
 }
 
 func (a *agent) Run(ctx context.Context, sessionID string, content string, attachments ...message.Attachment) (<-chan AgentEvent, error) {
+	return a.run(ctx, sessionID, content, false, attachments)
+}
+
+func (a *agent) Interject(ctx context.Context, sessionID string, content string, attachments ...message.Attachment) (<-chan AgentEvent, error) {
+	return a.run(ctx, sessionID, content, true, attachments)
+}
+
+// run starts a request for the prompt, or queues the prompt while the
+// session is busy
+func (a *agent) run(ctx context.Context, sessionID string, content string, interject bool, attachments []message.Attachment) (<-chan AgentEvent, error) {
 	if !a.Model().SupportsImages && attachments != nil {
 		attachments = nil
 	}
 	events := make(chan AgentEvent, 1)
 	if a.IsSessionBusy(sessionID) {
-		existing, ok := a.promptQueue.Get(sessionID)
-		if !ok {
-			existing = []string{}
-		}
-		existing = append(existing, content)
-		a.promptQueue.Set(sessionID, existing)
+		a.promptQueue.add(sessionID, QueuedPrompt{
+			ID:          uuid.New().String(),
+			Content:     content,
+			Attachments: attachments,
+			Interject:   interject,
+		})
 		return nil, nil
 	}
 
@@ -366,11 +421,7 @@ func (a *agent) Run(ctx context.Context, sessionID string, content string, attac
 		defer log.RecoverPanic("agent.Run", func() {
 			events <- a.err(fmt.Errorf("panic while running the agent"))
 		})
-		var attachmentParts []message.ContentPart
-		for _, attachment := range attachments {
-			attachmentParts = append(attachmentParts, message.BinaryContent{Path: attachment.FilePath, MIMEType: attachment.MimeType, Data: attachment.Content})
-		}
-		result := a.processGeneration(genCtx, sessionID, content, attachmentParts)
+		result := a.processGeneration(genCtx, sessionID, content, attachmentContent(attachments))
 		if result.Error != nil && !errors.Is(result.Error, ErrRequestCancelled) && !errors.Is(result.Error, context.Canceled) {
 			slog.Error(result.Error.Error())
 		}
@@ -406,19 +457,7 @@ func (a *agent) processGeneration(ctx context.Context, sessionID, content string
 	if err != nil {
 		return a.err(fmt.Errorf("failed to get session: %w", err))
 	}
-	if session.SummaryMessageID != "" {
-		summaryMsgInex := -1
-		for i, msg := range msgs {
-			if msg.ID == session.SummaryMessageID {
-				summaryMsgInex = i
-				break
-			}
-		}
-		if summaryMsgInex != -1 {
-			msgs = msgs[summaryMsgInex:]
-			msgs[0].Role = message.User
-		}
-	}
+	msgs, _, _ = sessionHistory(msgs, session)
 
 	userMsg, err := a.createUserMessage(ctx, sessionID, content, attachmentParts)
 	if err != nil {
@@ -427,6 +466,8 @@ func (a *agent) processGeneration(ctx context.Context, sessionID, content string
 	// Append the new user message to the conversation history.
 	msgHistory := append(msgs, userMsg)
 
+	guard := newLoopGuard()
+	autoCompact := true
 	for {
 		// Check for cancellation before each iteration
 		select {
@@ -435,6 +476,29 @@ func (a *agent) processGeneration(ctx context.Context, sessionID, content string
 		default:
 			// Continue processing
 		}
+		if err := a.checkBudgets(ctx, sessionID); err != nil {
+			return a.err(err)
+		}
+		if autoCompact && a.shouldCompact(msgHistory) {
+			if err := a.compact(ctx, sessionID, func(string) {}); err != nil {
+				if errors.Is(err, context.Canceled) {
+					return a.err(ErrRequestCancelled)
+				}
+				a.Publish(pubsub.CreatedEvent, AgentEvent{
+					Type:      AgentEventTypeWarning,
+					SessionID: sessionID,
+					Progress:  fmt.Sprintf("Failed to compact the conversation: %v", err),
+				})
+				autoCompact = false
+			} else {
+				if msgHistory, err = a.history(ctx, sessionID); err != nil {
+					return a.err(err)
+				}
+				// Compacting again won't help when the kept turns alone
+				// are too long
+				autoCompact = !a.shouldCompact(msgHistory)
+			}
+		}
 		agentMessage, toolResults, err := a.streamAndHandleEvents(ctx, sessionID, msgHistory)
 		if err != nil {
 			if errors.Is(err, context.Canceled) {
@@ -448,31 +512,31 @@ func (a *agent) processGeneration(ctx context.Context, sessionID, content string
 			slog.Info("Result", "message", agentMessage.FinishReason(), "toolResults", toolResults)
 		}
 		if (agentMessage.FinishReason() == message.FinishReasonToolUse) && toolResults != nil {
+			if err := a.guardLoop(ctx, sessionID, guard, agentMessage); err != nil {
+				return a.err(err)
+			}
 			// We are not done, we need to respond with the tool response
 			msgHistory = append(msgHistory, agentMessage, *toolResults)
-			// If there are queued prompts, process the next one
-			nextPrompt, ok := a.promptQueue.Take(sessionID)
-			if ok {
-				for _, prompt := range nextPrompt {
-					// Create a new user message for the queued prompt
-					userMsg, err := a.createUserMessage(ctx, sessionID, prompt, nil)
-					if err != nil {
-						return a.err(fmt.Errorf("failed to create user message for queued prompt: %w", err))
-					}
-					// Append the new user message to the conversation history
-					msgHistory = append(msgHistory, userMsg)
+			// Interjections steer the request before its next model call
+			for _, prompt := range a.promptQueue.take(sessionID, true) {
+				userMsg, err := a.createUserMessage(ctx, sessionID, prompt.Content, attachmentContent(prompt.Attachments))
+				if err != nil {
+					return a.err(fmt.Errorf("failed to create user message for queued prompt: %w", err))
 				}
+				msgHistory = append(msgHistory, userMsg)
 			}
 
 			continue
 		} else if agentMessage.FinishReason() == message.FinishReasonEndTurn {
-			queuePrompts, ok := a.promptQueue.Take(sessionID)
-			if ok {
+			queuePrompts := a.promptQueue.take(sessionID, false)
+			if len(queuePrompts) > 0 {
+				// The queued prompts follow up on the answer
+				msgHistory = append(msgHistory, agentMessage)
 				for _, prompt := range queuePrompts {
-					if prompt == "" {
+					if prompt.Content == "" {
 						continue
 					}
-					userMsg, err := a.createUserMessage(ctx, sessionID, prompt, nil)
+					userMsg, err := a.createUserMessage(ctx, sessionID, prompt.Content, attachmentContent(prompt.Attachments))
 					if err != nil {
 						return a.err(fmt.Errorf("failed to create user message for queued prompt: %w", err))
 					}
@@ -495,7 +559,21 @@ func (a *agent) processGeneration(ctx context.Context, sessionID, content string
 	}
 }
 
+func attachmentContent(attachments []message.Attachment) []message.ContentPart {
+	var parts []message.ContentPart
+	for _, attachment := range attachments {
+		parts = append(parts, message.BinaryContent{Path: attachment.FilePath, MIMEType: attachment.MimeType, Data: attachment.Content})
+	}
+	return parts
+}
+
 func (a *agent) createUserMessage(ctx context.Context, sessionID, content string, attachmentParts []message.ContentPart) (message.Message, error) {
+	if a.checkpoints != nil {
+		// A failed checkpoint only takes away a point to go back to
+		if _, err := a.checkpoints.Create(ctx, sessionID, checkpointName(content)); err != nil {
+			slog.Warn("Failed to create checkpoint", "session_id", sessionID, "error", err)
+		}
+	}
 	parts := []message.ContentPart{message.TextContent{Text: content}}
 	parts = append(parts, attachmentParts...)
 	return a.messages.Create(ctx, sessionID, message.CreateMessageParams{
@@ -504,6 +582,16 @@ func (a *agent) createUserMessage(ctx context.Context, sessionID, content string
 	})
 }
 
+// checkpointName names the automatic checkpoint before the prompt after its
+// first line
+func checkpointName(prompt string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(prompt), "\n")
+	if runes := []rune(line); len(runes) > 50 {
+		line = string(runes[:49]) + "…"
+	}
+	return "Before: " + line
+}
+
 func (a *agent) getAllTools() ([]tools.BaseTool, error) {
 	allTools := slices.Collect(a.tools.Seq())
 	if a.agentToolFn != nil {
@@ -556,123 +644,159 @@ func (a *agent) streamAndHandleEvents(ctx context.Context, sessionID string, msg
 		}
 	}
 
-	toolResults := make([]message.ToolResult, len(assistantMsg.ToolCalls()))
-	toolCalls := assistantMsg.ToolCalls()
-	for i, toolCall := range toolCalls {
-		select {
-		case <-ctx.Done():
-			a.finishMessage(context.Background(), &assistantMsg, message.FinishReasonCanceled, "Request cancelled", "")
-			// Make all future tool calls cancelled
-			for j := i; j < len(toolCalls); j++ {
-				toolResults[j] = message.ToolResult{
-					ToolCallID: toolCalls[j].ID,
-					Content:    "Tool execution canceled by user",
-					IsError:    true,
-				}
+	toolResults := a.executeToolCalls(ctx, &assistantMsg, assistantMsg.ToolCalls())
+	if len(toolResults) == 0 {
+		return assistantMsg, nil, nil
+	}
+	parts := make([]message.ContentPart, 0)
+	for _, tr := range toolResults {
+		parts = append(parts, tr)
+	}
+	msg, err := a.messages.Create(context.Background(), assistantMsg.SessionID, message.CreateMessageParams{
+		Role:     message.Tool,
+		Parts:    parts,
+		Provider: a.providerID,
+	})
+	if err != nil {
+		return assistantMsg, nil, fmt.Errorf("failed to create cancelled tool message: %w", err)
+	}
+
+	return assistantMsg, &msg, err
+}
+
+// executeToolCalls runs the tool calls of an assistant message and returns
+// their results in the same order. Consecutive calls to read-only tools run
+// concurrently, up to maxParallelToolCalls at a time; every other call runs
+// on its own. Once the request is cancelled or a permission is denied, the
+// remaining calls are cancelled.
+func (a *agent) executeToolCalls(ctx context.Context, assistantMsg *message.Message, toolCalls []message.ToolCall) []message.ToolResult {
+	toolResults := make([]message.ToolResult, len(toolCalls))
+	cancelFrom := func(i int) {
+		for j := i; j < len(toolCalls); j++ {
+			toolResults[j] = message.ToolResult{
+				ToolCallID: toolCalls[j].ID,
+				Content:    "Tool execution canceled by user",
+				IsError:    true,
 			}
-			goto out
-		default:
-			// Continue processing
-			var tool tools.BaseTool
-			allTools, _ := a.getAllTools()
-			for _, availableTool := range allTools {
-				if availableTool.Info().Name == toolCall.Name {
-					tool = availableTool
+		}
+	}
+
+	allTools, _ := a.getAllTools()
+	findTool := func(name string) tools.BaseTool {
+		for _, availableTool := range allTools {
+			if availableTool.Info().Name == name {
+				return availableTool
+			}
+		}
+		return nil
+	}
+
+	for i := 0; i < len(toolCalls); {
+		if ctx.Err() != nil {
+			a.finishMessage(context.Background(), assistantMsg, message.FinishReasonCanceled, "Request cancelled", "")
+			cancelFrom(i)
+			return toolResults
+		}
+
+		batch := []tools.BaseTool{findTool(toolCalls[i].Name)}
+		if batch[0] != nil && tools.IsReadOnly(batch[0]) {
+			for i+len(batch) < len(toolCalls) && len(batch) < maxParallelToolCalls {
+				tool := findTool(toolCalls[i+len(batch)].Name)
+				if tool == nil || !tools.IsReadOnly(tool) {
 					break
 				}
+				batch = append(batch, tool)
 			}
+		}
 
-			// Tool not found
+		// Run the tools in goroutines to allow cancellation
+		type toolExecResult struct {
+			index    int
+			response tools.ToolResponse
+			err      error
+		}
+		resultChan := make(chan toolExecResult, len(batch))
+		cancels := make([]context.CancelFunc, len(batch))
+		for k, tool := range batch {
+			toolCall := toolCalls[i+k]
 			if tool == nil {
-				toolResults[i] = message.ToolResult{
-					ToolCallID: toolCall.ID,
-					Content:    fmt.Sprintf("Tool not found: %s", toolCall.Name),
-					IsError:    true,
+				resultChan <- toolExecResult{
+					index:    k,
+					response: tools.NewTextErrorResponse(fmt.Sprintf("Tool not found: %s", toolCall.Name)),
 				}
 				continue
 			}
-
-			// Run tool in goroutine to allow cancellation
-			type toolExecResult struct {
-				response tools.ToolResponse
-				err      error
-			}
-			resultChan := make(chan toolExecResult, 1)
-
+			var callCtx context.Context
+			callCtx, cancels[k] = context.WithCancel(ctx)
 			go func() {
-				response, err := tool.Run(ctx, tools.ToolCall{
+				response, err := tool.Run(callCtx, tools.ToolCall{
 					ID:    toolCall.ID,
 					Name:  toolCall.Name,
 					Input: toolCall.Input,
 				})
-				resultChan <- toolExecResult{response: response, err: err}
+				resultChan <- toolExecResult{index: k, response: response, err: err}
 			}()
+		}
+		stopFrom := func(k int) {
+			for ; k < len(cancels); k++ {
+				if cancels[k] != nil {
+					cancels[k]()
+				}
+			}
+		}
 
-			var toolResponse tools.ToolResponse
-			var toolErr error
-
+		// Wait for the calls that come before the first denied one, as if
+		// they had run one after another
+		done := make([]bool, len(batch))
+		denied := len(batch)
+		waiting := func() bool {
+			return slices.Contains(done[:denied], false)
+		}
+		for waiting() {
 			select {
 			case <-ctx.Done():
-				a.finishMessage(context.Background(), &assistantMsg, message.FinishReasonCanceled, "Request cancelled", "")
-				// Mark remaining tool calls as cancelled
-				for j := i; j < len(toolCalls); j++ {
-					toolResults[j] = message.ToolResult{
-						ToolCallID: toolCalls[j].ID,
-						Content:    "Tool execution canceled by user",
-						IsError:    true,
+				stopFrom(0)
+				a.finishMessage(context.Background(), assistantMsg, message.FinishReasonCanceled, "Request cancelled", "")
+				for k := range denied {
+					if !done[k] {
+						cancelFrom(i + k)
+						return toolResults
 					}
 				}
-				goto out
 			case result := <-resultChan:
-				toolResponse = result.response
-				toolErr = result.err
-			}
-
-			if toolErr != nil {
-				slog.Error("Tool execution error", "toolCall", toolCall.ID, "error", toolErr)
-				if errors.Is(toolErr, permission.ErrorPermissionDenied) {
-					toolResults[i] = message.ToolResult{
-						ToolCallID: toolCall.ID,
-						Content:    "Permission denied",
-						IsError:    true,
-					}
-					for j := i + 1; j < len(toolCalls); j++ {
-						toolResults[j] = message.ToolResult{
-							ToolCallID: toolCalls[j].ID,
-							Content:    "Tool execution canceled by user",
-							IsError:    true,
-						}
+				done[result.index] = true
+				toolCall := toolCalls[i+result.index]
+				if result.err != nil {
+					slog.Error("Tool execution error", "toolCall", toolCall.ID, "error", result.err)
+					if errors.Is(result.err, permission.ErrorPermissionDenied) && result.index < denied {
+						denied = result.index
+						stopFrom(denied + 1)
 					}
-					a.finishMessage(ctx, &assistantMsg, message.FinishReasonPermissionDenied, "Permission denied", "")
-					break
+				}
+				toolResults[i+result.index] = message.ToolResult{
+					ToolCallID: toolCall.ID,
+					Content:    result.response.Content,
+					Metadata:   result.response.Metadata,
+					IsError:    result.response.IsError,
 				}
 			}
-			toolResults[i] = message.ToolResult{
-				ToolCallID: toolCall.ID,
-				Content:    toolResponse.Content,
-				Metadata:   toolResponse.Metadata,
-				IsError:    toolResponse.IsError,
+		}
+		// Release the calls, stopping those after a denied one
+		stopFrom(0)
+
+		if denied < len(batch) {
+			toolResults[i+denied] = message.ToolResult{
+				ToolCallID: toolCalls[i+denied].ID,
+				Content:    "Permission denied",
+				IsError:    true,
 			}
+			cancelFrom(i + denied + 1)
+			a.finishMessage(ctx, assistantMsg, message.FinishReasonPermissionDenied, "Permission denied", "")
+			return toolResults
 		}
+		i += len(batch)
 	}
-out:
-	if len(toolResults) == 0 {
-		return assistantMsg, nil, nil
-	}
-	parts := make([]message.ContentPart, 0)
-	for _, tr := range toolResults {
-		parts = append(parts, tr)
-	}
-	msg, err := a.messages.Create(context.Background(), assistantMsg.SessionID, message.CreateMessageParams{
-		Role:     message.Tool,
-		Parts:    parts,
-		Provider: a.providerID,
-	})
-	if err != nil {
-		return assistantMsg, nil, fmt.Errorf("failed to create cancelled tool message: %w", err)
-	}
-
-	return assistantMsg, &msg, err
+	return toolResults
 }
 
 func (a *agent) finishMessage(ctx context.Context, msg *message.Message, finishReason message.FinishReason, message, details string) {
@@ -711,16 +835,35 @@ func (a *agent) processEvent(ctx context.Context, sessionID string, assistantMsg
 		slog.Info("Finished tool call", "toolCall", event.ToolCall)
 		assistantMsg.FinishToolCall(event.ToolCall.ID)
 		return a.messages.Update(ctx, *assistantMsg)
+	case provider.EventWarning:
+		a.Publish(pubsub.CreatedEvent, AgentEvent{
+			Type:      AgentEventTypeWarning,
+			SessionID: sessionID,
+			Progress:  event.Content,
+		})
+		if event.Restart {
+			// The response is streamed again from the start
+			assistantMsg.Parts = nil
+			return a.messages.Update(ctx, *assistantMsg)
+		}
+		return nil
 	case provider.EventError:
 		return event.Error
 	case provider.EventComplete:
+		model := a.Model()
+		// Record the fallback model that answered instead
+		if event.Response.Model != nil {
+			model = *event.Response.Model
+			assistantMsg.Model = model.ID
+			assistantMsg.Provider = event.Response.ProviderID
+		}
 		assistantMsg.FinishThinking()
 		assistantMsg.SetToolCalls(event.Response.ToolCalls)
 		assistantMsg.AddFinish(event.Response.FinishReason, "", "")
 		if err := a.messages.Update(ctx, *assistantMsg); err != nil {
 			return fmt.Errorf("failed to update message: %w", err)
 		}
-		return a.TrackUsage(ctx, sessionID, a.Model(), event.Response.Usage)
+		return a.TrackUsage(ctx, sessionID, model, event.Response.Usage)
 	}
 
 	return nil
@@ -737,7 +880,9 @@ func (a *agent) TrackUsage(ctx context.Context, sessionID string, model catwalk.
 		model.CostPer1MIn/1e6*float64(usage.InputTokens) +
 		model.CostPer1MOut/1e6*float64(usage.OutputTokens)
 
+	tokens := usage.InputTokens + usage.OutputTokens + usage.CacheCreationTokens + usage.CacheReadTokens
 	sess.Cost += cost
+	sess.TotalTokens += tokens
 	sess.CompletionTokens = usage.OutputTokens + usage.CacheReadTokens
 	sess.PromptTokens = usage.InputTokens + usage.CacheCreationTokens
 
@@ -745,6 +890,9 @@ func (a *agent) TrackUsage(ctx context.Context, sessionID string, model catwalk.
 	if err != nil {
 		return fmt.Errorf("failed to save session: %w", err)
 	}
+	if err := a.sessions.RecordUsage(ctx, sessionID, session.Usage{Cost: cost, Tokens: tokens}); err != nil {
+		return fmt.Errorf("failed to record usage: %w", err)
+	}
 	return nil
 }
 
@@ -767,170 +915,42 @@ func (a *agent) Summarize(ctx context.Context, sessionID string) error {
 	go func() {
 		defer a.activeRequests.Del(sessionID + "-summarize")
 		defer cancel()
-		event := AgentEvent{
+		a.Publish(pubsub.CreatedEvent, AgentEvent{
 			Type:     AgentEventTypeSummarize,
 			Progress: "Starting summarization...",
-		}
-
-		a.Publish(pubsub.CreatedEvent, event)
-		// Get all messages from the session
-		msgs, err := a.messages.List(summarizeCtx, sessionID)
-		if err != nil {
-			event = AgentEvent{
-				Type:  AgentEventTypeError,
-				Error: fmt.Errorf("failed to list messages: %w", err),
-				Done:  true,
-			}
-			a.Publish(pubsub.CreatedEvent, event)
-			return
-		}
+		})
 		summarizeCtx = context.WithValue(summarizeCtx, tools.SessionIDContextKey, sessionID)
-
-		if len(msgs) == 0 {
-			event = AgentEvent{
-				Type:  AgentEventTypeError,
-				Error: fmt.Errorf("no messages to summarize"),
-				Done:  true,
-			}
-			a.Publish(pubsub.CreatedEvent, event)
-			return
-		}
-
-		event = AgentEvent{
-			Type:     AgentEventTypeSummarize,
-			Progress: "Analyzing conversation...",
-		}
-		a.Publish(pubsub.CreatedEvent, event)
-
-		// Add a system message to guide the summarization
-		summarizePrompt := "Provide a detailed but concise summary of our conversation above. Focus on information that would be helpful for continuing the conversation, including what we did, what we're doing, which files we're working on, and what we're going to do next."
-
-		// Create a new message with the summarize prompt
-		promptMsg := message.Message{
-			Role:  message.User,
-			Parts: []message.ContentPart{message.TextContent{Text: summarizePrompt}},
-		}
-
-		// Append the prompt to the messages
-		msgsWithPrompt := append(msgs, promptMsg)
-
-		event = AgentEvent{
-			Type:     AgentEventTypeSummarize,
-			Progress: "Generating summary...",
-		}
-
-		a.Publish(pubsub.CreatedEvent, event)
-
-		// Send the messages to the summarize provider
-		response := a.summarizeProvider.StreamResponse(
-			summarizeCtx,
-			msgsWithPrompt,
-			nil,
-		)
-		var finalResponse *provider.ProviderResponse
-		for r := range response {
-			if r.Error != nil {
-				event = AgentEvent{
-					Type:  AgentEventTypeError,
-					Error: fmt.Errorf("failed to summarize: %w", r.Error),
-					Done:  true,
-				}
-				a.Publish(pubsub.CreatedEvent, event)
-				return
-			}
-			finalResponse = r.Response
-		}
-
-		summary := strings.TrimSpace(finalResponse.Content)
-		if summary == "" {
-			event = AgentEvent{
-				Type:  AgentEventTypeError,
-				Error: fmt.Errorf("empty summary returned"),
-				Done:  true,
-			}
-			a.Publish(pubsub.CreatedEvent, event)
-			return
-		}
-		shell := shell.GetPersistentShell(config.Get().WorkingDir())
-		summary += "\n\n**Current working directory of the persistent shell**\n\n" + shell.GetWorkingDir()
-		event = AgentEvent{
-			Type:     AgentEventTypeSummarize,
-			Progress: "Creating new session...",
-		}
-
-		a.Publish(pubsub.CreatedEvent, event)
-		oldSession, err := a.sessions.Get(summarizeCtx, sessionID)
-		if err != nil {
-			event = AgentEvent{
-				Type:  AgentEventTypeError,
-				Error: fmt.Errorf("failed to get session: %w", err),
-				Done:  true,
-			}
-
-			a.Publish(pubsub.CreatedEvent, event)
-			return
-		}
-		// Create a message in the new session with the summary
-		msg, err := a.messages.Create(summarizeCtx, oldSession.ID, message.CreateMessageParams{
-			Role: message.Assistant,
-			Parts: []message.ContentPart{
-				message.TextContent{Text: summary},
-				message.Finish{
-					Reason: message.FinishReasonEndTurn,
-					Time:   time.Now().Unix(),
-				},
-			},
-			Model:    a.summarizeProvider.Model().ID,
-			Provider: a.summarizeProviderID,
+		err := a.compact(summarizeCtx, sessionID, func(progress string) {
+			a.Publish(pubsub.CreatedEvent, AgentEvent{
+				Type:     AgentEventTypeSummarize,
+				Progress: progress,
+			})
 		})
 		if err != nil {
-			event = AgentEvent{
+			a.Publish(pubsub.CreatedEvent, AgentEvent{
 				Type:  AgentEventTypeError,
-				Error: fmt.Errorf("failed to create summary message: %w", err),
+				Error: err,
 				Done:  true,
-			}
-
-			a.Publish(pubsub.CreatedEvent, event)
+			})
 			return
 		}
-		oldSession.SummaryMessageID = msg.ID
-		oldSession.CompletionTokens = finalResponse.Usage.OutputTokens
-		oldSession.PromptTokens = 0
-		model := a.summarizeProvider.Model()
-		usage := finalResponse.Usage
-		cost := model.CostPer1MInCached/1e6*float64(usage.CacheCreationTokens) +
-			model.CostPer1MOutCached/1e6*float64(usage.CacheReadTokens) +
-			model.CostPer1MIn/1e6*float64(usage.InputTokens) +
-			model.CostPer1MOut/1e6*float64(usage.OutputTokens)
-		oldSession.Cost += cost
-		_, err = a.sessions.Save(summarizeCtx, oldSession)
-		if err != nil {
-			event = AgentEvent{
-				Type:  AgentEventTypeError,
-				Error: fmt.Errorf("failed to save session: %w", err),
-				Done:  true,
-			}
-			a.Publish(pubsub.CreatedEvent, event)
-		}
-
-		event = AgentEvent{
+		a.Publish(pubsub.CreatedEvent, AgentEvent{
 			Type:      AgentEventTypeSummarize,
-			SessionID: oldSession.ID,
+			SessionID: sessionID,
 			Progress:  "Summary complete",
 			Done:      true,
-		}
-		a.Publish(pubsub.CreatedEvent, event)
-		// Send final success event with the new session ID
+		})
 	}()
 
 	return nil
 }
 
-func (a *agent) ClearQueue(sessionID string) {
-	if a.QueuedPrompts(sessionID) > 0 {
-		slog.Info("Clearing queued prompts", "session_id", sessionID)
-		a.promptQueue.Del(sessionID)
+func (a *agent) ClearQueue(sessionID string) []QueuedPrompt {
+	prompts := a.promptQueue.clear(sessionID)
+	if len(prompts) > 0 {
+		slog.Info("Cleared queued prompts", "session_id", sessionID)
 	}
+	return prompts
 }
 
 func (a *agent) CancelAll() {
@@ -969,14 +989,11 @@ func (a *agent) UpdateModel() error {
 			return fmt.Errorf("model not found for agent %s", a.agentCfg.Name)
 		}
 
-		promptID := agentPromptMap[a.agentCfg.ID]
-		if promptID == "" {
-			promptID = prompt.PromptDefault
-		}
-
 		opts := []provider.ProviderClientOption{
 			provider.WithModel(a.agentCfg.Model),
-			provider.WithSystemMessage(prompt.GetPrompt(promptID, currentProviderCfg.ID, cfg.Options.ContextPaths...)),
+			provider.WithSystemPrompt(func(providerID string) string {
+				return systemPrompt(a.agentCfg, providerID)
+			}),
 		}
 
 		newProvider, err := provider.NewProvider(*currentProviderCfg, opts...)
@@ -1023,7 +1040,9 @@ func (a *agent) UpdateModel() error {
 	// Recreate title provider
 	titleOpts := []provider.ProviderClientOption{
 		provider.WithModel(config.SelectedModelTypeSmall),
-		provider.WithSystemMessage(prompt.GetPrompt(prompt.PromptTitle, smallModelProviderCfg.ID)),
+		provider.WithSystemPrompt(func(providerID string) string {
+			return prompt.GetPrompt(prompt.PromptTitle, providerID)
+		}),
 		provider.WithMaxTokens(maxTitleTokens),
 	}
 	newTitleProvider, err := provider.NewProvider(smallModelProviderCfg, titleOpts...)
@@ -1040,7 +1059,9 @@ func (a *agent) UpdateModel() error {
 		}
 		summarizeOpts := []provider.ProviderClientOption{
 			provider.WithModel(config.SelectedModelTypeLarge),
-			provider.WithSystemMessage(prompt.GetPrompt(prompt.PromptSummarizer, largeModelProviderCfg.ID)),
+			provider.WithSystemPrompt(func(providerID string) string {
+				return prompt.GetPrompt(prompt.PromptSummarizer, providerID)
+			}),
 		}
 		newSummarizeProvider, err := provider.NewProvider(largeModelProviderCfg, summarizeOpts...)
 		if err != nil {
@@ -0,0 +1,182 @@
+package agent
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nom-nom-hub/floss/internal/csync"
+	"github.com/nom-nom-hub/floss/internal/db"
+	"github.com/nom-nom-hub/floss/internal/llm/tools"
+	"github.com/nom-nom-hub/floss/internal/message"
+	"github.com/nom-nom-hub/floss/internal/permission"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeTool runs run, or answers with its name, and records how many of the
+// calls it is part of run at once
+type fakeTool struct {
+	name     string
+	readOnly bool
+	run      func(ctx context.Context) (tools.ToolResponse, error)
+	counter  *callCounter
+}
+
+func (t *fakeTool) Info() tools.ToolInfo { return tools.ToolInfo{Name: t.name} }
+func (t *fakeTool) Name() string         { return t.name }
+func (t *fakeTool) ReadOnly() bool       { return t.readOnly }
+
+func (t *fakeTool) Run(ctx context.Context, call tools.ToolCall) (tools.ToolResponse, error) {
+	if t.counter != nil {
+		t.counter.start()
+		defer t.counter.stop()
+	}
+	if t.run != nil {
+		return t.run(ctx)
+	}
+	return tools.NewTextResponse(t.name + " " + call.Input), nil
+}
+
+type callCounter struct {
+	mu        sync.Mutex
+	active    int
+	maxActive int
+}
+
+func (c *callCounter) start() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.active++
+	c.maxActive = max(c.maxActive, c.active)
+}
+
+func (c *callCounter) stop() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.active--
+}
+
+func newToolTestAgent(t *testing.T, agentTools ...tools.BaseTool) *agent {
+	t.Helper()
+	conn, err := db.Connect(t.Context(), t.TempDir())
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return &agent{
+		messages: message.NewService(db.New(conn)),
+		tools:    csync.NewLazySlice(func() []tools.BaseTool { return agentTools }),
+	}
+}
+
+func toolCalls(names ...string) []message.ToolCall {
+	calls := make([]message.ToolCall, len(names))
+	for i, name := range names {
+		calls[i] = message.ToolCall{ID: string(rune('a' + i)), Name: name, Input: string(rune('a' + i))}
+	}
+	return calls
+}
+
+func resultContents(results []message.ToolResult) []string {
+	contents := make([]string, len(results))
+	for i, result := range results {
+		contents[i] = result.Content
+	}
+	return contents
+}
+
+func TestExecuteToolCallsRunsReadOnlyCallsConcurrently(t *testing.T) {
+	t.Parallel()
+
+	// Each view call waits until the other one runs too
+	counter := &callCounter{}
+	both := make(chan struct{})
+	var once sync.Once
+	var started sync.WaitGroup
+	started.Add(2)
+	view := &fakeTool{name: "view", readOnly: true, counter: counter, run: func(ctx context.Context) (tools.ToolResponse, error) {
+		started.Done()
+		once.Do(func() {
+			go func() {
+				started.Wait()
+				close(both)
+			}()
+		})
+		select {
+		case <-both:
+			return tools.NewTextResponse("viewed"), nil
+		case <-time.After(5 * time.Second):
+			return tools.NewTextErrorResponse("ran alone"), nil
+		}
+	}}
+	grep := &fakeTool{name: "grep", readOnly: true, counter: counter}
+	edit := &fakeTool{name: "edit", counter: counter}
+	a := newToolTestAgent(t, view, grep, edit)
+
+	results := a.executeToolCalls(t.Context(), &message.Message{}, toolCalls("view", "view", "edit", "grep", "missing", "grep"))
+	require.Equal(t, []string{"viewed", "viewed", "edit c", "grep d", "Tool not found: missing", "grep f"}, resultContents(results))
+	for i, result := range results {
+		require.Equal(t, string(rune('a'+i)), result.ToolCallID)
+	}
+	require.Equal(t, 2, counter.maxActive)
+}
+
+func TestExecuteToolCallsSerializesMutatingCalls(t *testing.T) {
+	t.Parallel()
+
+	counter := &callCounter{}
+	edit := &fakeTool{name: "edit", counter: counter, run: func(context.Context) (tools.ToolResponse, error) {
+		time.Sleep(10 * time.Millisecond)
+		return tools.NewTextResponse("edited"), nil
+	}}
+	a := newToolTestAgent(t, edit)
+
+	results := a.executeToolCalls(t.Context(), &message.Message{}, toolCalls("edit", "edit", "edit"))
+	require.Equal(t, []string{"edited", "edited", "edited"}, resultContents(results))
+	require.Equal(t, 1, counter.maxActive)
+}
+
+func TestExecuteToolCallsStopsAtPermissionDenied(t *testing.T) {
+	t.Parallel()
+
+	view := &fakeTool{name: "view", readOnly: true}
+	denied := &fakeTool{name: "ls", readOnly: true, run: func(context.Context) (tools.ToolResponse, error) {
+		return tools.ToolResponse{}, permission.ErrorPermissionDenied
+	}}
+	// A later call in the same batch waits for its own prompt, which is
+	// never answered once an earlier call was denied
+	blocked := &fakeTool{name: "glob", readOnly: true, run: func(ctx context.Context) (tools.ToolResponse, error) {
+		<-ctx.Done()
+		return tools.ToolResponse{}, ctx.Err()
+	}}
+	edit := &fakeTool{name: "edit"}
+	a := newToolTestAgent(t, view, denied, blocked, edit)
+
+	msg := &message.Message{}
+	results := a.executeToolCalls(t.Context(), msg, toolCalls("view", "ls", "glob", "edit"))
+	require.Equal(t, []string{"view a", "Permission denied", "Tool execution canceled by user", "Tool execution canceled by user"}, resultContents(results))
+	require.Equal(t, message.FinishReasonPermissionDenied, msg.FinishReason())
+}
+
+func TestExecuteToolCallsCancelled(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(t.Context())
+	view := &fakeTool{name: "view", readOnly: true}
+	slow := &fakeTool{name: "grep", readOnly: true, run: func(context.Context) (tools.ToolResponse, error) {
+		cancel()
+		time.Sleep(time.Second)
+		return tools.NewTextResponse("too late"), nil
+	}}
+	edit := &fakeTool{name: "edit"}
+	a := newToolTestAgent(t, view, slow, edit)
+
+	msg := &message.Message{}
+	results := a.executeToolCalls(ctx, msg, toolCalls("edit", "view", "grep", "edit"))
+	require.Equal(t, "edit a", results[0].Content)
+	require.Equal(t, "b", results[1].ToolCallID)
+	for _, result := range results[2:] {
+		require.Equal(t, "Tool execution canceled by user", result.Content)
+		require.True(t, result.IsError)
+	}
+	require.Equal(t, message.FinishReasonCanceled, msg.FinishReason())
+}
@@ -0,0 +1,76 @@
+package agent
+
+import (
+	"slices"
+
+	"github.com/nom-nom-hub/floss/internal/config"
+	"github.com/nom-nom-hub/floss/internal/csync"
+	"github.com/nom-nom-hub/floss/internal/llm/prompt"
+	"github.com/nom-nom-hub/floss/internal/llm/tools"
+	"github.com/nom-nom-hub/floss/internal/lsp"
+)
+
+// FilterTools returns the tools an agent may use. Built-in tools must be
+// listed in the agent's AllowedTools and MCP tools must be allowed by its
+// AllowedMCP. In both cases nil allows every tool and an empty value allows
+// none. Tools that are filtered out can't be called: the agent answers calls
+// to them with "Tool not found".
+func FilterTools(agentCfg config.Agent, available []tools.BaseTool) []tools.BaseTool {
+	var allowed []tools.BaseTool
+	for _, tool := range available {
+		if mcpTool, ok := tool.(*McpTool); ok {
+			if mcpToolAllowed(agentCfg.AllowedMCP, mcpTool) {
+				allowed = append(allowed, tool)
+			}
+			continue
+		}
+		if agentCfg.AllowedTools == nil || slices.Contains(agentCfg.AllowedTools, tool.Name()) {
+			allowed = append(allowed, tool)
+		}
+	}
+	return allowed
+}
+
+func mcpToolAllowed(allowedMCP map[string][]string, tool *McpTool) bool {
+	if allowedMCP == nil {
+		return true
+	}
+	toolNames, ok := allowedMCP[tool.mcpName]
+	if !ok {
+		return false
+	}
+	return toolNames == nil || slices.Contains(toolNames, tool.tool.Name)
+}
+
+// allowedLSPClients returns the LSP clients an agent may use
+func allowedLSPClients(allowedLSP []string, lspClients *csync.Map[string, *lsp.Client]) *csync.Map[string, *lsp.Client] {
+	if allowedLSP == nil {
+		return lspClients
+	}
+	clients := csync.NewMap[string, *lsp.Client]()
+	for name, client := range lspClients.Seq2() {
+		if slices.Contains(allowedLSP, name) {
+			clients.Set(name, client)
+		}
+	}
+	return clients
+}
+
+// systemPrompt returns the system prompt of an agent. A prompt configured for
+// the agent replaces the built-in one, and context paths configured for the
+// agent replace the global ones.
+func systemPrompt(agentCfg config.Agent, providerID string) string {
+	contextPaths := config.Get().Options.ContextPaths
+	if agentCfg.ContextPaths != nil {
+		contextPaths = agentCfg.ContextPaths
+	}
+	if agentCfg.Prompt != "" {
+		return prompt.CustomPrompt(agentCfg.Prompt, contextPaths...)
+	}
+
+	promptID := agentPromptMap[agentCfg.ID]
+	if promptID == "" {
+		promptID = prompt.PromptDefault
+	}
+	return prompt.GetPrompt(promptID, providerID, contextPaths...)
+}
@@ -0,0 +1,101 @@
+package agent
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/nom-nom-hub/floss/internal/config"
+	"github.com/nom-nom-hub/floss/internal/csync"
+	"github.com/nom-nom-hub/floss/internal/llm/tools"
+	"github.com/nom-nom-hub/floss/internal/lsp"
+	"github.com/stretchr/testify/require"
+)
+
+type namedTool string
+
+func (t namedTool) Info() tools.ToolInfo { return tools.ToolInfo{Name: string(t)} }
+func (t namedTool) Name() string         { return string(t) }
+func (t namedTool) Run(context.Context, tools.ToolCall) (tools.ToolResponse, error) {
+	return tools.NewTextResponse(string(t)), nil
+}
+
+func toolNames(t []tools.BaseTool) []string {
+	names := make([]string, 0, len(t))
+	for _, tool := range t {
+		names = append(names, tool.Name())
+	}
+	return names
+}
+
+func TestFilterTools(t *testing.T) {
+	t.Parallel()
+
+	available := []tools.BaseTool{
+		namedTool("bash"),
+		namedTool("view"),
+		namedTool("write"),
+		&McpTool{mcpName: "github", tool: mcp.Tool{Name: "create_issue"}},
+		&McpTool{mcpName: "github", tool: mcp.Tool{Name: "list_issues"}},
+		&McpTool{mcpName: "docs", tool: mcp.Tool{Name: "search"}},
+	}
+
+	tests := []struct {
+		name     string
+		agentCfg config.Agent
+		expected []string
+	}{
+		{
+			name:     "nil allows everything",
+			agentCfg: config.Agent{},
+			expected: []string{"bash", "view", "write", "mcp_github_create_issue", "mcp_github_list_issues", "mcp_docs_search"},
+		},
+		{
+			name: "empty allows nothing",
+			agentCfg: config.Agent{
+				AllowedTools: []string{},
+				AllowedMCP:   map[string][]string{},
+			},
+			expected: []string{},
+		},
+		{
+			name: "listed tools only",
+			agentCfg: config.Agent{
+				AllowedTools: []string{"view"},
+				AllowedMCP:   map[string][]string{"github": {"list_issues"}},
+			},
+			expected: []string{"view", "mcp_github_list_issues"},
+		},
+		{
+			name: "all tools of an MCP server",
+			agentCfg: config.Agent{
+				AllowedTools: []string{"bash"},
+				AllowedMCP:   map[string][]string{"docs": nil},
+			},
+			expected: []string{"bash", "mcp_docs_search"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			require.Equal(t, tt.expected, toolNames(FilterTools(tt.agentCfg, available)))
+		})
+	}
+}
+
+func TestAllowedLSPClients(t *testing.T) {
+	t.Parallel()
+
+	lspClients := csync.NewMap[string, *lsp.Client]()
+	lspClients.Set("gopls", &lsp.Client{})
+	lspClients.Set("tsserver", &lsp.Client{})
+
+	require.Same(t, lspClients, allowedLSPClients(nil, lspClients))
+	require.Zero(t, allowedLSPClients([]string{}, lspClients).Len())
+
+	clients := allowedLSPClients([]string{"gopls", "pyright"}, lspClients)
+	require.Equal(t, 1, clients.Len())
+	_, ok := clients.Get("gopls")
+	require.True(t, ok)
+}
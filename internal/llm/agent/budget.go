@@ -0,0 +1,106 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/nom-nom-hub/floss/internal/config"
+	"github.com/nom-nom-hub/floss/internal/permission"
+	"github.com/nom-nom-hub/floss/internal/pubsub"
+	"github.com/nom-nom-hub/floss/internal/session"
+)
+
+// ErrBudgetExceeded is the error of a run stopped at a budget limit
+var ErrBudgetExceeded = errors.New("budget exceeded")
+
+// BudgetToolName is the tool name of the confirmations to continue past a
+// budget limit
+const BudgetToolName = "budget"
+
+type nonInteractiveContextKey struct{}
+
+// WithNonInteractive marks the runs started with ctx as having nobody to
+// ask, so that they stop at a budget limit instead of asking to continue
+func WithNonInteractive(ctx context.Context) context.Context {
+	return context.WithValue(ctx, nonInteractiveContextKey{}, true)
+}
+
+func isNonInteractive(ctx context.Context) bool {
+	nonInteractive, _ := ctx.Value(nonInteractiveContextKey{}).(bool)
+	return nonInteractive
+}
+
+// checkBudgets is called before each request of a session. Once usage
+// reaches the warning threshold of a budget it warns, once per session, and
+// past a limit it asks whether to continue, or stops non-interactive runs.
+func (a *agent) checkBudgets(ctx context.Context, sessionID string) error {
+	budgets := config.Get().Options.Budgets
+	if budgets == nil {
+		return nil
+	}
+	sess, err := a.sessions.Get(ctx, sessionID)
+	if err != nil {
+		return fmt.Errorf("failed to get session: %w", err)
+	}
+	var daily session.Usage
+	if budgets.Daily != (config.UsageLimit{}) {
+		now := time.Now()
+		midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+		if daily, err = a.sessions.UsageSince(ctx, midnight); err != nil {
+			return fmt.Errorf("failed to get daily usage: %w", err)
+		}
+	}
+
+	checks := []struct {
+		name  string
+		limit config.UsageLimit
+		usage session.Usage
+	}{
+		{"session", budgets.Session, session.Usage{Cost: sess.Cost, Tokens: sess.TotalTokens}},
+		{"daily", budgets.Daily, daily},
+	}
+	for _, check := range checks {
+		if reached := limitReached(check.limit, check.usage, 1); reached != "" {
+			if isNonInteractive(ctx) {
+				return fmt.Errorf("%w: the %s budget is used up: %s", ErrBudgetExceeded, check.name, reached)
+			}
+			granted := a.permissions.Confirm(permission.CreatePermissionRequest{
+				SessionID:   sessionID,
+				ToolName:    BudgetToolName,
+				Action:      check.name,
+				Description: fmt.Sprintf("The %s budget is used up: %s. Continue anyway?", check.name, reached),
+				Path:        config.Get().WorkingDir(),
+			})
+			if !granted {
+				return fmt.Errorf("%w: the %s budget is used up: %s", ErrBudgetExceeded, check.name, reached)
+			}
+			continue
+		}
+		if reached := limitReached(check.limit, check.usage, budgets.WarnFraction()); reached != "" {
+			key := sessionID + ":" + check.name
+			if warned, _ := a.budgetWarnings.Get(key); !warned {
+				a.budgetWarnings.Set(key, true)
+				a.Publish(pubsub.CreatedEvent, AgentEvent{
+					Type:      AgentEventTypeWarning,
+					SessionID: sessionID,
+					Progress:  fmt.Sprintf("Nearing the %s budget: %s", check.name, reached),
+				})
+			}
+		}
+	}
+	return nil
+}
+
+// limitReached describes the first limit usage has reached the fraction of,
+// or returns "" while usage is below it
+func limitReached(limit config.UsageLimit, usage session.Usage, fraction float64) string {
+	switch {
+	case limit.MaxCost > 0 && usage.Cost >= fraction*limit.MaxCost:
+		return fmt.Sprintf("$%.2f of $%.2f spent", usage.Cost, limit.MaxCost)
+	case limit.MaxTokens > 0 && float64(usage.Tokens) >= fraction*float64(limit.MaxTokens):
+		return fmt.Sprintf("%d of %d tokens used", usage.Tokens, limit.MaxTokens)
+	}
+	return ""
+}
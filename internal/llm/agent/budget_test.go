@@ -0,0 +1,96 @@
+package agent
+
+import (
+	"context"
+	"testing"
+
+	"github.com/nom-nom-hub/floss/internal/config"
+	"github.com/nom-nom-hub/floss/internal/llm/provider"
+	"github.com/nom-nom-hub/floss/internal/permission"
+	"github.com/nom-nom-hub/floss/internal/pubsub"
+	"github.com/stretchr/testify/require"
+)
+
+// confirmPermissions records the confirmations to continue past the limits
+// of toolName and grants the first grants of them, leaving the others to the
+// permission service
+type confirmPermissions struct {
+	permission.Service
+	toolName string
+	grants   int
+	requests []permission.CreatePermissionRequest
+}
+
+func (p *confirmPermissions) Confirm(opts permission.CreatePermissionRequest) bool {
+	if opts.ToolName != p.toolName {
+		return p.Service.Confirm(opts)
+	}
+	p.requests = append(p.requests, opts)
+	return len(p.requests) <= p.grants
+}
+
+// Each turn of the replay harness uses 110 tokens
+func lsTurns(t *testing.T, n int) *replayHarness {
+	t.Helper()
+	var turns []provider.FixtureTurn
+	for range n {
+		turns = append(turns, toolTurn(t, toolUse{"ls", map[string]any{}}))
+	}
+	return newReplayHarness(t, append(turns, textTurn("Done"))...)
+}
+
+func TestBudgetStopsNonInteractiveRun(t *testing.T) {
+	h := lsTurns(t, 3)
+	config.Get().Options.Budgets = &config.Budgets{Session: config.UsageLimit{MaxTokens: 200}}
+
+	event := h.runContext(WithNonInteractive(t.Context()), t, "List the files")
+	require.ErrorIs(t, event.Error, ErrBudgetExceeded)
+	require.EqualError(t, event.Error, "budget exceeded: the session budget is used up: 220 of 200 tokens used")
+	require.Len(t, h.toolResults(t), 2)
+}
+
+func TestBudgetAsksToContinue(t *testing.T) {
+	h := lsTurns(t, 3)
+	config.Get().Options.Budgets = &config.Budgets{Daily: config.UsageLimit{MaxTokens: 250}}
+	a := h.agent.(*agent)
+	permissions := &confirmPermissions{Service: a.permissions, toolName: BudgetToolName}
+	a.permissions = permissions
+
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+	events := a.Subscribe(ctx)
+
+	event := h.run(t, "List the files")
+	require.ErrorIs(t, event.Error, ErrBudgetExceeded)
+	require.Len(t, permissions.requests, 1)
+	require.Equal(t, "daily", permissions.requests[0].Action)
+	require.Equal(t, "The daily budget is used up: 330 of 250 tokens used. Continue anyway?", permissions.requests[0].Description)
+	require.Len(t, h.toolResults(t), 3)
+
+	// The warning came once, when the second turn went past 200 tokens
+	var warnings []string
+	for len(events) > 0 {
+		e := <-events
+		if e.Type == pubsub.CreatedEvent && e.Payload.Type == AgentEventTypeWarning {
+			warnings = append(warnings, e.Payload.Progress)
+		}
+	}
+	require.Equal(t, []string{"Nearing the daily budget: 220 of 250 tokens used"}, warnings)
+}
+
+func TestBudgetAsksWhenRequestsAreSkipped(t *testing.T) {
+	h := lsTurns(t, 3)
+	config.Get().Options.Budgets = &config.Budgets{Session: config.UsageLimit{MaxTokens: 200}}
+	a := h.agent.(*agent)
+	require.True(t, a.permissions.SkipRequests())
+
+	requests := a.permissions.Subscribe(t.Context())
+	go func() {
+		request := <-requests
+		a.permissions.Deny(request.Payload)
+	}()
+
+	event := h.run(t, "List the files")
+	require.ErrorIs(t, event.Error, ErrBudgetExceeded)
+	require.Len(t, h.toolResults(t), 2)
+}
@@ -0,0 +1,35 @@
+package agent
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckpointBeforePrompt(t *testing.T) {
+	h := newReplayHarness(t, textTurn("First done"), textTurn("Second done"))
+	h.run(t, "First task")
+	h.run(t, "Second task\nwith details")
+
+	checkpoints, err := h.checkpoints.List(t.Context(), h.session.ID)
+	require.NoError(t, err)
+	require.Len(t, checkpoints, 2)
+	require.Equal(t, "Before: Second task", checkpoints[0].Name)
+	require.Equal(t, "Before: First task", checkpoints[1].Name)
+	require.Empty(t, checkpoints[1].MessageID)
+
+	require.NoError(t, h.checkpoints.Restore(t.Context(), checkpoints[0].ID, false))
+	msgs, err := h.messages.List(t.Context(), h.session.ID)
+	require.NoError(t, err)
+	require.Len(t, msgs, 2)
+	require.Equal(t, checkpoints[0].MessageID, msgs[1].ID)
+	require.Equal(t, "First done", msgs[1].Content().Text)
+}
+
+func TestCheckpointName(t *testing.T) {
+	t.Parallel()
+
+	require.Equal(t, "Before: Fix the build", checkpointName("  Fix the build\n\nIt fails on CI"))
+	long := "Rename every handler in the server package to follow the new scheme"
+	require.Equal(t, "Before: Rename every handler in the server package to fol…", checkpointName(long))
+}
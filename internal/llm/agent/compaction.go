@@ -0,0 +1,239 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/nom-nom-hub/floss/internal/config"
+	"github.com/nom-nom-hub/floss/internal/llm/provider"
+	"github.com/nom-nom-hub/floss/internal/message"
+	"github.com/nom-nom-hub/floss/internal/session"
+	"github.com/nom-nom-hub/floss/internal/shell"
+)
+
+// imageTokens is about what an image costs, whatever its size
+const imageTokens = 1000
+
+// estimateTokens guesses the tokens of the messages at four characters a
+// token, as no tokenizer covers every provider. It leaves out the system
+// prompt and the tool definitions.
+func estimateTokens(msgs []message.Message) int64 {
+	var chars, tokens int64
+	for _, msg := range msgs {
+		for _, part := range msg.Parts {
+			switch part := part.(type) {
+			case message.TextContent:
+				chars += int64(len(part.Text))
+			case message.ReasoningContent:
+				chars += int64(len(part.Thinking))
+			case message.ToolCall:
+				chars += int64(len(part.Name) + len(part.Input))
+			case message.ToolResult:
+				chars += int64(len(part.Content))
+			case message.ImageURLContent:
+				tokens += imageTokens
+			case message.BinaryContent:
+				if strings.HasPrefix(part.MIMEType, "image/") {
+					tokens += imageTokens
+				} else {
+					chars += int64(len(part.Data))
+				}
+			}
+		}
+	}
+	return tokens + chars/4
+}
+
+// shouldCompact reports whether the history fills enough of the context
+// window of the model to compact it before sending it
+func (a *agent) shouldCompact(msgHistory []message.Message) bool {
+	cfg := config.Get()
+	contextWindow := a.Model().ContextWindow
+	if cfg.Options.DisableAutoSummarize || a.summarizeProvider == nil || contextWindow <= 0 {
+		return false
+	}
+	return float64(estimateTokens(msgHistory)) >= cfg.Options.Compaction.ThresholdFraction()*float64(contextWindow)
+}
+
+// sessionHistory returns the messages of the session to send to the model.
+// After a compaction they are the earlier messages it preserved, its
+// summary as a user message, the turns it kept and the messages since. The
+// preserved messages and the summary are the first summarized ones, and
+// since is the index of the first message created after the summary.
+func sessionHistory(msgs []message.Message, sess session.Session) (history []message.Message, summarized, since int) {
+	summary := slices.IndexFunc(msgs, func(msg message.Message) bool {
+		return sess.SummaryMessageID != "" && msg.ID == sess.SummaryMessageID
+	})
+	if summary == -1 {
+		return msgs, 0, 0
+	}
+	kept := summary
+	if sess.FirstKeptMessageID != "" {
+		if i := slices.IndexFunc(msgs[:summary], func(msg message.Message) bool {
+			return msg.ID == sess.FirstKeptMessageID
+		}); i != -1 {
+			kept = i
+		}
+	}
+	for _, msg := range msgs[:kept] {
+		if preserved, ok := preservedMessage(msg); ok {
+			history = append(history, preserved)
+		}
+	}
+	summaryMsg := msgs[summary]
+	summaryMsg.Role = message.User
+	history = append(history, summaryMsg)
+	summarized = len(history)
+	history = append(history, msgs[kept:summary]...)
+	since = len(history)
+	return append(history, msgs[summary+1:]...), summarized, since
+}
+
+// preservedMessage returns what compaction keeps of msg: pinned messages
+// and user messages with attachments are kept with their text and
+// attachments. Tool calls and results become text, since what they answer
+// or what answers them may be summarized away.
+func preservedMessage(msg message.Message) (message.Message, bool) {
+	attached := msg.Role == message.User && len(msg.BinaryContent()) > 0
+	if !msg.Pinned && !attached {
+		return message.Message{}, false
+	}
+	preserved := msg
+	preserved.Parts = nil
+	for _, part := range msg.Parts {
+		switch part := part.(type) {
+		case message.TextContent, message.BinaryContent, message.ImageURLContent:
+			preserved.Parts = append(preserved.Parts, part)
+		case message.ToolCall:
+			preserved.Parts = append(preserved.Parts, message.TextContent{Text: fmt.Sprintf("Called the %s tool with %s", part.Name, part.Input)})
+		case message.ToolResult:
+			preserved.Parts = append(preserved.Parts, message.TextContent{Text: part.Content})
+		}
+	}
+	if msg.Role == message.Tool {
+		preserved.Role = message.User
+	}
+	return preserved, len(preserved.Parts) > 0
+}
+
+// compactionCut returns the index of the message to keep the history from:
+// the start of the latest turns since the last summary, as many as asked
+// for while leaving something to summarize, or len(history) to summarize
+// the whole history
+func compactionCut(history []message.Message, summarized, since, keepTurns int) int {
+	var turns []int
+	for i := max(since, summarized+1); i < len(history); i++ {
+		if history[i].Role == message.User {
+			turns = append(turns, i)
+		}
+	}
+	if len(turns) == 0 || keepTurns == 0 {
+		return len(history)
+	}
+	return turns[max(len(turns)-keepTurns, 0)]
+}
+
+// compact summarizes the history of the session but for its latest turns,
+// which come verbatim after the summary, and the messages that
+// preservedMessage keeps before it. Progress reports each step.
+func (a *agent) compact(ctx context.Context, sessionID string, progress func(string)) error {
+	msgs, err := a.messages.List(ctx, sessionID)
+	if err != nil {
+		return fmt.Errorf("failed to list messages: %w", err)
+	}
+	if len(msgs) == 0 {
+		return errors.New("no messages to summarize")
+	}
+	sess, err := a.sessions.Get(ctx, sessionID)
+	if err != nil {
+		return fmt.Errorf("failed to get session: %w", err)
+	}
+
+	progress("Analyzing conversation...")
+	history, summarized, since := sessionHistory(msgs, sess)
+	cut := compactionCut(history, summarized, since, config.Get().Options.Compaction.TurnsToKeep())
+
+	// Add a system message to guide the summarization
+	summarizePrompt := "Provide a detailed but concise summary of our conversation above. Focus on information that would be helpful for continuing the conversation, including what we did, what we're doing, which files we're working on, and what we're going to do next."
+	promptMsg := message.Message{
+		Role:  message.User,
+		Parts: []message.ContentPart{message.TextContent{Text: summarizePrompt}},
+	}
+
+	progress("Generating summary...")
+	var finalResponse *provider.ProviderResponse
+	for r := range a.summarizeProvider.StreamResponse(ctx, append(slices.Clip(history[:cut]), promptMsg), nil) {
+		if r.Error != nil {
+			return fmt.Errorf("failed to summarize: %w", r.Error)
+		}
+		finalResponse = r.Response
+	}
+	if finalResponse == nil || strings.TrimSpace(finalResponse.Content) == "" {
+		return errors.New("empty summary returned")
+	}
+	summary := strings.TrimSpace(finalResponse.Content)
+	shell := shell.GetPersistentShell(config.Get().WorkingDir())
+	summary += "\n\n**Current working directory of the persistent shell**\n\n" + shell.GetWorkingDir()
+
+	progress("Saving summary...")
+	// A fallback model may have written the summary
+	model, providerID := a.summarizeProvider.Model(), a.summarizeProviderID
+	if finalResponse.Model != nil {
+		model, providerID = *finalResponse.Model, finalResponse.ProviderID
+	}
+	msg, err := a.messages.Create(ctx, sessionID, message.CreateMessageParams{
+		Role: message.Assistant,
+		Parts: []message.ContentPart{
+			message.TextContent{Text: summary},
+			message.Finish{
+				Reason: message.FinishReasonEndTurn,
+				Time:   time.Now().Unix(),
+			},
+		},
+		Model:    model.ID,
+		Provider: providerID,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create summary message: %w", err)
+	}
+	sess.SummaryMessageID = msg.ID
+	sess.FirstKeptMessageID = ""
+	if cut < len(history) {
+		sess.FirstKeptMessageID = history[cut].ID
+	}
+	sess.CompletionTokens = finalResponse.Usage.OutputTokens
+	sess.PromptTokens = 0
+	usage := finalResponse.Usage
+	cost := model.CostPer1MInCached/1e6*float64(usage.CacheCreationTokens) +
+		model.CostPer1MOutCached/1e6*float64(usage.CacheReadTokens) +
+		model.CostPer1MIn/1e6*float64(usage.InputTokens) +
+		model.CostPer1MOut/1e6*float64(usage.OutputTokens)
+	tokens := usage.InputTokens + usage.OutputTokens + usage.CacheCreationTokens + usage.CacheReadTokens
+	sess.Cost += cost
+	sess.TotalTokens += tokens
+	if _, err := a.sessions.Save(ctx, sess); err != nil {
+		return fmt.Errorf("failed to save session: %w", err)
+	}
+	if err := a.sessions.RecordUsage(ctx, sessionID, session.Usage{Cost: cost, Tokens: tokens}); err != nil {
+		return fmt.Errorf("failed to record usage: %w", err)
+	}
+	return nil
+}
+
+// history returns the messages of the session to send to the model
+func (a *agent) history(ctx context.Context, sessionID string) ([]message.Message, error) {
+	msgs, err := a.messages.List(ctx, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list messages: %w", err)
+	}
+	sess, err := a.sessions.Get(ctx, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get session: %w", err)
+	}
+	history, _, _ := sessionHistory(msgs, sess)
+	return history, nil
+}
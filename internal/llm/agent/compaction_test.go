@@ -0,0 +1,145 @@
+package agent
+
+import (
+	"context"
+	"testing"
+
+	"github.com/charmbracelet/catwalk/pkg/catwalk"
+	"github.com/nom-nom-hub/floss/internal/config"
+	"github.com/nom-nom-hub/floss/internal/llm/provider"
+	"github.com/nom-nom-hub/floss/internal/llm/tools"
+	"github.com/nom-nom-hub/floss/internal/message"
+	"github.com/nom-nom-hub/floss/internal/session"
+	"github.com/stretchr/testify/require"
+)
+
+func textMessage(id string, role message.MessageRole, text string) message.Message {
+	return message.Message{ID: id, Role: role, Parts: []message.ContentPart{message.TextContent{Text: text}}}
+}
+
+func messageIDs(msgs []message.Message) []string {
+	var ids []string
+	for _, msg := range msgs {
+		ids = append(ids, msg.ID)
+	}
+	return ids
+}
+
+func TestSessionHistory(t *testing.T) {
+	t.Parallel()
+
+	pinned := textMessage("u1", message.User, "Always use tabs")
+	pinned.Pinned = true
+	attached := textMessage("u2", message.User, "Fix this file")
+	attached.Parts = append(attached.Parts, message.BinaryContent{Path: "main.go", MIMEType: "text/plain", Data: []byte("package main")})
+	call := message.Message{ID: "a2", Role: message.Assistant, Parts: []message.ContentPart{
+		message.ToolCall{ID: "call_1", Name: "view", Input: `{"file_path":"main.go"}`},
+	}}
+	result := message.Message{ID: "t2", Role: message.Tool, Parts: []message.ContentPart{
+		message.ToolResult{ToolCallID: "call_1", Content: "package main"},
+	}}
+	result.Pinned = true
+	msgs := []message.Message{
+		pinned,
+		textMessage("a1", message.Assistant, "OK"),
+		attached,
+		call,
+		result,
+		textMessage("u3", message.User, "Now test it"),
+		textMessage("a3", message.Assistant, "Tested"),
+		textMessage("s1", message.Assistant, "Summary"),
+		textMessage("u4", message.User, "Thanks"),
+	}
+
+	history, summarized, since := sessionHistory(msgs, session.Session{})
+	require.Equal(t, msgs, history)
+	require.Zero(t, summarized)
+	require.Zero(t, since)
+
+	history, summarized, since = sessionHistory(msgs, session.Session{SummaryMessageID: "s1", FirstKeptMessageID: "u3"})
+	require.Equal(t, []string{"u1", "u2", "t2", "s1", "u3", "a3", "u4"}, messageIDs(history))
+	require.Equal(t, 4, summarized)
+	require.Equal(t, 6, since)
+	require.Equal(t, message.User, history[3].Role)
+	require.Len(t, history[1].BinaryContent(), 1)
+	// The pinned tool result no longer answers a call
+	require.Equal(t, message.User, history[2].Role)
+	require.Equal(t, "package main", history[2].Content().Text)
+
+	// Summaries from before keeping turns summarized every earlier message
+	history, _, _ = sessionHistory(msgs, session.Session{SummaryMessageID: "s1"})
+	require.Equal(t, []string{"u1", "u2", "t2", "s1", "u4"}, messageIDs(history))
+}
+
+func TestCompactionCut(t *testing.T) {
+	t.Parallel()
+
+	history := []message.Message{
+		textMessage("s1", message.User, "Summary"),
+		textMessage("u1", message.User, "Kept by the summary"),
+		textMessage("a1", message.Assistant, "OK"),
+		textMessage("u2", message.User, "Second"),
+		textMessage("a2", message.Assistant, "OK"),
+		textMessage("u3", message.User, "Third"),
+	}
+	// The turns the last summary kept are summarized first
+	require.Equal(t, 3, compactionCut(history, 1, 3, 2))
+	require.Equal(t, 5, compactionCut(history, 1, 3, 1))
+	require.Equal(t, len(history), compactionCut(history, 1, 3, 0))
+	// Without a summary the first turn is summarized anyway, and a lone
+	// turn is summarized whole
+	require.Equal(t, 2, compactionCut(history[1:], 0, 0, 5))
+	require.Equal(t, 2, compactionCut(history[1:3], 0, 0, 2))
+}
+
+// recordingMessages records the messages of the requests to its provider
+type recordingMessages struct {
+	provider.Provider
+	requests [][]message.Message
+}
+
+func (p *recordingMessages) StreamResponse(ctx context.Context, messages []message.Message, tools []tools.BaseTool) <-chan provider.ProviderEvent {
+	p.requests = append(p.requests, messages)
+	return p.Provider.StreamResponse(ctx, messages, tools)
+}
+
+func TestAutoCompaction(t *testing.T) {
+	h := newReplayHarness(t, textTurn("First done"), textTurn("Second done"), textTurn("Third done"))
+	a := h.agent.(*agent)
+	recorded := &recordingMessages{Provider: a.provider}
+	a.provider = recorded
+	summarizer := &recordingMessages{Provider: provider.NewReplayProvider(&provider.Fixture{
+		Turns: []provider.FixtureTurn{textTurn("They asked for two tasks")},
+	}, catwalk.Model{})}
+	a.summarizeProvider = summarizer
+
+	require.NoError(t, h.run(t, "First task").Error)
+	msgs, err := h.messages.List(t.Context(), h.session.ID)
+	require.NoError(t, err)
+	require.NoError(t, h.messages.SetPinned(t.Context(), msgs[0].ID, true))
+	require.NoError(t, h.run(t, "Second task").Error)
+	require.Empty(t, summarizer.requests)
+
+	// Any history is past the threshold now
+	config.Get().Options.Compaction = &config.Compaction{KeepTurns: 1, Threshold: 0.0001}
+	event := h.run(t, "Third task")
+	require.NoError(t, event.Error)
+	require.Equal(t, "Third done", event.Message.Content().Text)
+
+	require.Len(t, summarizer.requests, 1)
+	summarized := summarizer.requests[0]
+	require.Len(t, summarized, 5)
+	require.Equal(t, "Second done", summarized[3].Content().Text)
+
+	sent := recorded.requests[len(recorded.requests)-1]
+	require.Len(t, sent, 3)
+	require.Equal(t, "First task", sent[0].Content().Text)
+	require.Contains(t, sent[1].Content().Text, "They asked for two tasks")
+	require.Equal(t, message.User, sent[1].Role)
+	require.Equal(t, "Third task", sent[2].Content().Text)
+
+	sess, err := a.sessions.Get(t.Context(), h.session.ID)
+	require.NoError(t, err)
+	require.Equal(t, sent[1].ID, sess.SummaryMessageID)
+	require.Equal(t, sent[2].ID, sess.FirstKeptMessageID)
+}
@@ -0,0 +1,95 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/nom-nom-hub/floss/internal/config"
+	"github.com/nom-nom-hub/floss/internal/message"
+	"github.com/nom-nom-hub/floss/internal/permission"
+)
+
+// ErrLoopDetected is the error of a run stopped by the loop guard
+var ErrLoopDetected = errors.New("loop detected")
+
+// LoopGuardToolName is the tool name of the confirmations to continue a
+// request the loop guard paused
+const LoopGuardToolName = "loop_guard"
+
+// loopGuard counts the tool-use iterations of a request and the times it
+// made each tool call
+type loopGuard struct {
+	iterations int
+	calls      map[string]int
+}
+
+func newLoopGuard() *loopGuard {
+	return &loopGuard{calls: make(map[string]int)}
+}
+
+// check records an iteration ending with the tool calls. When the request
+// goes past a limit it returns the name of the limit and why it paused.
+func (g *loopGuard) check(limits *config.LoopGuard, toolCalls []message.ToolCall) (action, reason string) {
+	g.iterations++
+	if limit := limits.RepeatLimit(); limit > 0 {
+		for _, call := range toolCalls {
+			key := call.Name + "\x00" + call.Input
+			g.calls[key]++
+			if g.calls[key] >= limit {
+				action, reason = "repeated_call", fmt.Sprintf("the %s tool was called %d times with the same input", call.Name, g.calls[key])
+			}
+		}
+		if action != "" {
+			return action, reason
+		}
+	}
+	if limit := limits.IterationLimit(); limit > 0 && g.iterations >= limit {
+		return "iterations", fmt.Sprintf("the request ran %d tool-use iterations", g.iterations)
+	}
+	return "", ""
+}
+
+// reset starts counting again once the user chose to continue
+func (g *loopGuard) reset() {
+	g.iterations = 0
+	clear(g.calls)
+}
+
+// guardLoop pauses the request after the tool calls of agentMessage when it
+// looks like a runaway loop, and asks whether to continue. When the request
+// stops, or right away in non-interactive runs, it ends with an assistant
+// message finishing with the reason.
+func (a *agent) guardLoop(ctx context.Context, sessionID string, guard *loopGuard, agentMessage message.Message) error {
+	action, reason := guard.check(config.Get().Options.LoopGuard, agentMessage.ToolCalls())
+	if action == "" {
+		return nil
+	}
+	if !isNonInteractive(ctx) && a.permissions.Confirm(permission.CreatePermissionRequest{
+		SessionID:   sessionID,
+		ToolName:    LoopGuardToolName,
+		Action:      action,
+		Description: fmt.Sprintf("The agent may be stuck in a loop: %s. Continue anyway?", reason),
+		Path:        config.Get().WorkingDir(),
+	}) {
+		guard.reset()
+		return nil
+	}
+	_, err := a.messages.Create(ctx, sessionID, message.CreateMessageParams{
+		Role: message.Assistant,
+		Parts: []message.ContentPart{
+			message.Finish{
+				Reason:  message.FinishReasonLoopDetected,
+				Time:    time.Now().Unix(),
+				Message: "Stopped because " + reason,
+			},
+		},
+		Model:    a.Model().ID,
+		Provider: a.providerID,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create loop guard message: %w", err)
+	}
+	return fmt.Errorf("%w: %s", ErrLoopDetected, reason)
+}
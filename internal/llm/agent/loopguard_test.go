@@ -0,0 +1,66 @@
+package agent
+
+import (
+	"testing"
+
+	"github.com/nom-nom-hub/floss/internal/config"
+	"github.com/nom-nom-hub/floss/internal/llm/provider"
+	"github.com/nom-nom-hub/floss/internal/message"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoopGuardStopsRepeatedCalls(t *testing.T) {
+	var turns []provider.FixtureTurn
+	for range 4 {
+		turns = append(turns, toolTurn(t, toolUse{"view", map[string]any{"file_path": "missing.go"}}))
+	}
+	h := newReplayHarness(t, append(turns, textTurn("Done"))...)
+	config.Get().Options.LoopGuard = &config.LoopGuard{MaxRepeatedCalls: 3}
+
+	event := h.runContext(WithNonInteractive(t.Context()), t, "Read missing.go")
+	require.ErrorIs(t, event.Error, ErrLoopDetected)
+	require.EqualError(t, event.Error, "loop detected: the view tool was called 3 times with the same input")
+	require.Len(t, h.toolResults(t), 3)
+
+	msgs, err := h.messages.List(t.Context(), h.session.ID)
+	require.NoError(t, err)
+	last := msgs[len(msgs)-1]
+	require.Equal(t, message.Assistant, last.Role)
+	require.Equal(t, message.FinishReasonLoopDetected, last.FinishReason())
+	require.Equal(t, "Stopped because the view tool was called 3 times with the same input", last.FinishPart().Message)
+}
+
+func TestLoopGuardAsksToContinue(t *testing.T) {
+	h := lsTurns(t, 3)
+	config.Get().Options.LoopGuard = &config.LoopGuard{MaxIterations: 2, MaxRepeatedCalls: -1}
+	a := h.agent.(*agent)
+	permissions := &confirmPermissions{Service: a.permissions, toolName: LoopGuardToolName, grants: 1}
+	a.permissions = permissions
+
+	event := h.run(t, "List the files")
+	require.NoError(t, event.Error)
+	require.Equal(t, "Done", event.Message.Content().Text)
+	require.Len(t, h.toolResults(t), 3)
+
+	// Continuing starts counting again, so the third iteration didn't pause
+	require.Len(t, permissions.requests, 1)
+	require.Equal(t, "iterations", permissions.requests[0].Action)
+	require.Equal(t, "The agent may be stuck in a loop: the request ran 2 tool-use iterations. Continue anyway?", permissions.requests[0].Description)
+}
+
+func TestLoopGuardAsksWhenRequestsAreSkipped(t *testing.T) {
+	h := lsTurns(t, 3)
+	config.Get().Options.LoopGuard = &config.LoopGuard{MaxIterations: 2, MaxRepeatedCalls: -1}
+	a := h.agent.(*agent)
+	require.True(t, a.permissions.SkipRequests())
+
+	requests := a.permissions.Subscribe(t.Context())
+	go func() {
+		request := <-requests
+		a.permissions.Deny(request.Payload)
+	}()
+
+	event := h.run(t, "List the files")
+	require.ErrorIs(t, event.Error, ErrLoopDetected)
+	require.Len(t, h.toolResults(t), 2)
+}
@@ -95,6 +95,11 @@ func (b *McpTool) Name() string {
 	return fmt.Sprintf("mcp_%s_%s", b.mcpName, b.tool.Name)
 }
 
+// ReadOnly trusts the server's read-only hint
+func (b *McpTool) ReadOnly() bool {
+	return b.tool.Annotations.ReadOnlyHint != nil && *b.tool.Annotations.ReadOnlyHint
+}
+
 func (b *McpTool) Info() tools.ToolInfo {
 	required := b.tool.InputSchema.Required
 	if required == nil {
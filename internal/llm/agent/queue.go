@@ -0,0 +1,118 @@
+package agent
+
+import (
+	"slices"
+	"sync"
+
+	"github.com/nom-nom-hub/floss/internal/message"
+)
+
+// QueuedPrompt is a prompt sent while its session was busy
+type QueuedPrompt struct {
+	ID          string
+	Content     string
+	Attachments []message.Attachment
+	// Interject prompts go in before the next request to the model, the
+	// others once the agent ends its turn
+	Interject bool
+}
+
+// promptQueue holds the queued prompts of each session in order
+type promptQueue struct {
+	mu      sync.Mutex
+	prompts map[string][]QueuedPrompt
+}
+
+func newPromptQueue() *promptQueue {
+	return &promptQueue{prompts: make(map[string][]QueuedPrompt)}
+}
+
+func (q *promptQueue) add(sessionID string, prompt QueuedPrompt) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.prompts[sessionID] = append(q.prompts[sessionID], prompt)
+}
+
+func (q *promptQueue) list(sessionID string) []QueuedPrompt {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return slices.Clone(q.prompts[sessionID])
+}
+
+// take removes and returns the interjections of the session, or all its
+// prompts unless interjections is set
+func (q *promptQueue) take(sessionID string, interjections bool) []QueuedPrompt {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var taken, left []QueuedPrompt
+	for _, prompt := range q.prompts[sessionID] {
+		if prompt.Interject || !interjections {
+			taken = append(taken, prompt)
+		} else {
+			left = append(left, prompt)
+		}
+	}
+	q.set(sessionID, left)
+	return taken
+}
+
+func (q *promptQueue) clear(sessionID string) []QueuedPrompt {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	prompts := q.prompts[sessionID]
+	delete(q.prompts, sessionID)
+	return prompts
+}
+
+func (q *promptQueue) update(sessionID string, prompt QueuedPrompt) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	i := q.index(sessionID, prompt.ID)
+	if i == -1 {
+		return false
+	}
+	q.prompts[sessionID][i] = prompt
+	return true
+}
+
+func (q *promptQueue) remove(sessionID, promptID string) (QueuedPrompt, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	i := q.index(sessionID, promptID)
+	if i == -1 {
+		return QueuedPrompt{}, false
+	}
+	prompt := q.prompts[sessionID][i]
+	q.set(sessionID, slices.Delete(q.prompts[sessionID], i, i+1))
+	return prompt, true
+}
+
+// move moves the prompt by offset places, stopping at either end
+func (q *promptQueue) move(sessionID, promptID string, offset int) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	i := q.index(sessionID, promptID)
+	if i == -1 {
+		return false
+	}
+	prompts := q.prompts[sessionID]
+	prompt := prompts[i]
+	prompts = slices.Delete(prompts, i, i+1)
+	j := min(max(i+offset, 0), len(prompts))
+	q.prompts[sessionID] = slices.Insert(prompts, j, prompt)
+	return true
+}
+
+func (q *promptQueue) index(sessionID, promptID string) int {
+	return slices.IndexFunc(q.prompts[sessionID], func(prompt QueuedPrompt) bool {
+		return prompt.ID == promptID
+	})
+}
+
+func (q *promptQueue) set(sessionID string, prompts []QueuedPrompt) {
+	if len(prompts) == 0 {
+		delete(q.prompts, sessionID)
+		return
+	}
+	q.prompts[sessionID] = prompts
+}
@@ -0,0 +1,78 @@
+package agent
+
+import (
+	"testing"
+
+	"github.com/nom-nom-hub/floss/internal/message"
+	"github.com/stretchr/testify/require"
+)
+
+func queuedIDs(prompts []QueuedPrompt) []string {
+	var ids []string
+	for _, prompt := range prompts {
+		ids = append(ids, prompt.ID)
+	}
+	return ids
+}
+
+func TestPromptQueue(t *testing.T) {
+	t.Parallel()
+
+	q := newPromptQueue()
+	q.add("s1", QueuedPrompt{ID: "p1", Content: "First"})
+	q.add("s1", QueuedPrompt{ID: "p2", Content: "Second", Interject: true})
+	q.add("s1", QueuedPrompt{ID: "p3", Content: "Third"})
+	q.add("s2", QueuedPrompt{ID: "p4", Content: "Other session"})
+
+	require.True(t, q.move("s1", "p3", -5))
+	require.Equal(t, []string{"p3", "p1", "p2"}, queuedIDs(q.list("s1")))
+	require.True(t, q.move("s1", "p3", 1))
+	require.Equal(t, []string{"p1", "p3", "p2"}, queuedIDs(q.list("s1")))
+	require.False(t, q.move("s1", "p4", 1))
+
+	require.True(t, q.update("s1", QueuedPrompt{ID: "p1", Content: "First edited", Interject: true}))
+	require.False(t, q.update("s2", QueuedPrompt{ID: "p1"}))
+
+	interjections := q.take("s1", true)
+	require.Equal(t, []string{"p1", "p2"}, queuedIDs(interjections))
+	require.Equal(t, "First edited", interjections[0].Content)
+	require.Equal(t, []string{"p3"}, queuedIDs(q.list("s1")))
+
+	prompt, ok := q.remove("s1", "p3")
+	require.True(t, ok)
+	require.Equal(t, "Third", prompt.Content)
+	require.Empty(t, q.list("s1"))
+	_, ok = q.remove("s1", "p3")
+	require.False(t, ok)
+
+	require.Equal(t, []string{"p4"}, queuedIDs(q.take("s2", false)))
+}
+
+func TestInterjection(t *testing.T) {
+	h := newReplayHarness(t,
+		toolTurn(t, toolUse{"ls", map[string]any{}}),
+		textTurn("Done"),
+		textTurn("Queued done"),
+	)
+	a := h.agent.(*agent)
+	recorded := &recordingMessages{Provider: a.provider}
+	a.provider = recorded
+	// Queued as if sent while the request ran
+	a.promptQueue.add(h.session.ID, QueuedPrompt{ID: "p1", Content: "Afterwards, count them"})
+	a.promptQueue.add(h.session.ID, QueuedPrompt{ID: "p2", Content: "Skip hidden files", Interject: true})
+
+	event := h.run(t, "List the files")
+	require.NoError(t, event.Error)
+	require.Equal(t, "Queued done", event.Message.Content().Text)
+	require.Empty(t, a.QueuedPrompts(h.session.ID))
+
+	require.Len(t, recorded.requests, 3)
+	// The interjection follows the tool results of the first call
+	second := recorded.requests[1]
+	require.Len(t, second, 4)
+	require.Equal(t, message.Tool, second[2].Role)
+	require.Equal(t, "Skip hidden files", second[3].Content().Text)
+	third := recorded.requests[2]
+	require.Len(t, third, 6)
+	require.Equal(t, "Afterwards, count them", third[5].Content().Text)
+}
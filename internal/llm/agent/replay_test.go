@@ -0,0 +1,217 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/nom-nom-hub/floss/internal/checkpoint"
+	"github.com/nom-nom-hub/floss/internal/config"
+	"github.com/nom-nom-hub/floss/internal/csync"
+	"github.com/nom-nom-hub/floss/internal/db"
+	"github.com/nom-nom-hub/floss/internal/history"
+	"github.com/nom-nom-hub/floss/internal/llm/provider"
+	"github.com/nom-nom-hub/floss/internal/lsp"
+	"github.com/nom-nom-hub/floss/internal/message"
+	"github.com/nom-nom-hub/floss/internal/permission"
+	"github.com/nom-nom-hub/floss/internal/session"
+	"github.com/stretchr/testify/require"
+)
+
+// replayHarness runs the coder agent with its real tools in a temporary
+// working directory, answering with the turns of a replay fixture
+type replayHarness struct {
+	dir         string
+	agent       Service
+	messages    message.Service
+	checkpoints checkpoint.Service
+	session     session.Session
+}
+
+// newReplayHarness configures a replay provider for the turns. It sets the
+// global configuration, so tests using it can't run in parallel.
+func newReplayHarness(t *testing.T, turns ...provider.FixtureTurn) *replayHarness {
+	t.Helper()
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+
+	fixturePath := filepath.Join(t.TempDir(), "fixture.json")
+	require.NoError(t, (&provider.Fixture{Turns: turns}).Save(fixturePath))
+	configData, err := json.Marshal(map[string]any{
+		"options": map[string]any{"disable_provider_auto_update": true},
+		"providers": map[string]any{
+			"replay": map[string]any{
+				"type":     config.ProviderTypeReplay,
+				"base_url": fixturePath,
+				"models":   []map[string]any{{"id": "replay-model", "name": "Replay", "context_window": 100000, "default_max_tokens": 1000}},
+			},
+		},
+		"models": map[string]any{
+			"large": map[string]any{"provider": "replay", "model": "replay-model"},
+			"small": map[string]any{"provider": "replay", "model": "replay-model"},
+		},
+	})
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "floss.json"), configData, 0o644))
+	cfg, err := config.Init(dir, "", false)
+	require.NoError(t, err)
+
+	conn, err := db.Connect(t.Context(), t.TempDir())
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	queries := db.New(conn)
+	sessions := session.NewService(queries)
+	messages := message.NewService(queries)
+	files := history.NewService(queries, conn)
+	checkpoints := checkpoint.NewService(queries, sessions, messages, files)
+
+	a, err := NewAgent(
+		t.Context(),
+		cfg.Agents["coder"],
+		permission.NewPermissionService(dir, true, nil),
+		sessions,
+		messages,
+		files,
+		checkpoints,
+		csync.NewMap[string, *lsp.Client](),
+	)
+	require.NoError(t, err)
+	sess, err := sessions.Create(t.Context(), "replay")
+	require.NoError(t, err)
+	return &replayHarness{dir: dir, agent: a, messages: messages, checkpoints: checkpoints, session: sess}
+}
+
+// run sends the prompt and waits for the agent to finish
+func (h *replayHarness) run(t *testing.T, prompt string) AgentEvent {
+	t.Helper()
+	return h.runContext(t.Context(), t, prompt)
+}
+
+func (h *replayHarness) runContext(ctx context.Context, t *testing.T, prompt string) AgentEvent {
+	t.Helper()
+	events, err := h.agent.Run(ctx, h.session.ID, prompt)
+	require.NoError(t, err)
+	select {
+	case event := <-events:
+		return event
+	case <-time.After(10 * time.Second):
+		t.Fatal("agent didn't finish")
+		return AgentEvent{}
+	}
+}
+
+// toolResults returns the results of the tool calls of the session in order
+func (h *replayHarness) toolResults(t *testing.T) []message.ToolResult {
+	t.Helper()
+	msgs, err := h.messages.List(t.Context(), h.session.ID)
+	require.NoError(t, err)
+	var results []message.ToolResult
+	for _, msg := range msgs {
+		results = append(results, msg.ToolResults()...)
+	}
+	return results
+}
+
+// toolUse is a call to the named tool, with its input marshalled to JSON
+type toolUse struct {
+	name  string
+	input any
+}
+
+// toolTurn answers with the tool calls
+func toolTurn(t *testing.T, uses ...toolUse) provider.FixtureTurn {
+	t.Helper()
+	var turn provider.FixtureTurn
+	var toolCalls []message.ToolCall
+	for i, use := range uses {
+		input, err := json.Marshal(use.input)
+		require.NoError(t, err)
+		call := message.ToolCall{ID: fmt.Sprintf("call_%d", i), Name: use.name, Input: string(input), Type: "function", Finished: true}
+		turn.Events = append(turn.Events,
+			provider.FixtureEvent{Type: provider.EventToolUseStart, ToolCall: &message.ToolCall{ID: call.ID, Name: call.Name}},
+			provider.FixtureEvent{Type: provider.EventToolUseStop, ToolCall: &call},
+		)
+		toolCalls = append(toolCalls, call)
+	}
+	turn.Events = append(turn.Events, provider.FixtureEvent{
+		Type: provider.EventComplete,
+		Response: &provider.ProviderResponse{
+			ToolCalls:    toolCalls,
+			Usage:        provider.TokenUsage{InputTokens: 100, OutputTokens: 10},
+			FinishReason: message.FinishReasonToolUse,
+		},
+	})
+	return turn
+}
+
+// textTurn answers with the text and ends the turn
+func textTurn(text string) provider.FixtureTurn {
+	return provider.FixtureTurn{Events: []provider.FixtureEvent{
+		{Type: provider.EventContentDelta, Content: text},
+		{
+			Type: provider.EventComplete,
+			Response: &provider.ProviderResponse{
+				Content:      text,
+				Usage:        provider.TokenUsage{InputTokens: 100, OutputTokens: 10},
+				FinishReason: message.FinishReasonEndTurn,
+			},
+		},
+	}}
+}
+
+func TestReplayWriteViewEdit(t *testing.T) {
+	// Relative paths are in the working directory of the harness
+	path := "main.go"
+	h := newReplayHarness(t,
+		toolTurn(t, toolUse{"write", map[string]any{"file_path": path, "content": "package main\n\nfunc main() {}\n"}}),
+		toolTurn(t, toolUse{"view", map[string]any{"file_path": path}}),
+		toolTurn(t, toolUse{"edit", map[string]any{"file_path": path, "old_string": "func main() {}", "new_string": "func main() {\n\tprintln(\"hello\")\n}"}}),
+		textTurn("Done"),
+	)
+
+	event := h.run(t, "Write a hello world program")
+	require.NoError(t, event.Error)
+	require.Equal(t, "Done", event.Message.Content().Text)
+
+	data, err := os.ReadFile(filepath.Join(h.dir, path))
+	require.NoError(t, err)
+	require.Equal(t, "package main\n\nfunc main() {\n\tprintln(\"hello\")\n}\n", string(data))
+
+	results := h.toolResults(t)
+	require.Len(t, results, 3)
+	for _, result := range results {
+		require.False(t, result.IsError, result.Content)
+	}
+	require.Contains(t, results[1].Content, "func main() {}")
+}
+
+func TestReplayToolError(t *testing.T) {
+	h := newReplayHarness(t,
+		toolTurn(t,
+			toolUse{"edit", map[string]any{"file_path": "missing.go", "old_string": "a", "new_string": "b"}},
+			toolUse{"ls", map[string]any{}},
+		),
+		textTurn("The file doesn't exist"),
+	)
+
+	event := h.run(t, "Fix missing.go")
+	require.NoError(t, event.Error)
+	require.Equal(t, message.FinishReasonEndTurn, event.Message.FinishReason())
+
+	results := h.toolResults(t)
+	require.Len(t, results, 2)
+	require.True(t, results[0].IsError)
+	require.False(t, results[1].IsError)
+}
+
+func TestReplayFixtureExhausted(t *testing.T) {
+	h := newReplayHarness(t, toolTurn(t, toolUse{"ls", map[string]any{}}))
+
+	event := h.run(t, "List the files")
+	require.ErrorContains(t, event.Error, "replay fixture has no more turns")
+}
@@ -0,0 +1,19 @@
+package prompt
+
+import (
+	"fmt"
+
+	"github.com/nom-nom-hub/floss/internal/config"
+)
+
+// CustomPrompt builds a system prompt from a configured template, followed by
+// the environment information and the content of the given context files.
+func CustomPrompt(template string, contextFiles ...string) string {
+	basePrompt := fmt.Sprintf("%s\n\n%s", template, getEnvironmentInfo())
+
+	contextContent := getContextFromPaths(config.Get().WorkingDir(), contextFiles)
+	if contextContent != "" {
+		return fmt.Sprintf("%s\n\n# Project-Specific Context\n Make sure to follow the instructions in the context below\n%s", basePrompt, contextContent)
+	}
+	return basePrompt
+}
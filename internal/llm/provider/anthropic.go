@@ -10,7 +10,6 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
-	"time"
 
 	"github.com/anthropics/anthropic-sdk-go"
 	"github.com/anthropics/anthropic-sdk-go/bedrock"
@@ -52,7 +51,8 @@ func newAnthropicClient(opts providerClientOptions, tp AnthropicClientType) Anth
 }
 
 func createAnthropicClient(opts providerClientOptions, tp AnthropicClientType) anthropic.Client {
-	anthropicClientOptions := []option.RequestOption{}
+	// Retries are left to retryClient
+	anthropicClientOptions := []option.RequestOption{option.WithMaxRetries(0)}
 
 	// Check if Authorization header is provided in extra headers
 	hasBearerAuth := false
@@ -223,22 +223,14 @@ func (a *anthropicClient) finishReason(reason string) message.FinishReason {
 }
 
 func (a *anthropicClient) isThinkingEnabled() bool {
-	cfg := config.Get()
-	modelConfig := cfg.Models[config.SelectedModelTypeLarge]
-	if a.providerOptions.modelType == config.SelectedModelTypeSmall {
-		modelConfig = cfg.Models[config.SelectedModelTypeSmall]
-	}
+	modelConfig := a.providerOptions.modelConfig()
 	return a.Model().CanReason && modelConfig.Think
 }
 
 func (a *anthropicClient) preparedMessages(messages []anthropic.MessageParam, tools []anthropic.ToolUnionParam) anthropic.MessageNewParams {
 	model := a.providerOptions.model(a.providerOptions.modelType)
 	var thinkingParam anthropic.ThinkingConfigParamUnion
-	cfg := config.Get()
-	modelConfig := cfg.Models[config.SelectedModelTypeLarge]
-	if a.providerOptions.modelType == config.SelectedModelTypeSmall {
-		modelConfig = cfg.Models[config.SelectedModelTypeSmall]
-	}
+	modelConfig := a.providerOptions.modelConfig()
 	temperature := anthropic.Float(0)
 
 	maxTokens := model.DefaultMaxTokens
@@ -286,219 +278,169 @@ func (a *anthropicClient) preparedMessages(messages []anthropic.MessageParam, to
 	}
 }
 
-func (a *anthropicClient) send(ctx context.Context, messages []message.Message, tools []tools.BaseTool) (response *ProviderResponse, err error) {
-	attempts := 0
-	for {
-		attempts++
-		// Prepare messages on each attempt in case max_tokens was adjusted
+func (a *anthropicClient) send(ctx context.Context, messages []message.Message, tools []tools.BaseTool) (*ProviderResponse, error) {
+	// Prepared on each call since max_tokens may have been adjusted after a
+	// context limit error
+	preparedMessages := a.preparedMessages(a.convertMessages(messages), a.convertTools(tools))
+
+	var opts []option.RequestOption
+	if a.isThinkingEnabled() {
+		opts = append(opts, option.WithHeaderAdd("anthropic-beta", "interleaved-thinking-2025-05-14"))
+	}
+	anthropicResponse, err := a.client.Messages.New(
+		ctx,
+		preparedMessages,
+		opts...,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	content := ""
+	for _, block := range anthropicResponse.Content {
+		if text, ok := block.AsAny().(anthropic.TextBlock); ok {
+			content += text.Text
+		}
+	}
+
+	return &ProviderResponse{
+		Content:   content,
+		ToolCalls: a.toolCalls(*anthropicResponse),
+		Usage:     a.usage(*anthropicResponse),
+	}, nil
+}
+
+func (a *anthropicClient) stream(ctx context.Context, messages []message.Message, tools []tools.BaseTool) <-chan ProviderEvent {
+	eventChan := make(chan ProviderEvent)
+	go func() {
+		defer close(eventChan)
+		// Prepared on each call since max_tokens may have been adjusted
+		// after a context limit error
 		preparedMessages := a.preparedMessages(a.convertMessages(messages), a.convertTools(tools))
 
 		var opts []option.RequestOption
 		if a.isThinkingEnabled() {
 			opts = append(opts, option.WithHeaderAdd("anthropic-beta", "interleaved-thinking-2025-05-14"))
 		}
-		anthropicResponse, err := a.client.Messages.New(
+
+		anthropicStream := a.client.Messages.NewStreaming(
 			ctx,
 			preparedMessages,
 			opts...,
 		)
-		// If there is an error we are going to see if we can retry the call
-		if err != nil {
-			retry, after, retryErr := a.shouldRetry(attempts, err)
-			if retryErr != nil {
-				return nil, retryErr
-			}
-			if retry {
-				slog.Warn("Retrying due to rate limit", "attempt", attempts, "max_retries", maxRetries, "error", err)
-				select {
-				case <-ctx.Done():
-					return nil, ctx.Err()
-				case <-time.After(time.Duration(after) * time.Millisecond):
-					continue
-				}
-			}
-			return nil, retryErr
-		}
-
-		content := ""
-		for _, block := range anthropicResponse.Content {
-			if text, ok := block.AsAny().(anthropic.TextBlock); ok {
-				content += text.Text
-			}
-		}
-
-		return &ProviderResponse{
-			Content:   content,
-			ToolCalls: a.toolCalls(*anthropicResponse),
-			Usage:     a.usage(*anthropicResponse),
-		}, nil
-	}
-}
-
-func (a *anthropicClient) stream(ctx context.Context, messages []message.Message, tools []tools.BaseTool) <-chan ProviderEvent {
-	attempts := 0
-	eventChan := make(chan ProviderEvent)
-	go func() {
-		for {
-			attempts++
-			// Prepare messages on each attempt in case max_tokens was adjusted
-			preparedMessages := a.preparedMessages(a.convertMessages(messages), a.convertTools(tools))
-
-			var opts []option.RequestOption
-			if a.isThinkingEnabled() {
-				opts = append(opts, option.WithHeaderAdd("anthropic-beta", "interleaved-thinking-2025-05-14"))
+		accumulatedMessage := anthropic.Message{}
+
+		currentToolCallID := ""
+		for anthropicStream.Next() {
+			event := anthropicStream.Current()
+			err := accumulatedMessage.Accumulate(event)
+			if err != nil {
+				slog.Warn("Error accumulating message", "error", err)
+				continue
 			}
 
-			anthropicStream := a.client.Messages.NewStreaming(
-				ctx,
-				preparedMessages,
-				opts...,
-			)
-			accumulatedMessage := anthropic.Message{}
-
-			currentToolCallID := ""
-			for anthropicStream.Next() {
-				event := anthropicStream.Current()
-				err := accumulatedMessage.Accumulate(event)
-				if err != nil {
-					slog.Warn("Error accumulating message", "error", err)
-					continue
+			switch event := event.AsAny().(type) {
+			case anthropic.ContentBlockStartEvent:
+				switch event.ContentBlock.Type {
+				case "text":
+					eventChan <- ProviderEvent{Type: EventContentStart}
+				case "tool_use":
+					currentToolCallID = event.ContentBlock.ID
+					eventChan <- ProviderEvent{
+						Type: EventToolUseStart,
+						ToolCall: &message.ToolCall{
+							ID:       event.ContentBlock.ID,
+							Name:     event.ContentBlock.Name,
+							Finished: false,
+						},
+					}
 				}
 
-				switch event := event.AsAny().(type) {
-				case anthropic.ContentBlockStartEvent:
-					switch event.ContentBlock.Type {
-					case "text":
-						eventChan <- ProviderEvent{Type: EventContentStart}
-					case "tool_use":
-						currentToolCallID = event.ContentBlock.ID
-						eventChan <- ProviderEvent{
-							Type: EventToolUseStart,
-							ToolCall: &message.ToolCall{
-								ID:       event.ContentBlock.ID,
-								Name:     event.ContentBlock.Name,
-								Finished: false,
-							},
-						}
+			case anthropic.ContentBlockDeltaEvent:
+				if event.Delta.Type == "thinking_delta" && event.Delta.Thinking != "" {
+					eventChan <- ProviderEvent{
+						Type:     EventThinkingDelta,
+						Thinking: event.Delta.Thinking,
 					}
-
-				case anthropic.ContentBlockDeltaEvent:
-					if event.Delta.Type == "thinking_delta" && event.Delta.Thinking != "" {
-						eventChan <- ProviderEvent{
-							Type:     EventThinkingDelta,
-							Thinking: event.Delta.Thinking,
-						}
-					} else if event.Delta.Type == "signature_delta" && event.Delta.Signature != "" {
-						eventChan <- ProviderEvent{
-							Type:      EventSignatureDelta,
-							Signature: event.Delta.Signature,
-						}
-					} else if event.Delta.Type == "text_delta" && event.Delta.Text != "" {
-						eventChan <- ProviderEvent{
-							Type:    EventContentDelta,
-							Content: event.Delta.Text,
-						}
-					} else if event.Delta.Type == "input_json_delta" {
-						if currentToolCallID != "" {
-							eventChan <- ProviderEvent{
-								Type: EventToolUseDelta,
-								ToolCall: &message.ToolCall{
-									ID:       currentToolCallID,
-									Finished: false,
-									Input:    event.Delta.PartialJSON,
-								},
-							}
-						}
+				} else if event.Delta.Type == "signature_delta" && event.Delta.Signature != "" {
+					eventChan <- ProviderEvent{
+						Type:      EventSignatureDelta,
+						Signature: event.Delta.Signature,
+					}
+				} else if event.Delta.Type == "text_delta" && event.Delta.Text != "" {
+					eventChan <- ProviderEvent{
+						Type:    EventContentDelta,
+						Content: event.Delta.Text,
 					}
-				case anthropic.ContentBlockStopEvent:
+				} else if event.Delta.Type == "input_json_delta" {
 					if currentToolCallID != "" {
 						eventChan <- ProviderEvent{
-							Type: EventToolUseStop,
+							Type: EventToolUseDelta,
 							ToolCall: &message.ToolCall{
-								ID: currentToolCallID,
+								ID:       currentToolCallID,
+								Finished: false,
+								Input:    event.Delta.PartialJSON,
 							},
 						}
-						currentToolCallID = ""
-					} else {
-						eventChan <- ProviderEvent{Type: EventContentStop}
-					}
-
-				case anthropic.MessageStopEvent:
-					content := ""
-					for _, block := range accumulatedMessage.Content {
-						if text, ok := block.AsAny().(anthropic.TextBlock); ok {
-							content += text.Text
-						}
 					}
-
+				}
+			case anthropic.ContentBlockStopEvent:
+				if currentToolCallID != "" {
 					eventChan <- ProviderEvent{
-						Type: EventComplete,
-						Response: &ProviderResponse{
-							Content:      content,
-							ToolCalls:    a.toolCalls(accumulatedMessage),
-							Usage:        a.usage(accumulatedMessage),
-							FinishReason: a.finishReason(string(accumulatedMessage.StopReason)),
+						Type: EventToolUseStop,
+						ToolCall: &message.ToolCall{
+							ID: currentToolCallID,
 						},
-						Content: content,
 					}
+					currentToolCallID = ""
+				} else {
+					eventChan <- ProviderEvent{Type: EventContentStop}
 				}
-			}
-
-			err := anthropicStream.Err()
-			if err == nil || errors.Is(err, io.EOF) {
-				close(eventChan)
-				return
-			}
 
-			// If there is an error we are going to see if we can retry the call
-			retry, after, retryErr := a.shouldRetry(attempts, err)
-			if retryErr != nil {
-				eventChan <- ProviderEvent{Type: EventError, Error: retryErr}
-				close(eventChan)
-				return
-			}
-			if retry {
-				slog.Warn("Retrying due to rate limit", "attempt", attempts, "max_retries", maxRetries, "error", err)
-				select {
-				case <-ctx.Done():
-					// context cancelled
-					if ctx.Err() != nil {
-						eventChan <- ProviderEvent{Type: EventError, Error: ctx.Err()}
+			case anthropic.MessageStopEvent:
+				content := ""
+				for _, block := range accumulatedMessage.Content {
+					if text, ok := block.AsAny().(anthropic.TextBlock); ok {
+						content += text.Text
 					}
-					close(eventChan)
-					return
-				case <-time.After(time.Duration(after) * time.Millisecond):
-					continue
+				}
+
+				eventChan <- ProviderEvent{
+					Type: EventComplete,
+					Response: &ProviderResponse{
+						Content:      content,
+						ToolCalls:    a.toolCalls(accumulatedMessage),
+						Usage:        a.usage(accumulatedMessage),
+						FinishReason: a.finishReason(string(accumulatedMessage.StopReason)),
+					},
+					Content: content,
 				}
 			}
-			if ctx.Err() != nil {
-				eventChan <- ProviderEvent{Type: EventError, Error: ctx.Err()}
-			}
+		}
 
-			close(eventChan)
-			return
+		err := anthropicStream.Err()
+		if err != nil && !errors.Is(err, io.EOF) {
+			eventChan <- ProviderEvent{Type: EventError, Error: err}
 		}
 	}()
 	return eventChan
 }
 
-func (a *anthropicClient) shouldRetry(attempts int, err error) (bool, int64, error) {
+// recoverFrom refreshes the API key after an authentication error and lowers
+// max_tokens after a context limit error
+func (a *anthropicClient) recoverFrom(err error) (bool, error) {
 	var apiErr *anthropic.Error
 	if !errors.As(err, &apiErr) {
-		return false, 0, err
-	}
-
-	if attempts > maxRetries {
-		return false, 0, fmt.Errorf("maximum retry attempts reached for rate limit: %d retries", maxRetries)
+		return false, nil
 	}
 
 	if apiErr.StatusCode == 401 {
 		a.providerOptions.apiKey, err = config.Get().Resolve(a.providerOptions.config.APIKey)
 		if err != nil {
-			return false, 0, fmt.Errorf("failed to resolve API key: %w", err)
+			return false, fmt.Errorf("failed to resolve API key: %w", err)
 		}
 		a.client = createAnthropicClient(a.providerOptions, a.tp)
-		return true, 0, nil
+		return true, nil
 	}
 
 	// Handle context limit exceeded error (400 Bad Request)
@@ -506,27 +448,10 @@ func (a *anthropicClient) shouldRetry(attempts int, err error) (bool, int64, err
 		if adjusted, ok := a.handleContextLimitError(apiErr); ok {
 			a.adjustedMaxTokens = adjusted
 			slog.Debug("Adjusted max_tokens due to context limit", "new_max_tokens", adjusted)
-			return true, 0, nil
-		}
-	}
-
-	isOverloaded := strings.Contains(apiErr.Error(), "overloaded") || strings.Contains(apiErr.Error(), "rate limit exceeded")
-	if apiErr.StatusCode != 429 && apiErr.StatusCode != 529 && !isOverloaded {
-		return false, 0, err
-	}
-
-	retryMs := 0
-	retryAfterValues := apiErr.Response.Header.Values("Retry-After")
-
-	backoffMs := 2000 * (1 << (attempts - 1))
-	jitterMs := int(float64(backoffMs) * 0.2)
-	retryMs = backoffMs + jitterMs
-	if len(retryAfterValues) > 0 {
-		if _, err := fmt.Sscanf(retryAfterValues[0], "%d", &retryMs); err == nil {
-			retryMs = retryMs * 1000
+			return true, nil
 		}
 	}
-	return true, int64(retryMs), nil
+	return false, nil
 }
 
 // handleContextLimitError parses context limit error and returns adjusted max_tokens
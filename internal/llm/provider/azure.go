@@ -22,6 +22,7 @@ func newAzureClient(opts providerClientOptions) AzureClient {
 
 	reqOpts := []option.RequestOption{
 		azure.WithEndpoint(opts.baseURL, apiVersion),
+		option.WithMaxRetries(0),
 	}
 
 	if config.Get().Options.Debug {
@@ -32,14 +32,15 @@ func newBedrockClient(opts providerClientOptions) BedrockClient {
 		}
 	}
 
+	baseModel := opts.model
 	opts.model = func(modelType config.SelectedModelType) catwalk.Model {
-		model := config.Get().GetModelByType(modelType)
+		model := baseModel(modelType)
 
 		// Prefix the model name with region
 		regionPrefix := region[:2]
 		modelName := model.ID
 		model.ID = fmt.Sprintf("%s.%s", regionPrefix, modelName)
-		return *model
+		return model
 	}
 
 	model := opts.model(opts.modelType)
@@ -88,6 +89,13 @@ func (b *bedrockClient) stream(ctx context.Context, messages []message.Message,
 	return b.childProvider.stream(ctx, messages, tools)
 }
 
+func (b *bedrockClient) recoverFrom(err error) (bool, error) {
+	if recovering, ok := b.childProvider.(recoveringClient); ok {
+		return recovering.recoverFrom(err)
+	}
+	return false, nil
+}
+
 func (b *bedrockClient) Model() catwalk.Model {
 	return b.providerOptions.model(b.providerOptions.modelType)
 }
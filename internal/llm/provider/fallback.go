@@ -0,0 +1,123 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/charmbracelet/catwalk/pkg/catwalk"
+
+	"github.com/nom-nom-hub/floss/internal/llm/tools"
+	"github.com/nom-nom-hub/floss/internal/message"
+)
+
+// fallbackProvider answers with the first of its models that doesn't fail.
+// When a model fails after it started answering, the warning announcing the
+// fallback has Restart set, since the fallback answers from the start.
+type fallbackProvider struct {
+	models []fallbackModel
+}
+
+type fallbackModel struct {
+	provider   Provider
+	providerID string
+}
+
+func (p *fallbackProvider) SendMessages(ctx context.Context, messages []message.Message, tools []tools.BaseTool) (*ProviderResponse, error) {
+	var err error
+	for i, model := range p.models {
+		var response *ProviderResponse
+		response, err = model.provider.SendMessages(ctx, historyFor(messages, model.providerID), tools)
+		if err == nil {
+			p.recordModel(i, response)
+			return response, nil
+		}
+		if ctx.Err() != nil {
+			return nil, err
+		}
+		p.logFallback(i, err)
+	}
+	return nil, err
+}
+
+func (p *fallbackProvider) StreamResponse(ctx context.Context, messages []message.Message, tools []tools.BaseTool) <-chan ProviderEvent {
+	eventChan := make(chan ProviderEvent)
+	go func() {
+		defer close(eventChan)
+		for i, model := range p.models {
+			answered := false
+			var failure error
+			for event := range model.provider.StreamResponse(ctx, historyFor(messages, model.providerID), tools) {
+				if event.Type == EventError && ctx.Err() == nil && i < len(p.models)-1 {
+					failure = event.Error
+					continue
+				}
+				if event.Type != EventWarning && event.Type != EventContentStart {
+					answered = true
+				}
+				if event.Type == EventComplete {
+					p.recordModel(i, event.Response)
+				}
+				eventChan <- event
+			}
+			if failure == nil {
+				return
+			}
+			p.logFallback(i, failure)
+			eventChan <- ProviderEvent{
+				Type:    EventWarning,
+				Content: fmt.Sprintf("%s failed, falling back to %s: %v", p.models[i].provider.Model().Name, p.models[i+1].provider.Model().Name, failure),
+				Restart: answered,
+			}
+		}
+	}()
+	return eventChan
+}
+
+// Model returns the configured model, the first of the chain
+func (p *fallbackProvider) Model() catwalk.Model {
+	return p.models[0].provider.Model()
+}
+
+// recordModel marks the response of a fallback model with the model
+func (p *fallbackProvider) recordModel(i int, response *ProviderResponse) {
+	if i == 0 || response == nil {
+		return
+	}
+	model := p.models[i].provider.Model()
+	response.ProviderID = p.models[i].providerID
+	response.Model = &model
+}
+
+func (p *fallbackProvider) logFallback(i int, err error) {
+	if i == len(p.models)-1 {
+		return
+	}
+	slog.Warn("Model failed, falling back",
+		"provider", p.models[i].providerID,
+		"model", p.models[i].provider.Model().ID,
+		"fallback_provider", p.models[i+1].providerID,
+		"fallback_model", p.models[i+1].provider.Model().ID,
+		"error", err,
+	)
+}
+
+// historyFor drops the reasoning of assistant messages written by other
+// providers, since signed thinking blocks are only valid for the provider
+// that signed them
+func historyFor(messages []message.Message, providerID string) []message.Message {
+	converted := make([]message.Message, len(messages))
+	for i, msg := range messages {
+		if msg.Role == message.Assistant && msg.Provider != providerID {
+			parts := make([]message.ContentPart, 0, len(msg.Parts))
+			for _, part := range msg.Parts {
+				if _, ok := part.(message.ReasoningContent); !ok {
+					parts = append(parts, part)
+				}
+			}
+			msg.Parts = parts
+		}
+		converted[i] = msg
+	}
+	return converted
+}
@@ -0,0 +1,207 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/charmbracelet/catwalk/pkg/catwalk"
+	"github.com/nom-nom-hub/floss/internal/config"
+	"github.com/nom-nom-hub/floss/internal/llm/tools"
+	"github.com/nom-nom-hub/floss/internal/message"
+	"github.com/stretchr/testify/require"
+)
+
+// scriptedProvider streams its events and records the history it was sent
+type scriptedProvider struct {
+	model   catwalk.Model
+	events  []ProviderEvent
+	history []message.Message
+}
+
+func (p *scriptedProvider) SendMessages(_ context.Context, messages []message.Message, _ []tools.BaseTool) (*ProviderResponse, error) {
+	p.history = messages
+	for _, event := range p.events {
+		if event.Type == EventError {
+			return nil, event.Error
+		}
+		if event.Type == EventComplete {
+			return event.Response, nil
+		}
+	}
+	return nil, errors.New("no response")
+}
+
+func (p *scriptedProvider) StreamResponse(_ context.Context, messages []message.Message, _ []tools.BaseTool) <-chan ProviderEvent {
+	p.history = messages
+	eventChan := make(chan ProviderEvent, len(p.events))
+	for _, event := range p.events {
+		eventChan <- event
+	}
+	close(eventChan)
+	return eventChan
+}
+
+func (p *scriptedProvider) Model() catwalk.Model { return p.model }
+
+func answering(id, text string) *scriptedProvider {
+	return &scriptedProvider{
+		model: catwalk.Model{ID: id, Name: id},
+		events: []ProviderEvent{
+			{Type: EventContentDelta, Content: text},
+			{Type: EventComplete, Response: &ProviderResponse{Content: text, FinishReason: message.FinishReasonEndTurn}},
+		},
+	}
+}
+
+func failing(id string, err error) *scriptedProvider {
+	return &scriptedProvider{
+		model:  catwalk.Model{ID: id, Name: id},
+		events: []ProviderEvent{{Type: EventError, Error: err}},
+	}
+}
+
+func collect(events <-chan ProviderEvent) []ProviderEvent {
+	var collected []ProviderEvent
+	for event := range events {
+		collected = append(collected, event)
+	}
+	return collected
+}
+
+func TestFallbackProviderStreamResponse(t *testing.T) {
+	t.Parallel()
+
+	primary := failing("primary", errors.New("429 Too Many Requests"))
+	second := failing("second", errors.New("503 Service Unavailable"))
+	third := answering("third", "hello")
+	p := &fallbackProvider{models: []fallbackModel{
+		{provider: primary, providerID: "anthropic"},
+		{provider: second, providerID: "openai"},
+		{provider: third, providerID: "gemini"},
+	}}
+
+	events := collect(p.StreamResponse(t.Context(), nil, nil))
+	require.Len(t, events, 4)
+	require.Equal(t, EventWarning, events[0].Type)
+	require.Equal(t, "primary failed, falling back to second: 429 Too Many Requests", events[0].Content)
+	require.False(t, events[0].Restart)
+	require.Equal(t, EventWarning, events[1].Type)
+	require.Equal(t, EventContentDelta, events[2].Type)
+	require.Equal(t, EventComplete, events[3].Type)
+	require.Equal(t, "gemini", events[3].Response.ProviderID)
+	require.Equal(t, "third", events[3].Response.Model.ID)
+	require.Equal(t, "primary", p.Model().ID)
+}
+
+func TestFallbackProviderKeepsPrimaryAnswer(t *testing.T) {
+	t.Parallel()
+
+	primary := answering("primary", "hello")
+	fallback := answering("fallback", "hi")
+	p := &fallbackProvider{models: []fallbackModel{
+		{provider: primary, providerID: "anthropic"},
+		{provider: fallback, providerID: "openai"},
+	}}
+
+	events := collect(p.StreamResponse(t.Context(), nil, nil))
+	require.Len(t, events, 2)
+	require.Empty(t, events[1].Response.ProviderID)
+	require.Nil(t, events[1].Response.Model)
+	require.Nil(t, fallback.history)
+}
+
+func TestFallbackProviderRestartsPartialAnswer(t *testing.T) {
+	t.Parallel()
+
+	primary := &scriptedProvider{
+		model: catwalk.Model{ID: "primary"},
+		events: []ProviderEvent{
+			{Type: EventContentDelta, Content: "hel"},
+			{Type: EventError, Error: errors.New("stream cut")},
+		},
+	}
+	fallback := answering("fallback", "hi")
+	p := &fallbackProvider{models: []fallbackModel{
+		{provider: primary, providerID: "anthropic"},
+		{provider: fallback, providerID: "openai"},
+	}}
+
+	events := collect(p.StreamResponse(t.Context(), nil, nil))
+	require.Len(t, events, 4)
+	require.Equal(t, EventWarning, events[1].Type)
+	require.True(t, events[1].Restart)
+	require.Equal(t, "hi", events[2].Content)
+	require.Equal(t, "openai", events[3].Response.ProviderID)
+}
+
+func TestFallbackProviderLastError(t *testing.T) {
+	t.Parallel()
+
+	p := &fallbackProvider{models: []fallbackModel{
+		{provider: failing("primary", errors.New("overloaded")), providerID: "anthropic"},
+		{provider: failing("fallback", errors.New("unauthorized")), providerID: "openai"},
+	}}
+
+	events := collect(p.StreamResponse(t.Context(), nil, nil))
+	require.Len(t, events, 2)
+	require.Equal(t, EventWarning, events[0].Type)
+	require.EqualError(t, events[1].Error, "unauthorized")
+
+	_, err := p.SendMessages(t.Context(), nil, nil)
+	require.EqualError(t, err, "unauthorized")
+}
+
+func TestFallbackProviderSendMessages(t *testing.T) {
+	t.Parallel()
+
+	p := &fallbackProvider{models: []fallbackModel{
+		{provider: failing("primary", errors.New("overloaded")), providerID: "anthropic"},
+		{provider: answering("fallback", "hi"), providerID: "openai"},
+	}}
+
+	response, err := p.SendMessages(t.Context(), nil, nil)
+	require.NoError(t, err)
+	require.Equal(t, "hi", response.Content)
+	require.Equal(t, "openai", response.ProviderID)
+	require.Equal(t, "fallback", response.Model.ID)
+}
+
+func TestFallbackProviderConvertsHistory(t *testing.T) {
+	t.Parallel()
+
+	messages := []message.Message{
+		{Role: message.User, Parts: []message.ContentPart{message.TextContent{Text: "Hi"}}},
+		{
+			Role:     message.Assistant,
+			Provider: "anthropic",
+			Parts: []message.ContentPart{
+				message.ReasoningContent{Thinking: "Greet back", Signature: "sig"},
+				message.TextContent{Text: "Hello"},
+			},
+		},
+	}
+	primary := failing("primary", errors.New("overloaded"))
+	fallback := answering("fallback", "hi")
+	p := &fallbackProvider{models: []fallbackModel{
+		{provider: primary, providerID: "anthropic"},
+		{provider: fallback, providerID: "openai"},
+	}}
+
+	collect(p.StreamResponse(t.Context(), messages, nil))
+	require.Equal(t, messages, primary.history)
+	require.Equal(t, []message.ContentPart{message.TextContent{Text: "Hello"}}, fallback.history[1].Parts)
+	// The history of the caller is left alone
+	require.Len(t, messages[1].Parts, 2)
+}
+
+func TestSystemPromptOfEachProvider(t *testing.T) {
+	t.Parallel()
+
+	prompt := WithSystemPrompt(func(providerID string) string { return "You answer through " + providerID })
+	for _, providerID := range []string{"anthropic", "openai"} {
+		options, err := newClientOptions(config.ProviderConfig{ID: providerID}, prompt)
+		require.NoError(t, err)
+		require.Equal(t, "You answer through "+providerID, options.systemMessage)
+	}
+}
@@ -5,10 +5,8 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
 	"log/slog"
 	"strings"
-	"time"
 
 	"github.com/charmbracelet/catwalk/pkg/catwalk"
 	"github.com/google/uuid"
@@ -178,12 +176,7 @@ func (g *geminiClient) send(ctx context.Context, messages []message.Message, too
 	// Convert messages
 	geminiMessages := g.convertMessages(messages)
 	model := g.providerOptions.model(g.providerOptions.modelType)
-	cfg := config.Get()
-
-	modelConfig := cfg.Models[config.SelectedModelTypeLarge]
-	if g.providerOptions.modelType == config.SelectedModelTypeSmall {
-		modelConfig = cfg.Models[config.SelectedModelTypeSmall]
-	}
+	modelConfig := g.providerOptions.modelConfig()
 
 	maxTokens := model.DefaultMaxTokens
 	if modelConfig.MaxTokens > 0 {
@@ -204,69 +197,51 @@ func (g *geminiClient) send(ctx context.Context, messages []message.Message, too
 	config.Tools = g.convertTools(tools)
 	chat, _ := g.client.Chats.Create(ctx, model.ID, config, history)
 
-	attempts := 0
-	for {
-		attempts++
-		var toolCalls []message.ToolCall
+	var toolCalls []message.ToolCall
 
-		var lastMsgParts []genai.Part
-		for _, part := range lastMsg.Parts {
-			lastMsgParts = append(lastMsgParts, *part)
-		}
-		resp, err := chat.SendMessage(ctx, lastMsgParts...)
-		// If there is an error we are going to see if we can retry the call
-		if err != nil {
-			retry, after, retryErr := g.shouldRetry(attempts, err)
-			if retryErr != nil {
-				return nil, retryErr
-			}
-			if retry {
-				slog.Warn("Retrying due to rate limit", "attempt", attempts, "max_retries", maxRetries, "error", err)
-				select {
-				case <-ctx.Done():
-					return nil, ctx.Err()
-				case <-time.After(time.Duration(after) * time.Millisecond):
-					continue
-				}
-			}
-			return nil, retryErr
-		}
+	var lastMsgParts []genai.Part
+	for _, part := range lastMsg.Parts {
+		lastMsgParts = append(lastMsgParts, *part)
+	}
+	resp, err := chat.SendMessage(ctx, lastMsgParts...)
+	if err != nil {
+		return nil, err
+	}
 
-		content := ""
-
-		if len(resp.Candidates) > 0 && resp.Candidates[0].Content != nil {
-			for _, part := range resp.Candidates[0].Content.Parts {
-				switch {
-				case part.Text != "":
-					content = string(part.Text)
-				case part.FunctionCall != nil:
-					id := "call_" + uuid.New().String()
-					args, _ := json.Marshal(part.FunctionCall.Args)
-					toolCalls = append(toolCalls, message.ToolCall{
-						ID:       id,
-						Name:     part.FunctionCall.Name,
-						Input:    string(args),
-						Type:     "function",
-						Finished: true,
-					})
-				}
+	content := ""
+
+	if len(resp.Candidates) > 0 && resp.Candidates[0].Content != nil {
+		for _, part := range resp.Candidates[0].Content.Parts {
+			switch {
+			case part.Text != "":
+				content = string(part.Text)
+			case part.FunctionCall != nil:
+				id := "call_" + uuid.New().String()
+				args, _ := json.Marshal(part.FunctionCall.Args)
+				toolCalls = append(toolCalls, message.ToolCall{
+					ID:       id,
+					Name:     part.FunctionCall.Name,
+					Input:    string(args),
+					Type:     "function",
+					Finished: true,
+				})
 			}
 		}
-		finishReason := message.FinishReasonEndTurn
-		if len(resp.Candidates) > 0 {
-			finishReason = g.finishReason(resp.Candidates[0].FinishReason)
-		}
-		if len(toolCalls) > 0 {
-			finishReason = message.FinishReasonToolUse
-		}
-
-		return &ProviderResponse{
-			Content:      content,
-			ToolCalls:    toolCalls,
-			Usage:        g.usage(resp),
-			FinishReason: finishReason,
-		}, nil
 	}
+	finishReason := message.FinishReasonEndTurn
+	if len(resp.Candidates) > 0 {
+		finishReason = g.finishReason(resp.Candidates[0].FinishReason)
+	}
+	if len(toolCalls) > 0 {
+		finishReason = message.FinishReasonToolUse
+	}
+
+	return &ProviderResponse{
+		Content:      content,
+		ToolCalls:    toolCalls,
+		Usage:        g.usage(resp),
+		FinishReason: finishReason,
+	}, nil
 }
 
 func (g *geminiClient) stream(ctx context.Context, messages []message.Message, tools []tools.BaseTool) <-chan ProviderEvent {
@@ -274,12 +249,7 @@ func (g *geminiClient) stream(ctx context.Context, messages []message.Message, t
 	geminiMessages := g.convertMessages(messages)
 
 	model := g.providerOptions.model(g.providerOptions.modelType)
-	cfg := config.Get()
-
-	modelConfig := cfg.Models[config.SelectedModelTypeLarge]
-	if g.providerOptions.modelType == config.SelectedModelTypeSmall {
-		modelConfig = cfg.Models[config.SelectedModelTypeSmall]
-	}
+	modelConfig := g.providerOptions.modelConfig()
 	maxTokens := model.DefaultMaxTokens
 	if modelConfig.MaxTokens > 0 {
 		maxTokens = modelConfig.MaxTokens
@@ -304,158 +274,116 @@ func (g *geminiClient) stream(ctx context.Context, messages []message.Message, t
 	config.Tools = g.convertTools(tools)
 	chat, _ := g.client.Chats.Create(ctx, model.ID, config, history)
 
-	attempts := 0
 	eventChan := make(chan ProviderEvent)
 
 	go func() {
 		defer close(eventChan)
 
-		for {
-			attempts++
+		currentContent := ""
+		toolCalls := []message.ToolCall{}
+		var finalResp *genai.GenerateContentResponse
 
-			currentContent := ""
-			toolCalls := []message.ToolCall{}
-			var finalResp *genai.GenerateContentResponse
+		eventChan <- ProviderEvent{Type: EventContentStart}
 
-			eventChan <- ProviderEvent{Type: EventContentStart}
+		var lastMsgParts []genai.Part
 
-			var lastMsgParts []genai.Part
+		for _, part := range lastMsg.Parts {
+			lastMsgParts = append(lastMsgParts, *part)
+		}
 
-			for _, part := range lastMsg.Parts {
-				lastMsgParts = append(lastMsgParts, *part)
+		for resp, err := range chat.SendMessageStream(ctx, lastMsgParts...) {
+			if err != nil {
+				eventChan <- ProviderEvent{Type: EventError, Error: err}
+				return
 			}
 
-			for resp, err := range chat.SendMessageStream(ctx, lastMsgParts...) {
-				if err != nil {
-					retry, after, retryErr := g.shouldRetry(attempts, err)
-					if retryErr != nil {
-						eventChan <- ProviderEvent{Type: EventError, Error: retryErr}
-						return
-					}
-					if retry {
-						slog.Warn("Retrying due to rate limit", "attempt", attempts, "max_retries", maxRetries, "error", err)
-						select {
-						case <-ctx.Done():
-							if ctx.Err() != nil {
-								eventChan <- ProviderEvent{Type: EventError, Error: ctx.Err()}
+			finalResp = resp
+
+			if len(resp.Candidates) > 0 && resp.Candidates[0].Content != nil {
+				for _, part := range resp.Candidates[0].Content.Parts {
+					switch {
+					case part.Text != "":
+						delta := string(part.Text)
+						if delta != "" {
+							eventChan <- ProviderEvent{
+								Type:    EventContentDelta,
+								Content: delta,
 							}
-
-							return
-						case <-time.After(time.Duration(after) * time.Millisecond):
-							continue
+							currentContent += delta
 						}
-					} else {
-						eventChan <- ProviderEvent{Type: EventError, Error: err}
-						return
-					}
-				}
-
-				finalResp = resp
-
-				if len(resp.Candidates) > 0 && resp.Candidates[0].Content != nil {
-					for _, part := range resp.Candidates[0].Content.Parts {
-						switch {
-						case part.Text != "":
-							delta := string(part.Text)
-							if delta != "" {
-								eventChan <- ProviderEvent{
-									Type:    EventContentDelta,
-									Content: delta,
-								}
-								currentContent += delta
-							}
-						case part.FunctionCall != nil:
-							id := "call_" + uuid.New().String()
-							args, _ := json.Marshal(part.FunctionCall.Args)
-							newCall := message.ToolCall{
-								ID:       id,
-								Name:     part.FunctionCall.Name,
-								Input:    string(args),
-								Type:     "function",
-								Finished: true,
-							}
-
-							toolCalls = append(toolCalls, newCall)
+					case part.FunctionCall != nil:
+						id := "call_" + uuid.New().String()
+						args, _ := json.Marshal(part.FunctionCall.Args)
+						newCall := message.ToolCall{
+							ID:       id,
+							Name:     part.FunctionCall.Name,
+							Input:    string(args),
+							Type:     "function",
+							Finished: true,
 						}
+
+						toolCalls = append(toolCalls, newCall)
 					}
-				} else {
-					// no content received
-					break
 				}
+			} else {
+				// no content received
+				break
 			}
+		}
 
-			eventChan <- ProviderEvent{Type: EventContentStop}
+		eventChan <- ProviderEvent{Type: EventContentStop}
 
-			if finalResp != nil {
-				finishReason := message.FinishReasonEndTurn
-				if len(finalResp.Candidates) > 0 {
-					finishReason = g.finishReason(finalResp.Candidates[0].FinishReason)
-				}
-				if len(toolCalls) > 0 {
-					finishReason = message.FinishReasonToolUse
-				}
-				eventChan <- ProviderEvent{
-					Type: EventComplete,
-					Response: &ProviderResponse{
-						Content:      currentContent,
-						ToolCalls:    toolCalls,
-						Usage:        g.usage(finalResp),
-						FinishReason: finishReason,
-					},
-				}
-				return
-			} else {
-				eventChan <- ProviderEvent{
-					Type:  EventError,
-					Error: errors.New("no content received"),
-				}
+		if finalResp == nil {
+			eventChan <- ProviderEvent{
+				Type:  EventError,
+				Error: errors.New("no content received"),
 			}
+			return
+		}
+		// The SDK ends the stream without an error when the connection
+		// drops, but the last chunk of a complete response has a finish
+		// reason
+		if len(finalResp.Candidates) > 0 && finalResp.Candidates[0].FinishReason == "" {
+			eventChan <- ProviderEvent{Type: EventError, Error: errIncompleteStream}
+			return
+		}
+
+		finishReason := message.FinishReasonEndTurn
+		if len(finalResp.Candidates) > 0 {
+			finishReason = g.finishReason(finalResp.Candidates[0].FinishReason)
+		}
+		if len(toolCalls) > 0 {
+			finishReason = message.FinishReasonToolUse
+		}
+		eventChan <- ProviderEvent{
+			Type: EventComplete,
+			Response: &ProviderResponse{
+				Content:      currentContent,
+				ToolCalls:    toolCalls,
+				Usage:        g.usage(finalResp),
+				FinishReason: finishReason,
+			},
 		}
 	}()
 
 	return eventChan
 }
 
-func (g *geminiClient) shouldRetry(attempts int, err error) (bool, int64, error) {
-	// Check if error is a rate limit error
-	if attempts > maxRetries {
-		return false, 0, fmt.Errorf("maximum retry attempts reached for rate limit: %d retries", maxRetries)
-	}
-
-	// Gemini doesn't have a standard error type we can check against
-	// So we'll check the error message for rate limit indicators
-	if errors.Is(err, io.EOF) {
-		return false, 0, err
+// recoverFrom refreshes the API key after an authentication error. Gemini
+// doesn't have a standard error type for it, so the message is checked.
+func (g *geminiClient) recoverFrom(err error) (bool, error) {
+	if !contains(err.Error(), "unauthorized", "invalid api key", "api key expired") {
+		return false, nil
 	}
-
-	errMsg := err.Error()
-	isRateLimit := contains(errMsg, "rate limit", "quota exceeded", "too many requests")
-
-	// Check for token expiration (401 Unauthorized)
-	if contains(errMsg, "unauthorized", "invalid api key", "api key expired") {
-		g.providerOptions.apiKey, err = config.Get().Resolve(g.providerOptions.config.APIKey)
-		if err != nil {
-			return false, 0, fmt.Errorf("failed to resolve API key: %w", err)
-		}
-		g.client, err = createGeminiClient(g.providerOptions)
-		if err != nil {
-			return false, 0, fmt.Errorf("failed to create Gemini client after API key refresh: %w", err)
-		}
-		return true, 0, nil
+	g.providerOptions.apiKey, err = config.Get().Resolve(g.providerOptions.config.APIKey)
+	if err != nil {
+		return false, fmt.Errorf("failed to resolve API key: %w", err)
 	}
-
-	// Check for common rate limit error messages
-
-	if !isRateLimit {
-		return false, 0, err
+	g.client, err = createGeminiClient(g.providerOptions)
+	if err != nil {
+		return false, fmt.Errorf("failed to create Gemini client after API key refresh: %w", err)
 	}
-
-	// Calculate backoff with jitter
-	backoffMs := 2000 * (1 << (attempts - 1))
-	jitterMs := int(float64(backoffMs) * 0.2)
-	retryMs := backoffMs + jitterMs
-
-	return true, int64(retryMs), nil
+	return true, nil
 }
 
 func (g *geminiClient) usage(resp *genai.GenerateContentResponse) TokenUsage {
@@ -0,0 +1,274 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync/atomic"
+
+	"github.com/charmbracelet/catwalk/pkg/catwalk"
+	"github.com/google/uuid"
+	"github.com/nom-nom-hub/floss/internal/config"
+	"github.com/nom-nom-hub/floss/internal/llm/tools"
+	"github.com/nom-nom-hub/floss/internal/log"
+	"github.com/nom-nom-hub/floss/internal/message"
+	"github.com/nom-nom-hub/floss/internal/ollama"
+)
+
+type ollamaClient struct {
+	providerOptions providerClientOptions
+	client          *ollama.Client
+	// toolsUnsupported is set once the model refused a request with tools,
+	// after which it is only asked to answer in text
+	toolsUnsupported atomic.Bool
+}
+
+type OllamaClient ProviderClient
+
+func newOllamaClient(opts providerClientOptions) OllamaClient {
+	return &ollamaClient{
+		providerOptions: opts,
+		client:          createOllamaClient(opts),
+	}
+}
+
+func createOllamaClient(opts providerClientOptions) *ollama.Client {
+	httpClient := &http.Client{}
+	if config.Get().Options.Debug {
+		httpClient = log.NewHTTPClient()
+	}
+
+	baseURL := opts.baseURL
+	if resolved, err := config.Get().Resolve(opts.baseURL); err == nil {
+		baseURL = resolved
+	}
+	client := ollama.NewClient(baseURL, httpClient)
+	if opts.apiKey != "" {
+		client.Header.Set("Authorization", "Bearer "+opts.apiKey)
+	}
+	for key, value := range opts.extraHeaders {
+		client.Header.Set(key, value)
+	}
+	return client
+}
+
+func (o *ollamaClient) convertMessages(messages []message.Message) []ollama.Message {
+	systemMessage := o.providerOptions.systemMessage
+	if o.providerOptions.systemPromptPrefix != "" {
+		systemMessage = o.providerOptions.systemPromptPrefix + "\n" + systemMessage
+	}
+	ollamaMessages := []ollama.Message{{Role: "system", Content: systemMessage}}
+
+	for _, msg := range messages {
+		switch msg.Role {
+		case message.User:
+			userMsg := ollama.Message{Role: "user", Content: msg.Content().String()}
+			if o.Model().SupportsImages {
+				for _, binaryContent := range msg.BinaryContent() {
+					userMsg.Images = append(userMsg.Images, binaryContent.Data)
+				}
+			}
+			ollamaMessages = append(ollamaMessages, userMsg)
+
+		case message.Assistant:
+			assistantMsg := ollama.Message{Role: "assistant", Content: msg.Content().String()}
+			// Only include finished tool calls; interrupted tool calls must not be resent.
+			for _, call := range msg.ToolCalls() {
+				if !call.Finished {
+					continue
+				}
+				var arguments map[string]any
+				if err := json.Unmarshal([]byte(call.Input), &arguments); err != nil {
+					slog.Warn("Failed to parse tool call arguments", "tool", call.Name, "error", err)
+				}
+				assistantMsg.ToolCalls = append(assistantMsg.ToolCalls, ollama.ToolCall{
+					Function: ollama.ToolCallFunction{Name: call.Name, Arguments: arguments},
+				})
+			}
+			if assistantMsg.Content == "" && len(assistantMsg.ToolCalls) == 0 {
+				continue
+			}
+			ollamaMessages = append(ollamaMessages, assistantMsg)
+
+		case message.Tool:
+			for _, result := range msg.ToolResults() {
+				ollamaMessages = append(ollamaMessages, ollama.Message{
+					Role:     "tool",
+					Content:  result.Content,
+					ToolName: result.Name,
+				})
+			}
+		}
+	}
+
+	return ollamaMessages
+}
+
+func (o *ollamaClient) convertTools(tools []tools.BaseTool) []ollama.Tool {
+	if o.toolsUnsupported.Load() {
+		return nil
+	}
+	ollamaTools := make([]ollama.Tool, len(tools))
+	for i, tool := range tools {
+		info := tool.Info()
+		ollamaTools[i] = ollama.Tool{
+			Type: "function",
+			Function: ollama.ToolFunction{
+				Name:        info.Name,
+				Description: info.Description,
+				Parameters: map[string]any{
+					"type":       "object",
+					"properties": info.Parameters,
+					"required":   info.Required,
+				},
+			},
+		}
+	}
+	return ollamaTools
+}
+
+func (o *ollamaClient) preparedRequest(messages []message.Message, tools []tools.BaseTool, stream bool) ollama.ChatRequest {
+	model := o.Model()
+	modelConfig := o.providerOptions.modelConfig()
+
+	maxTokens := model.DefaultMaxTokens
+	if modelConfig.MaxTokens > 0 {
+		maxTokens = modelConfig.MaxTokens
+	}
+	// Override max tokens if set in provider options
+	if o.providerOptions.maxTokens > 0 {
+		maxTokens = o.providerOptions.maxTokens
+	}
+
+	request := ollama.ChatRequest{
+		Model:    model.ID,
+		Messages: o.convertMessages(messages),
+		Tools:    o.convertTools(tools),
+		Stream:   stream,
+		Options:  map[string]any{},
+	}
+	// Ollama loads models with a short context unless asked for more, and
+	// silently drops the start of longer conversations
+	if model.ContextWindow > 0 {
+		request.Options["num_ctx"] = model.ContextWindow
+	}
+	if maxTokens > 0 {
+		request.Options["num_predict"] = maxTokens
+	}
+	if model.CanReason {
+		think := modelConfig.Think
+		request.Think = &think
+	}
+	return request
+}
+
+func (o *ollamaClient) finishReason(response ollama.ChatResponse, toolCalls []message.ToolCall) message.FinishReason {
+	switch {
+	case len(toolCalls) > 0:
+		return message.FinishReasonToolUse
+	case response.DoneReason == "length":
+		return message.FinishReasonMaxTokens
+	default:
+		return message.FinishReasonEndTurn
+	}
+}
+
+func (o *ollamaClient) toolCalls(calls []ollama.ToolCall) []message.ToolCall {
+	toolCalls := make([]message.ToolCall, 0, len(calls))
+	for _, call := range calls {
+		input, err := json.Marshal(call.Function.Arguments)
+		if err != nil || call.Function.Arguments == nil {
+			input = []byte("{}")
+		}
+		toolCalls = append(toolCalls, message.ToolCall{
+			// Ollama doesn't identify tool calls
+			ID:       "call_" + uuid.NewString(),
+			Name:     call.Function.Name,
+			Input:    string(input),
+			Type:     "function",
+			Finished: true,
+		})
+	}
+	return toolCalls
+}
+
+func (o *ollamaClient) send(ctx context.Context, messages []message.Message, tools []tools.BaseTool) (*ProviderResponse, error) {
+	request := o.preparedRequest(messages, tools, false)
+	for response, err := range o.client.Chat(ctx, request, o.providerOptions.extraBody) {
+		if err != nil {
+			return nil, err
+		}
+		toolCalls := o.toolCalls(response.Message.ToolCalls)
+		return &ProviderResponse{
+			Content:      response.Message.Content,
+			ToolCalls:    toolCalls,
+			Usage:        TokenUsage{InputTokens: response.PromptEvalCount, OutputTokens: response.EvalCount},
+			FinishReason: o.finishReason(response, toolCalls),
+		}, nil
+	}
+	return nil, errIncompleteStream
+}
+
+func (o *ollamaClient) stream(ctx context.Context, messages []message.Message, tools []tools.BaseTool) <-chan ProviderEvent {
+	request := o.preparedRequest(messages, tools, true)
+	eventChan := make(chan ProviderEvent)
+
+	go func() {
+		defer close(eventChan)
+		var content strings.Builder
+		var toolCalls []message.ToolCall
+		for response, err := range o.client.Chat(ctx, request, o.providerOptions.extraBody) {
+			if err != nil {
+				eventChan <- ProviderEvent{Type: EventError, Error: err}
+				return
+			}
+			if response.Message.Thinking != "" {
+				eventChan <- ProviderEvent{Type: EventThinkingDelta, Thinking: response.Message.Thinking}
+			}
+			if response.Message.Content != "" {
+				eventChan <- ProviderEvent{Type: EventContentDelta, Content: response.Message.Content}
+				content.WriteString(response.Message.Content)
+			}
+			// Tool calls come whole, each in one chunk
+			for _, call := range o.toolCalls(response.Message.ToolCalls) {
+				eventChan <- ProviderEvent{
+					Type:     EventToolUseStart,
+					ToolCall: &message.ToolCall{ID: call.ID, Name: call.Name},
+				}
+				eventChan <- ProviderEvent{Type: EventToolUseStop, ToolCall: &call}
+				toolCalls = append(toolCalls, call)
+			}
+			if response.Done {
+				eventChan <- ProviderEvent{
+					Type: EventComplete,
+					Response: &ProviderResponse{
+						Content:      content.String(),
+						ToolCalls:    toolCalls,
+						Usage:        TokenUsage{InputTokens: response.PromptEvalCount, OutputTokens: response.EvalCount},
+						FinishReason: o.finishReason(response, toolCalls),
+					},
+				}
+				return
+			}
+		}
+		eventChan <- ProviderEvent{Type: EventError, Error: errIncompleteStream}
+	}()
+
+	return eventChan
+}
+
+// recoverFrom drops the tools of the requests to a model that doesn't
+// support tool calling, so that it can still answer in text
+func (o *ollamaClient) recoverFrom(err error) (bool, error) {
+	if !ollama.IsToolsUnsupported(err) || o.toolsUnsupported.Swap(true) {
+		return false, nil
+	}
+	slog.Warn("Model doesn't support tools, continuing without them", "model", o.Model().ID)
+	return true, nil
+}
+
+func (o *ollamaClient) Model() catwalk.Model {
+	return o.providerOptions.model(o.providerOptions.modelType)
+}
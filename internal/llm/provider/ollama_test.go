@@ -0,0 +1,115 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/charmbracelet/catwalk/pkg/catwalk"
+	"github.com/nom-nom-hub/floss/internal/config"
+	"github.com/nom-nom-hub/floss/internal/llm/tools"
+	"github.com/nom-nom-hub/floss/internal/message"
+	"github.com/nom-nom-hub/floss/internal/ollama"
+	"github.com/stretchr/testify/require"
+)
+
+// newOllamaTestClient serves the handler as the Ollama API and records the
+// chat requests it was sent
+func newOllamaTestClient(t *testing.T, handler func(w http.ResponseWriter, request ollama.ChatRequest)) (*retryClient, *[]ollama.ChatRequest) {
+	t.Helper()
+	var requests []ollama.ChatRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request ollama.ChatRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+		requests = append(requests, request)
+		handler(w, request)
+	}))
+	t.Cleanup(server.Close)
+
+	opts := providerClientOptions{
+		baseURL:       server.URL,
+		modelType:     config.SelectedModelTypeLarge,
+		selectedModel: &config.SelectedModel{},
+		systemMessage: "test",
+		model: func(config.SelectedModelType) catwalk.Model {
+			return catwalk.Model{ID: "qwen3:8b", Name: "qwen3:8b", ContextWindow: 40960, DefaultMaxTokens: 10240}
+		},
+	}
+	return newRetryClient(&ollamaClient{providerOptions: opts, client: ollama.NewClient(opts.baseURL, &http.Client{})}), &requests
+}
+
+// namedTool is a tool that only has a name
+type namedTool string
+
+func (t namedTool) Info() tools.ToolInfo { return tools.ToolInfo{Name: string(t)} }
+func (t namedTool) Name() string         { return string(t) }
+func (t namedTool) Run(context.Context, tools.ToolCall) (tools.ToolResponse, error) {
+	return tools.ToolResponse{}, nil
+}
+
+func TestOllamaClientStreamToolCall(t *testing.T) {
+	t.Parallel()
+
+	client, requests := newOllamaTestClient(t, func(w http.ResponseWriter, _ ollama.ChatRequest) {
+		w.Write([]byte(`{"message":{"role":"assistant","content":"","tool_calls":[{"function":{"name":"view","arguments":{"file_path":"main.go"}}}]},"done":false}` + "\n" +
+			`{"message":{"role":"assistant","content":""},"done":true,"done_reason":"stop","prompt_eval_count":12,"eval_count":5}` + "\n"))
+	})
+
+	var started, stopped int
+	var complete *ProviderResponse
+	for event := range client.stream(t.Context(), retryTestMessages, []tools.BaseTool{namedTool("view")}) {
+		require.NoError(t, event.Error)
+		switch event.Type {
+		case EventToolUseStart:
+			started++
+		case EventToolUseStop:
+			stopped++
+		case EventComplete:
+			complete = event.Response
+		}
+	}
+	require.Equal(t, 1, started)
+	require.Equal(t, 1, stopped)
+	require.NotNil(t, complete)
+	require.Equal(t, message.FinishReasonToolUse, complete.FinishReason)
+	require.Len(t, complete.ToolCalls, 1)
+	require.Equal(t, "view", complete.ToolCalls[0].Name)
+	require.JSONEq(t, `{"file_path":"main.go"}`, complete.ToolCalls[0].Input)
+	require.Equal(t, TokenUsage{InputTokens: 12, OutputTokens: 5}, complete.Usage)
+
+	require.Len(t, *requests, 1)
+	request := (*requests)[0]
+	require.Len(t, request.Tools, 1)
+	require.Equal(t, "system", request.Messages[0].Role)
+	require.EqualValues(t, 40960, request.Options["num_ctx"])
+	require.EqualValues(t, 10240, request.Options["num_predict"])
+	require.Nil(t, request.Think)
+}
+
+func TestOllamaClientWithoutToolSupport(t *testing.T) {
+	t.Parallel()
+
+	client, requests := newOllamaTestClient(t, func(w http.ResponseWriter, request ollama.ChatRequest) {
+		if len(request.Tools) > 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":"registry.ollama.ai/library/gemma2:2b does not support tools"}`))
+			return
+		}
+		w.Write([]byte(`{"message":{"role":"assistant","content":"hello"},"done":true,"done_reason":"stop"}` + "\n"))
+	})
+
+	response, err := client.send(t.Context(), retryTestMessages, []tools.BaseTool{namedTool("view")})
+	require.NoError(t, err)
+	require.Equal(t, "hello", response.Content)
+	require.Len(t, *requests, 2)
+	require.NotEmpty(t, (*requests)[0].Tools)
+	require.Empty(t, (*requests)[1].Tools)
+
+	// Later requests leave the tools out right away
+	_, err = client.send(t.Context(), retryTestMessages, []tools.BaseTool{namedTool("view")})
+	require.NoError(t, err)
+	require.Len(t, *requests, 3)
+	require.Empty(t, (*requests)[2].Tools)
+}
@@ -17,12 +17,17 @@ import (
 )
 
 func TestMain(m *testing.M) {
-	_, err := config.Init(".", "", true)
+	dir, err := os.MkdirTemp("", "floss-provider")
 	if err != nil {
+		panic(err)
+	}
+	if _, err := config.Init(dir, "", true); err != nil {
 		panic("Failed to initialize config: " + err.Error())
 	}
 
-	os.Exit(m.Run())
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
 }
 
 func TestOpenAIClientStreamChoices(t *testing.T) {
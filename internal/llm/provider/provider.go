@@ -3,6 +3,7 @@ package provider
 import (
 	"context"
 	"fmt"
+	"log/slog"
 
 	"github.com/charmbracelet/catwalk/pkg/catwalk"
 
@@ -41,6 +42,11 @@ type ProviderResponse struct {
 	ToolCalls    []message.ToolCall
 	Usage        TokenUsage
 	FinishReason message.FinishReason
+
+	// ProviderID and Model are set when a fallback model answered instead
+	// of the configured one
+	ProviderID string
+	Model      *catwalk.Model
 }
 
 type ProviderEvent struct {
@@ -52,6 +58,11 @@ type ProviderEvent struct {
 	Response  *ProviderResponse
 	ToolCall  *message.ToolCall
 	Error     error
+
+	// Restart is set on the warning announcing a retry after part of the
+	// response was already streamed. The retry streams the response again
+	// from the start.
+	Restart bool
 }
 type Provider interface {
 	SendMessages(ctx context.Context, messages []message.Message, tools []tools.BaseTool) (*ProviderResponse, error)
@@ -62,11 +73,14 @@ type Provider interface {
 }
 
 type providerClientOptions struct {
-	baseURL            string
-	config             config.ProviderConfig
-	apiKey             string
-	modelType          config.SelectedModelType
-	model              func(config.SelectedModelType) catwalk.Model
+	baseURL   string
+	config    config.ProviderConfig
+	apiKey    string
+	modelType config.SelectedModelType
+	model     func(config.SelectedModelType) catwalk.Model
+	// selectedModel, when set, replaces the settings of the configured
+	// model of modelType, as for fallback models
+	selectedModel      *config.SelectedModel
 	disableCache       bool
 	systemMessage      string
 	systemPromptPrefix string
@@ -74,10 +88,26 @@ type providerClientOptions struct {
 	extraHeaders       map[string]string
 	extraBody          map[string]any
 	extraParams        map[string]string
+	// systemPrompt, when set, builds systemMessage for the provider, as
+	// fallback models may use other providers than the model
+	systemPrompt func(providerID string) string
 }
 
 type ProviderClientOption func(*providerClientOptions)
 
+// modelConfig returns the settings, such as max tokens, of the model the
+// client uses
+func (o providerClientOptions) modelConfig() config.SelectedModel {
+	if o.selectedModel != nil {
+		return *o.selectedModel
+	}
+	cfg := config.Get()
+	if o.modelType == config.SelectedModelTypeSmall {
+		return cfg.Models[config.SelectedModelTypeSmall]
+	}
+	return cfg.Models[config.SelectedModelTypeLarge]
+}
+
 type ProviderClient interface {
 	send(ctx context.Context, messages []message.Message, tools []tools.BaseTool) (*ProviderResponse, error)
 	stream(ctx context.Context, messages []message.Message, tools []tools.BaseTool) <-chan ProviderEvent
@@ -133,18 +163,72 @@ func WithSystemMessage(systemMessage string) ProviderClientOption {
 	}
 }
 
+// WithSystemPrompt builds the system message for the provider of the model
+// and of each of its fallback models
+func WithSystemPrompt(systemPrompt func(providerID string) string) ProviderClientOption {
+	return func(options *providerClientOptions) {
+		options.systemPrompt = systemPrompt
+	}
+}
+
 func WithMaxTokens(maxTokens int64) ProviderClientOption {
 	return func(options *providerClientOptions) {
 		options.maxTokens = maxTokens
 	}
 }
 
+// NewProvider creates the provider for the model of the WithModel type. When
+// fallback models are configured for that type, the provider falls back to
+// them in order if the model fails.
 func NewProvider(cfg config.ProviderConfig, opts ...ProviderClientOption) (Provider, error) {
 	restore := config.PushPopCrushEnv()
 	defer restore()
+	clientOptions, err := newClientOptions(cfg, opts...)
+	if err != nil {
+		return nil, err
+	}
+	primary, err := newProvider(clientOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	selected := clientOptions.modelConfig()
+	if len(selected.Fallbacks) == 0 {
+		return primary, nil
+	}
+	chain := &fallbackProvider{models: []fallbackModel{{provider: primary, providerID: cfg.ID}}}
+	for _, fallback := range selected.Fallbacks {
+		providerCfg, ok := config.Get().Providers.Get(fallback.Provider)
+		if !ok {
+			slog.Warn("Skipping fallback model of unknown provider", "provider", fallback.Provider, "model", fallback.Model)
+			continue
+		}
+		fallbackOptions, err := newClientOptions(providerCfg, opts...)
+		if err != nil {
+			slog.Warn("Skipping fallback model", "provider", fallback.Provider, "model", fallback.Model, "error", err)
+			continue
+		}
+		fallbackOptions.selectedModel = &fallback
+		fallbackOptions.model = func(config.SelectedModelType) catwalk.Model {
+			if model := config.Get().GetModel(fallback.Provider, fallback.Model); model != nil {
+				return *model
+			}
+			return catwalk.Model{ID: fallback.Model}
+		}
+		provider, err := newProvider(fallbackOptions)
+		if err != nil {
+			slog.Warn("Skipping fallback model", "provider", fallback.Provider, "model", fallback.Model, "error", err)
+			continue
+		}
+		chain.models = append(chain.models, fallbackModel{provider: provider, providerID: providerCfg.ID})
+	}
+	return chain, nil
+}
+
+func newClientOptions(cfg config.ProviderConfig, opts ...ProviderClientOption) (providerClientOptions, error) {
 	resolvedAPIKey, err := config.Get().Resolve(cfg.APIKey)
 	if err != nil {
-		return nil, fmt.Errorf("failed to resolve API key for provider %s: %w", cfg.ID, err)
+		return providerClientOptions{}, fmt.Errorf("failed to resolve API key for provider %s: %w", cfg.ID, err)
 	}
 
 	// Resolve extra headers
@@ -152,7 +236,7 @@ func NewProvider(cfg config.ProviderConfig, opts ...ProviderClientOption) (Provi
 	for key, value := range cfg.ExtraHeaders {
 		resolvedValue, err := config.Get().Resolve(value)
 		if err != nil {
-			return nil, fmt.Errorf("failed to resolve extra header %s for provider %s: %w", key, cfg.ID, err)
+			return providerClientOptions{}, fmt.Errorf("failed to resolve extra header %s for provider %s: %w", key, cfg.ID, err)
 		}
 		resolvedExtraHeaders[key] = resolvedValue
 	}
@@ -172,42 +256,64 @@ func NewProvider(cfg config.ProviderConfig, opts ...ProviderClientOption) (Provi
 	for _, o := range opts {
 		o(&clientOptions)
 	}
-	switch cfg.Type {
+	if clientOptions.systemPrompt != nil {
+		clientOptions.systemMessage = clientOptions.systemPrompt(cfg.ID)
+	}
+	return clientOptions, nil
+}
+
+func newProvider(clientOptions providerClientOptions) (Provider, error) {
+	switch clientOptions.config.Type {
 	case catwalk.TypeAnthropic:
 		return &baseProvider[AnthropicClient]{
 			options: clientOptions,
-			client:  newAnthropicClient(clientOptions, AnthropicClientTypeNormal),
+			client:  newRetryClient(newAnthropicClient(clientOptions, AnthropicClientTypeNormal)),
 		}, nil
 	case catwalk.TypeOpenAI:
 		return &baseProvider[OpenAIClient]{
 			options: clientOptions,
-			client:  newOpenAIClient(clientOptions),
+			client:  newRetryClient(newOpenAIClient(clientOptions)),
 		}, nil
 	case catwalk.TypeGemini:
 		return &baseProvider[GeminiClient]{
 			options: clientOptions,
-			client:  newGeminiClient(clientOptions),
+			client:  newRetryClient(newGeminiClient(clientOptions)),
 		}, nil
 	case catwalk.TypeBedrock:
 		return &baseProvider[BedrockClient]{
 			options: clientOptions,
-			client:  newBedrockClient(clientOptions),
+			client:  newRetryClient(newBedrockClient(clientOptions)),
 		}, nil
 	case catwalk.TypeAzure:
 		return &baseProvider[AzureClient]{
 			options: clientOptions,
-			client:  newAzureClient(clientOptions),
+			client:  newRetryClient(newAzureClient(clientOptions)),
 		}, nil
 	case catwalk.TypeVertexAI:
 		return &baseProvider[VertexAIClient]{
 			options: clientOptions,
-			client:  newVertexAIClient(clientOptions),
+			client:  newRetryClient(newVertexAIClient(clientOptions)),
 		}, nil
 	case "qwen":
 		return &baseProvider[QwenClient]{
 			options: clientOptions,
-			client:  newQwenClient(clientOptions),
+			client:  newRetryClient(newQwenClient(clientOptions)),
+		}, nil
+	case config.ProviderTypeOllama:
+		return &baseProvider[OllamaClient]{
+			options: clientOptions,
+			client:  newRetryClient(newOllamaClient(clientOptions)),
 		}, nil
+	case config.ProviderTypeReplay:
+		path, err := config.Get().Resolve(clientOptions.baseURL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve fixture path: %w", err)
+		}
+		fixture, err := LoadFixture(path)
+		if err != nil {
+			return nil, err
+		}
+		return NewReplayProvider(fixture, clientOptions.model(clientOptions.modelType)), nil
 	}
-	return nil, fmt.Errorf("provider not supported: %s", cfg.Type)
+	return nil, fmt.Errorf("provider not supported: %s", clientOptions.config.Type)
 }
@@ -106,6 +106,18 @@ type tokenResponse struct {
 	ResourceURL  string `json:"resource_url,omitempty"`
 }
 
+// qwenAPIError is the error of a request the Qwen API answered with a status
+// other than 200 OK
+type qwenAPIError struct {
+	StatusCode int
+	Header     http.Header
+	Body       string
+}
+
+func (e *qwenAPIError) Error() string {
+	return fmt.Sprintf("API request failed with status %d: %s", e.StatusCode, e.Body)
+}
+
 func (q *qwenClient) convertMessages(messages []message.Message) []QwenMessage {
 	var qwenMessages []QwenMessage
 
@@ -184,12 +196,7 @@ func (q *qwenClient) finishReason(reason string) message.FinishReason {
 
 func (q *qwenClient) preparedParams(messages []QwenMessage, tools []QwenTool) QwenChatCompletionRequest {
 	model := q.providerOptions.model(q.providerOptions.modelType)
-	cfg := config.Get()
-
-	modelConfig := cfg.Models[config.SelectedModelTypeLarge]
-	if q.providerOptions.modelType == config.SelectedModelTypeSmall {
-		modelConfig = cfg.Models[config.SelectedModelTypeSmall]
-	}
+	modelConfig := q.providerOptions.modelConfig()
 
 	maxTokens := model.DefaultMaxTokens
 	if modelConfig.MaxTokens > 0 {
@@ -368,7 +375,7 @@ func (q *qwenClient) send(ctx context.Context, messages []message.Message, tools
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(body))
+		return nil, &qwenAPIError{StatusCode: resp.StatusCode, Header: resp.Header, Body: string(body)}
 	}
 
 	var qwenResponse QwenChatCompletionResponse
@@ -496,7 +503,7 @@ func (q *qwenClient) stream(ctx context.Context, messages []message.Message, too
 			body, _ := io.ReadAll(resp.Body)
 			eventChan <- ProviderEvent{
 				Type:  EventError,
-				Error: fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(body)),
+				Error: &qwenAPIError{StatusCode: resp.StatusCode, Header: resp.Header, Body: string(body)},
 			}
 			return
 		}
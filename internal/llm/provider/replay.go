@@ -0,0 +1,221 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/charmbracelet/catwalk/pkg/catwalk"
+
+	"github.com/nom-nom-hub/floss/internal/llm/tools"
+	"github.com/nom-nom-hub/floss/internal/message"
+)
+
+// Fixture holds the responses a provider streamed, one turn per request, so
+// that they can be replayed without the provider
+type Fixture struct {
+	Model catwalk.Model `json:"model"`
+	Turns []FixtureTurn `json:"turns"`
+}
+
+// FixtureTurn is the response to one request
+type FixtureTurn struct {
+	Events []FixtureEvent `json:"events"`
+}
+
+// FixtureEvent is a ProviderEvent with its error kept as text
+type FixtureEvent struct {
+	Type      EventType         `json:"type"`
+	Content   string            `json:"content,omitempty"`
+	Thinking  string            `json:"thinking,omitempty"`
+	Signature string            `json:"signature,omitempty"`
+	Response  *ProviderResponse `json:"response,omitempty"`
+	ToolCall  *message.ToolCall `json:"tool_call,omitempty"`
+	Error     string            `json:"error,omitempty"`
+	Restart   bool              `json:"restart,omitempty"`
+}
+
+func newFixtureEvent(event ProviderEvent) FixtureEvent {
+	fixtureEvent := FixtureEvent{
+		Type:      event.Type,
+		Content:   event.Content,
+		Thinking:  event.Thinking,
+		Signature: event.Signature,
+		Response:  event.Response,
+		ToolCall:  event.ToolCall,
+		Restart:   event.Restart,
+	}
+	if event.Error != nil {
+		fixtureEvent.Error = event.Error.Error()
+	}
+	return fixtureEvent
+}
+
+func (e FixtureEvent) providerEvent() ProviderEvent {
+	event := ProviderEvent{
+		Type:      e.Type,
+		Content:   e.Content,
+		Thinking:  e.Thinking,
+		Signature: e.Signature,
+		Response:  e.Response,
+		ToolCall:  e.ToolCall,
+		Restart:   e.Restart,
+	}
+	if e.Error != "" {
+		event.Error = errors.New(e.Error)
+	}
+	return event
+}
+
+// LoadFixture reads the fixture at path
+func LoadFixture(path string) (*Fixture, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read fixture: %w", err)
+	}
+	var fixture Fixture
+	if err := json.Unmarshal(data, &fixture); err != nil {
+		return nil, fmt.Errorf("failed to parse fixture %s: %w", path, err)
+	}
+	return &fixture, nil
+}
+
+// Save writes the fixture to path, creating its directory
+func (f *Fixture) Save(path string) error {
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal fixture: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create fixture directory: %w", err)
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+// replayProvider answers each request with the next turn of its fixture,
+// whatever the request
+type replayProvider struct {
+	model catwalk.Model
+
+	mu    sync.Mutex
+	turns []FixtureTurn
+}
+
+// NewReplayProvider returns a provider that replays the turns of fixture in
+// order as model, or as the recorded model when model has no ID
+func NewReplayProvider(fixture *Fixture, model catwalk.Model) Provider {
+	if model.ID == "" {
+		model = fixture.Model
+	}
+	return &replayProvider{model: model, turns: fixture.Turns}
+}
+
+func (p *replayProvider) next() (FixtureTurn, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.turns) == 0 {
+		return FixtureTurn{}, errors.New("replay fixture has no more turns")
+	}
+	turn := p.turns[0]
+	p.turns = p.turns[1:]
+	return turn, nil
+}
+
+func (p *replayProvider) SendMessages(_ context.Context, _ []message.Message, _ []tools.BaseTool) (*ProviderResponse, error) {
+	turn, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	for _, event := range turn.Events {
+		switch event.Type {
+		case EventError:
+			return nil, event.providerEvent().Error
+		case EventComplete:
+			return event.Response, nil
+		}
+	}
+	return nil, errIncompleteStream
+}
+
+func (p *replayProvider) StreamResponse(ctx context.Context, _ []message.Message, _ []tools.BaseTool) <-chan ProviderEvent {
+	eventChan := make(chan ProviderEvent)
+	go func() {
+		defer close(eventChan)
+		turn, err := p.next()
+		if err != nil {
+			eventChan <- ProviderEvent{Type: EventError, Error: err}
+			return
+		}
+		for _, event := range turn.Events {
+			select {
+			case <-ctx.Done():
+				eventChan <- ProviderEvent{Type: EventError, Error: ctx.Err()}
+				return
+			case eventChan <- event.providerEvent():
+			}
+		}
+	}()
+	return eventChan
+}
+
+func (p *replayProvider) Model() catwalk.Model {
+	return p.model
+}
+
+// recordingProvider saves the responses of its provider to a fixture file
+// after each request
+type recordingProvider struct {
+	provider Provider
+	path     string
+
+	mu      sync.Mutex
+	fixture Fixture
+}
+
+// NewRecordingProvider returns a provider that answers with p and records
+// its responses to the fixture at path, for NewReplayProvider to replay
+func NewRecordingProvider(p Provider, path string) Provider {
+	return &recordingProvider{provider: p, path: path, fixture: Fixture{Model: p.Model()}}
+}
+
+func (p *recordingProvider) record(events []FixtureEvent) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.fixture.Turns = append(p.fixture.Turns, FixtureTurn{Events: events})
+	if err := p.fixture.Save(p.path); err != nil {
+		slog.Error("Failed to save provider recording", "path", p.path, "error", err)
+	}
+}
+
+func (p *recordingProvider) SendMessages(ctx context.Context, messages []message.Message, tools []tools.BaseTool) (*ProviderResponse, error) {
+	response, err := p.provider.SendMessages(ctx, messages, tools)
+	if err != nil {
+		p.record([]FixtureEvent{newFixtureEvent(ProviderEvent{Type: EventError, Error: err})})
+		return nil, err
+	}
+	p.record([]FixtureEvent{newFixtureEvent(ProviderEvent{Type: EventComplete, Response: response})})
+	return response, nil
+}
+
+func (p *recordingProvider) StreamResponse(ctx context.Context, messages []message.Message, tools []tools.BaseTool) <-chan ProviderEvent {
+	eventChan := make(chan ProviderEvent)
+	go func() {
+		defer close(eventChan)
+		var events []FixtureEvent
+		for event := range p.provider.StreamResponse(ctx, messages, tools) {
+			events = append(events, newFixtureEvent(event))
+			eventChan <- event
+		}
+		p.record(events)
+	}()
+	return eventChan
+}
+
+func (p *recordingProvider) Model() catwalk.Model {
+	return p.provider.Model()
+}
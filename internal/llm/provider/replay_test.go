@@ -0,0 +1,51 @@
+package provider
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/charmbracelet/catwalk/pkg/catwalk"
+	"github.com/nom-nom-hub/floss/internal/message"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordAndReplay(t *testing.T) {
+	t.Parallel()
+
+	call := message.ToolCall{ID: "call_1", Name: "view", Input: `{"file_path":"main.go"}`, Finished: true}
+	recorded := &scriptedProvider{
+		model: catwalk.Model{ID: "recorded", Name: "Recorded"},
+		events: []ProviderEvent{
+			{Type: EventThinkingDelta, Thinking: "Look at main.go"},
+			{Type: EventToolUseStart, ToolCall: &message.ToolCall{ID: call.ID, Name: call.Name}},
+			{Type: EventToolUseStop, ToolCall: &call},
+			{Type: EventComplete, Response: &ProviderResponse{
+				ToolCalls:    []message.ToolCall{call},
+				Usage:        TokenUsage{InputTokens: 10, OutputTokens: 5},
+				FinishReason: message.FinishReasonToolUse,
+			}},
+		},
+	}
+	path := filepath.Join(t.TempDir(), "recordings", "fixture.json")
+	recording := NewRecordingProvider(recorded, path)
+	want := collect(recording.StreamResponse(t.Context(), nil, nil))
+	recorded.events = []ProviderEvent{{Type: EventError, Error: errors.New("overloaded")}}
+	_, err := recording.SendMessages(t.Context(), nil, nil)
+	require.EqualError(t, err, "overloaded")
+
+	fixture, err := LoadFixture(path)
+	require.NoError(t, err)
+	require.Equal(t, "recorded", fixture.Model.ID)
+	require.Len(t, fixture.Turns, 2)
+
+	replay := NewReplayProvider(fixture, catwalk.Model{})
+	require.Equal(t, "recorded", replay.Model().ID)
+	require.Equal(t, want, collect(replay.StreamResponse(t.Context(), nil, nil)))
+	_, err = replay.SendMessages(t.Context(), nil, nil)
+	require.EqualError(t, err, "overloaded")
+
+	events := collect(replay.StreamResponse(t.Context(), nil, nil))
+	require.Len(t, events, 1)
+	require.EqualError(t, events[0].Error, "replay fixture has no more turns")
+}
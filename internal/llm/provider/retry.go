@@ -0,0 +1,274 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/charmbracelet/catwalk/pkg/catwalk"
+	"github.com/openai/openai-go"
+	"google.golang.org/genai"
+
+	"github.com/nom-nom-hub/floss/internal/llm/tools"
+	"github.com/nom-nom-hub/floss/internal/message"
+	"github.com/nom-nom-hub/floss/internal/ollama"
+)
+
+// maxRetryAfter caps how long a provider can make a request wait before it
+// is retried, so a huge Retry-After doesn't stall the agent
+const maxRetryAfter = 2 * time.Minute
+
+// errIncompleteStream is the error of a stream that ended before the
+// provider finished the response
+var errIncompleteStream = errors.New("stream ended before the response was complete")
+
+// retryClient sends the requests of a provider client again when they fail
+// with a transient error: rate limits, overloaded or unavailable servers,
+// dropped connections and streams cut off mid-response. It waits as long as
+// the provider asks with Retry-After, up to maxRetryAfter, or backs off
+// exponentially.
+type retryClient struct {
+	client     ProviderClient
+	maxRetries int
+	// backoff is the delay before the given retry when the provider didn't
+	// say how long to wait
+	backoff func(retry int) time.Duration
+}
+
+// recoveringClient is implemented by provider clients that can fix some
+// failed requests themselves, such as by refreshing an expired API key.
+// recoverFrom reports whether the request should be sent again right away.
+type recoveringClient interface {
+	recoverFrom(err error) (bool, error)
+}
+
+func newRetryClient(client ProviderClient) *retryClient {
+	return &retryClient{
+		client:     client,
+		maxRetries: maxRetries,
+		backoff:    exponentialBackoff,
+	}
+}
+
+// exponentialBackoff waits 2.4s before the first retry and twice as long
+// before each next one
+func exponentialBackoff(retry int) time.Duration {
+	backoff := 2 * time.Second << (retry - 1)
+	return backoff + backoff/5
+}
+
+func (r *retryClient) send(ctx context.Context, messages []message.Message, tools []tools.BaseTool) (*ProviderResponse, error) {
+	for attempt := 1; ; attempt++ {
+		response, err := r.client.send(ctx, messages, tools)
+		if err == nil {
+			return response, nil
+		}
+		delay, err := r.retryDelay(ctx, attempt, err)
+		if err != nil {
+			return nil, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+// stream forwards the events of each attempt. When an attempt fails after
+// it streamed part of the response, the warning announcing the retry has
+// Restart set, so the partial response can be dropped.
+func (r *retryClient) stream(ctx context.Context, messages []message.Message, tools []tools.BaseTool) <-chan ProviderEvent {
+	eventChan := make(chan ProviderEvent)
+	go func() {
+		defer close(eventChan)
+		for attempt := 1; ; attempt++ {
+			streamed, completed := false, false
+			var failure error
+			for event := range r.client.stream(ctx, messages, tools) {
+				switch event.Type {
+				case EventError:
+					failure = event.Error
+					continue
+				case EventComplete:
+					completed = true
+				case EventWarning, EventContentStart:
+				default:
+					streamed = true
+				}
+				eventChan <- event
+			}
+			if failure == nil && !completed {
+				failure = errIncompleteStream
+				if ctx.Err() != nil {
+					failure = ctx.Err()
+				}
+			}
+			if failure == nil {
+				return
+			}
+
+			delay, err := r.retryDelay(ctx, attempt, failure)
+			if err != nil {
+				eventChan <- ProviderEvent{Type: EventError, Error: err}
+				return
+			}
+			if delay > 0 || streamed {
+				eventChan <- ProviderEvent{
+					Type:    EventWarning,
+					Content: fmt.Sprintf("%s: %s, retrying in %s (%d/%d)", r.client.Model().Name, describeError(failure), delay.Round(time.Second), attempt, r.maxRetries),
+					Restart: streamed,
+				}
+			}
+			select {
+			case <-ctx.Done():
+				eventChan <- ProviderEvent{Type: EventError, Error: ctx.Err()}
+				return
+			case <-time.After(delay):
+			}
+		}
+	}()
+	return eventChan
+}
+
+func (r *retryClient) Model() catwalk.Model {
+	return r.client.Model()
+}
+
+// retryDelay returns how long to wait before sending a request that failed
+// with err again, or the error to give up with
+func (r *retryClient) retryDelay(ctx context.Context, attempt int, err error) (time.Duration, error) {
+	if ctx.Err() != nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return 0, err
+	}
+
+	var delay time.Duration
+	var recovered bool
+	var recoverErr error
+	if recovering, ok := r.client.(recoveringClient); ok {
+		recovered, recoverErr = recovering.recoverFrom(err)
+	}
+	switch {
+	case recoverErr != nil:
+		return 0, recoverErr
+	case recovered:
+	case isTransient(err):
+		if after, ok := retryAfter(err); ok {
+			delay = after
+		} else {
+			delay = r.backoff(attempt)
+		}
+	default:
+		return 0, err
+	}
+
+	if attempt > r.maxRetries {
+		return 0, fmt.Errorf("maximum retry attempts reached: %d retries: %w", r.maxRetries, err)
+	}
+	slog.Warn("Retrying provider request", "model", r.client.Model().ID, "attempt", attempt, "max_retries", r.maxRetries, "delay", delay, "error", err)
+	return delay, nil
+}
+
+// errorStatus returns the HTTP status and headers of the failed response
+// behind err, or 0 when the request failed before a response came
+func errorStatus(err error) (int, http.Header) {
+	var anthropicErr *anthropic.Error
+	var openaiErr *openai.Error
+	var geminiErr genai.APIError
+	var qwenErr *qwenAPIError
+	var ollamaErr *ollama.Error
+	switch {
+	case errors.As(err, &anthropicErr):
+		return anthropicErr.StatusCode, responseHeader(anthropicErr.Response)
+	case errors.As(err, &openaiErr):
+		return openaiErr.StatusCode, responseHeader(openaiErr.Response)
+	case errors.As(err, &geminiErr):
+		return geminiErr.Code, nil
+	case errors.As(err, &qwenErr):
+		return qwenErr.StatusCode, qwenErr.Header
+	case errors.As(err, &ollamaErr):
+		return ollamaErr.StatusCode, ollamaErr.Header
+	}
+	return 0, nil
+}
+
+func responseHeader(response *http.Response) http.Header {
+	if response == nil {
+		return nil
+	}
+	return response.Header
+}
+
+// isTransient reports whether a request that failed with err may succeed
+// when it is sent again
+func isTransient(err error) bool {
+	status, _ := errorStatus(err)
+	switch {
+	case status == http.StatusRequestTimeout, status == http.StatusTooManyRequests, status >= 500:
+		return true
+	case errors.Is(err, errIncompleteStream), errors.Is(err, io.ErrUnexpectedEOF), errors.Is(err, syscall.ECONNRESET), errors.Is(err, syscall.EPIPE):
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	// Errors sent within a stream, and those of providers without an error
+	// type, only have a message
+	return contains(err.Error(), "overloaded", "rate limit", "quota exceeded", "too many requests", "connection reset", "unexpected eof", "stream error")
+}
+
+// retryAfter returns how long to wait before retrying as the provider asked,
+// at most maxRetryAfter
+func retryAfter(err error) (time.Duration, bool) {
+	delay, ok := requestedDelay(err)
+	return min(delay, maxRetryAfter), ok
+}
+
+// requestedDelay returns how long the provider asked to wait before retrying
+func requestedDelay(err error) (time.Duration, bool) {
+	// Gemini sends the delay in the details of the error
+	var geminiErr genai.APIError
+	if errors.As(err, &geminiErr) {
+		for _, detail := range geminiErr.Details {
+			if delay, ok := detail["retryDelay"].(string); ok {
+				if d, err := time.ParseDuration(delay); err == nil {
+					return d, true
+				}
+			}
+		}
+		return 0, false
+	}
+
+	_, header := errorStatus(err)
+	if header == nil {
+		return 0, false
+	}
+	if ms, err := strconv.ParseFloat(header.Get("Retry-After-Ms"), 64); err == nil && ms >= 0 {
+		return time.Duration(ms * float64(time.Millisecond)), true
+	}
+	value := header.Get("Retry-After")
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds >= 0 {
+		return time.Duration(seconds * float64(time.Second)), true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return max(time.Until(date), 0), true
+	}
+	return 0, false
+}
+
+// describeError shortens err for the retry warning
+func describeError(err error) string {
+	if status, _ := errorStatus(err); status != 0 {
+		return fmt.Sprintf("%d %s", status, http.StatusText(status))
+	}
+	return err.Error()
+}
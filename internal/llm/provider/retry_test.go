@@ -0,0 +1,450 @@
+package provider
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/anthropics/anthropic-sdk-go/bedrock"
+	anthropicoption "github.com/anthropics/anthropic-sdk-go/option"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/protocol/eventstream"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/charmbracelet/catwalk/pkg/catwalk"
+	"github.com/nom-nom-hub/floss/internal/config"
+	"github.com/nom-nom-hub/floss/internal/message"
+	"github.com/nom-nom-hub/floss/internal/ollama"
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/azure"
+	"github.com/openai/openai-go/option"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genai"
+)
+
+const (
+	anthropicMessage = `{"id":"msg_1","type":"message","role":"assistant","model":"test-model","content":[{"type":"text","text":"hello"}],"stop_reason":"end_turn","usage":{"input_tokens":1,"output_tokens":1}}`
+	anthropicStart   = "event: message_start\ndata: {\"type\":\"message_start\",\"message\":{\"id\":\"msg_1\",\"type\":\"message\",\"role\":\"assistant\",\"model\":\"test-model\",\"content\":[],\"usage\":{\"input_tokens\":1,\"output_tokens\":0}}}\n\n" +
+		"event: content_block_start\ndata: {\"type\":\"content_block_start\",\"index\":0,\"content_block\":{\"type\":\"text\",\"text\":\"\"}}\n\n" +
+		"event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"text_delta\",\"text\":\"hello\"}}\n\n"
+	anthropicEnd = "event: content_block_stop\ndata: {\"type\":\"content_block_stop\",\"index\":0}\n\n" +
+		"event: message_delta\ndata: {\"type\":\"message_delta\",\"delta\":{\"stop_reason\":\"end_turn\"},\"usage\":{\"output_tokens\":1}}\n\n" +
+		"event: message_stop\ndata: {\"type\":\"message_stop\"}\n\n"
+
+	openaiCompletion = `{"id":"1","object":"chat.completion","created":1,"model":"test-model","choices":[{"index":0,"message":{"role":"assistant","content":"hello"},"finish_reason":"stop"}]}`
+	openaiStart      = "data: {\"id\":\"1\",\"object\":\"chat.completion.chunk\",\"created\":1,\"model\":\"test-model\",\"choices\":[{\"index\":0,\"delta\":{\"content\":\"hello\"}}]}\n\n"
+	openaiEnd        = "data: {\"id\":\"1\",\"object\":\"chat.completion.chunk\",\"created\":1,\"model\":\"test-model\",\"choices\":[{\"index\":0,\"delta\":{},\"finish_reason\":\"stop\"}]}\n\ndata: [DONE]\n\n"
+
+	geminiResponse = `{"candidates":[{"content":{"role":"model","parts":[{"text":"hello"}]},"finishReason":"STOP"}]}`
+	geminiStart    = "data: {\"candidates\":[{\"content\":{\"role\":\"model\",\"parts\":[{\"text\":\"hel\"}]}}]}\n\n"
+	geminiEnd      = "data: {\"candidates\":[{\"content\":{\"role\":\"model\",\"parts\":[{\"text\":\"lo\"}]},\"finishReason\":\"STOP\"}]}\n\n"
+	geminiLimited  = `{"error":{"code":429,"message":"Resource has been exhausted","status":"RESOURCE_EXHAUSTED","details":[{"@type":"type.googleapis.com/google.rpc.RetryInfo","retryDelay":"0.01s"}]}}`
+
+	qwenCompletion = `{"id":"1","object":"chat.completion","created":1,"model":"test-model","choices":[{"index":0,"message":{"role":"assistant","content":"hello"},"finish_reason":"stop"}]}`
+	qwenStart      = "data: {\"id\":\"1\",\"choices\":[{\"index\":0,\"message\":{\"role\":\"assistant\",\"content\":\"hello\"}}]}\n\n"
+	qwenEnd        = "data: [DONE]\n\n"
+
+	ollamaResponse = `{"model":"test-model","message":{"role":"assistant","content":"hello"},"done":true,"done_reason":"stop"}`
+	ollamaStart    = `{"model":"test-model","message":{"role":"assistant","content":"hel"},"done":false}` + "\n"
+	ollamaEnd      = `{"model":"test-model","message":{"role":"assistant","content":"lo"},"done":false}` + "\n" +
+		`{"model":"test-model","message":{"role":"assistant","content":""},"done":true,"done_reason":"stop"}` + "\n"
+)
+
+// retryTestProvider answers like the API of a provider type. The clients are
+// created without the debug logging of the test configuration, which reads
+// whole responses before the client does.
+type retryTestProvider struct {
+	name string
+	// client creates the client of the provider type for the API at baseURL
+	client func(t *testing.T, opts providerClientOptions) ProviderClient
+	// response is the body of a successful request, and start and end the
+	// two halves of a successful stream
+	response   string
+	start, end string
+	// streamType is the content type of streams, text/event-stream when
+	// empty
+	streamType string
+	// rateLimited answers that the client sent too many requests
+	rateLimited http.HandlerFunc
+}
+
+var retryTestProviders = []retryTestProvider{
+	{
+		name: "anthropic",
+		client: func(t *testing.T, opts providerClientOptions) ProviderClient {
+			return &anthropicClient{
+				providerOptions: opts,
+				tp:              AnthropicClientTypeNormal,
+				client: anthropic.NewClient(
+					anthropicoption.WithAPIKey(opts.apiKey),
+					anthropicoption.WithBaseURL(opts.baseURL),
+					anthropicoption.WithMaxRetries(0),
+				),
+			}
+		},
+		response:    anthropicMessage,
+		start:       anthropicStart,
+		end:         anthropicEnd,
+		rateLimited: rateLimited("Retry-After", "0.01", `{"type":"error","error":{"type":"rate_limit_error","message":"Rate limited"}}`),
+	},
+	{
+		name: "openai",
+		client: func(t *testing.T, opts providerClientOptions) ProviderClient {
+			return &openaiClient{
+				providerOptions: opts,
+				client: openai.NewClient(
+					option.WithAPIKey(opts.apiKey),
+					option.WithBaseURL(opts.baseURL),
+					option.WithMaxRetries(0),
+				),
+			}
+		},
+		response:    openaiCompletion,
+		start:       openaiStart,
+		end:         openaiEnd,
+		rateLimited: rateLimited("Retry-After-Ms", "10", `{"error":{"message":"Rate limited","type":"rate_limit_error"}}`),
+	},
+	{
+		name: "bedrock",
+		client: func(t *testing.T, opts providerClientOptions) ProviderClient {
+			child := &anthropicClient{
+				providerOptions: opts,
+				tp:              AnthropicClientTypeBedrock,
+				client: anthropic.NewClient(
+					bedrock.WithConfig(aws.Config{
+						Region:      "us-east-1",
+						Credentials: credentials.NewStaticCredentialsProvider("test-key", "test-secret", ""),
+					}),
+					anthropicoption.WithBaseURL(opts.baseURL),
+					anthropicoption.WithMaxRetries(0),
+				),
+			}
+			return &bedrockClient{providerOptions: opts, childProvider: child}
+		},
+		response:    anthropicMessage,
+		start:       bedrockEvents(anthropicStart),
+		end:         bedrockEvents(anthropicEnd),
+		streamType:  "application/vnd.amazon.eventstream",
+		rateLimited: rateLimited("Retry-After", "0.01", `{"message":"Too many requests, please wait before trying again."}`),
+	},
+	{
+		name: "azure",
+		client: func(t *testing.T, opts providerClientOptions) ProviderClient {
+			return &azureClient{openaiClient: &openaiClient{
+				providerOptions: opts,
+				client: openai.NewClient(
+					azure.WithEndpoint(opts.baseURL, "2025-01-01-preview"),
+					azure.WithAPIKey(opts.apiKey),
+					option.WithMaxRetries(0),
+				),
+			}}
+		},
+		response:    openaiCompletion,
+		start:       openaiStart,
+		end:         openaiEnd,
+		rateLimited: rateLimited("Retry-After", "0.01", `{"error":{"code":"429","message":"Rate limit exceeded"}}`),
+	},
+	{
+		name: "gemini",
+		client: func(t *testing.T, opts providerClientOptions) ProviderClient {
+			client, err := genai.NewClient(t.Context(), &genai.ClientConfig{
+				APIKey:      opts.apiKey,
+				Backend:     genai.BackendGeminiAPI,
+				HTTPOptions: genai.HTTPOptions{BaseURL: opts.baseURL},
+			})
+			require.NoError(t, err)
+			return &geminiClient{providerOptions: opts, client: client}
+		},
+		response:    geminiResponse,
+		start:       geminiStart,
+		end:         geminiEnd,
+		rateLimited: rateLimited("", "", geminiLimited),
+	},
+	{
+		name: "vertexai",
+		client: func(t *testing.T, opts providerClientOptions) ProviderClient {
+			// A client of its own skips looking up Google credentials
+			client, err := genai.NewClient(t.Context(), &genai.ClientConfig{
+				Project:     "test-project",
+				Location:    "us-central1",
+				Backend:     genai.BackendVertexAI,
+				HTTPClient:  &http.Client{},
+				HTTPOptions: genai.HTTPOptions{BaseURL: opts.baseURL},
+			})
+			require.NoError(t, err)
+			return &geminiClient{providerOptions: opts, client: client}
+		},
+		response:    geminiResponse,
+		start:       geminiStart,
+		end:         geminiEnd,
+		rateLimited: rateLimited("", "", geminiLimited),
+	},
+	{
+		name: "qwen",
+		client: func(t *testing.T, opts providerClientOptions) ProviderClient {
+			return &qwenClient{providerOptions: opts, client: &http.Client{}}
+		},
+		response:    qwenCompletion,
+		start:       qwenStart,
+		end:         qwenEnd,
+		rateLimited: rateLimited("", "", "Too many requests"),
+	},
+	{
+		name: "ollama",
+		client: func(t *testing.T, opts providerClientOptions) ProviderClient {
+			return &ollamaClient{providerOptions: opts, client: ollama.NewClient(opts.baseURL, &http.Client{})}
+		},
+		response:    ollamaResponse,
+		start:       ollamaStart,
+		end:         ollamaEnd,
+		rateLimited: rateLimited("Retry-After", "0.01", `{"error":"too many requests"}`),
+	},
+}
+
+// bedrockEvents encodes the server-sent events of an Anthropic stream as the
+// event stream Bedrock answers with
+func bedrockEvents(sse string) string {
+	var buf bytes.Buffer
+	encoder := eventstream.NewEncoder()
+	for line := range strings.SplitSeq(sse, "\n") {
+		data, ok := strings.CutPrefix(line, "data: ")
+		if !ok {
+			continue
+		}
+		payload, _ := json.Marshal(map[string]string{"bytes": base64.StdEncoding.EncodeToString([]byte(data))})
+		var headers eventstream.Headers
+		headers.Set(":message-type", eventstream.StringValue("event"))
+		headers.Set(":event-type", eventstream.StringValue("chunk"))
+		headers.Set(":content-type", eventstream.StringValue("application/json"))
+		if err := encoder.Encode(&buf, eventstream.Message{Headers: headers, Payload: payload}); err != nil {
+			panic(err)
+		}
+	}
+	return buf.String()
+}
+
+func rateLimited(header, value, body string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if header != "" {
+			w.Header().Set(header, value)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write([]byte(body))
+	}
+}
+
+func (p retryTestProvider) answer(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	if strings.Contains(r.URL.Path, "streamGenerateContent") || strings.HasSuffix(r.URL.Path, "invoke-with-response-stream") || strings.Contains(string(body), `"stream":true`) {
+		w.Header().Set("Content-Type", p.contentType())
+		w.Write([]byte(p.start + p.end))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(p.response))
+}
+
+func (p retryTestProvider) contentType() string {
+	if p.streamType == "" {
+		return "text/event-stream"
+	}
+	return p.streamType
+}
+
+// cut streams the first half of the response and drops the connection
+func (p retryTestProvider) cut(w http.ResponseWriter, r *http.Request) {
+	// Unread request data would make the server reset the connection
+	// before the client got the first half
+	io.Copy(io.Discard, r.Body)
+	w.Header().Set("Content-Type", p.contentType())
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(p.start))
+	w.(http.Flusher).Flush()
+	panic(http.ErrAbortHandler)
+}
+
+// newRetryTestClient serves the handlers to the requests in order, the last
+// one to all remaining requests, and counts the requests
+func newRetryTestClient(t *testing.T, p retryTestProvider, handlers ...http.HandlerFunc) (*retryClient, *atomic.Int32) {
+	t.Helper()
+	requests := &atomic.Int32{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(requests.Add(1))
+		handlers[min(n, len(handlers))-1](w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	client := newRetryClient(p.client(t, providerClientOptions{
+		baseURL:       server.URL,
+		apiKey:        "test-key",
+		modelType:     config.SelectedModelTypeLarge,
+		systemMessage: "test",
+		maxTokens:     100,
+		model: func(config.SelectedModelType) catwalk.Model {
+			return catwalk.Model{ID: "test-model", Name: "Test Model", DefaultMaxTokens: 100}
+		},
+	}))
+	client.backoff = func(int) time.Duration { return time.Millisecond }
+	return client, requests
+}
+
+var retryTestMessages = []message.Message{
+	{Role: message.User, Parts: []message.ContentPart{message.TextContent{Text: "Hello"}}},
+}
+
+func TestRetryClientRateLimited(t *testing.T) {
+	t.Parallel()
+
+	for _, p := range retryTestProviders {
+		t.Run(p.name, func(t *testing.T) {
+			t.Parallel()
+
+			client, requests := newRetryTestClient(t, p, p.rateLimited, p.answer)
+			var warnings []ProviderEvent
+			var complete *ProviderEvent
+			for event := range client.stream(t.Context(), retryTestMessages, nil) {
+				require.NoError(t, event.Error)
+				switch event.Type {
+				case EventWarning:
+					warnings = append(warnings, event)
+				case EventComplete:
+					complete = &event
+				}
+			}
+			require.Len(t, warnings, 1)
+			require.Contains(t, warnings[0].Content, "Test Model: 429 Too Many Requests, retrying in")
+			require.False(t, warnings[0].Restart)
+			require.NotNil(t, complete)
+			require.Equal(t, "hello", complete.Response.Content)
+			require.Equal(t, int32(2), requests.Load())
+
+			client, requests = newRetryTestClient(t, p, p.rateLimited, p.answer)
+			response, err := client.send(t.Context(), retryTestMessages, nil)
+			require.NoError(t, err)
+			require.Equal(t, "hello", response.Content)
+			require.Equal(t, int32(2), requests.Load())
+		})
+	}
+}
+
+func TestRetryClientStreamCut(t *testing.T) {
+	t.Parallel()
+
+	for _, p := range retryTestProviders {
+		t.Run(p.name, func(t *testing.T) {
+			t.Parallel()
+
+			client, requests := newRetryTestClient(t, p, p.cut, p.answer)
+			var content string
+			var restarts int
+			for event := range client.stream(t.Context(), retryTestMessages, nil) {
+				require.NoError(t, event.Error)
+				switch event.Type {
+				case EventContentDelta:
+					content += event.Content
+				case EventWarning:
+					require.True(t, event.Restart)
+					content = ""
+					restarts++
+				}
+			}
+			require.Equal(t, 1, restarts)
+			require.Equal(t, "hello", content)
+			require.Equal(t, int32(2), requests.Load())
+		})
+	}
+}
+
+func TestRetryClientDoesNotRetryBadRequest(t *testing.T) {
+	t.Parallel()
+
+	badRequest := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":{"code":400,"message":"Invalid model","type":"invalid_request_error"}}`))
+	}
+	for _, p := range retryTestProviders {
+		t.Run(p.name, func(t *testing.T) {
+			t.Parallel()
+
+			client, requests := newRetryTestClient(t, p, badRequest, p.answer)
+			var failure error
+			for event := range client.stream(t.Context(), retryTestMessages, nil) {
+				require.NotEqual(t, EventWarning, event.Type)
+				if event.Type == EventError {
+					failure = event.Error
+				}
+			}
+			require.Error(t, failure)
+			status, _ := errorStatus(failure)
+			require.Equal(t, http.StatusBadRequest, status)
+			require.Equal(t, int32(1), requests.Load())
+		})
+	}
+}
+
+func TestRetryClientGivesUp(t *testing.T) {
+	t.Parallel()
+
+	unavailable := func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte(`{"error":{"message":"Unavailable"}}`))
+	}
+	client, requests := newRetryTestClient(t, retryTestProviders[1], unavailable)
+	client.maxRetries = 2
+
+	_, err := client.send(t.Context(), retryTestMessages, nil)
+	require.ErrorContains(t, err, "maximum retry attempts reached: 2 retries")
+	status, _ := errorStatus(err)
+	require.Equal(t, http.StatusServiceUnavailable, status)
+	require.Equal(t, int32(3), requests.Load())
+}
+
+func TestRetryAfter(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		header http.Header
+		want   time.Duration
+		ok     bool
+	}{
+		{"seconds", http.Header{"Retry-After": {"3"}}, 3 * time.Second, true},
+		{"milliseconds", http.Header{"Retry-After-Ms": {"250"}, "Retry-After": {"1"}}, 250 * time.Millisecond, true},
+		{"past date", http.Header{"Retry-After": {"Wed, 21 Oct 2015 07:28:00 GMT"}}, 0, true},
+		{"too long", http.Header{"Retry-After": {"86400"}}, maxRetryAfter, true},
+		{"far date", http.Header{"Retry-After": {"Fri, 01 Jan 2100 00:00:00 GMT"}}, maxRetryAfter, true},
+		{"missing", http.Header{}, 0, false},
+		{"invalid", http.Header{"Retry-After": {"soon"}}, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			after, ok := retryAfter(&qwenAPIError{StatusCode: http.StatusTooManyRequests, Header: tt.header})
+			require.Equal(t, tt.ok, ok)
+			require.Equal(t, tt.want, after)
+		})
+	}
+}
+
+func TestIsTransient(t *testing.T) {
+	t.Parallel()
+
+	require.True(t, isTransient(&qwenAPIError{StatusCode: http.StatusTooManyRequests}))
+	require.True(t, isTransient(&qwenAPIError{StatusCode: 529}))
+	require.True(t, isTransient(errIncompleteStream))
+	require.True(t, isTransient(errors.Join(errors.New("failed to read stream"), io.ErrUnexpectedEOF)))
+	require.True(t, isTransient(errors.New(`received error while streaming: {"type":"overloaded_error"}`)))
+	require.False(t, isTransient(&qwenAPIError{StatusCode: http.StatusBadRequest}))
+	require.False(t, isTransient(io.EOF))
+}
@@ -0,0 +1,209 @@
+package tools
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/charmbracelet/x/powernap/pkg/lsp/protocol"
+	"github.com/nom-nom-hub/floss/internal/csync"
+	"github.com/nom-nom-hub/floss/internal/history"
+	"github.com/nom-nom-hub/floss/internal/lsp"
+	"github.com/nom-nom-hub/floss/internal/permission"
+)
+
+type CodeActionParams struct {
+	FilePath string `json:"file_path"`
+	Line     int    `json:"line,omitempty"`
+	EndLine  int    `json:"end_line,omitempty"`
+	Kind     string `json:"kind,omitempty"`
+	Title    string `json:"title,omitempty"`
+}
+
+type codeActionTool struct {
+	editor workspaceEditor
+}
+
+const CodeActionToolName = "code_action"
+
+//go:embed code_action.md
+var codeActionDescription []byte
+
+func NewCodeActionTool(lspClients *csync.Map[string, *lsp.Client], permissions permission.Service, files history.Service, workingDir string) BaseTool {
+	return &codeActionTool{
+		editor: workspaceEditor{
+			lspClients:  lspClients,
+			permissions: permissions,
+			files:       files,
+			workingDir:  workingDir,
+		},
+	}
+}
+
+func (c *codeActionTool) Name() string {
+	return CodeActionToolName
+}
+
+func (c *codeActionTool) Info() ToolInfo {
+	return ToolInfo{
+		Name:        CodeActionToolName,
+		Description: string(codeActionDescription),
+		Parameters: map[string]any{
+			"file_path": map[string]any{
+				"type":        "string",
+				"description": "The path to the file",
+			},
+			"line": map[string]any{
+				"type":        "integer",
+				"description": "The line to get code actions for (1-based), the whole file when left out",
+			},
+			"end_line": map[string]any{
+				"type":        "integer",
+				"description": "The last line of the range to get code actions for (1-based), defaults to line",
+			},
+			"kind": map[string]any{
+				"type":        "string",
+				"description": "Only list code actions of this kind, such as quickfix or source.organizeImports",
+			},
+			"title": map[string]any{
+				"type":        "string",
+				"description": "The title of the code action to apply, as listed by a call without it",
+			},
+		},
+		Required: []string{"file_path"},
+	}
+}
+
+func (c *codeActionTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error) {
+	var params CodeActionParams
+	if err := json.Unmarshal([]byte(call.Input), &params); err != nil {
+		return NewTextErrorResponse(fmt.Sprintf("error parsing parameters: %s", err)), nil
+	}
+	if params.FilePath == "" {
+		return NewTextErrorResponse("file_path is required"), nil
+	}
+	path := resolvePath(c.editor.workingDir, params.FilePath)
+	rng, err := lineSpan(path, params.Line, params.EndLine)
+	if err != nil {
+		return NewTextErrorResponse(err.Error()), nil
+	}
+	client, err := lspClientFor(ctx, c.editor.lspClients, path)
+	if err != nil {
+		return NewTextErrorResponse(err.Error()), nil
+	}
+
+	var only []protocol.CodeActionKind
+	if params.Kind != "" {
+		only = []protocol.CodeActionKind{protocol.CodeActionKind(params.Kind)}
+	}
+	listed, err := client.CodeActions(ctx, path, rng, only)
+	if err != nil {
+		return NewTextErrorResponse(err.Error()), nil
+	}
+	var actions []protocol.CodeAction
+	for _, action := range listed {
+		if action.Disabled == nil {
+			actions = append(actions, action)
+		}
+	}
+	if len(actions) == 0 {
+		return NewTextResponse("No code actions available"), nil
+	}
+
+	action, ok := selectCodeAction(actions, params)
+	if !ok {
+		result := formatCodeActions(actions)
+		if params.Title != "" {
+			return NewTextErrorResponse(fmt.Sprintf("no single code action matches %q, pick one of:\n%s", params.Title, result)), nil
+		}
+		return NewTextResponse(result + "\nCall code_action again with the title of the one to apply."), nil
+	}
+
+	if action.Edit == nil && action.Data != nil {
+		if action, err = client.ResolveCodeAction(ctx, action); err != nil {
+			return NewTextErrorResponse(err.Error()), nil
+		}
+	}
+	var edits []protocol.WorkspaceEdit
+	if action.Edit != nil {
+		edits = append(edits, *action.Edit)
+	}
+	if action.Command != nil {
+		commandEdits, err := client.ExecuteCommand(ctx, *action.Command)
+		if err != nil {
+			return NewTextErrorResponse(err.Error()), nil
+		}
+		edits = append(edits, commandEdits...)
+	}
+	return c.editor.apply(ctx, call, CodeActionToolName, fmt.Sprintf("Apply %q", action.Title), edits...)
+}
+
+// lineSpan returns the range of the lines, or of the whole file when no
+// line is given
+func lineSpan(path string, line, endLine int) (protocol.Range, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return protocol.Range{}, fmt.Errorf("error reading file: %w", err)
+	}
+	lines := strings.Split(string(content), "\n")
+	if line == 0 {
+		line, endLine = 1, len(lines)
+	}
+	if endLine == 0 {
+		endLine = line
+	}
+	if line < 1 || endLine < line || endLine > len(lines) {
+		return protocol.Range{}, fmt.Errorf("lines %d-%d are out of range, the file has %d lines", line, endLine, len(lines))
+	}
+	return protocol.Range{
+		Start: protocol.Position{Line: uint32(line - 1)},
+		End:   protocol.Position{Line: uint32(endLine - 1), Character: uint32(utf16Len(strings.TrimSuffix(lines[endLine-1], "\r")))},
+	}, nil
+}
+
+// selectCodeAction picks the action with the title, or one containing it
+// when no title matches exactly. Without a title, it is the only action of
+// the kind asked for.
+func selectCodeAction(actions []protocol.CodeAction, params CodeActionParams) (protocol.CodeAction, bool) {
+	if params.Title == "" {
+		if params.Kind != "" && len(actions) == 1 {
+			return actions[0], true
+		}
+		return protocol.CodeAction{}, false
+	}
+	for _, action := range actions {
+		if strings.EqualFold(action.Title, params.Title) {
+			return action, true
+		}
+	}
+	var matches []protocol.CodeAction
+	for _, action := range actions {
+		if strings.Contains(strings.ToLower(action.Title), strings.ToLower(params.Title)) {
+			matches = append(matches, action)
+		}
+	}
+	if len(matches) == 1 {
+		return matches[0], true
+	}
+	return protocol.CodeAction{}, false
+}
+
+// formatCodeActions lists the titles of the actions with their kind
+func formatCodeActions(actions []protocol.CodeAction) string {
+	var out strings.Builder
+	fmt.Fprintf(&out, "%d code actions:\n", len(actions))
+	for _, action := range actions {
+		fmt.Fprintf(&out, "- %s", action.Title)
+		if action.Kind != "" {
+			fmt.Fprintf(&out, " [%s]", action.Kind)
+		}
+		if action.IsPreferred {
+			out.WriteString(" (preferred)")
+		}
+		out.WriteString("\n")
+	}
+	return out.String()
+}
@@ -0,0 +1,69 @@
+package tools
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+
+	"github.com/nom-nom-hub/floss/internal/csync"
+	"github.com/nom-nom-hub/floss/internal/lsp"
+)
+
+type definitionTool struct {
+	lspClients *csync.Map[string, *lsp.Client]
+	workingDir string
+}
+
+const DefinitionToolName = "definition"
+
+//go:embed definition.md
+var definitionDescription []byte
+
+func NewDefinitionTool(lspClients *csync.Map[string, *lsp.Client], workingDir string) BaseTool {
+	return &definitionTool{
+		lspClients: lspClients,
+		workingDir: workingDir,
+	}
+}
+
+func (d *definitionTool) Name() string {
+	return DefinitionToolName
+}
+
+func (d *definitionTool) ReadOnly() bool {
+	return true
+}
+
+func (d *definitionTool) Info() ToolInfo {
+	return ToolInfo{
+		Name:        DefinitionToolName,
+		Description: string(definitionDescription),
+		Parameters:  symbolPositionParameters(),
+		Required:    []string{"file_path", "line"},
+	}
+}
+
+func (d *definitionTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error) {
+	var params SymbolPositionParams
+	if err := json.Unmarshal([]byte(call.Input), &params); err != nil {
+		return NewTextErrorResponse(fmt.Sprintf("error parsing parameters: %s", err)), nil
+	}
+	path := resolvePath(d.workingDir, params.FilePath)
+	position, err := symbolPosition(path, params)
+	if err != nil {
+		return NewTextErrorResponse(err.Error()), nil
+	}
+	client, err := lspClientFor(ctx, d.lspClients, path)
+	if err != nil {
+		return NewTextErrorResponse(err.Error()), nil
+	}
+	locations, err := client.Definition(ctx, path, position)
+	if err != nil {
+		return NewTextErrorResponse(err.Error()), nil
+	}
+	if len(locations) == 0 {
+		return NewTextResponse("No definition found"), nil
+	}
+	return NewTextResponse(formatLocations(d.workingDir, locations)), nil
+}
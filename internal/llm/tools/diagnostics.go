@@ -38,6 +38,10 @@ func (b *diagnosticsTool) Name() string {
 	return DiagnosticsToolName
 }
 
+func (b *diagnosticsTool) ReadOnly() bool {
+	return true
+}
+
 func (b *diagnosticsTool) Info() ToolInfo {
 	return ToolInfo{
 		Name:        DiagnosticsToolName,
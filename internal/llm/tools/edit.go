@@ -11,6 +11,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/nom-nom-hub/floss/internal/config"
 	"github.com/nom-nom-hub/floss/internal/csync"
 	"github.com/nom-nom-hub/floss/internal/diff"
 	"github.com/nom-nom-hub/floss/internal/fsext"
@@ -44,6 +45,7 @@ type editTool struct {
 	lspClients  *csync.Map[string, *lsp.Client]
 	permissions permission.Service
 	files       history.Service
+	formatters  config.Formatters
 	workingDir  string
 }
 
@@ -52,11 +54,12 @@ const EditToolName = "edit"
 //go:embed edit.md
 var editDescription []byte
 
-func NewEditTool(lspClients *csync.Map[string, *lsp.Client], permissions permission.Service, files history.Service, workingDir string) BaseTool {
+func NewEditTool(lspClients *csync.Map[string, *lsp.Client], permissions permission.Service, files history.Service, formatters config.Formatters, workingDir string) BaseTool {
 	return &editTool{
 		lspClients:  lspClients,
 		permissions: permissions,
 		files:       files,
+		formatters:  formatters,
 		workingDir:  workingDir,
 	}
 }
@@ -161,6 +164,7 @@ func (e *editTool) createNewFile(ctx context.Context, filePath, content string,
 		return ToolResponse{}, fmt.Errorf("session ID and message ID are required for creating a new file")
 	}
 
+	content = formatContent(ctx, e.lspClients, e.formatters, e.workingDir, filePath, content)
 	_, additions, removals := diff.GenerateDiff(
 		"",
 		content,
@@ -191,7 +195,7 @@ func (e *editTool) createNewFile(ctx context.Context, filePath, content string,
 	}
 
 	// File can't be in the history so we create a new file history
-	_, err = e.files.Create(ctx, sessionID, filePath, "")
+	_, err = e.files.CreateNew(ctx, sessionID, filePath)
 	if err != nil {
 		// Log error but don't fail the operation
 		return ToolResponse{}, fmt.Errorf("error creating file history: %w", err)
@@ -274,6 +278,7 @@ func (e *editTool) deleteContent(ctx context.Context, filePath, oldString string
 		newContent = oldContent[:index] + oldContent[index+len(oldString):]
 		deletionCount = 1
 	}
+	newContent = formatContent(ctx, e.lspClients, e.formatters, e.workingDir, filePath, newContent)
 
 	sessionID, messageID := GetContextValues(ctx)
 
@@ -332,7 +337,7 @@ func (e *editTool) deleteContent(ctx context.Context, filePath, oldString string
 		}
 	}
 	// Store the new version
-	_, err = e.files.CreateVersion(ctx, sessionID, filePath, "")
+	_, err = e.files.CreateVersion(ctx, sessionID, filePath, newContent)
 	if err != nil {
 		slog.Debug("Error creating file history version", "error", err)
 	}
@@ -407,6 +412,7 @@ func (e *editTool) replaceContent(ctx context.Context, filePath, oldString, newS
 		newContent = oldContent[:index] + newString + oldContent[index+len(oldString):]
 		replacementCount = 1
 	}
+	newContent = formatContent(ctx, e.lspClients, e.formatters, e.workingDir, filePath, newContent)
 
 	if oldContent == newContent {
 		return NewTextErrorResponse("new content is the same as old content. No changes made."), nil
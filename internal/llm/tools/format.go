@@ -0,0 +1,95 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/nom-nom-hub/floss/internal/config"
+	"github.com/nom-nom-hub/floss/internal/csync"
+	"github.com/nom-nom-hub/floss/internal/lsp"
+)
+
+// formatTimeout bounds how long formatting a file may take
+const formatTimeout = 10 * time.Second
+
+// formatContent formats the content about to be written to a file, with the
+// first formatter command handling it or else a language server set to
+// format it. The content is kept as is when nothing formats the file or
+// formatting fails, so an edit never fails because of its formatting.
+func formatContent(ctx context.Context, lspClients *csync.Map[string, *lsp.Client], formatters config.Formatters, workingDir, path, content string) string {
+	ctx, cancel := context.WithTimeout(ctx, formatTimeout)
+	defer cancel()
+
+	for _, formatter := range formatters.Sorted() {
+		if formatter.Formatter.Disabled || !handlesFileType(formatter.Formatter.FileTypes, path) {
+			continue
+		}
+		formatted, err := runFormatter(ctx, formatter.Formatter, workingDir, path, content)
+		if err != nil {
+			slog.Warn("Error formatting file", "formatter", formatter.Name, "file", path, "error", err)
+			return content
+		}
+		return formatted
+	}
+
+	if lspClients == nil {
+		return content
+	}
+	for name, client := range lspClients.Seq2() {
+		if !client.FormatsFile(path) {
+			continue
+		}
+		formatted, err := client.Format(ctx, path, content)
+		if err != nil {
+			slog.Warn("Error formatting file", "lsp", name, "file", path, "error", err)
+			return content
+		}
+		return formatted
+	}
+	return content
+}
+
+// runFormatter pipes the content through a formatter command
+func runFormatter(ctx context.Context, formatter config.FormatterConfig, workingDir, path, content string) (string, error) {
+	args := make([]string, len(formatter.Args))
+	for i, arg := range formatter.Args {
+		args[i] = strings.ReplaceAll(arg, "$FILE", path)
+	}
+	cmd := exec.CommandContext(ctx, formatter.Command, args...)
+	cmd.Dir = workingDir
+	cmd.Stdin = strings.NewReader(content)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return "", fmt.Errorf("%w: %s", err, message)
+		}
+		return "", err
+	}
+	if stdout.Len() == 0 && strings.TrimSpace(content) != "" {
+		return "", fmt.Errorf("%s wrote nothing", formatter.Command)
+	}
+	return stdout.String(), nil
+}
+
+// handlesFileType checks if the file has one of the file types
+func handlesFileType(fileTypes []string, path string) bool {
+	name := strings.ToLower(filepath.Base(path))
+	for _, fileType := range fileTypes {
+		suffix := strings.ToLower(fileType)
+		if !strings.HasPrefix(suffix, ".") {
+			suffix = "." + suffix
+		}
+		if strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+	return false
+}
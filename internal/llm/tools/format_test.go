@@ -0,0 +1,137 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/nom-nom-hub/floss/internal/config"
+	"github.com/nom-nom-hub/floss/internal/csync"
+	"github.com/nom-nom-hub/floss/internal/db"
+	"github.com/nom-nom-hub/floss/internal/history"
+	"github.com/nom-nom-hub/floss/internal/lsp"
+	"github.com/nom-nom-hub/floss/internal/permission"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatContent(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	upper := config.FormatterConfig{Command: "tr", Args: []string{"a-z", "A-Z"}, FileTypes: []string{"go"}}
+	tests := []struct {
+		name       string
+		formatters config.Formatters
+		path       string
+		want       string
+	}{
+		{name: "formats matching files", formatters: config.Formatters{"upper": upper}, path: "main.go", want: "PACKAGE MAIN\n"},
+		{name: "skips other files", formatters: config.Formatters{"upper": upper}, path: "main.ts", want: "package main\n"},
+		{
+			name:       "skips disabled formatters",
+			formatters: config.Formatters{"upper": {Disabled: true, Command: "tr", Args: []string{"a-z", "A-Z"}, FileTypes: []string{"go"}}},
+			path:       "main.go",
+			want:       "package main\n",
+		},
+		{
+			name:       "passes the path",
+			formatters: config.Formatters{"path": {Command: "sh", Args: []string{"-c", `cat; echo "// $1"`, "sh", "$FILE"}, FileTypes: []string{"go"}}},
+			path:       "main.go",
+			want:       "package main\n// " + filepath.Join(dir, "main.go") + "\n",
+		},
+		{
+			name:       "keeps the content when formatting fails",
+			formatters: config.Formatters{"broken": {Command: "sh", Args: []string{"-c", "echo syntax error >&2; exit 2"}, FileTypes: []string{"go"}}},
+			path:       "main.go",
+			want:       "package main\n",
+		},
+		{
+			name:       "keeps the content when nothing is written",
+			formatters: config.Formatters{"silent": {Command: "true", FileTypes: []string{"go"}}},
+			path:       "main.go",
+			want:       "package main\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			lspClients := csync.NewMap[string, *lsp.Client]()
+			got := formatContent(t.Context(), lspClients, tt.formatters, dir, filepath.Join(dir, tt.path), "package main\n")
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestWriteFormatsContent(t *testing.T) {
+	t.Parallel()
+
+	conn, err := db.Connect(t.Context(), t.TempDir())
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	queries := db.New(conn)
+	files := history.NewService(queries, conn)
+	_, err = queries.CreateSession(t.Context(), db.CreateSessionParams{ID: "session", Title: "format"})
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	permissions := permission.NewPermissionService(dir, false, nil)
+	requests := permissions.Subscribe(t.Context())
+	formatters := config.Formatters{"upper": {Command: "tr", Args: []string{"a-z", "A-Z"}, FileTypes: []string{"go"}}}
+	tool := NewWriteTool(csync.NewMap[string, *lsp.Client](), permissions, files, formatters, dir)
+
+	input, err := json.Marshal(WriteParams{FilePath: "main.go", Content: "package main\n"})
+	require.NoError(t, err)
+	ctx := context.WithValue(t.Context(), SessionIDContextKey, "session")
+	ctx = context.WithValue(ctx, MessageIDContextKey, "message")
+	done := make(chan ToolResponse)
+	go func() {
+		response, err := tool.Run(ctx, ToolCall{ID: "call", Input: string(input)})
+		if err != nil {
+			response = NewTextErrorResponse(err.Error())
+		}
+		done <- response
+	}()
+
+	// The formatted content is what gets approved
+	request := (<-requests).Payload
+	require.Equal(t, "PACKAGE MAIN\n", request.Params.(WritePermissionsParams).NewContent)
+	permissions.Grant(request)
+
+	response := <-done
+	require.False(t, response.IsError, response.Content)
+	main := filepath.Join(dir, "main.go")
+	content, err := os.ReadFile(main)
+	require.NoError(t, err)
+	require.Equal(t, "PACKAGE MAIN\n", string(content))
+
+	// and recorded in the history
+	latest, err := files.ListLatestSessionFiles(t.Context(), "session")
+	require.NoError(t, err)
+	require.Len(t, latest, 1)
+	require.Equal(t, main, latest[0].Path)
+	require.Equal(t, "PACKAGE MAIN\n", latest[0].Content)
+}
+
+func TestWriteSkipsContentFormattedToTheSame(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	main := filepath.Join(dir, "main.go")
+	require.NoError(t, os.WriteFile(main, []byte("PACKAGE MAIN\n"), 0o644))
+	recordFileRead(main)
+
+	permissions := permission.NewPermissionService(dir, false, nil)
+	formatters := config.Formatters{"upper": {Command: "tr", Args: []string{"a-z", "A-Z"}, FileTypes: []string{"go"}}}
+	tool := NewWriteTool(csync.NewMap[string, *lsp.Client](), permissions, nil, formatters, dir)
+
+	input, err := json.Marshal(WriteParams{FilePath: "main.go", Content: "package main\n"})
+	require.NoError(t, err)
+	ctx := context.WithValue(t.Context(), SessionIDContextKey, "session")
+	ctx = context.WithValue(ctx, MessageIDContextKey, "message")
+	response, err := tool.Run(ctx, ToolCall{ID: "call", Input: string(input)})
+	require.NoError(t, err)
+	require.True(t, response.IsError)
+	require.Contains(t, response.Content, "already contains the exact content")
+}
@@ -44,6 +44,10 @@ func (g *globTool) Name() string {
 	return GlobToolName
 }
 
+func (g *globTool) ReadOnly() bool {
+	return true
+}
+
 func (g *globTool) Info() ToolInfo {
 	return ToolInfo{
 		Name:        GlobToolName,
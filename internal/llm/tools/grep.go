@@ -108,6 +108,10 @@ func (g *grepTool) Name() string {
 	return GrepToolName
 }
 
+func (g *grepTool) ReadOnly() bool {
+	return true
+}
+
 func (g *grepTool) Info() ToolInfo {
 	return ToolInfo{
 		Name:        GrepToolName,
@@ -0,0 +1,70 @@
+package tools
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/nom-nom-hub/floss/internal/csync"
+	"github.com/nom-nom-hub/floss/internal/lsp"
+)
+
+type hoverTool struct {
+	lspClients *csync.Map[string, *lsp.Client]
+	workingDir string
+}
+
+const HoverToolName = "hover"
+
+//go:embed hover.md
+var hoverDescription []byte
+
+func NewHoverTool(lspClients *csync.Map[string, *lsp.Client], workingDir string) BaseTool {
+	return &hoverTool{
+		lspClients: lspClients,
+		workingDir: workingDir,
+	}
+}
+
+func (h *hoverTool) Name() string {
+	return HoverToolName
+}
+
+func (h *hoverTool) ReadOnly() bool {
+	return true
+}
+
+func (h *hoverTool) Info() ToolInfo {
+	return ToolInfo{
+		Name:        HoverToolName,
+		Description: string(hoverDescription),
+		Parameters:  symbolPositionParameters(),
+		Required:    []string{"file_path", "line"},
+	}
+}
+
+func (h *hoverTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error) {
+	var params SymbolPositionParams
+	if err := json.Unmarshal([]byte(call.Input), &params); err != nil {
+		return NewTextErrorResponse(fmt.Sprintf("error parsing parameters: %s", err)), nil
+	}
+	path := resolvePath(h.workingDir, params.FilePath)
+	position, err := symbolPosition(path, params)
+	if err != nil {
+		return NewTextErrorResponse(err.Error()), nil
+	}
+	client, err := lspClientFor(ctx, h.lspClients, path)
+	if err != nil {
+		return NewTextErrorResponse(err.Error()), nil
+	}
+	hover, err := client.Hover(ctx, path, position)
+	if err != nil {
+		return NewTextErrorResponse(err.Error()), nil
+	}
+	if strings.TrimSpace(hover) == "" {
+		return NewTextResponse("No hover information"), nil
+	}
+	return NewTextResponse(strings.TrimSpace(hover)), nil
+}
@@ -59,6 +59,10 @@ func (l *lsTool) Name() string {
 	return LSToolName
 }
 
+func (l *lsTool) ReadOnly() bool {
+	return true
+}
+
 func (l *lsTool) Info() ToolInfo {
 	return ToolInfo{
 		Name:        LSToolName,
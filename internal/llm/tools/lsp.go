@@ -0,0 +1,220 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/charmbracelet/x/powernap/pkg/lsp/protocol"
+	"github.com/nom-nom-hub/floss/internal/csync"
+	"github.com/nom-nom-hub/floss/internal/lsp"
+)
+
+// SymbolPositionParams points the LSP tools at a symbol: the line of the
+// file it is on, and its name or column
+type SymbolPositionParams struct {
+	FilePath string `json:"file_path"`
+	Line     int    `json:"line"`
+	Symbol   string `json:"symbol,omitempty"`
+	Column   int    `json:"column,omitempty"`
+}
+
+// maxLocations is how many locations the LSP tools list before cutting off
+const maxLocations = 50
+
+func symbolPositionParameters() map[string]any {
+	return map[string]any{
+		"file_path": map[string]any{
+			"type":        "string",
+			"description": "The path to the file the symbol is in",
+		},
+		"line": map[string]any{
+			"type":        "integer",
+			"description": "The line number the symbol is on (1-based)",
+		},
+		"symbol": map[string]any{
+			"type":        "string",
+			"description": "The name of the symbol on the line",
+		},
+		"column": map[string]any{
+			"type":        "integer",
+			"description": "The column of the symbol (1-based), only needed when its name appears more than once on the line",
+		},
+	}
+}
+
+// resolvePath makes the path absolute from the working directory
+func resolvePath(workingDir, path string) string {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(workingDir, path)
+	}
+	return filepath.Clean(path)
+}
+
+// relPath shows the path from the working directory when it is within it
+func relPath(workingDir, path string) string {
+	if rel, err := filepath.Rel(workingDir, path); err == nil && !strings.HasPrefix(rel, "..") {
+		return rel
+	}
+	return path
+}
+
+// symbolPosition finds the LSP position of the symbol the parameters point
+// at. Without a symbol or column it is the start of the line.
+func symbolPosition(path string, params SymbolPositionParams) (protocol.Position, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return protocol.Position{}, fmt.Errorf("error reading file: %w", err)
+	}
+	lines := strings.Split(string(content), "\n")
+	if params.Line < 1 || params.Line > len(lines) {
+		return protocol.Position{}, fmt.Errorf("line %d is out of range, the file has %d lines", params.Line, len(lines))
+	}
+	line := strings.TrimSuffix(lines[params.Line-1], "\r")
+
+	var offset int
+	switch {
+	case params.Column > 0:
+		runes := []rune(line)
+		if params.Column > len(runes)+1 {
+			return protocol.Position{}, fmt.Errorf("column %d is out of range, line %d has %d characters", params.Column, params.Line, len(runes))
+		}
+		offset = len(string(runes[:params.Column-1]))
+	case params.Symbol != "":
+		offset = symbolIndex(line, params.Symbol)
+		if offset == -1 {
+			return protocol.Position{}, fmt.Errorf("symbol %q not found on line %d: %s", params.Symbol, params.Line, strings.TrimSpace(line))
+		}
+	default:
+		offset = len(line) - len(strings.TrimLeftFunc(line, unicode.IsSpace))
+	}
+	return protocol.Position{
+		Line:      uint32(params.Line - 1),
+		Character: uint32(utf16Len(line[:offset])),
+	}, nil
+}
+
+// symbolIndex returns the byte offset of the first whole word occurrence of
+// the symbol in the line, or -1
+func symbolIndex(line, symbol string) int {
+	isWord := func(r rune) bool {
+		return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+	}
+	for start := 0; start <= len(line); {
+		i := strings.Index(line[start:], symbol)
+		if i == -1 {
+			return -1
+		}
+		i += start
+		before, _ := utf8.DecodeLastRuneInString(line[:i])
+		after, _ := utf8.DecodeRuneInString(line[i+len(symbol):])
+		if (i == 0 || !isWord(before)) && (i+len(symbol) == len(line) || !isWord(after)) {
+			return i
+		}
+		start = i + 1
+	}
+	return -1
+}
+
+// utf16Len counts the UTF-16 code units LSP positions are measured in
+func utf16Len(s string) int {
+	n := 0
+	for _, r := range s {
+		n += utf16.RuneLen(r)
+	}
+	return n
+}
+
+// lspClientFor returns a ready client that handles the file, with the file
+// opened in it
+func lspClientFor(ctx context.Context, lspClients *csync.Map[string, *lsp.Client], path string) (*lsp.Client, error) {
+	handled := false
+	for client := range lspClients.Seq() {
+		if !client.HandlesFile(path) {
+			continue
+		}
+		handled = true
+		if client.GetServerState() != lsp.StateReady {
+			continue
+		}
+		if err := client.OpenFileOnDemand(ctx, path); err != nil {
+			return nil, fmt.Errorf("error opening file in %s: %w", client.GetName(), err)
+		}
+		return client, nil
+	}
+	if handled {
+		return nil, fmt.Errorf("the language server for %s is not ready yet", filepath.Base(path))
+	}
+	return nil, fmt.Errorf("no language server handles %s", filepath.Base(path))
+}
+
+// formatLocations lists the locations as path:line:column with the line of
+// code at each, sorted by path and line
+func formatLocations(workingDir string, locations []protocol.Location) string {
+	type location struct {
+		path string
+		rng  protocol.Range
+	}
+	var sorted []location
+	for _, loc := range locations {
+		path, err := loc.URI.Path()
+		if err != nil {
+			continue
+		}
+		sorted = append(sorted, location{path: path, rng: loc.Range})
+	}
+	slices.SortFunc(sorted, func(a, b location) int {
+		if c := strings.Compare(a.path, b.path); c != 0 {
+			return c
+		}
+		return int(a.rng.Start.Line) - int(b.rng.Start.Line)
+	})
+	sorted = slices.CompactFunc(sorted, func(a, b location) bool {
+		return a.path == b.path && a.rng.Start == b.rng.Start
+	})
+
+	files := make(map[string][]string)
+	var out strings.Builder
+	for i, loc := range sorted {
+		if i == maxLocations {
+			fmt.Fprintf(&out, "... and %d more\n", len(sorted)-maxLocations)
+			break
+		}
+		lines, ok := files[loc.path]
+		if !ok {
+			if content, err := os.ReadFile(loc.path); err == nil {
+				lines = strings.Split(string(content), "\n")
+			}
+			files[loc.path] = lines
+		}
+		line := int(loc.rng.Start.Line)
+		fmt.Fprintf(&out, "%s:%d:%d", relPath(workingDir, loc.path), line+1, loc.rng.Start.Character+1)
+		if line < len(lines) {
+			fmt.Fprintf(&out, ": %s", strings.TrimSpace(lines[line]))
+		}
+		out.WriteString("\n")
+	}
+	return out.String()
+}
+
+// symbolKind names the kind of a symbol
+func symbolKind(kind protocol.SymbolKind) string {
+	if name, ok := protocol.TableKindMap[kind]; ok {
+		return name
+	}
+	return "Symbol"
+}
+
+// lineRange shows the lines a range spans
+func lineRange(rng protocol.Range) string {
+	if rng.Start.Line == rng.End.Line {
+		return fmt.Sprintf("line %d", rng.Start.Line+1)
+	}
+	return fmt.Sprintf("lines %d-%d", rng.Start.Line+1, rng.End.Line+1)
+}
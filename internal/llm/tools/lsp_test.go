@@ -0,0 +1,116 @@
+package tools
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/charmbracelet/x/powernap/pkg/lsp/protocol"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSymbolPosition(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "main.go")
+	content := "package main\n\n\tname := \"héllo\" + username + name\n"
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+
+	tests := []struct {
+		name   string
+		params SymbolPositionParams
+		want   protocol.Position
+		err    string
+	}{
+		{
+			name:   "first non-space character",
+			params: SymbolPositionParams{Line: 3},
+			want:   protocol.Position{Line: 2, Character: 1},
+		},
+		{
+			name:   "whole word symbol",
+			params: SymbolPositionParams{Line: 3, Symbol: "username"},
+			want:   protocol.Position{Line: 2, Character: 19},
+		},
+		{
+			name:   "symbol inside another word is skipped",
+			params: SymbolPositionParams{Line: 3, Symbol: "name"},
+			want:   protocol.Position{Line: 2, Character: 1},
+		},
+		{
+			name:   "column in characters",
+			params: SymbolPositionParams{Line: 3, Column: 31, Symbol: "ignored"},
+			want:   protocol.Position{Line: 2, Character: 30},
+		},
+		{
+			name:   "line out of range",
+			params: SymbolPositionParams{Line: 9},
+			err:    "line 9 is out of range, the file has 4 lines",
+		},
+		{
+			name:   "missing symbol",
+			params: SymbolPositionParams{Line: 1, Symbol: "main2"},
+			err:    `symbol "main2" not found on line 1: package main`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := symbolPosition(path, tt.params)
+			if tt.err != "" {
+				require.EqualError(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestSymbolPositionUTF16(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "emoji.go")
+	require.NoError(t, os.WriteFile(path, []byte("s := \"😀\" + value\n"), 0o644))
+
+	got, err := symbolPosition(path, SymbolPositionParams{Line: 1, Symbol: "value"})
+	require.NoError(t, err)
+	require.Equal(t, protocol.Position{Line: 0, Character: 12}, got)
+}
+
+func TestFormatLocations(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a.go")
+	b := filepath.Join(dir, "b.go")
+	require.NoError(t, os.WriteFile(a, []byte("package main\n\nfunc run() {}\n"), 0o644))
+	require.NoError(t, os.WriteFile(b, []byte("package main\n\nfunc main() {\n\trun()\n}\n"), 0o644))
+
+	at := func(path string, line, character uint32) protocol.Location {
+		position := protocol.Position{Line: line, Character: character}
+		return protocol.Location{URI: protocol.URIFromPath(path), Range: protocol.Range{Start: position, End: position}}
+	}
+	got := formatLocations(dir, []protocol.Location{at(b, 3, 1), at(a, 2, 5), at(b, 3, 1)})
+	require.Equal(t, "a.go:3:6: func run() {}\nb.go:4:2: run()\n", got)
+}
+
+func TestFormatDocumentSymbols(t *testing.T) {
+	t.Parallel()
+
+	span := func(start, end uint32) protocol.Range {
+		return protocol.Range{Start: protocol.Position{Line: start}, End: protocol.Position{Line: end}}
+	}
+	got := formatDocumentSymbols([]protocol.DocumentSymbol{
+		{
+			Name:   "Server",
+			Kind:   protocol.Struct,
+			Range:  span(4, 9),
+			Detail: "struct{...}",
+			Children: []protocol.DocumentSymbol{
+				{Name: "addr", Kind: protocol.Field, Range: span(5, 5)},
+			},
+		},
+	})
+	require.Equal(t, "Struct Server struct{...} (lines 5-10)\n  Field addr (line 6)\n", got)
+}
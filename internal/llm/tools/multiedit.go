@@ -11,6 +11,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/nom-nom-hub/floss/internal/config"
 	"github.com/nom-nom-hub/floss/internal/csync"
 	"github.com/nom-nom-hub/floss/internal/diff"
 	"github.com/nom-nom-hub/floss/internal/fsext"
@@ -48,6 +49,7 @@ type multiEditTool struct {
 	lspClients  *csync.Map[string, *lsp.Client]
 	permissions permission.Service
 	files       history.Service
+	formatters  config.Formatters
 	workingDir  string
 }
 
@@ -56,11 +58,12 @@ const MultiEditToolName = "multiedit"
 //go:embed multiedit.md
 var multieditDescription []byte
 
-func NewMultiEditTool(lspClients *csync.Map[string, *lsp.Client], permissions permission.Service, files history.Service, workingDir string) BaseTool {
+func NewMultiEditTool(lspClients *csync.Map[string, *lsp.Client], permissions permission.Service, files history.Service, formatters config.Formatters, workingDir string) BaseTool {
 	return &multiEditTool{
 		lspClients:  lspClients,
 		permissions: permissions,
 		files:       files,
+		formatters:  formatters,
 		workingDir:  workingDir,
 	}
 }
@@ -204,6 +207,7 @@ func (m *multiEditTool) processMultiEditWithCreation(ctx context.Context, params
 		}
 		currentContent = newContent
 	}
+	currentContent = formatContent(ctx, m.lspClients, m.formatters, m.workingDir, params.FilePath, currentContent)
 
 	// Get session and message IDs
 	sessionID, messageID := GetContextValues(ctx)
@@ -238,7 +242,7 @@ func (m *multiEditTool) processMultiEditWithCreation(ctx context.Context, params
 	}
 
 	// Update file history
-	_, err = m.files.Create(ctx, sessionID, params.FilePath, "")
+	_, err = m.files.CreateNew(ctx, sessionID, params.FilePath)
 	if err != nil {
 		return ToolResponse{}, fmt.Errorf("error creating file history: %w", err)
 	}
@@ -309,6 +313,7 @@ func (m *multiEditTool) processMultiEditExistingFile(ctx context.Context, params
 		}
 		currentContent = newContent
 	}
+	currentContent = formatContent(ctx, m.lspClients, m.formatters, m.workingDir, params.FilePath, currentContent)
 
 	// Check if content actually changed
 	if oldContent == currentContent {
@@ -0,0 +1,69 @@
+package tools
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+
+	"github.com/nom-nom-hub/floss/internal/csync"
+	"github.com/nom-nom-hub/floss/internal/lsp"
+)
+
+type referencesTool struct {
+	lspClients *csync.Map[string, *lsp.Client]
+	workingDir string
+}
+
+const ReferencesToolName = "references"
+
+//go:embed references.md
+var referencesDescription []byte
+
+func NewReferencesTool(lspClients *csync.Map[string, *lsp.Client], workingDir string) BaseTool {
+	return &referencesTool{
+		lspClients: lspClients,
+		workingDir: workingDir,
+	}
+}
+
+func (r *referencesTool) Name() string {
+	return ReferencesToolName
+}
+
+func (r *referencesTool) ReadOnly() bool {
+	return true
+}
+
+func (r *referencesTool) Info() ToolInfo {
+	return ToolInfo{
+		Name:        ReferencesToolName,
+		Description: string(referencesDescription),
+		Parameters:  symbolPositionParameters(),
+		Required:    []string{"file_path", "line"},
+	}
+}
+
+func (r *referencesTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error) {
+	var params SymbolPositionParams
+	if err := json.Unmarshal([]byte(call.Input), &params); err != nil {
+		return NewTextErrorResponse(fmt.Sprintf("error parsing parameters: %s", err)), nil
+	}
+	path := resolvePath(r.workingDir, params.FilePath)
+	position, err := symbolPosition(path, params)
+	if err != nil {
+		return NewTextErrorResponse(err.Error()), nil
+	}
+	client, err := lspClientFor(ctx, r.lspClients, path)
+	if err != nil {
+		return NewTextErrorResponse(err.Error()), nil
+	}
+	locations, err := client.References(ctx, path, position, false)
+	if err != nil {
+		return NewTextErrorResponse(err.Error()), nil
+	}
+	if len(locations) == 0 {
+		return NewTextResponse("No references found"), nil
+	}
+	return NewTextResponse(fmt.Sprintf("%d references:\n%s", len(locations), formatLocations(r.workingDir, locations))), nil
+}
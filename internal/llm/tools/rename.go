@@ -0,0 +1,85 @@
+package tools
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+
+	"github.com/nom-nom-hub/floss/internal/csync"
+	"github.com/nom-nom-hub/floss/internal/history"
+	"github.com/nom-nom-hub/floss/internal/lsp"
+	"github.com/nom-nom-hub/floss/internal/permission"
+)
+
+type RenameParams struct {
+	SymbolPositionParams
+	NewName string `json:"new_name"`
+}
+
+type renameTool struct {
+	editor workspaceEditor
+}
+
+const RenameToolName = "rename"
+
+//go:embed rename.md
+var renameDescription []byte
+
+func NewRenameTool(lspClients *csync.Map[string, *lsp.Client], permissions permission.Service, files history.Service, workingDir string) BaseTool {
+	return &renameTool{
+		editor: workspaceEditor{
+			lspClients:  lspClients,
+			permissions: permissions,
+			files:       files,
+			workingDir:  workingDir,
+		},
+	}
+}
+
+func (r *renameTool) Name() string {
+	return RenameToolName
+}
+
+func (r *renameTool) Info() ToolInfo {
+	parameters := symbolPositionParameters()
+	parameters["new_name"] = map[string]any{
+		"type":        "string",
+		"description": "The new name of the symbol",
+	}
+	return ToolInfo{
+		Name:        RenameToolName,
+		Description: string(renameDescription),
+		Parameters:  parameters,
+		Required:    []string{"file_path", "line", "new_name"},
+	}
+}
+
+func (r *renameTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error) {
+	var params RenameParams
+	if err := json.Unmarshal([]byte(call.Input), &params); err != nil {
+		return NewTextErrorResponse(fmt.Sprintf("error parsing parameters: %s", err)), nil
+	}
+	if params.NewName == "" {
+		return NewTextErrorResponse("new_name is required"), nil
+	}
+	path := resolvePath(r.editor.workingDir, params.FilePath)
+	position, err := symbolPosition(path, params.SymbolPositionParams)
+	if err != nil {
+		return NewTextErrorResponse(err.Error()), nil
+	}
+	client, err := lspClientFor(ctx, r.editor.lspClients, path)
+	if err != nil {
+		return NewTextErrorResponse(err.Error()), nil
+	}
+	edit, err := client.Rename(ctx, path, position, params.NewName)
+	if err != nil {
+		return NewTextErrorResponse(err.Error()), nil
+	}
+
+	symbol := params.Symbol
+	if symbol == "" {
+		symbol = fmt.Sprintf("the symbol on line %d", params.Line)
+	}
+	return r.editor.apply(ctx, call, RenameToolName, fmt.Sprintf("Rename %s to %s", symbol, params.NewName), edit)
+}
@@ -0,0 +1,198 @@
+package tools
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/x/powernap/pkg/lsp/protocol"
+	"github.com/nom-nom-hub/floss/internal/csync"
+	"github.com/nom-nom-hub/floss/internal/lsp"
+)
+
+type DocumentSymbolsParams struct {
+	FilePath string `json:"file_path"`
+}
+
+type WorkspaceSymbolsParams struct {
+	Query string `json:"query"`
+}
+
+type documentSymbolsTool struct {
+	lspClients *csync.Map[string, *lsp.Client]
+	workingDir string
+}
+
+type workspaceSymbolsTool struct {
+	lspClients *csync.Map[string, *lsp.Client]
+	workingDir string
+}
+
+const (
+	DocumentSymbolsToolName  = "document_symbols"
+	WorkspaceSymbolsToolName = "workspace_symbols"
+)
+
+// maxDocumentSymbols is how many symbols of a file document_symbols lists
+// before cutting off
+const maxDocumentSymbols = 200
+
+//go:embed document_symbols.md
+var documentSymbolsDescription []byte
+
+//go:embed workspace_symbols.md
+var workspaceSymbolsDescription []byte
+
+func NewDocumentSymbolsTool(lspClients *csync.Map[string, *lsp.Client], workingDir string) BaseTool {
+	return &documentSymbolsTool{
+		lspClients: lspClients,
+		workingDir: workingDir,
+	}
+}
+
+func (d *documentSymbolsTool) Name() string {
+	return DocumentSymbolsToolName
+}
+
+func (d *documentSymbolsTool) ReadOnly() bool {
+	return true
+}
+
+func (d *documentSymbolsTool) Info() ToolInfo {
+	return ToolInfo{
+		Name:        DocumentSymbolsToolName,
+		Description: string(documentSymbolsDescription),
+		Parameters: map[string]any{
+			"file_path": map[string]any{
+				"type":        "string",
+				"description": "The path to the file to list the symbols of",
+			},
+		},
+		Required: []string{"file_path"},
+	}
+}
+
+func (d *documentSymbolsTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error) {
+	var params DocumentSymbolsParams
+	if err := json.Unmarshal([]byte(call.Input), &params); err != nil {
+		return NewTextErrorResponse(fmt.Sprintf("error parsing parameters: %s", err)), nil
+	}
+	path := resolvePath(d.workingDir, params.FilePath)
+	client, err := lspClientFor(ctx, d.lspClients, path)
+	if err != nil {
+		return NewTextErrorResponse(err.Error()), nil
+	}
+	symbols, err := client.DocumentSymbols(ctx, path)
+	if err != nil {
+		return NewTextErrorResponse(err.Error()), nil
+	}
+	if len(symbols) == 0 {
+		return NewTextResponse("No symbols found"), nil
+	}
+	return NewTextResponse(formatDocumentSymbols(symbols)), nil
+}
+
+// formatDocumentSymbols lists the symbols with the lines they span,
+// indenting children under their parent
+func formatDocumentSymbols(symbols []protocol.DocumentSymbol) string {
+	var lines []string
+	var walk func(symbols []protocol.DocumentSymbol, depth int)
+	walk = func(symbols []protocol.DocumentSymbol, depth int) {
+		for _, symbol := range symbols {
+			line := fmt.Sprintf("%s%s %s", strings.Repeat("  ", depth), symbolKind(symbol.Kind), symbol.Name)
+			if symbol.Detail != "" {
+				line += " " + symbol.Detail
+			}
+			lines = append(lines, fmt.Sprintf("%s (%s)", line, lineRange(symbol.Range)))
+			walk(symbol.Children, depth+1)
+		}
+	}
+	walk(symbols, 0)
+	if len(lines) > maxDocumentSymbols {
+		return strings.Join(lines[:maxDocumentSymbols], "\n") + fmt.Sprintf("\n... and %d more\n", len(lines)-maxDocumentSymbols)
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+func NewWorkspaceSymbolsTool(lspClients *csync.Map[string, *lsp.Client], workingDir string) BaseTool {
+	return &workspaceSymbolsTool{
+		lspClients: lspClients,
+		workingDir: workingDir,
+	}
+}
+
+func (w *workspaceSymbolsTool) Name() string {
+	return WorkspaceSymbolsToolName
+}
+
+func (w *workspaceSymbolsTool) ReadOnly() bool {
+	return true
+}
+
+func (w *workspaceSymbolsTool) Info() ToolInfo {
+	return ToolInfo{
+		Name:        WorkspaceSymbolsToolName,
+		Description: string(workspaceSymbolsDescription),
+		Parameters: map[string]any{
+			"query": map[string]any{
+				"type":        "string",
+				"description": "The name or part of the name of the symbols to search for",
+			},
+		},
+		Required: []string{"query"},
+	}
+}
+
+func (w *workspaceSymbolsTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error) {
+	var params WorkspaceSymbolsParams
+	if err := json.Unmarshal([]byte(call.Input), &params); err != nil {
+		return NewTextErrorResponse(fmt.Sprintf("error parsing parameters: %s", err)), nil
+	}
+	if params.Query == "" {
+		return NewTextErrorResponse("query is required"), nil
+	}
+
+	var symbols []protocol.SymbolInformation
+	var errs []string
+	for name, client := range w.lspClients.Seq2() {
+		if client.GetServerState() != lsp.StateReady {
+			continue
+		}
+		found, err := client.WorkspaceSymbols(ctx, params.Query)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %s", name, err))
+			continue
+		}
+		symbols = append(symbols, found...)
+	}
+	if len(symbols) == 0 && len(errs) > 0 {
+		return NewTextErrorResponse(strings.Join(errs, "\n")), nil
+	}
+	if len(symbols) == 0 {
+		return NewTextResponse("No symbols found"), nil
+	}
+	return NewTextResponse(formatWorkspaceSymbols(w.workingDir, symbols)), nil
+}
+
+// formatWorkspaceSymbols lists the symbols with where they are defined
+func formatWorkspaceSymbols(workingDir string, symbols []protocol.SymbolInformation) string {
+	var out strings.Builder
+	for i, symbol := range symbols {
+		if i == maxLocations {
+			fmt.Fprintf(&out, "... and %d more\n", len(symbols)-maxLocations)
+			break
+		}
+		name := symbol.Name
+		if symbol.ContainerName != "" {
+			name = symbol.ContainerName + "." + name
+		}
+		location := string(symbol.Location.URI)
+		if path, err := symbol.Location.URI.Path(); err == nil {
+			location = fmt.Sprintf("%s:%d", relPath(workingDir, path), symbol.Location.Range.Start.Line+1)
+		}
+		fmt.Fprintf(&out, "%s %s %s\n", symbolKind(symbol.Kind), name, location)
+	}
+	return out.String()
+}
@@ -72,6 +72,20 @@ type BaseTool interface {
 	Run(ctx context.Context, params ToolCall) (ToolResponse, error)
 }
 
+// ReadOnlyTool is implemented by tools that don't change anything, so the
+// agent may run several calls to them from one assistant message at once.
+// They may still ask for permission: permission requests are serialized.
+type ReadOnlyTool interface {
+	BaseTool
+	ReadOnly() bool
+}
+
+// IsReadOnly reports whether calls to tool can run concurrently
+func IsReadOnly(tool BaseTool) bool {
+	readOnly, ok := tool.(ReadOnlyTool)
+	return ok && readOnly.ReadOnly()
+}
+
 func GetContextValues(ctx context.Context) (string, string) {
 	sessionID := ctx.Value(SessionIDContextKey)
 	messageID := ctx.Value(MessageIDContextKey)
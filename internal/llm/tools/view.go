@@ -62,6 +62,10 @@ func (v *viewTool) Name() string {
 	return ViewToolName
 }
 
+func (v *viewTool) ReadOnly() bool {
+	return true
+}
+
 func (v *viewTool) Info() ToolInfo {
 	return ToolInfo{
 		Name:        ViewToolName,
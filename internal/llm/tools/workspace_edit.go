@@ -0,0 +1,201 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/x/powernap/pkg/lsp/protocol"
+	"github.com/nom-nom-hub/floss/internal/csync"
+	"github.com/nom-nom-hub/floss/internal/diff"
+	"github.com/nom-nom-hub/floss/internal/history"
+	"github.com/nom-nom-hub/floss/internal/lsp"
+	"github.com/nom-nom-hub/floss/internal/lsp/util"
+	"github.com/nom-nom-hub/floss/internal/permission"
+)
+
+// WorkspaceEditPermissionsParams shows every file an edit from a language
+// server changes
+type WorkspaceEditPermissionsParams struct {
+	Description string            `json:"description"`
+	Changes     []util.FileChange `json:"changes"`
+}
+
+type WorkspaceEditResponseMetadata struct {
+	Changes   []util.FileChange `json:"changes"`
+	Additions int               `json:"additions"`
+	Removals  int               `json:"removals"`
+}
+
+// workspaceEditor applies the edits language servers compute, for the tools
+// asking them for refactorings
+type workspaceEditor struct {
+	lspClients  *csync.Map[string, *lsp.Client]
+	permissions permission.Service
+	files       history.Service
+	workingDir  string
+}
+
+// apply asks to make the edits with a diff of every file they change, then
+// writes the files and records them in the history
+func (w workspaceEditor) apply(ctx context.Context, call ToolCall, toolName, description string, edits ...protocol.WorkspaceEdit) (ToolResponse, error) {
+	changes, err := util.PreviewWorkspaceEdit(edits...)
+	if err != nil {
+		return NewTextErrorResponse(fmt.Sprintf("error applying the edit: %s", err)), nil
+	}
+	if len(changes) == 0 {
+		return NewTextResponse(fmt.Sprintf("%s: no files to change", description)), nil
+	}
+
+	sessionID, messageID := GetContextValues(ctx)
+	if sessionID == "" || messageID == "" {
+		return ToolResponse{}, fmt.Errorf("session_id and message_id are required")
+	}
+
+	p := w.permissions.Request(
+		permission.CreatePermissionRequest{
+			SessionID:   sessionID,
+			Path:        w.workingDir,
+			ToolCallID:  call.ID,
+			ToolName:    toolName,
+			Action:      "write",
+			Description: fmt.Sprintf("%s in %d files", description, len(changes)),
+			Params: WorkspaceEditPermissionsParams{
+				Description: description,
+				Changes:     changes,
+			},
+		},
+	)
+	if !p {
+		return ToolResponse{}, permission.ErrorPermissionDenied
+	}
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "<result>\n%s:\n", description)
+	metadata := WorkspaceEditResponseMetadata{Changes: changes}
+	for i, change := range changes {
+		if err := w.write(ctx, sessionID, change); err != nil {
+			left := w.rollback(ctx, sessionID, changes[:i])
+			if errors.Is(err, errChangeKept) {
+				left = append([]string{relPath(w.workingDir, change.Path)}, left...)
+			}
+			if len(left) > 0 {
+				return ToolResponse{}, fmt.Errorf("%w; these files could not be restored and keep the edit: %s", err, strings.Join(left, ", "))
+			}
+			return ToolResponse{}, fmt.Errorf("%w; the files already changed were restored", err)
+		}
+		_, additions, removals := diff.GenerateDiff(change.OldContent, change.NewContent, relPath(w.workingDir, change.Path))
+		metadata.Additions += additions
+		metadata.Removals += removals
+
+		switch {
+		case change.Created:
+			fmt.Fprintf(&out, "created %s\n", relPath(w.workingDir, change.Path))
+		case change.Deleted:
+			fmt.Fprintf(&out, "deleted %s\n", relPath(w.workingDir, change.Path))
+		default:
+			fmt.Fprintf(&out, "edited %s (+%d -%d)\n", relPath(w.workingDir, change.Path), additions, removals)
+		}
+	}
+	out.WriteString("</result>")
+	for _, change := range changes {
+		if !change.Deleted {
+			out.WriteString(getDiagnostics(change.Path, w.lspClients))
+			break
+		}
+	}
+	return WithResponseMetadata(NewTextResponse(out.String()), metadata), nil
+}
+
+// rollback undoes the changes already written when a later one fails, so
+// that an edit is not left half applied, and returns the files it could not
+// restore
+func (w workspaceEditor) rollback(ctx context.Context, sessionID string, written []util.FileChange) []string {
+	var left []string
+	for _, change := range slices.Backward(written) {
+		if err := w.write(ctx, sessionID, inverse(change)); err != nil {
+			slog.Warn("Error restoring file", "file", change.Path, "error", err)
+			left = append(left, relPath(w.workingDir, change.Path))
+		}
+	}
+	return left
+}
+
+// errChangeKept is returned by write when the change was written but could
+// not be recorded nor undone
+var errChangeKept = errors.New("the change could not be undone")
+
+// inverse returns the change that undoes the change
+func inverse(change util.FileChange) util.FileChange {
+	return util.FileChange{
+		Path:       change.Path,
+		OldContent: change.NewContent,
+		NewContent: change.OldContent,
+		Created:    change.Deleted,
+		Deleted:    change.Created,
+	}
+}
+
+// writeFile makes a change to a file on disk
+func writeFile(change util.FileChange) error {
+	if change.Deleted {
+		if err := os.Remove(change.Path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("error deleting file: %w", err)
+		}
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(change.Path), 0o755); err != nil {
+		return fmt.Errorf("error creating directory: %w", err)
+	}
+	if err := os.WriteFile(change.Path, []byte(change.NewContent), 0o644); err != nil {
+		return fmt.Errorf("error writing file: %w", err)
+	}
+	return nil
+}
+
+// write makes a change to a file and records it in the history of the
+// session. When the history can't record it, the file is put back as it was.
+func (w workspaceEditor) write(ctx context.Context, sessionID string, change util.FileChange) error {
+	if err := writeFile(change); err != nil {
+		return err
+	}
+
+	file, err := w.files.GetByPathAndSession(ctx, change.Path, sessionID)
+	if err != nil {
+		if change.Created {
+			_, err = w.files.CreateNew(ctx, sessionID, change.Path)
+		} else {
+			_, err = w.files.Create(ctx, sessionID, change.Path, change.OldContent)
+		}
+		if err != nil {
+			err = fmt.Errorf("error creating file history: %w", err)
+			if undoErr := writeFile(inverse(change)); undoErr != nil {
+				slog.Warn("Error restoring file", "file", change.Path, "error", undoErr)
+				return fmt.Errorf("%w: %w", err, errChangeKept)
+			}
+			return err
+		}
+	} else if file.Content != change.OldContent {
+		// The file was changed outside of floss since, store an intermediate version
+		_, err = w.files.CreateVersion(ctx, sessionID, change.Path, change.OldContent)
+		if err != nil {
+			slog.Debug("Error creating file history version", "error", err)
+		}
+	}
+	_, err = w.files.CreateVersion(ctx, sessionID, change.Path, change.NewContent)
+	if err != nil {
+		slog.Debug("Error creating file history version", "error", err)
+	}
+
+	recordFileWrite(change.Path)
+	if !change.Deleted {
+		recordFileRead(change.Path)
+		notifyLSPs(ctx, w.lspClients, change.Path)
+	}
+	return nil
+}
@@ -0,0 +1,229 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/charmbracelet/x/powernap/pkg/lsp/protocol"
+	"github.com/nom-nom-hub/floss/internal/csync"
+	"github.com/nom-nom-hub/floss/internal/db"
+	"github.com/nom-nom-hub/floss/internal/history"
+	"github.com/nom-nom-hub/floss/internal/lsp"
+	"github.com/nom-nom-hub/floss/internal/permission"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWorkspaceEditorApply(t *testing.T) {
+	t.Parallel()
+
+	conn, err := db.Connect(t.Context(), t.TempDir())
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	queries := db.New(conn)
+	files := history.NewService(queries, conn)
+	_, err = queries.CreateSession(t.Context(), db.CreateSessionParams{ID: "session", Title: "rename"})
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	main := filepath.Join(dir, "main.go")
+	server := filepath.Join(dir, "server.go")
+	require.NoError(t, os.WriteFile(main, []byte("package main\n\nfunc main() { serve() }\n"), 0o644))
+	require.NoError(t, os.WriteFile(server, []byte("package main\n\nfunc serve() {}\n"), 0o644))
+
+	permissions := permission.NewPermissionService(dir, false, nil)
+	requests := permissions.Subscribe(t.Context())
+	editor := workspaceEditor{
+		lspClients:  csync.NewMap[string, *lsp.Client](),
+		permissions: permissions,
+		files:       files,
+		workingDir:  dir,
+	}
+	edit := protocol.WorkspaceEdit{Changes: map[protocol.DocumentURI][]protocol.TextEdit{
+		protocol.URIFromPath(main):   {{Range: protocol.Range{Start: protocol.Position{Line: 2, Character: 14}, End: protocol.Position{Line: 2, Character: 19}}, NewText: "listen"}},
+		protocol.URIFromPath(server): {{Range: protocol.Range{Start: protocol.Position{Line: 2, Character: 5}, End: protocol.Position{Line: 2, Character: 10}}, NewText: "listen"}},
+	}}
+
+	ctx := context.WithValue(t.Context(), SessionIDContextKey, "session")
+	ctx = context.WithValue(ctx, MessageIDContextKey, "message")
+	done := make(chan ToolResponse)
+	go func() {
+		response, err := editor.apply(ctx, ToolCall{ID: "call"}, RenameToolName, "Rename serve to listen", edit)
+		if err != nil {
+			response = NewTextErrorResponse(err.Error())
+		}
+		done <- response
+	}()
+
+	// Nothing is written before the diff of both files is approved
+	request := (<-requests).Payload
+	params := request.Params.(WorkspaceEditPermissionsParams)
+	require.Len(t, params.Changes, 2)
+	require.Equal(t, "Rename serve to listen in 2 files", request.Description)
+	content, err := os.ReadFile(server)
+	require.NoError(t, err)
+	require.Equal(t, "package main\n\nfunc serve() {}\n", string(content))
+	permissions.Grant(request)
+
+	response := <-done
+	require.False(t, response.IsError, response.Content)
+	require.Contains(t, response.Content, "edited main.go (+1 -1)")
+	require.Contains(t, response.Content, "edited server.go (+1 -1)")
+	content, err = os.ReadFile(server)
+	require.NoError(t, err)
+	require.Equal(t, "package main\n\nfunc listen() {}\n", string(content))
+
+	// Both files can be reverted like any other edit
+	_, err = files.RevertSession(t.Context(), "session", false)
+	require.NoError(t, err)
+	content, err = os.ReadFile(main)
+	require.NoError(t, err)
+	require.Equal(t, "package main\n\nfunc main() { serve() }\n", string(content))
+}
+
+func TestSelectCodeAction(t *testing.T) {
+	t.Parallel()
+
+	actions := []protocol.CodeAction{
+		{Title: "Organize Imports", Kind: "source.organizeImports"},
+		{Title: "Extract function", Kind: "refactor.extract"},
+		{Title: "Extract variable", Kind: "refactor.extract"},
+	}
+	tests := []struct {
+		name   string
+		params CodeActionParams
+		want   string
+	}{
+		{name: "exact title, any case", params: CodeActionParams{Title: "organize imports"}, want: "Organize Imports"},
+		{name: "unique part of a title", params: CodeActionParams{Title: "variable"}, want: "Extract variable"},
+		{name: "ambiguous part of a title", params: CodeActionParams{Title: "Extract"}},
+		{name: "no title lists them", params: CodeActionParams{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			action, ok := selectCodeAction(actions, tt.params)
+			require.Equal(t, tt.want != "", ok)
+			require.Equal(t, tt.want, action.Title)
+		})
+	}
+
+	action, ok := selectCodeAction(actions[:1], CodeActionParams{Kind: "source.organizeImports"})
+	require.True(t, ok, "the only action of the kind is applied without a title")
+	require.Equal(t, "Organize Imports", action.Title)
+}
+
+func TestWorkspaceEditorApplyRollsBack(t *testing.T) {
+	t.Parallel()
+
+	conn, err := db.Connect(t.Context(), t.TempDir())
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	queries := db.New(conn)
+	files := history.NewService(queries, conn)
+	_, err = queries.CreateSession(t.Context(), db.CreateSessionParams{ID: "session", Title: "rename"})
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	main := filepath.Join(dir, "main.go")
+	server := filepath.Join(dir, "server.go")
+	require.NoError(t, os.WriteFile(main, []byte("package main\n\nfunc main() { serve() }\n"), 0o644))
+	require.NoError(t, os.WriteFile(server, []byte("package main\n\nfunc serve() {}\n"), 0o644))
+
+	permissions := permission.NewPermissionService(dir, false, nil)
+	requests := permissions.Subscribe(t.Context())
+	editor := workspaceEditor{
+		lspClients:  csync.NewMap[string, *lsp.Client](),
+		permissions: permissions,
+		files:       files,
+		workingDir:  dir,
+	}
+	mainEdit := protocol.WorkspaceEdit{Changes: map[protocol.DocumentURI][]protocol.TextEdit{
+		protocol.URIFromPath(main): {{Range: protocol.Range{Start: protocol.Position{Line: 2, Character: 14}, End: protocol.Position{Line: 2, Character: 19}}, NewText: "listen"}},
+	}}
+	serverEdit := protocol.WorkspaceEdit{Changes: map[protocol.DocumentURI][]protocol.TextEdit{
+		protocol.URIFromPath(server): {{Range: protocol.Range{Start: protocol.Position{Line: 2, Character: 5}, End: protocol.Position{Line: 2, Character: 10}}, NewText: "listen"}},
+	}}
+
+	ctx := context.WithValue(t.Context(), SessionIDContextKey, "session")
+	ctx = context.WithValue(ctx, MessageIDContextKey, "message")
+	done := make(chan error)
+	go func() {
+		_, err := editor.apply(ctx, ToolCall{ID: "call"}, RenameToolName, "Rename serve to listen", mainEdit, serverEdit)
+		done <- err
+	}()
+
+	// server.go can't be written once main.go is
+	request := (<-requests).Payload
+	require.NoError(t, os.Remove(server))
+	require.NoError(t, os.Mkdir(server, 0o755))
+	permissions.Grant(request)
+
+	err = <-done
+	require.ErrorContains(t, err, "the files already changed were restored")
+	content, err := os.ReadFile(main)
+	require.NoError(t, err)
+	require.Equal(t, "package main\n\nfunc main() { serve() }\n", string(content))
+
+	// and the history agrees with the file
+	latest, err := files.GetByPathAndSession(t.Context(), main, "session")
+	require.NoError(t, err)
+	require.Equal(t, string(content), latest.Content)
+}
+
+// failingHistory can't record the history of one file
+type failingHistory struct {
+	history.Service
+	path string
+}
+
+func (h failingHistory) Create(ctx context.Context, sessionID, path, content string) (history.File, error) {
+	if path == h.path {
+		return history.File{}, errors.New("database is locked")
+	}
+	return h.Service.Create(ctx, sessionID, path, content)
+}
+
+func TestWorkspaceEditorApplyRestoresFileItCouldNotRecord(t *testing.T) {
+	t.Parallel()
+
+	conn, err := db.Connect(t.Context(), t.TempDir())
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	queries := db.New(conn)
+	_, err = queries.CreateSession(t.Context(), db.CreateSessionParams{ID: "session", Title: "rename"})
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	main := filepath.Join(dir, "main.go")
+	server := filepath.Join(dir, "server.go")
+	require.NoError(t, os.WriteFile(main, []byte("package main\n\nfunc main() { serve() }\n"), 0o644))
+	require.NoError(t, os.WriteFile(server, []byte("package main\n\nfunc serve() {}\n"), 0o644))
+
+	permissions := permission.NewPermissionService(dir, true, nil)
+	editor := workspaceEditor{
+		lspClients:  csync.NewMap[string, *lsp.Client](),
+		permissions: permissions,
+		files:       failingHistory{Service: history.NewService(queries, conn), path: server},
+		workingDir:  dir,
+	}
+	edit := protocol.WorkspaceEdit{Changes: map[protocol.DocumentURI][]protocol.TextEdit{
+		protocol.URIFromPath(main):   {{Range: protocol.Range{Start: protocol.Position{Line: 2, Character: 14}, End: protocol.Position{Line: 2, Character: 19}}, NewText: "listen"}},
+		protocol.URIFromPath(server): {{Range: protocol.Range{Start: protocol.Position{Line: 2, Character: 5}, End: protocol.Position{Line: 2, Character: 10}}, NewText: "listen"}},
+	}}
+
+	ctx := context.WithValue(t.Context(), SessionIDContextKey, "session")
+	ctx = context.WithValue(ctx, MessageIDContextKey, "message")
+	_, err = editor.apply(ctx, ToolCall{ID: "call"}, RenameToolName, "Rename serve to listen", edit)
+	require.ErrorContains(t, err, "database is locked; the files already changed were restored")
+
+	// Both files are as they were, the one whose history failed included
+	content, err := os.ReadFile(main)
+	require.NoError(t, err)
+	require.Equal(t, "package main\n\nfunc main() { serve() }\n", string(content))
+	content, err = os.ReadFile(server)
+	require.NoError(t, err)
+	require.Equal(t, "package main\n\nfunc serve() {}\n", string(content))
+}
@@ -11,6 +11,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/nom-nom-hub/floss/internal/config"
 	"github.com/nom-nom-hub/floss/internal/csync"
 	"github.com/nom-nom-hub/floss/internal/diff"
 	"github.com/nom-nom-hub/floss/internal/fsext"
@@ -38,6 +39,7 @@ type writeTool struct {
 	lspClients  *csync.Map[string, *lsp.Client]
 	permissions permission.Service
 	files       history.Service
+	formatters  config.Formatters
 	workingDir  string
 }
 
@@ -49,11 +51,12 @@ type WriteResponseMetadata struct {
 
 const WriteToolName = "write"
 
-func NewWriteTool(lspClients *csync.Map[string, *lsp.Client], permissions permission.Service, files history.Service, workingDir string) BaseTool {
+func NewWriteTool(lspClients *csync.Map[string, *lsp.Client], permissions permission.Service, files history.Service, formatters config.Formatters, workingDir string) BaseTool {
 	return &writeTool{
 		lspClients:  lspClients,
 		permissions: permissions,
 		files:       files,
+		formatters:  formatters,
 		workingDir:  workingDir,
 	}
 }
@@ -98,6 +101,7 @@ func (w *writeTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error
 	if !filepath.IsAbs(filePath) {
 		filePath = filepath.Join(w.workingDir, filePath)
 	}
+	params.Content = formatContent(ctx, w.lspClients, w.formatters, w.workingDir, filePath, params.Content)
 
 	fileInfo, err := os.Stat(filePath)
 	if err == nil {
@@ -171,7 +175,11 @@ func (w *writeTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error
 	// Check if file exists in history
 	file, err := w.files.GetByPathAndSession(ctx, filePath, sessionID)
 	if err != nil {
-		_, err = w.files.Create(ctx, sessionID, filePath, oldContent)
+		if fileInfo == nil {
+			_, err = w.files.CreateNew(ctx, sessionID, filePath)
+		} else {
+			_, err = w.files.Create(ctx, sessionID, filePath, oldContent)
+		}
 		if err != nil {
 			// Log error but don't fail the operation
 			return ToolResponse{}, fmt.Errorf("error creating file history: %w", err)
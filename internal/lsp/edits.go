@@ -0,0 +1,129 @@
+package lsp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/charmbracelet/x/powernap/pkg/lsp/protocol"
+)
+
+// editCapture collects the edits the server asks to apply while a command
+// runs
+type editCapture struct {
+	mu    sync.Mutex
+	edits []protocol.WorkspaceEdit
+}
+
+// Rename returns the edit renaming the symbol at the position, without
+// applying it. The file must be open.
+func (c *Client) Rename(ctx context.Context, path string, position protocol.Position, newName string) (protocol.WorkspaceEdit, error) {
+	var edit *protocol.WorkspaceEdit
+	err := c.call(ctx, methodRename, protocol.RenameParams{
+		TextDocument: protocol.TextDocumentIdentifier{URI: protocol.URIFromPath(path)},
+		Position:     position,
+		NewName:      newName,
+	}, &edit)
+	if err != nil || edit == nil {
+		return protocol.WorkspaceEdit{}, err
+	}
+	return *edit, nil
+}
+
+// CodeActions returns the code actions for the range, with the diagnostics
+// in it as context. Only lists the actions of the kinds when given. Commands
+// the server lists on their own are returned as actions running them. The
+// file must be open.
+func (c *Client) CodeActions(ctx context.Context, path string, rng protocol.Range, only []protocol.CodeActionKind) ([]protocol.CodeAction, error) {
+	uri := protocol.URIFromPath(path)
+	diagnostics := []protocol.Diagnostic{}
+	for _, diagnostic := range c.GetFileDiagnostics(uri) {
+		if diagnostic.Range.Start.Line <= rng.End.Line && diagnostic.Range.End.Line >= rng.Start.Line {
+			diagnostics = append(diagnostics, diagnostic)
+		}
+	}
+
+	var result []json.RawMessage
+	err := c.call(ctx, methodCodeAction, protocol.CodeActionParams{
+		TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+		Range:        rng,
+		Context:      protocol.CodeActionContext{Diagnostics: diagnostics, Only: only},
+	}, &result)
+	if err != nil {
+		return nil, err
+	}
+
+	actions := make([]protocol.CodeAction, 0, len(result))
+	for _, item := range result {
+		var kind struct {
+			Command json.RawMessage `json:"command"`
+		}
+		if err := json.Unmarshal(item, &kind); err != nil {
+			return nil, err
+		}
+		if len(kind.Command) > 0 && kind.Command[0] == '"' {
+			var command protocol.Command
+			if err := json.Unmarshal(item, &command); err != nil {
+				return nil, err
+			}
+			actions = append(actions, protocol.CodeAction{Title: command.Title, Command: &command})
+			continue
+		}
+		var action protocol.CodeAction
+		if err := json.Unmarshal(item, &action); err != nil {
+			return nil, err
+		}
+		actions = append(actions, action)
+	}
+	return actions, nil
+}
+
+// ResolveCodeAction fills in the edit of a code action the server left out
+// when listing it
+func (c *Client) ResolveCodeAction(ctx context.Context, action protocol.CodeAction) (protocol.CodeAction, error) {
+	var resolved protocol.CodeAction
+	if err := c.call(ctx, methodCodeActionResolve, action, &resolved); err != nil {
+		return action, err
+	}
+	return resolved, nil
+}
+
+// ExecuteCommand runs a command on the server and returns the edits it asked
+// to apply meanwhile. They are not applied: the server is told they were so
+// the command completes, and they are left to the caller to review.
+func (c *Client) ExecuteCommand(ctx context.Context, command protocol.Command) ([]protocol.WorkspaceEdit, error) {
+	c.commandMu.Lock()
+	defer c.commandMu.Unlock()
+
+	capture := &editCapture{}
+	c.commandEdits.Store(capture)
+	defer c.commandEdits.Store(nil)
+
+	var result json.RawMessage
+	err := c.call(ctx, methodExecuteCommand, protocol.ExecuteCommandParams{
+		Command:   command.Command,
+		Arguments: command.Arguments,
+	}, &result)
+
+	capture.mu.Lock()
+	defer capture.mu.Unlock()
+	return capture.edits, err
+}
+
+// handleApplyEdit collects the edits of a running command and applies the
+// others
+func (c *Client) handleApplyEdit(ctx context.Context, method string, params json.RawMessage) (any, error) {
+	capture := c.commandEdits.Load()
+	if capture == nil {
+		return HandleApplyEdit(ctx, method, params)
+	}
+	var edit protocol.ApplyWorkspaceEditParams
+	if err := json.Unmarshal(params, &edit); err != nil {
+		return nil, fmt.Errorf("invalid workspace edit: %w", err)
+	}
+	capture.mu.Lock()
+	defer capture.mu.Unlock()
+	capture.edits = append(capture.edits, edit.Edit)
+	return protocol.ApplyWorkspaceEditResult{Applied: true}, nil
+}
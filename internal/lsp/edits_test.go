@@ -0,0 +1,53 @@
+package lsp
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/charmbracelet/x/powernap/pkg/lsp/protocol"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRefactoring(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	main := filepath.Join(dir, "main.go")
+	uri := string(protocol.URIFromPath(main))
+	content := "package main\n\nimport \"os\"\n\nfunc main() {}\n"
+	require.NoError(t, os.WriteFile(main, []byte(content), 0o644))
+	removeImport := `{"changes":{"` + uri + `":[{"range":{"start":{"line":2,"character":0},"end":{"line":3,"character":0}},"newText":""}]}}`
+
+	client := newFakeClient(t, map[string]string{
+		methodRename:            `{"documentChanges":[{"textDocument":{"uri":"` + uri + `","version":1},"edits":[{"range":{"start":{"line":4,"character":5},"end":{"line":4,"character":9}},"newText":"run"}]}]}`,
+		methodCodeAction:        `[{"title":"Organize Imports","kind":"source.organizeImports","data":{"id":1}},{"title":"Run tests","command":"test.run","arguments":["./..."]}]`,
+		methodCodeActionResolve: `{"title":"Organize Imports","kind":"source.organizeImports","edit":` + removeImport + `}`,
+		"workspace/applyEdit":   removeImport,
+	})
+	require.NoError(t, client.OpenFileOnDemand(t.Context(), main))
+
+	edit, err := client.Rename(t.Context(), main, protocol.Position{Line: 4, Character: 6}, "run")
+	require.NoError(t, err)
+	require.Len(t, edit.DocumentChanges, 1)
+	require.Equal(t, protocol.DocumentURI(uri), edit.DocumentChanges[0].TextDocumentEdit.TextDocument.URI)
+
+	actions, err := client.CodeActions(t.Context(), main, protocol.Range{End: protocol.Position{Line: 5}}, nil)
+	require.NoError(t, err)
+	require.Len(t, actions, 2)
+	require.Nil(t, actions[0].Edit)
+	require.Equal(t, "Run tests", actions[1].Title, "commands are returned as actions")
+	require.Equal(t, "test.run", actions[1].Command.Command)
+
+	resolved, err := client.ResolveCodeAction(t.Context(), actions[0])
+	require.NoError(t, err)
+	require.NotNil(t, resolved.Edit)
+	require.Len(t, resolved.Edit.Changes[protocol.DocumentURI(uri)], 1)
+
+	edits, err := client.ExecuteCommand(t.Context(), *actions[1].Command)
+	require.NoError(t, err)
+	require.Len(t, edits, 1, "the edit the server asked to apply is returned")
+	data, err := os.ReadFile(main)
+	require.NoError(t, err)
+	require.Equal(t, content, string(data), "and not applied")
+}
@@ -0,0 +1,80 @@
+package lsp
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+
+	"github.com/charmbracelet/x/powernap/pkg/lsp/protocol"
+	"github.com/nom-nom-hub/floss/internal/lsp/util"
+)
+
+// FormatsFile checks if this LSP client is configured to format the file
+func (c *Client) FormatsFile(path string) bool {
+	return c.config.Format && c.HandlesFile(path)
+}
+
+// Format returns the content formatted by the server as the content of the
+// file, which doesn't have to be written yet. The server is shown the content
+// for the request only: an open file gets its content on disk back after, an
+// unopened one is closed again.
+func (c *Client) Format(ctx context.Context, path, content string) (string, error) {
+	uri := string(protocol.URIFromPath(path))
+	if info, isOpen := c.openFiles.Get(uri); isOpen {
+		info.Version++
+		if err := c.client.NotifyDidChangeTextDocument(ctx, uri, int(info.Version), wholeDocument(content)); err != nil {
+			return "", err
+		}
+		defer func() {
+			if err := c.NotifyChange(context.WithoutCancel(ctx), path); err != nil {
+				slog.Debug("Error restoring formatted file", "file", path, "error", err)
+			}
+		}()
+	} else {
+		if err := c.client.NotifyDidOpenTextDocument(ctx, uri, string(DetectLanguageID(uri)), 1, content); err != nil {
+			return "", err
+		}
+		defer func() {
+			if err := c.client.NotifyDidCloseTextDocument(context.WithoutCancel(ctx), uri); err != nil {
+				slog.Debug("Error closing formatted file", "file", path, "error", err)
+			}
+		}()
+	}
+
+	var edits []protocol.TextEdit
+	err := c.call(ctx, methodFormatting, protocol.DocumentFormattingParams{
+		TextDocument: protocol.TextDocumentIdentifier{URI: protocol.DocumentURI(uri)},
+		Options:      formattingOptions(content),
+	}, &edits)
+	if err != nil {
+		return "", err
+	}
+	return util.ApplyTextEdits(content, edits)
+}
+
+func wholeDocument(content string) []protocol.TextDocumentContentChangeEvent {
+	return []protocol.TextDocumentContentChangeEvent{
+		{
+			Value: protocol.TextDocumentContentChangeWholeDocument{
+				Text: content,
+			},
+		},
+	}
+}
+
+// formattingOptions follows the indentation of the first indented line, for
+// the servers leaving it to the editor
+func formattingOptions(content string) protocol.FormattingOptions {
+	options := protocol.FormattingOptions{TabSize: 4, InsertSpaces: true}
+	for _, line := range strings.Split(content, "\n") {
+		if strings.HasPrefix(line, "\t") {
+			options.InsertSpaces = false
+			break
+		}
+		if spaces := len(line) - len(strings.TrimLeft(line, " ")); spaces > 0 && strings.TrimSpace(line) != "" {
+			options.TabSize = uint32(min(spaces, 8))
+			break
+		}
+	}
+	return options
+}
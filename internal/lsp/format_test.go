@@ -0,0 +1,60 @@
+package lsp
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/charmbracelet/x/powernap/pkg/lsp/protocol"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormat(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	main := filepath.Join(dir, "main.go")
+	uri := string(protocol.URIFromPath(main))
+	require.NoError(t, os.WriteFile(main, []byte("package main\n"), 0o644))
+
+	client := newFakeClient(t, map[string]string{
+		methodFormatting: `[{"range":{"start":{"line":2,"character":0},"end":{"line":2,"character":22}},"newText":"func main() {}"}]`,
+	})
+	content := "package main\n\nfunc main()   {     }\n"
+	formatted, err := client.Format(t.Context(), main, content)
+	require.NoError(t, err)
+	require.Equal(t, "package main\n\nfunc main() {}\n", formatted)
+	require.False(t, client.IsFileOpen(main), "a file opened to format it is closed again")
+
+	require.NoError(t, client.OpenFileOnDemand(t.Context(), main))
+	formatted, err = client.Format(t.Context(), main, content)
+	require.NoError(t, err)
+	require.Equal(t, "package main\n\nfunc main() {}\n", formatted)
+	info, ok := client.openFiles.Get(uri)
+	require.True(t, ok)
+	require.Equal(t, int32(3), info.Version, "the content on disk is restored after formatting")
+
+	data, err := os.ReadFile(main)
+	require.NoError(t, err)
+	require.Equal(t, "package main\n", string(data), "nothing is written")
+}
+
+func TestFormattingOptions(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		content string
+		want    protocol.FormattingOptions
+	}{
+		{name: "tabs", content: "func main() {\n\tserve()\n}\n", want: protocol.FormattingOptions{TabSize: 4}},
+		{name: "two spaces", content: "{\n  \"a\": 1\n}\n", want: protocol.FormattingOptions{TabSize: 2, InsertSpaces: true}},
+		{name: "no indentation", content: "a\nb\n", want: protocol.FormattingOptions{TabSize: 4, InsertSpaces: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			require.Equal(t, tt.want, formattingOptions(tt.content))
+		})
+	}
+}
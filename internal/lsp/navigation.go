@@ -0,0 +1,195 @@
+package lsp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/charmbracelet/x/powernap/pkg/lsp/protocol"
+	"github.com/charmbracelet/x/powernap/pkg/transport"
+)
+
+// LSP methods powernap has no constants for
+const (
+	methodDocumentSymbol    = "textDocument/documentSymbol"
+	methodWorkspaceSymbol   = "workspace/symbol"
+	methodRename            = "textDocument/rename"
+	methodCodeAction        = "textDocument/codeAction"
+	methodCodeActionResolve = "codeAction/resolve"
+	methodExecuteCommand    = "workspace/executeCommand"
+	methodFormatting        = "textDocument/formatting"
+)
+
+// Definition returns where the symbol at the position is defined. The file
+// must be open.
+func (c *Client) Definition(ctx context.Context, path string, position protocol.Position) ([]protocol.Location, error) {
+	var result json.RawMessage
+	err := c.call(ctx, "textDocument/definition", protocol.DefinitionParams{
+		TextDocumentPositionParams: textDocumentPosition(path, position),
+	}, &result)
+	if err != nil {
+		return nil, err
+	}
+	return decodeLocations(result)
+}
+
+// References returns where the symbol at the position is used. The file
+// must be open.
+func (c *Client) References(ctx context.Context, path string, position protocol.Position, includeDeclaration bool) ([]protocol.Location, error) {
+	var result json.RawMessage
+	err := c.call(ctx, "textDocument/references", protocol.ReferenceParams{
+		TextDocumentPositionParams: textDocumentPosition(path, position),
+		Context:                    protocol.ReferenceContext{IncludeDeclaration: includeDeclaration},
+	}, &result)
+	if err != nil {
+		return nil, err
+	}
+	return decodeLocations(result)
+}
+
+// Hover returns the type and documentation of the symbol at the position as
+// text, or an empty string when the server has none. The file must be open.
+func (c *Client) Hover(ctx context.Context, path string, position protocol.Position) (string, error) {
+	var result struct {
+		Contents json.RawMessage `json:"contents"`
+	}
+	err := c.call(ctx, "textDocument/hover", textDocumentPosition(path, position), &result)
+	if err != nil {
+		return "", err
+	}
+	return decodeHoverContents(result.Contents), nil
+}
+
+// DocumentSymbols returns the symbols of the file as a tree. Servers that
+// only list them flat give symbols without children. The file must be open.
+func (c *Client) DocumentSymbols(ctx context.Context, path string) ([]protocol.DocumentSymbol, error) {
+	var result json.RawMessage
+	err := c.call(ctx, methodDocumentSymbol, protocol.DocumentSymbolParams{
+		TextDocument: protocol.TextDocumentIdentifier{URI: protocol.URIFromPath(path)},
+	}, &result)
+	if err != nil {
+		return nil, err
+	}
+	return decodeDocumentSymbols(result)
+}
+
+// WorkspaceSymbols searches the symbols of the workspace by name
+func (c *Client) WorkspaceSymbols(ctx context.Context, query string) ([]protocol.SymbolInformation, error) {
+	var symbols []protocol.SymbolInformation
+	err := c.call(ctx, methodWorkspaceSymbol, protocol.WorkspaceSymbolParams{Query: query}, &symbols)
+	return symbols, err
+}
+
+// call sends a request to the server. powernap only has methods for
+// completion and hover, whose results it decodes more strictly than servers
+// answer, so this goes through its connection directly.
+func (c *Client) call(ctx context.Context, method string, params, result any) error {
+	if c.GetServerState() != StateReady {
+		return fmt.Errorf("%s is not ready yet", c.name)
+	}
+	conn := reflect.ValueOf(c.client).Elem().FieldByName("conn")
+	if !conn.IsValid() || conn.IsNil() {
+		return fmt.Errorf("%s has no connection", c.name)
+	}
+	if err := (*transport.Connection)(conn.UnsafePointer()).Call(ctx, method, params, result); err != nil {
+		return fmt.Errorf("%s request failed: %w", method, err)
+	}
+	return nil
+}
+
+func textDocumentPosition(path string, position protocol.Position) protocol.TextDocumentPositionParams {
+	return protocol.TextDocumentPositionParams{
+		TextDocument: protocol.TextDocumentIdentifier{URI: protocol.URIFromPath(path)},
+		Position:     position,
+	}
+}
+
+// decodeLocations decodes a location, a list of them or of location links
+func decodeLocations(data json.RawMessage) ([]protocol.Location, error) {
+	if len(data) == 0 || string(data) == "null" {
+		return nil, nil
+	}
+	if data[0] == '{' {
+		var location protocol.Location
+		if err := json.Unmarshal(data, &location); err != nil {
+			return nil, err
+		}
+		return []protocol.Location{location}, nil
+	}
+	var items []struct {
+		protocol.Location
+		TargetURI            protocol.DocumentURI `json:"targetUri"`
+		TargetSelectionRange protocol.Range       `json:"targetSelectionRange"`
+	}
+	if err := json.Unmarshal(data, &items); err != nil {
+		return nil, err
+	}
+	locations := make([]protocol.Location, len(items))
+	for i, item := range items {
+		locations[i] = item.Location
+		if item.TargetURI != "" {
+			locations[i] = protocol.Location{URI: item.TargetURI, Range: item.TargetSelectionRange}
+		}
+	}
+	return locations, nil
+}
+
+// decodeHoverContents turns markup content, a marked string or a list of
+// them into text
+func decodeHoverContents(data json.RawMessage) string {
+	var text string
+	if json.Unmarshal(data, &text) == nil {
+		return text
+	}
+	var markup struct {
+		Language string `json:"language"`
+		Value    string `json:"value"`
+	}
+	if json.Unmarshal(data, &markup) == nil {
+		if markup.Language != "" {
+			return "```" + markup.Language + "\n" + markup.Value + "\n```"
+		}
+		return markup.Value
+	}
+	var list []json.RawMessage
+	if json.Unmarshal(data, &list) == nil {
+		parts := make([]string, 0, len(list))
+		for _, item := range list {
+			if part := decodeHoverContents(item); part != "" {
+				parts = append(parts, part)
+			}
+		}
+		return strings.Join(parts, "\n\n")
+	}
+	return ""
+}
+
+// decodeDocumentSymbols decodes a symbol tree, or a flat list of symbol
+// information as symbols without children
+func decodeDocumentSymbols(data json.RawMessage) ([]protocol.DocumentSymbol, error) {
+	if len(data) == 0 || string(data) == "null" {
+		return nil, nil
+	}
+	var infos []protocol.SymbolInformation
+	if err := json.Unmarshal(data, &infos); err != nil {
+		return nil, err
+	}
+	if len(infos) == 0 || infos[0].Location.URI == "" {
+		var symbols []protocol.DocumentSymbol
+		err := json.Unmarshal(data, &symbols)
+		return symbols, err
+	}
+	symbols := make([]protocol.DocumentSymbol, len(infos))
+	for i, info := range infos {
+		symbols[i] = protocol.DocumentSymbol{
+			Name:           info.Name,
+			Detail:         info.ContainerName,
+			Kind:           info.Kind,
+			Range:          info.Location.Range,
+			SelectionRange: info.Location.Range,
+		}
+	}
+	return symbols, nil
+}
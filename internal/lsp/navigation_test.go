@@ -0,0 +1,178 @@
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/charmbracelet/x/powernap/pkg/lsp/protocol"
+	"github.com/nom-nom-hub/floss/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeServerEnv makes the test binary act as a language server answering
+// each method with the result the variable maps it to
+const fakeServerEnv = "FLOSS_FAKE_LSP_RESULTS"
+
+func TestMain(m *testing.M) {
+	if results := os.Getenv(fakeServerEnv); results != "" {
+		runFakeServer(results)
+		os.Exit(0)
+	}
+	dir, err := os.MkdirTemp("", "floss-lsp")
+	if err != nil {
+		panic(err)
+	}
+	if _, err := config.Init(dir, "", false); err != nil {
+		panic("Failed to initialize config: " + err.Error())
+	}
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+func runFakeServer(results string) {
+	var answers map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(results), &answers); err != nil {
+		panic(err)
+	}
+	answers["initialize"] = json.RawMessage(`{"capabilities":{}}`)
+	answers["shutdown"] = json.RawMessage(`null`)
+
+	// An edit to apply is sent to the client when a command runs, and the
+	// command answered once the client applied it
+	var pendingCommand json.RawMessage
+	reader := textproto.NewReader(bufio.NewReader(os.Stdin))
+	for {
+		header, err := reader.ReadMIMEHeader()
+		if err != nil {
+			return
+		}
+		length, _ := strconv.Atoi(header.Get("Content-Length"))
+		body := make([]byte, length)
+		if _, err := io.ReadFull(reader.R, body); err != nil {
+			return
+		}
+		var request struct {
+			ID     json.RawMessage `json:"id"`
+			Method string          `json:"method"`
+		}
+		if err := json.Unmarshal(body, &request); err != nil {
+			return
+		}
+		switch {
+		case request.Method == "exit":
+			return
+		case request.ID == nil:
+			continue
+		case request.Method == "" && pendingCommand != nil:
+			writeMessage(fmt.Sprintf(`{"jsonrpc":"2.0","id":%s,"result":null}`, pendingCommand))
+			pendingCommand = nil
+			continue
+		case request.Method == methodExecuteCommand && answers["workspace/applyEdit"] != nil:
+			writeMessage(fmt.Sprintf(`{"jsonrpc":"2.0","id":"apply","method":"workspace/applyEdit","params":{"edit":%s}}`, answers["workspace/applyEdit"]))
+			pendingCommand = request.ID
+			continue
+		}
+		result, ok := answers[request.Method]
+		if !ok {
+			result = json.RawMessage(`null`)
+		}
+		writeMessage(fmt.Sprintf(`{"jsonrpc":"2.0","id":%s,"result":%s}`, request.ID, result))
+	}
+}
+
+func writeMessage(message string) {
+	fmt.Printf("Content-Length: %d\r\n\r\n%s", len(message), message)
+}
+
+// newFakeClient starts the test binary as a language server for Go files
+func newFakeClient(t *testing.T, results map[string]string) *Client {
+	t.Helper()
+	raw := make(map[string]json.RawMessage, len(results))
+	for method, result := range results {
+		raw[method] = json.RawMessage(result)
+	}
+	encoded, err := json.Marshal(raw)
+	require.NoError(t, err)
+	client, err := New(t.Context(), "fake", config.LSPConfig{
+		Command:   os.Args[0],
+		FileTypes: []string{"go"},
+		Env:       map[string]string{fakeServerEnv: string(encoded)},
+	})
+	require.NoError(t, err)
+	_, err = client.Initialize(t.Context(), t.TempDir())
+	require.NoError(t, err)
+	client.SetServerState(StateReady)
+	t.Cleanup(func() { client.Close(t.Context()) })
+	return client
+}
+
+func TestNavigation(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	main := filepath.Join(dir, "main.go")
+	uri := string(protocol.URIFromPath(main))
+	require.NoError(t, os.WriteFile(main, []byte("package main\n\nfunc main() {}\n"), 0o644))
+
+	client := newFakeClient(t, map[string]string{
+		"textDocument/definition": `[{"targetUri":"` + uri + `","targetRange":{"start":{"line":2,"character":0},"end":{"line":2,"character":14}},"targetSelectionRange":{"start":{"line":2,"character":5},"end":{"line":2,"character":9}}}]`,
+		"textDocument/references": `[{"uri":"` + uri + `","range":{"start":{"line":2,"character":5},"end":{"line":2,"character":9}}}]`,
+		"textDocument/hover":      `{"contents":[{"language":"go","value":"func main()"},"The entry point"]}`,
+		methodDocumentSymbol:      `[{"name":"main","kind":12,"location":{"uri":"` + uri + `","range":{"start":{"line":2,"character":0},"end":{"line":2,"character":14}}}}]`,
+		methodWorkspaceSymbol:     `[{"name":"main","kind":12,"containerName":"main","location":{"uri":"` + uri + `","range":{"start":{"line":2,"character":5},"end":{"line":2,"character":9}}}}]`,
+	})
+	require.NoError(t, client.OpenFileOnDemand(t.Context(), main))
+	position := protocol.Position{Line: 2, Character: 6}
+
+	definitions, err := client.Definition(t.Context(), main, position)
+	require.NoError(t, err)
+	require.Len(t, definitions, 1)
+	require.Equal(t, protocol.DocumentURI(uri), definitions[0].URI)
+	require.Equal(t, uint32(5), definitions[0].Range.Start.Character)
+
+	references, err := client.References(t.Context(), main, position, false)
+	require.NoError(t, err)
+	require.Len(t, references, 1)
+
+	hover, err := client.Hover(t.Context(), main, position)
+	require.NoError(t, err)
+	require.Equal(t, "```go\nfunc main()\n```\n\nThe entry point", hover)
+
+	symbols, err := client.DocumentSymbols(t.Context(), main)
+	require.NoError(t, err)
+	require.Len(t, symbols, 1)
+	require.Equal(t, "main", symbols[0].Name)
+	require.Equal(t, uint32(2), symbols[0].Range.Start.Line)
+
+	found, err := client.WorkspaceSymbols(t.Context(), "mai")
+	require.NoError(t, err)
+	require.Len(t, found, 1)
+	require.Equal(t, "main", found[0].ContainerName)
+
+	client.SetServerState(StateStarting)
+	_, err = client.Definition(t.Context(), main, position)
+	require.EqualError(t, err, "fake is not ready yet")
+}
+
+func TestDecodeDocumentSymbols(t *testing.T) {
+	t.Parallel()
+
+	symbols, err := decodeDocumentSymbols(json.RawMessage(`[{"name":"Server","kind":23,"range":{"start":{"line":4,"character":0},"end":{"line":9,"character":1}},"selectionRange":{"start":{"line":4,"character":5},"end":{"line":4,"character":11}},"children":[{"name":"addr","kind":8,"range":{"start":{"line":5,"character":1},"end":{"line":5,"character":12}},"selectionRange":{"start":{"line":5,"character":1},"end":{"line":5,"character":5}}}]}]`))
+	require.NoError(t, err)
+	require.Len(t, symbols, 1)
+	require.Equal(t, "Server", symbols[0].Name)
+	require.Len(t, symbols[0].Children, 1)
+	require.Equal(t, "addr", symbols[0].Children[0].Name)
+
+	symbols, err = decodeDocumentSymbols(json.RawMessage(`null`))
+	require.NoError(t, err)
+	require.Empty(t, symbols)
+}
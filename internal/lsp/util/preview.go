@@ -0,0 +1,171 @@
+package util
+
+import (
+	"fmt"
+	"os"
+	"slices"
+
+	"github.com/charmbracelet/x/powernap/pkg/lsp/protocol"
+)
+
+// FileChange is the content of a file before and after a workspace edit
+type FileChange struct {
+	Path       string `json:"path"`
+	OldContent string `json:"old_content"`
+	NewContent string `json:"new_content"`
+	// Created is set when the file did not exist before the edit
+	Created bool `json:"created,omitempty"`
+	// Deleted is set when the edit removes the file
+	Deleted bool `json:"deleted,omitempty"`
+}
+
+// previewFile tracks a file while the edits are applied in memory
+type previewFile struct {
+	FileChange
+	existed bool
+	exists  bool
+}
+
+// preview holds the files touched by workspace edits, in the order they are
+// first touched
+type preview struct {
+	files []*previewFile
+	paths map[string]*previewFile
+}
+
+func (p *preview) file(uri protocol.DocumentURI) (*previewFile, error) {
+	path, err := uri.Path()
+	if err != nil {
+		return nil, fmt.Errorf("invalid URI: %w", err)
+	}
+	if f, ok := p.paths[path]; ok {
+		return f, nil
+	}
+	f := &previewFile{FileChange: FileChange{Path: path}}
+	content, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		f.OldContent, f.NewContent = string(content), string(content)
+		f.existed, f.exists = true, true
+	case !os.IsNotExist(err):
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	p.paths[path] = f
+	p.files = append(p.files, f)
+	return f, nil
+}
+
+func (p *preview) editText(uri protocol.DocumentURI, edits []protocol.TextEdit) error {
+	f, err := p.file(uri)
+	if err != nil {
+		return err
+	}
+	if !f.exists {
+		return fmt.Errorf("cannot edit %s, it does not exist", f.Path)
+	}
+	content, err := editContent([]byte(f.NewContent), edits)
+	if err != nil {
+		return fmt.Errorf("failed to apply text edits to %s: %w", f.Path, err)
+	}
+	f.NewContent = string(content)
+	return nil
+}
+
+func (p *preview) change(change protocol.DocumentChange) error {
+	switch {
+	case change.CreateFile != nil:
+		f, err := p.file(change.CreateFile.URI)
+		if err != nil {
+			return err
+		}
+		options := change.CreateFile.Options
+		if f.exists && options != nil && options.IgnoreIfExists && !options.Overwrite {
+			return nil
+		}
+		f.NewContent, f.exists = "", true
+	case change.DeleteFile != nil:
+		f, err := p.file(change.DeleteFile.URI)
+		if err != nil {
+			return err
+		}
+		if info, err := os.Stat(f.Path); err == nil && info.IsDir() {
+			return fmt.Errorf("deleting directory %s is not supported", f.Path)
+		}
+		if !f.exists {
+			if options := change.DeleteFile.Options; options != nil && options.IgnoreIfNotExists {
+				return nil
+			}
+			return fmt.Errorf("cannot delete %s, it does not exist", f.Path)
+		}
+		f.NewContent, f.exists = "", false
+	case change.RenameFile != nil:
+		from, err := p.file(change.RenameFile.OldURI)
+		if err != nil {
+			return err
+		}
+		to, err := p.file(change.RenameFile.NewURI)
+		if err != nil {
+			return err
+		}
+		if !from.exists {
+			return fmt.Errorf("cannot rename %s, it does not exist", from.Path)
+		}
+		if to.exists {
+			options := change.RenameFile.Options
+			if options != nil && options.IgnoreIfExists && !options.Overwrite {
+				return nil
+			}
+			if options == nil || !options.Overwrite {
+				return fmt.Errorf("target file already exists and overwrite is not allowed: %s", to.Path)
+			}
+		}
+		to.NewContent, to.exists = from.NewContent, true
+		from.NewContent, from.exists = "", false
+	case change.TextDocumentEdit != nil:
+		textEdits := make([]protocol.TextEdit, len(change.TextDocumentEdit.Edits))
+		for i, edit := range change.TextDocumentEdit.Edits {
+			var err error
+			textEdits[i], err = edit.AsTextEdit()
+			if err != nil {
+				return fmt.Errorf("invalid edit type: %w", err)
+			}
+		}
+		return p.editText(change.TextDocumentEdit.TextDocument.URI, textEdits)
+	}
+	return nil
+}
+
+// PreviewWorkspaceEdit returns how the edits, applied one after the other,
+// would change the files, without writing them. Files the edits leave as
+// they were are left out.
+func PreviewWorkspaceEdit(edits ...protocol.WorkspaceEdit) ([]FileChange, error) {
+	p := &preview{paths: make(map[string]*previewFile)}
+	for _, edit := range edits {
+		uris := make([]protocol.DocumentURI, 0, len(edit.Changes))
+		for uri := range edit.Changes {
+			uris = append(uris, uri)
+		}
+		slices.Sort(uris)
+		for _, uri := range uris {
+			if err := p.editText(uri, edit.Changes[uri]); err != nil {
+				return nil, err
+			}
+		}
+		for _, change := range edit.DocumentChanges {
+			if err := p.change(change); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	var changes []FileChange
+	for _, f := range p.files {
+		if f.existed == f.exists && (!f.exists || f.OldContent == f.NewContent) {
+			continue
+		}
+		f.Created = !f.existed
+		f.Deleted = !f.exists
+		changes = append(changes, f.FileChange)
+	}
+	return changes, nil
+}
@@ -0,0 +1,112 @@
+package util
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/charmbracelet/x/powernap/pkg/lsp/protocol"
+	"github.com/stretchr/testify/require"
+)
+
+func textEdit(line, start, end uint32, text string) protocol.TextEdit {
+	return protocol.TextEdit{
+		Range: protocol.Range{
+			Start: protocol.Position{Line: line, Character: start},
+			End:   protocol.Position{Line: line, Character: end},
+		},
+		NewText: text,
+	}
+}
+
+func TestPreviewWorkspaceEdit(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	main := filepath.Join(dir, "main.go")
+	server := filepath.Join(dir, "server.go")
+	renamed := filepath.Join(dir, "http_server.go")
+	unchanged := filepath.Join(dir, "unchanged.go")
+	require.NoError(t, os.WriteFile(main, []byte("package main\n\nfunc main() { serve() }\n"), 0o644))
+	require.NoError(t, os.WriteFile(server, []byte("package main\n\nfunc serve() {}\n"), 0o644))
+	require.NoError(t, os.WriteFile(unchanged, []byte("package main\n"), 0o644))
+
+	changes, err := PreviewWorkspaceEdit(
+		protocol.WorkspaceEdit{
+			Changes: map[protocol.DocumentURI][]protocol.TextEdit{
+				protocol.URIFromPath(main):      {textEdit(2, 14, 19, "listen")},
+				protocol.URIFromPath(unchanged): {textEdit(0, 8, 12, "main")},
+			},
+		},
+		protocol.WorkspaceEdit{
+			DocumentChanges: []protocol.DocumentChange{
+				{TextDocumentEdit: &protocol.TextDocumentEdit{
+					TextDocument: protocol.OptionalVersionedTextDocumentIdentifier{
+						TextDocumentIdentifier: protocol.TextDocumentIdentifier{URI: protocol.URIFromPath(server)},
+					},
+					Edits: []protocol.Or_TextDocumentEdit_edits_Elem{{Value: textEdit(2, 5, 10, "listen")}},
+				}},
+				{RenameFile: &protocol.RenameFile{
+					Kind:   "rename",
+					OldURI: protocol.URIFromPath(server),
+					NewURI: protocol.URIFromPath(renamed),
+				}},
+			},
+		},
+	)
+	require.NoError(t, err)
+	require.Equal(t, []FileChange{
+		{
+			Path:       main,
+			OldContent: "package main\n\nfunc main() { serve() }\n",
+			NewContent: "package main\n\nfunc main() { listen() }\n",
+		},
+		{
+			Path:       server,
+			OldContent: "package main\n\nfunc serve() {}\n",
+			Deleted:    true,
+		},
+		{
+			Path:       renamed,
+			NewContent: "package main\n\nfunc listen() {}\n",
+			Created:    true,
+		},
+	}, changes)
+
+	// Nothing is written
+	content, err := os.ReadFile(main)
+	require.NoError(t, err)
+	require.Equal(t, "package main\n\nfunc main() { serve() }\n", string(content))
+	require.NoFileExists(t, renamed)
+}
+
+func TestPreviewWorkspaceEditErrors(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a.go")
+	b := filepath.Join(dir, "b.go")
+	require.NoError(t, os.WriteFile(a, []byte("package a\n"), 0o644))
+	require.NoError(t, os.WriteFile(b, []byte("package b\n"), 0o644))
+
+	_, err := PreviewWorkspaceEdit(protocol.WorkspaceEdit{
+		Changes: map[protocol.DocumentURI][]protocol.TextEdit{
+			protocol.URIFromPath(filepath.Join(dir, "missing.go")): {textEdit(0, 0, 0, "x")},
+		},
+	})
+	require.ErrorContains(t, err, "does not exist")
+
+	_, err = PreviewWorkspaceEdit(protocol.WorkspaceEdit{
+		DocumentChanges: []protocol.DocumentChange{
+			{RenameFile: &protocol.RenameFile{Kind: "rename", OldURI: protocol.URIFromPath(a), NewURI: protocol.URIFromPath(b)}},
+		},
+	})
+	require.ErrorContains(t, err, "overwrite is not allowed")
+
+	_, err = PreviewWorkspaceEdit(protocol.WorkspaceEdit{
+		Changes: map[protocol.DocumentURI][]protocol.TextEdit{
+			protocol.URIFromPath(a): {textEdit(0, 0, 7, "x"), textEdit(0, 5, 9, "y")},
+		},
+	})
+	require.ErrorContains(t, err, "overlapping edits")
+}
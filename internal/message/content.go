@@ -26,6 +26,7 @@ const (
 	FinishReasonCanceled         FinishReason = "canceled"
 	FinishReasonError            FinishReason = "error"
 	FinishReasonPermissionDenied FinishReason = "permission_denied"
+	FinishReasonLoopDetected     FinishReason = "loop_detected"
 
 	// Should never happen
 	FinishReasonUnknown FinishReason = "unknown"
@@ -120,6 +121,8 @@ type Message struct {
 	Parts     []ContentPart
 	Model     string
 	Provider  string
+	// Pinned messages survive compaction verbatim
+	Pinned    bool
 	CreatedAt int64
 	UpdatedAt int64
 }
@@ -23,6 +23,7 @@ type Service interface {
 	pubsub.Suscriber[Message]
 	Create(ctx context.Context, sessionID string, params CreateMessageParams) (Message, error)
 	Update(ctx context.Context, message Message) error
+	SetPinned(ctx context.Context, id string, pinned bool) error
 	Get(ctx context.Context, id string) (Message, error)
 	List(ctx context.Context, sessionID string) ([]Message, error)
 	Delete(ctx context.Context, id string) error
@@ -113,6 +114,8 @@ func (s *service) Update(ctx context.Context, message Message) error {
 		ID:         message.ID,
 		Parts:      string(parts),
 		FinishedAt: finishedAt,
+		Model:      sql.NullString{String: message.Model, Valid: true},
+		Provider:   sql.NullString{String: message.Provider, Valid: message.Provider != ""},
 	})
 	if err != nil {
 		return err
@@ -122,6 +125,23 @@ func (s *service) Update(ctx context.Context, message Message) error {
 	return nil
 }
 
+func (s *service) SetPinned(ctx context.Context, id string, pinned bool) error {
+	message, err := s.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	var value int64
+	if pinned {
+		value = 1
+	}
+	if err := s.q.UpdateMessagePinned(ctx, db.UpdateMessagePinnedParams{ID: id, Pinned: value}); err != nil {
+		return err
+	}
+	message.Pinned = pinned
+	s.Publish(pubsub.UpdatedEvent, message)
+	return nil
+}
+
 func (s *service) Get(ctx context.Context, id string) (Message, error) {
 	dbMessage, err := s.q.GetMessage(ctx, id)
 	if err != nil {
@@ -157,6 +177,7 @@ func (s *service) fromDBItem(item db.Message) (Message, error) {
 		Parts:     parts,
 		Model:     item.Model.String,
 		Provider:  item.Provider.String,
+		Pinned:    item.Pinned != 0,
 		CreatedAt: item.CreatedAt,
 		UpdatedAt: item.UpdatedAt,
 	}, nil
@@ -0,0 +1,125 @@
+package ollama
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/catwalk/pkg/catwalk"
+)
+
+// defaultContextWindow is the context window of models whose context length
+// the server doesn't report
+const defaultContextWindow = 8192
+
+// maxDefaultMaxTokens caps the default response length of models with a
+// large context window
+const maxDefaultMaxTokens = 16384
+
+// Capabilities a model can have
+const (
+	CapabilityCompletion = "completion"
+	CapabilityTools      = "tools"
+	CapabilityVision     = "vision"
+	CapabilityThinking   = "thinking"
+)
+
+// ListedModel is a model pulled to the server
+type ListedModel struct {
+	Name  string `json:"name"`
+	Model string `json:"model"`
+}
+
+// ModelDetails describes a model
+type ModelDetails struct {
+	Family            string `json:"family"`
+	ParameterSize     string `json:"parameter_size"`
+	QuantizationLevel string `json:"quantization_level"`
+}
+
+// ShowResponse is what the server knows about a model
+type ShowResponse struct {
+	Details      ModelDetails   `json:"details"`
+	ModelInfo    map[string]any `json:"model_info"`
+	Capabilities []string       `json:"capabilities"`
+}
+
+// ContextLength returns the longest context the model was trained for, or 0
+// when the server doesn't say. The key of the length is prefixed with the
+// architecture, such as llama.context_length.
+func (s ShowResponse) ContextLength() int64 {
+	for key, value := range s.ModelInfo {
+		if !strings.HasSuffix(key, ".context_length") {
+			continue
+		}
+		if length, ok := value.(float64); ok {
+			return int64(length)
+		}
+	}
+	return 0
+}
+
+// HasCapability reports whether the model has the capability. Servers too
+// old to report capabilities are assumed to allow everything.
+func (s ShowResponse) HasCapability(capability string) bool {
+	return s.Capabilities == nil || slices.Contains(s.Capabilities, capability)
+}
+
+// Tags lists the models pulled to the server
+func (c *Client) Tags(ctx context.Context) ([]ListedModel, error) {
+	var response struct {
+		Models []ListedModel `json:"models"`
+	}
+	if err := c.call(ctx, http.MethodGet, "/api/tags", nil, &response); err != nil {
+		return nil, err
+	}
+	return response.Models, nil
+}
+
+// Show describes a model
+func (c *Client) Show(ctx context.Context, model string) (ShowResponse, error) {
+	var response ShowResponse
+	err := c.call(ctx, http.MethodPost, "/api/show", map[string]string{"model": model}, &response)
+	return response, err
+}
+
+// Models lists the chat models pulled to the server, with their context
+// window and capabilities. Models that can only embed are left out.
+func (c *Client) Models(ctx context.Context) ([]catwalk.Model, error) {
+	listed, err := c.Tags(ctx)
+	if err != nil {
+		return nil, err
+	}
+	models := make([]catwalk.Model, 0, len(listed))
+	for _, model := range listed {
+		show, err := c.Show(ctx, model.Name)
+		if err != nil {
+			slog.Warn("Failed to describe Ollama model", "model", model.Name, "error", err)
+		} else if !show.HasCapability(CapabilityCompletion) {
+			continue
+		}
+		models = append(models, toCatwalkModel(model.Name, show))
+	}
+	return models, nil
+}
+
+func toCatwalkModel(name string, show ShowResponse) catwalk.Model {
+	contextWindow := show.ContextLength()
+	if contextWindow <= 0 {
+		contextWindow = defaultContextWindow
+	}
+	displayName := name
+	if size := show.Details.ParameterSize; size != "" {
+		displayName += " (" + size + ")"
+	}
+	return catwalk.Model{
+		ID:               name,
+		Name:             displayName,
+		ContextWindow:    contextWindow,
+		DefaultMaxTokens: min(contextWindow/4, maxDefaultMaxTokens),
+		CanReason:        show.Capabilities != nil && show.HasCapability(CapabilityThinking),
+		SupportsImages:   show.Capabilities != nil && show.HasCapability(CapabilityVision),
+	}
+}
@@ -0,0 +1,236 @@
+// Package ollama is a client of the native API of Ollama, used to discover
+// the local models and to chat with them.
+package ollama
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"net/http"
+	"strings"
+)
+
+// DefaultBaseURL is where Ollama listens unless configured otherwise
+const DefaultBaseURL = "http://localhost:11434"
+
+// maxLineSize is the longest line of a streamed response, since a chunk with
+// tool calls holds all their arguments
+const maxLineSize = 10 * 1024 * 1024
+
+// Client talks to the Ollama server at BaseURL
+type Client struct {
+	BaseURL    string
+	HTTPClient *http.Client
+	// Header is sent with each request, as for servers behind a proxy that
+	// requires authentication
+	Header http.Header
+}
+
+// NewClient returns a client of the server at baseURL, or at DefaultBaseURL
+// when baseURL is empty. The /v1 path of the OpenAI compatible API is
+// dropped, so that base URLs configured for it keep working.
+func NewClient(baseURL string, httpClient *http.Client) *Client {
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+	baseURL = strings.TrimSuffix(strings.TrimRight(baseURL, "/"), "/v1")
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &Client{BaseURL: baseURL, HTTPClient: httpClient, Header: http.Header{}}
+}
+
+// Error is the error of a request the server answered with a status other
+// than 200 OK, or the error the server sent within a stream, which has no
+// status
+type Error struct {
+	StatusCode int
+	Header     http.Header
+	Message    string
+}
+
+func (e *Error) Error() string {
+	if e.StatusCode == 0 {
+		return "ollama: " + e.Message
+	}
+	return fmt.Sprintf("ollama: %d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+}
+
+// Message is a message of a chat
+type Message struct {
+	Role      string     `json:"role"`
+	Content   string     `json:"content"`
+	Thinking  string     `json:"thinking,omitempty"`
+	Images    [][]byte   `json:"images,omitempty"`
+	ToolCalls []ToolCall `json:"tool_calls,omitempty"`
+	// ToolName is the tool whose result a message with the tool role holds
+	ToolName string `json:"tool_name,omitempty"`
+}
+
+type ToolCall struct {
+	Function ToolCallFunction `json:"function"`
+}
+
+type ToolCallFunction struct {
+	Name      string         `json:"name"`
+	Arguments map[string]any `json:"arguments"`
+}
+
+type Tool struct {
+	Type     string       `json:"type"`
+	Function ToolFunction `json:"function"`
+}
+
+type ToolFunction struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	Parameters  map[string]any `json:"parameters"`
+}
+
+// ChatRequest asks the model for the next message of a chat
+type ChatRequest struct {
+	Model    string    `json:"model"`
+	Messages []Message `json:"messages"`
+	Tools    []Tool    `json:"tools,omitempty"`
+	Stream   bool      `json:"stream"`
+	// Think is left out for models that can't think, which would fail the
+	// request otherwise
+	Think   *bool          `json:"think,omitempty"`
+	Options map[string]any `json:"options,omitempty"`
+}
+
+// ChatResponse is the answer to a chat request, or one chunk of it when
+// streaming. The last chunk is Done and has the token counts.
+type ChatResponse struct {
+	Model           string  `json:"model"`
+	Message         Message `json:"message"`
+	Done            bool    `json:"done"`
+	DoneReason      string  `json:"done_reason,omitempty"`
+	PromptEvalCount int64   `json:"prompt_eval_count,omitempty"`
+	EvalCount       int64   `json:"eval_count,omitempty"`
+	Error           string  `json:"error,omitempty"`
+}
+
+// Chat sends the chat request and returns the response, streamed or not.
+// Extra body fields are set on the request as they are.
+func (c *Client) Chat(ctx context.Context, request ChatRequest, extraBody map[string]any) iter.Seq2[ChatResponse, error] {
+	return func(yield func(ChatResponse, error) bool) {
+		body, err := json.Marshal(request)
+		if err == nil && len(extraBody) > 0 {
+			body, err = mergeBody(body, extraBody)
+		}
+		if err != nil {
+			yield(ChatResponse{}, fmt.Errorf("failed to marshal request: %w", err))
+			return
+		}
+		response, err := c.do(ctx, http.MethodPost, "/api/chat", body)
+		if err != nil {
+			yield(ChatResponse{}, err)
+			return
+		}
+		defer response.Body.Close()
+
+		scanner := bufio.NewScanner(response.Body)
+		scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+		for scanner.Scan() {
+			line := bytes.TrimSpace(scanner.Bytes())
+			if len(line) == 0 {
+				continue
+			}
+			var chunk ChatResponse
+			if err := json.Unmarshal(line, &chunk); err != nil {
+				yield(ChatResponse{}, fmt.Errorf("failed to parse response: %w", err))
+				return
+			}
+			if chunk.Error != "" {
+				yield(ChatResponse{}, &Error{Message: chunk.Error})
+				return
+			}
+			if !yield(chunk, nil) || chunk.Done {
+				return
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			yield(ChatResponse{}, fmt.Errorf("failed to read response: %w", err))
+		}
+	}
+}
+
+func mergeBody(body []byte, extraBody map[string]any) ([]byte, error) {
+	var merged map[string]any
+	if err := json.Unmarshal(body, &merged); err != nil {
+		return nil, err
+	}
+	for key, value := range extraBody {
+		merged[key] = value
+	}
+	return json.Marshal(merged)
+}
+
+// do sends the request and returns the response when its status is 200 OK
+func (c *Client) do(ctx context.Context, method, path string, body []byte) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	request, err := http.NewRequestWithContext(ctx, method, c.BaseURL+path, reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	for key, values := range c.Header {
+		request.Header[key] = values
+	}
+	if body != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+
+	response, err := c.HTTPClient.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	if response.StatusCode == http.StatusOK {
+		return response, nil
+	}
+	defer response.Body.Close()
+	data, _ := io.ReadAll(response.Body)
+	apiErr := &Error{StatusCode: response.StatusCode, Header: response.Header, Message: strings.TrimSpace(string(data))}
+	var errorBody struct {
+		Error string `json:"error"`
+	}
+	if json.Unmarshal(data, &errorBody) == nil && errorBody.Error != "" {
+		apiErr.Message = errorBody.Error
+	}
+	return nil, apiErr
+}
+
+// call sends a request and decodes the JSON response into v
+func (c *Client) call(ctx context.Context, method, path string, body any, v any) error {
+	var data []byte
+	if body != nil {
+		var err error
+		if data, err = json.Marshal(body); err != nil {
+			return fmt.Errorf("failed to marshal request: %w", err)
+		}
+	}
+	response, err := c.do(ctx, method, path, data)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if err := json.NewDecoder(response.Body).Decode(v); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+	return nil
+}
+
+// IsToolsUnsupported reports whether a chat request failed because the
+// model doesn't support tool calling
+func IsToolsUnsupported(err error) bool {
+	var apiErr *Error
+	return errors.As(err, &apiErr) && strings.Contains(apiErr.Message, "does not support tools")
+}
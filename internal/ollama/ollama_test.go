@@ -0,0 +1,81 @@
+package ollama
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientModels(t *testing.T) {
+	t.Parallel()
+
+	shows := map[string]string{
+		"qwen3:8b":         `{"details":{"parameter_size":"8.2B"},"model_info":{"qwen3.context_length":40960},"capabilities":["completion","tools","thinking"]}`,
+		"nomic-embed-text": `{"details":{"parameter_size":"137M"},"model_info":{"nomic-bert.context_length":2048},"capabilities":["embedding"]}`,
+		"old:latest":       `{"details":{},"model_info":{}}`,
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/tags":
+			w.Write([]byte(`{"models":[{"name":"qwen3:8b"},{"name":"nomic-embed-text"},{"name":"old:latest"}]}`))
+		case "/api/show":
+			var request struct {
+				Model string `json:"model"`
+			}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+			w.Write([]byte(shows[request.Model]))
+		}
+	}))
+	defer server.Close()
+
+	models, err := NewClient(server.URL+"/v1/", nil).Models(t.Context())
+	require.NoError(t, err)
+	require.Len(t, models, 2)
+
+	require.Equal(t, "qwen3:8b", models[0].ID)
+	require.Equal(t, "qwen3:8b (8.2B)", models[0].Name)
+	require.Equal(t, int64(40960), models[0].ContextWindow)
+	require.Equal(t, int64(10240), models[0].DefaultMaxTokens)
+	require.True(t, models[0].CanReason)
+	require.False(t, models[0].SupportsImages)
+
+	// Servers that don't report capabilities get the defaults
+	require.Equal(t, "old:latest", models[1].ID)
+	require.Equal(t, int64(defaultContextWindow), models[1].ContextWindow)
+	require.False(t, models[1].CanReason)
+}
+
+func TestClientChat(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request map[string]any
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+		require.Equal(t, "high", request["keep_alive"])
+		w.Write([]byte(`{"message":{"role":"assistant","content":"hel"},"done":false}` + "\n" +
+			`{"error":"model runner has unexpectedly stopped"}` + "\n"))
+	}))
+	defer server.Close()
+
+	var content string
+	var chatErr error
+	for response, err := range NewClient(server.URL, nil).Chat(t.Context(), ChatRequest{Model: "qwen3:8b", Stream: true}, map[string]any{"keep_alive": "high"}) {
+		if err != nil {
+			chatErr = err
+			break
+		}
+		content += response.Message.Content
+	}
+	require.Equal(t, "hel", content)
+	require.EqualError(t, chatErr, "ollama: model runner has unexpectedly stopped")
+}
+
+func TestIsToolsUnsupported(t *testing.T) {
+	t.Parallel()
+
+	require.True(t, IsToolsUnsupported(&Error{StatusCode: http.StatusBadRequest, Message: "registry.ollama.ai/library/gemma2:2b does not support tools"}))
+	require.False(t, IsToolsUnsupported(&Error{StatusCode: http.StatusNotFound, Message: `model "gemma2:2b" not found, try pulling it first`}))
+}
@@ -25,8 +25,13 @@ type CreatePermissionRequest struct {
 	Path        string `json:"path"`
 }
 
+// PermissionNotification tells that a tool call asked for permission, and
+// then whether it was granted or denied
 type PermissionNotification struct {
+	SessionID  string `json:"session_id"`
 	ToolCallID string `json:"tool_call_id"`
+	ToolName   string `json:"tool_name"`
+	Action     string `json:"action"`
 	Granted    bool   `json:"granted"`
 	Denied     bool   `json:"denied"`
 }
@@ -48,6 +53,7 @@ type Service interface {
 	Grant(permission PermissionRequest)
 	Deny(permission PermissionRequest)
 	Request(opts CreatePermissionRequest) bool
+	Confirm(opts CreatePermissionRequest) bool
 	AutoApproveSession(sessionID string)
 	SetSkipRequests(skip bool)
 	SkipRequests() bool
@@ -74,7 +80,10 @@ type permissionService struct {
 
 func (s *permissionService) GrantPersistent(permission PermissionRequest) {
 	s.notificationBroker.Publish(pubsub.CreatedEvent, PermissionNotification{
+		SessionID:  permission.SessionID,
 		ToolCallID: permission.ToolCallID,
+		ToolName:   permission.ToolName,
+		Action:     permission.Action,
 		Granted:    true,
 	})
 	respCh, ok := s.pendingRequests.Get(permission.ID)
@@ -93,7 +102,10 @@ func (s *permissionService) GrantPersistent(permission PermissionRequest) {
 
 func (s *permissionService) Grant(permission PermissionRequest) {
 	s.notificationBroker.Publish(pubsub.CreatedEvent, PermissionNotification{
+		SessionID:  permission.SessionID,
 		ToolCallID: permission.ToolCallID,
+		ToolName:   permission.ToolName,
+		Action:     permission.Action,
 		Granted:    true,
 	})
 	respCh, ok := s.pendingRequests.Get(permission.ID)
@@ -108,7 +120,10 @@ func (s *permissionService) Grant(permission PermissionRequest) {
 
 func (s *permissionService) Deny(permission PermissionRequest) {
 	s.notificationBroker.Publish(pubsub.CreatedEvent, PermissionNotification{
+		SessionID:  permission.SessionID,
 		ToolCallID: permission.ToolCallID,
+		ToolName:   permission.ToolName,
+		Action:     permission.Action,
 		Granted:    false,
 		Denied:     true,
 	})
@@ -124,12 +139,15 @@ func (s *permissionService) Deny(permission PermissionRequest) {
 
 func (s *permissionService) Request(opts CreatePermissionRequest) bool {
 	if s.skip {
-		return true
+		return s.autoGrant(opts)
 	}
 
 	// tell the UI that a permission was requested
 	s.notificationBroker.Publish(pubsub.CreatedEvent, PermissionNotification{
+		SessionID:  opts.SessionID,
 		ToolCallID: opts.ToolCallID,
+		ToolName:   opts.ToolName,
+		Action:     opts.Action,
 	})
 	s.requestMu.Lock()
 	defer s.requestMu.Unlock()
@@ -137,7 +155,7 @@ func (s *permissionService) Request(opts CreatePermissionRequest) bool {
 	// Check if the tool/action combination is in the allowlist
 	commandKey := opts.ToolName + ":" + opts.Action
 	if slices.Contains(s.allowedTools, commandKey) || slices.Contains(s.allowedTools, opts.ToolName) {
-		return true
+		return s.autoGrant(opts)
 	}
 
 	s.autoApproveSessionsMu.RLock()
@@ -145,9 +163,31 @@ func (s *permissionService) Request(opts CreatePermissionRequest) bool {
 	s.autoApproveSessionsMu.RUnlock()
 
 	if autoApprove {
-		return true
+		return s.autoGrant(opts)
 	}
 
+	return s.ask(opts)
+}
+
+// Confirm asks the user the question of opts, such as whether to continue
+// past a limit. Unlike Request, it is not answered by skipping requests,
+// allowed tools or auto-approved sessions, only by the user or by their
+// choice to allow it for the session.
+func (s *permissionService) Confirm(opts CreatePermissionRequest) bool {
+	s.notificationBroker.Publish(pubsub.CreatedEvent, PermissionNotification{
+		SessionID:  opts.SessionID,
+		ToolCallID: opts.ToolCallID,
+		ToolName:   opts.ToolName,
+		Action:     opts.Action,
+	})
+	s.requestMu.Lock()
+	defer s.requestMu.Unlock()
+	return s.ask(opts)
+}
+
+// ask publishes the request and waits for the answer, unless it was already
+// allowed for the session
+func (s *permissionService) ask(opts CreatePermissionRequest) bool {
 	fileInfo, err := os.Stat(opts.Path)
 	dir := opts.Path
 	if err == nil {
@@ -176,16 +216,7 @@ func (s *permissionService) Request(opts CreatePermissionRequest) bool {
 	for _, p := range s.sessionPermissions {
 		if p.ToolName == permission.ToolName && p.Action == permission.Action && p.SessionID == permission.SessionID && p.Path == permission.Path {
 			s.sessionPermissionsMu.RUnlock()
-			return true
-		}
-	}
-	s.sessionPermissionsMu.RUnlock()
-
-	s.sessionPermissionsMu.RLock()
-	for _, p := range s.sessionPermissions {
-		if p.ToolName == permission.ToolName && p.Action == permission.Action && p.SessionID == permission.SessionID && p.Path == permission.Path {
-			s.sessionPermissionsMu.RUnlock()
-			return true
+			return s.autoGrant(opts)
 		}
 	}
 	s.sessionPermissionsMu.RUnlock()
@@ -202,6 +233,18 @@ func (s *permissionService) Request(opts CreatePermissionRequest) bool {
 	return <-respCh
 }
 
+// autoGrant tells that the request was granted without asking
+func (s *permissionService) autoGrant(opts CreatePermissionRequest) bool {
+	s.notificationBroker.Publish(pubsub.CreatedEvent, PermissionNotification{
+		SessionID:  opts.SessionID,
+		ToolCallID: opts.ToolCallID,
+		ToolName:   opts.ToolName,
+		Action:     opts.Action,
+		Granted:    true,
+	})
+	return true
+}
+
 func (s *permissionService) AutoApproveSession(sessionID string) {
 	s.autoApproveSessionsMu.Lock()
 	s.autoApproveSessions[sessionID] = true
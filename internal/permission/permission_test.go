@@ -245,3 +245,40 @@ func TestPermissionService_SequentialProperties(t *testing.T) {
 		assert.True(t, result, "Repeated request should be auto-approved due to persistent permission")
 	})
 }
+
+func TestPermissionService_Confirm(t *testing.T) {
+	service := NewPermissionService("/tmp", true, []string{"budget"})
+	service.AutoApproveSession("session")
+	events := service.Subscribe(t.Context())
+
+	req := CreatePermissionRequest{
+		SessionID:   "session",
+		ToolName:    "budget",
+		Action:      "daily",
+		Description: "Continue anyway?",
+		Path:        "/tmp",
+	}
+	assert.True(t, service.Request(req), "Request should be granted without asking")
+
+	// Skipping requests, allowed tools and auto-approved sessions don't
+	// answer confirmations
+	var result bool
+	var wg sync.WaitGroup
+	wg.Go(func() {
+		result = service.Confirm(req)
+	})
+	event := <-events
+	service.Deny(event.Payload)
+	wg.Wait()
+	assert.False(t, result, "Confirmation should be denied")
+
+	// but allowing it for the session does
+	wg.Go(func() {
+		result = service.Confirm(req)
+	})
+	event = <-events
+	service.GrantPersistent(event.Payload)
+	wg.Wait()
+	assert.True(t, result, "Confirmation should be granted")
+	assert.True(t, service.Confirm(req), "Confirmation should be granted for the session")
+}
@@ -3,6 +3,7 @@ package session
 import (
 	"context"
 	"database/sql"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/nom-nom-hub/floss/internal/db"
@@ -17,9 +18,24 @@ type Session struct {
 	PromptTokens     int64
 	CompletionTokens int64
 	SummaryMessageID string
-	Cost             float64
-	CreatedAt        int64
-	UpdatedAt        int64
+	// FirstKeptMessageID is the first message the summary left out to keep
+	// verbatim after it, or empty when it summarized every earlier message
+	FirstKeptMessageID string
+	Cost               float64
+	// TotalTokens counts every token the session used, while PromptTokens
+	// and CompletionTokens only hold those of its last request
+	TotalTokens int64
+	// CheckpointID is the checkpoint of the parent session a fork started
+	// from. Unlike task sessions, forks are listed and used on their own.
+	CheckpointID string
+	CreatedAt    int64
+	UpdatedAt    int64
+}
+
+// Usage is what sessions cost and the tokens they used
+type Usage struct {
+	Cost   float64
+	Tokens int64
 }
 
 type Service interface {
@@ -27,8 +43,15 @@ type Service interface {
 	Create(ctx context.Context, title string) (Session, error)
 	CreateTitleSession(ctx context.Context, parentSessionID string) (Session, error)
 	CreateTaskSession(ctx context.Context, toolCallID, parentSessionID, title string) (Session, error)
+	CreateForkSession(ctx context.Context, parentSessionID, checkpointID, title string) (Session, error)
 	Get(ctx context.Context, id string) (Session, error)
 	List(ctx context.Context) ([]Session, error)
+	// RecordUsage records what a request of the session used, as of now, to
+	// add up in UsageSince. The session itself is updated with Save.
+	RecordUsage(ctx context.Context, sessionID string, usage Usage) error
+	// UsageSince adds up the usage recorded since the time, of all sessions
+	// including task sessions
+	UsageSince(ctx context.Context, since time.Time) (Usage, error)
 	Save(ctx context.Context, session Session) (Session, error)
 	Delete(ctx context.Context, id string) error
 }
@@ -65,6 +88,21 @@ func (s *service) CreateTaskSession(ctx context.Context, toolCallID, parentSessi
 	return session, nil
 }
 
+func (s *service) CreateForkSession(ctx context.Context, parentSessionID, checkpointID, title string) (Session, error) {
+	dbSession, err := s.q.CreateSession(ctx, db.CreateSessionParams{
+		ID:              uuid.New().String(),
+		ParentSessionID: sql.NullString{String: parentSessionID, Valid: true},
+		Title:           title,
+		CheckpointID:    sql.NullString{String: checkpointID, Valid: true},
+	})
+	if err != nil {
+		return Session{}, err
+	}
+	session := s.fromDBItem(dbSession)
+	s.Publish(pubsub.CreatedEvent, session)
+	return session, nil
+}
+
 func (s *service) CreateTitleSession(ctx context.Context, parentSessionID string) (Session, error) {
 	dbSession, err := s.q.CreateSession(ctx, db.CreateSessionParams{
 		ID:              "title-" + parentSessionID,
@@ -110,7 +148,12 @@ func (s *service) Save(ctx context.Context, session Session) (Session, error) {
 			String: session.SummaryMessageID,
 			Valid:  session.SummaryMessageID != "",
 		},
-		Cost: session.Cost,
+		FirstKeptMessageID: sql.NullString{
+			String: session.FirstKeptMessageID,
+			Valid:  session.FirstKeptMessageID != "",
+		},
+		Cost:        session.Cost,
+		TotalTokens: session.TotalTokens,
 	})
 	if err != nil {
 		return Session{}, err
@@ -132,18 +175,38 @@ func (s *service) List(ctx context.Context) ([]Session, error) {
 	return sessions, nil
 }
 
+func (s *service) RecordUsage(ctx context.Context, sessionID string, usage Usage) error {
+	return s.q.CreateSessionUsage(ctx, db.CreateSessionUsageParams{
+		SessionID: sessionID,
+		Cost:      usage.Cost,
+		Tokens:    usage.Tokens,
+		CreatedAt: time.Now().Unix(),
+	})
+}
+
+func (s *service) UsageSince(ctx context.Context, since time.Time) (Usage, error) {
+	usage, err := s.q.GetUsageSince(ctx, since.Unix())
+	if err != nil {
+		return Usage{}, err
+	}
+	return Usage{Cost: usage.Cost, Tokens: usage.Tokens}, nil
+}
+
 func (s service) fromDBItem(item db.Session) Session {
 	return Session{
-		ID:               item.ID,
-		ParentSessionID:  item.ParentSessionID.String,
-		Title:            item.Title,
-		MessageCount:     item.MessageCount,
-		PromptTokens:     item.PromptTokens,
-		CompletionTokens: item.CompletionTokens,
-		SummaryMessageID: item.SummaryMessageID.String,
-		Cost:             item.Cost,
-		CreatedAt:        item.CreatedAt,
-		UpdatedAt:        item.UpdatedAt,
+		ID:                 item.ID,
+		ParentSessionID:    item.ParentSessionID.String,
+		Title:              item.Title,
+		MessageCount:       item.MessageCount,
+		PromptTokens:       item.PromptTokens,
+		CompletionTokens:   item.CompletionTokens,
+		SummaryMessageID:   item.SummaryMessageID.String,
+		FirstKeptMessageID: item.FirstKeptMessageID.String,
+		CheckpointID:       item.CheckpointID.String,
+		Cost:               item.Cost,
+		TotalTokens:        item.TotalTokens,
+		CreatedAt:          item.CreatedAt,
+		UpdatedAt:          item.UpdatedAt,
 	}
 }
 
@@ -0,0 +1,51 @@
+package session
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nom-nom-hub/floss/internal/db"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUsageSinceCountsUsageOfTheDay(t *testing.T) {
+	t.Parallel()
+
+	conn, err := db.Connect(t.Context(), t.TempDir())
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	queries := db.New(conn)
+	sessions := NewService(queries)
+
+	now := time.Now()
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+
+	// A session started before midnight and used again after it
+	sess, err := sessions.Create(t.Context(), "across midnight")
+	require.NoError(t, err)
+	require.NoError(t, queries.CreateSessionUsage(t.Context(), db.CreateSessionUsageParams{
+		SessionID: sess.ID,
+		Cost:      5,
+		Tokens:    5000,
+		CreatedAt: midnight.Add(-time.Hour).Unix(),
+	}))
+	require.NoError(t, sessions.RecordUsage(t.Context(), sess.ID, Usage{Cost: 1, Tokens: 1000}))
+	sess.Cost, sess.TotalTokens = 6, 6000
+	_, err = sessions.Save(t.Context(), sess)
+	require.NoError(t, err)
+
+	// and a task session of it
+	task, err := sessions.CreateTaskSession(t.Context(), "call", sess.ID, "task")
+	require.NoError(t, err)
+	require.NoError(t, sessions.RecordUsage(t.Context(), task.ID, Usage{Cost: 0.5, Tokens: 500}))
+
+	usage, err := sessions.UsageSince(t.Context(), midnight)
+	require.NoError(t, err)
+	require.Equal(t, Usage{Cost: 1.5, Tokens: 1500}, usage)
+
+	// Usage stays counted after its session is deleted
+	require.NoError(t, sessions.Delete(t.Context(), sess.ID))
+	usage, err = sessions.UsageSince(t.Context(), midnight.Add(-24*time.Hour))
+	require.NoError(t, err)
+	require.Equal(t, Usage{Cost: 6.5, Tokens: 6500}, usage)
+}
@@ -104,7 +104,7 @@ func (m *messageListCmp) Init() tea.Cmd {
 func (m *messageListCmp) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	var cmds []tea.Cmd
 	if m.session.ID != "" && m.app.CoderAgent != nil {
-		queueSize := m.app.CoderAgent.QueuedPrompts(m.session.ID)
+		queueSize := len(m.app.CoderAgent.QueuedPrompts(m.session.ID))
 		if queueSize != m.promptQueue {
 			m.promptQueue = queueSize
 			cmds = append(cmds, m.SetSize(m.width, m.height))
@@ -332,11 +332,18 @@ func (m *messageListCmp) handleMessageEvent(event pubsub.Event[message.Message])
 			return m.handleChildSession(event)
 		}
 		switch event.Payload.Role {
+		case message.User:
+			return m.handleUpdateUserMessage(event.Payload)
 		case message.Assistant:
 			return m.handleUpdateAssistantMessage(event.Payload)
 		case message.Tool:
 			return m.handleToolMessage(event.Payload)
 		}
+	case pubsub.DeletedEvent:
+		// Restoring a checkpoint deletes the messages after it
+		if event.Payload.SessionID == m.session.ID && m.messageExists(event.Payload.ID) {
+			return m.loadMessages()
+		}
 	}
 	return nil
 }
@@ -372,6 +379,18 @@ func (m *messageListCmp) handleNewUserMessage(msg message.Message) tea.Cmd {
 	return m.listCmp.AppendItem(messages.NewMessageCmp(msg))
 }
 
+// handleUpdateUserMessage updates a user message already in the list, such
+// as when it gets pinned.
+func (m *messageListCmp) handleUpdateUserMessage(msg message.Message) tea.Cmd {
+	for _, item := range m.listCmp.Items() {
+		if uiMsg, ok := item.(messages.MessageCmp); ok && uiMsg.GetMessage().ID == msg.ID {
+			uiMsg.SetMessage(msg)
+			return m.listCmp.UpdateItem(uiMsg.ID(), uiMsg)
+		}
+	}
+	return nil
+}
+
 // handleToolMessage updates existing tool calls with their results.
 func (m *messageListCmp) handleToolMessage(msg message.Message) tea.Cmd {
 	items := m.listCmp.Items()
@@ -541,7 +560,12 @@ func (m *messageListCmp) SetSession(session session.Session) tea.Cmd {
 	}
 
 	m.session = session
-	sessionMessages, err := m.app.Messages.List(context.Background(), session.ID)
+	return m.loadMessages()
+}
+
+// loadMessages shows the messages of the session
+func (m *messageListCmp) loadMessages() tea.Cmd {
+	sessionMessages, err := m.app.Messages.List(context.Background(), m.session.ID)
 	if err != nil {
 		return util.ReportError(err)
 	}
@@ -6,6 +6,7 @@ import (
 
 	tea "github.com/charmbracelet/bubbletea/v2"
 	"github.com/nom-nom-hub/floss/internal/app"
+	"github.com/nom-nom-hub/floss/internal/tui/components/dialogs/commands"
 	"github.com/nom-nom-hub/floss/internal/tui/util"
 )
 
@@ -62,6 +63,8 @@ func (p *Parser) ExecuteCommand(cmd *Command) tea.Cmd {
 			return p.handleListAgents()
 		}
 		return util.ReportError(fmt.Errorf("Unknown command: %s. Type /help for available commands.", cmd.Name))
+	case "checkpoint":
+		return util.CmdHandler(commands.CreateCheckpointMsg{Name: strings.Join(cmd.Args, " ")})
 	case "help":
 		return p.handleHelp()
 	default:
@@ -98,6 +101,7 @@ func (p *Parser) handleListAgents() tea.Cmd {
 func (p *Parser) handleHelp() tea.Cmd {
 	helpText := `Available commands:
   /list agents    - List available agents
+  /checkpoint     - Create a checkpoint of the session, optionally named
   /help           - Show this help message`
 	return util.ReportInfo(helpText)
 }
\ No newline at end of file
@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	"github.com/nom-nom-hub/floss/internal/app"
+	"github.com/nom-nom-hub/floss/internal/tui/components/dialogs/commands"
 )
 
 func TestCommandParsing(t *testing.T) {
@@ -37,4 +38,16 @@ func TestCommandParsing(t *testing.T) {
 	if parser.IsCommand("hello") {
 		t.Error("Expected 'hello' to not be recognized as a command")
 	}
-}
\ No newline at end of file
+}
+func TestCheckpointCommand(t *testing.T) {
+	parser := NewParser(&app.App{})
+
+	msg := parser.ExecuteCommand(parser.ParseCommand("/checkpoint before the refactor"))()
+	create, ok := msg.(commands.CreateCheckpointMsg)
+	if !ok {
+		t.Fatalf("Expected CreateCheckpointMsg, got %T", msg)
+	}
+	if create.Name != "before the refactor" {
+		t.Errorf("Expected name 'before the refactor', got '%s'", create.Name)
+	}
+}
@@ -68,7 +68,7 @@ func (m *flossMessageListCmp) Init() tea.Cmd {
 func (m *flossMessageListCmp) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	var cmds []tea.Cmd
 	if m.session.ID != "" && m.app.CoderAgent != nil {
-		queueSize := m.app.CoderAgent.QueuedPrompts(m.session.ID)
+		queueSize := len(m.app.CoderAgent.QueuedPrompts(m.session.ID))
 		if queueSize != m.promptQueue {
 			m.promptQueue = queueSize
 			cmds = append(cmds, m.SetSize(m.width, m.height))
@@ -224,6 +224,8 @@ func (m *enhancedMessageCmp) renderAssistantMessage() string {
 		content = ""
 	} else if finished && content == "" && finishedData.Reason == message.FinishReasonCanceled {
 		content = "*Canceled*"
+	} else if finished && content == "" && finishedData.Reason == message.FinishReasonLoopDetected {
+		content = "*" + finishedData.Message + "*"
 	} else if finished && content == "" && finishedData.Reason == message.FinishReasonError {
 		// Error display with appropriate styling according to UI/UX specification
 		errTag := t.S().Base.Padding(0, 1).Background(t.Error).Foreground(t.White).Render("ERROR")
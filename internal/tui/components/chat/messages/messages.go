@@ -31,6 +31,15 @@ var CopyKey = key.NewBinding(key.WithKeys("c", "y", "C", "Y"), key.WithHelp("c/y
 // ClearSelectionKey is the key binding for clearing the current selection in the chat interface.
 var ClearSelectionKey = key.NewBinding(key.WithKeys("esc"), key.WithHelp("esc", "clear selection"))
 
+// PinKey is the key binding for pinning a message, or unpinning it, so that
+// compaction keeps it verbatim.
+var PinKey = key.NewBinding(key.WithKeys("p", "P"), key.WithHelp("p", "pin"))
+
+// TogglePinMsg asks to pin the message, or unpin it when it is pinned.
+type TogglePinMsg struct {
+	Message message.Message
+}
+
 // EnhancedCopyKey is the key binding for copying message content to the clipboard in enhanced messages.
 var EnhancedCopyKey = key.NewBinding(key.WithKeys("c", "y", "C", "Y"), key.WithHelp("c/y", "copy"))
 
@@ -119,6 +128,9 @@ func (m *messageCmp) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				util.ReportInfo("Message copied to clipboard"),
 			)
 		}
+		if key.Matches(msg, PinKey) && m.message.ID != "" {
+			return m, util.CmdHandler(TogglePinMsg{Message: m.message})
+		}
 	}
 	return m, nil
 }
@@ -202,6 +214,8 @@ func (m *messageCmp) renderAssistantMessage() string {
 		content = ""
 	} else if finished && content == "" && finishedData.Reason == message.FinishReasonCanceled {
 		content = "*Canceled*"
+	} else if finished && content == "" && finishedData.Reason == message.FinishReasonLoopDetected {
+		content = "*" + finishedData.Message + "*"
 	} else if finished && content == "" && finishedData.Reason == message.FinishReasonError {
 		// Error display with appropriate styling according to UI/UX specification
 		errTag := t.S().Base.Padding(0, 1).Background(t.Error).Foreground(t.White).Render("ERROR")
@@ -223,6 +237,10 @@ func (m *messageCmp) renderAssistantMessage() string {
 		parts = append(parts, m.toMarkdown(content))
 	}
 
+	if m.message.Pinned {
+		parts = append(parts, "", m.renderPinned())
+	}
+
 	joined := lipgloss.JoinVertical(lipgloss.Left, parts...)
 	return m.style().Render(joined)
 }
@@ -256,10 +274,21 @@ func (m *messageCmp) renderUserMessage() string {
 		parts = append(parts, "", strings.Join(attachments, ""))
 	}
 
+	if m.message.Pinned {
+		parts = append(parts, "", m.renderPinned())
+	}
+
 	joined := lipgloss.JoinVertical(lipgloss.Left, parts...)
 	return m.style().Render(joined)
 }
 
+// renderPinned renders the marker of pinned messages, which compaction keeps
+// verbatim
+func (m *messageCmp) renderPinned() string {
+	t := styles.CurrentTheme()
+	return t.S().Subtle.Render(styles.PinIcon + " Pinned")
+}
+
 // toMarkdown converts text content to rendered markdown using the configured renderer
 func (m *messageCmp) toMarkdown(content string) string {
 	r := styles.GetMarkdownRenderer(m.textWidth())
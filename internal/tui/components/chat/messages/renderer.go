@@ -174,6 +174,13 @@ func init() {
 	registry.register(tools.LSToolName, func() renderer { return lsRenderer{} })
 	registry.register(tools.SourcegraphToolName, func() renderer { return sourcegraphRenderer{} })
 	registry.register(tools.DiagnosticsToolName, func() renderer { return diagnosticsRenderer{} })
+	registry.register(tools.DefinitionToolName, func() renderer { return symbolPositionRenderer{} })
+	registry.register(tools.ReferencesToolName, func() renderer { return symbolPositionRenderer{} })
+	registry.register(tools.HoverToolName, func() renderer { return symbolPositionRenderer{} })
+	registry.register(tools.DocumentSymbolsToolName, func() renderer { return documentSymbolsRenderer{} })
+	registry.register(tools.WorkspaceSymbolsToolName, func() renderer { return workspaceSymbolsRenderer{} })
+	registry.register(tools.RenameToolName, func() renderer { return workspaceEditRenderer{} })
+	registry.register(tools.CodeActionToolName, func() renderer { return workspaceEditRenderer{} })
 	registry.register(agent.AgentToolName, func() renderer { return agentRenderer{} })
 }
 
@@ -589,6 +596,132 @@ func (dr diagnosticsRenderer) Render(v *toolCallCmp) string {
 	})
 }
 
+// -----------------------------------------------------------------------------
+//  LSP navigation renderers
+// -----------------------------------------------------------------------------
+
+// symbolPositionRenderer handles the tools looking up the symbol at a
+// position: definition, references and hover
+type symbolPositionRenderer struct {
+	baseRenderer
+}
+
+// Render displays the file and line with the symbol looked up
+func (sr symbolPositionRenderer) Render(v *toolCallCmp) string {
+	var params tools.SymbolPositionParams
+	var args []string
+	if err := sr.unmarshalParams(v.call.Input, &params); err == nil {
+		args = newParamBuilder().
+			addMain(fmt.Sprintf("%s:%d", fsext.PrettyPath(params.FilePath), params.Line)).
+			addKeyValue("symbol", params.Symbol).
+			build()
+	}
+
+	return sr.renderWithParams(v, prettifyToolName(v.call.Name), args, func() string {
+		return renderPlainContent(v, v.result.Content)
+	})
+}
+
+// documentSymbolsRenderer handles the outline of a file
+type documentSymbolsRenderer struct {
+	baseRenderer
+}
+
+// Render displays the file path with its symbols
+func (dr documentSymbolsRenderer) Render(v *toolCallCmp) string {
+	var params tools.DocumentSymbolsParams
+	var args []string
+	if err := dr.unmarshalParams(v.call.Input, &params); err == nil {
+		args = newParamBuilder().addMain(fsext.PrettyPath(params.FilePath)).build()
+	}
+
+	return dr.renderWithParams(v, "Symbols", args, func() string {
+		return renderPlainContent(v, v.result.Content)
+	})
+}
+
+// workspaceSymbolsRenderer handles symbol searches across the project
+type workspaceSymbolsRenderer struct {
+	baseRenderer
+}
+
+// Render displays the query with the symbols found
+func (wr workspaceSymbolsRenderer) Render(v *toolCallCmp) string {
+	var params tools.WorkspaceSymbolsParams
+	var args []string
+	if err := wr.unmarshalParams(v.call.Input, &params); err == nil {
+		args = newParamBuilder().addMain(params.Query).build()
+	}
+
+	return wr.renderWithParams(v, "Workspace Symbols", args, func() string {
+		return renderPlainContent(v, v.result.Content)
+	})
+}
+
+// workspaceEditRenderer handles the edits language servers make across
+// files: rename and code_action
+type workspaceEditRenderer struct {
+	baseRenderer
+}
+
+// Render displays the symbol or code action with a diff of every file changed
+func (wr workspaceEditRenderer) Render(v *toolCallCmp) string {
+	t := styles.CurrentTheme()
+	var args []string
+	if v.call.Name == tools.RenameToolName {
+		var params tools.RenameParams
+		if err := wr.unmarshalParams(v.call.Input, &params); err == nil {
+			args = newParamBuilder().
+				addMain(fmt.Sprintf("%s:%d", fsext.PrettyPath(params.FilePath), params.Line)).
+				addKeyValue("symbol", params.Symbol).
+				addKeyValue("to", params.NewName).
+				build()
+		}
+	} else {
+		var params tools.CodeActionParams
+		if err := wr.unmarshalParams(v.call.Input, &params); err == nil {
+			args = newParamBuilder().
+				addMain(fsext.PrettyPath(params.FilePath)).
+				addKeyValue("line", formatNonZero(params.Line)).
+				addKeyValue("kind", params.Kind).
+				addKeyValue("title", params.Title).
+				build()
+		}
+	}
+
+	return wr.renderWithParams(v, prettifyToolName(v.call.Name), args, func() string {
+		var meta tools.WorkspaceEditResponseMetadata
+		if err := wr.unmarshalParams(v.result.Metadata, &meta); err != nil || len(meta.Changes) == 0 {
+			return renderPlainContent(v, v.result.Content)
+		}
+
+		var diffs []string
+		for _, change := range meta.Changes {
+			path := fsext.PrettyPath(change.Path)
+			formatter := section.DiffFormatter().
+				Before(path, change.OldContent).
+				After(path, change.NewContent).
+				Width(v.textWidth() - 2) // -2 for padding
+			if v.textWidth() > 120 {
+				formatter = formatter.Split()
+			}
+			diffs = append(diffs, t.S().Muted.Render(path), formatter.String())
+		}
+		// add a message to the bottom if the content was truncated
+		formatted := strings.Join(diffs, "\n")
+		if lipgloss.Height(formatted) > responseContextHeight {
+			contentLines := strings.Split(formatted, "\n")
+			truncateMessage := t.S().Muted.
+				Background(t.BgBaseLighter).
+				PaddingLeft(2).
+				Width(v.textWidth() - 2).
+				Render(fmt.Sprintf("… (%d lines)", len(contentLines)-responseContextHeight))
+			formatted = strings.Join(contentLines[:responseContextHeight], "\n") + "\n" + truncateMessage
+		}
+		return formatted
+	})
+}
+
 // -----------------------------------------------------------------------------
 //  Task renderer
 // -----------------------------------------------------------------------------
@@ -866,6 +999,10 @@ func prettifyToolName(name string) string {
 		return "Agent"
 	case tools.BashToolName:
 		return "Bash"
+	case tools.CodeActionToolName:
+		return "Code Action"
+	case tools.DefinitionToolName:
+		return "Definition"
 	case tools.DownloadToolName:
 		return "Download"
 	case tools.EditToolName:
@@ -878,8 +1015,14 @@ func prettifyToolName(name string) string {
 		return "Glob"
 	case tools.GrepToolName:
 		return "Grep"
+	case tools.HoverToolName:
+		return "Hover"
 	case tools.LSToolName:
 		return "List"
+	case tools.ReferencesToolName:
+		return "References"
+	case tools.RenameToolName:
+		return "Rename"
 	case tools.SourcegraphToolName:
 		return "Sourcegraph"
 	case tools.ViewToolName:
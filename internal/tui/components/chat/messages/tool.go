@@ -397,7 +397,8 @@ func (m *toolCallCmp) formatResultForCopy() string {
 		return m.formatFetchResultForCopy()
 	case agent.AgentToolName:
 		return m.formatAgentResultForCopy()
-	case tools.DownloadToolName, tools.GrepToolName, tools.GlobToolName, tools.LSToolName, tools.SourcegraphToolName, tools.DiagnosticsToolName:
+	case tools.DownloadToolName, tools.GrepToolName, tools.GlobToolName, tools.LSToolName, tools.SourcegraphToolName, tools.DiagnosticsToolName,
+		tools.DefinitionToolName, tools.ReferencesToolName, tools.HoverToolName, tools.DocumentSymbolsToolName, tools.WorkspaceSymbolsToolName:
 		return fmt.Sprintf("```\n%s\n```", m.result.Content)
 	default:
 		return m.result.Content
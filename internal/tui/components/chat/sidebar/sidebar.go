@@ -177,6 +177,10 @@ func (m *sidebarCmp) View() string {
 }
 
 func (m *sidebarCmp) handleFileHistoryEvent(event pubsub.Event[history.File]) tea.Cmd {
+	// Reverting drops versions, so the files are listed again
+	if event.Type == pubsub.DeletedEvent {
+		return m.loadSessionFiles
+	}
 	return func() tea.Msg {
 		file := event.Payload
 		found := false
@@ -255,7 +259,7 @@ func (m *sidebarCmp) loadSessionFiles() tea.Msg {
 		_, additions, deletions := diff.GenerateDiff(before, after, path)
 		sessionFiles = append(sessionFiles, SessionFile{
 			History:   fh,
-			FilePath:  path,
+			FilePath:  fh.initialVersion.Path,
 			Additions: additions,
 			Deletions: deletions,
 		})
@@ -0,0 +1,470 @@
+package agents
+
+import (
+	"context"
+	"fmt"
+	"image/color"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/v2/help"
+	"github.com/charmbracelet/bubbles/v2/key"
+	tea "github.com/charmbracelet/bubbletea/v2"
+	"github.com/charmbracelet/lipgloss/v2"
+	"github.com/charmbracelet/x/ansi"
+	agentsystem "github.com/nom-nom-hub/floss/internal/agent"
+	"github.com/nom-nom-hub/floss/internal/pubsub"
+	"github.com/nom-nom-hub/floss/internal/session"
+	"github.com/nom-nom-hub/floss/internal/tui/components/chat"
+	"github.com/nom-nom-hub/floss/internal/tui/components/core"
+	"github.com/nom-nom-hub/floss/internal/tui/components/dialogs"
+	"github.com/nom-nom-hub/floss/internal/tui/styles"
+	"github.com/nom-nom-hub/floss/internal/tui/util"
+)
+
+const AgentsDialogID dialogs.DialogID = "agents"
+
+const companyWidth = 36
+
+// AgentsDialog interface for the agent dashboard dialog
+type AgentsDialog interface {
+	dialogs.DialogModel
+}
+
+type (
+	instancesLoadedMsg []agentsystem.WorkflowInstance
+	costsLoadedMsg     map[string]float64
+	tickMsg            struct{ id int }
+)
+
+// row is a selectable line of the workflow list. Step 0 is the instance
+// itself.
+type row struct {
+	instanceID string
+	step       int
+}
+
+type agentsDialogCmp struct {
+	wWidth, wHeight int
+	width, height   int
+	orchestrator    *agentsystem.AgentOrchestrator
+	sessions        session.Service
+	structure       agentsystem.CompanyStructure
+	instances       []agentsystem.WorkflowInstance
+	costs           map[string]float64
+	selected        row
+	offset          int
+	tick            int
+	keyMap          KeyMap
+	help            help.Model
+}
+
+// NewAgentsDialogCmp creates a dashboard of the virtual company and its
+// workflow instances
+func NewAgentsDialogCmp(orchestrator *agentsystem.AgentOrchestrator, sessions session.Service) AgentsDialog {
+	t := styles.CurrentTheme()
+	help := help.New()
+	help.Styles = t.S().Help
+	return &agentsDialogCmp{
+		orchestrator: orchestrator,
+		sessions:     sessions,
+		structure:    orchestrator.CompanyStructure(),
+		costs:        make(map[string]float64),
+		keyMap:       DefaultKeyMap(),
+		help:         help,
+	}
+}
+
+func (a *agentsDialogCmp) Init() tea.Cmd {
+	return tea.Batch(a.loadInstances(), a.startTicking())
+}
+
+func (a *agentsDialogCmp) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		a.wWidth = msg.Width
+		a.wHeight = msg.Height
+		a.width = min(140, a.wWidth-8)
+		a.height = a.wHeight - 6
+		a.scrollToSelected()
+		return a, nil
+	case instancesLoadedMsg:
+		a.instances = msg
+		a.sortInstances()
+		if a.selected.instanceID == "" && len(a.instances) > 0 {
+			a.selected = row{instanceID: a.instances[0].ID}
+		}
+		a.scrollToSelected()
+		return a, a.loadCosts(a.instances...)
+	case costsLoadedMsg:
+		for id, cost := range msg {
+			a.costs[id] = cost
+		}
+		return a, nil
+	case pubsub.Event[agentsystem.WorkflowInstance]:
+		a.upsertInstance(msg.Payload)
+		return a, tea.Batch(a.loadCosts(msg.Payload), a.startTicking())
+	case tickMsg:
+		// Elapsed times only change while something runs
+		if msg.id != a.tick || !a.running() {
+			return a, nil
+		}
+		return a, a.nextTick()
+	case tea.KeyPressMsg:
+		switch {
+		case key.Matches(msg, a.keyMap.Close):
+			return a, util.CmdHandler(dialogs.CloseDialogMsg{})
+		case key.Matches(msg, a.keyMap.Next):
+			a.move(1)
+		case key.Matches(msg, a.keyMap.Previous):
+			a.move(-1)
+		case key.Matches(msg, a.keyMap.Select):
+			return a, a.openSession()
+		}
+	}
+	return a, nil
+}
+
+func (a *agentsDialogCmp) loadInstances() tea.Cmd {
+	return func() tea.Msg {
+		return instancesLoadedMsg(a.orchestrator.ListWorkflowInstances())
+	}
+}
+
+// loadCosts looks up the cost of the sessions of the instances and their
+// steps
+func (a *agentsDialogCmp) loadCosts(instances ...agentsystem.WorkflowInstance) tea.Cmd {
+	var sessionIDs []string
+	for _, instance := range instances {
+		for _, step := range instance.Steps {
+			if step.SessionID != "" {
+				sessionIDs = append(sessionIDs, step.SessionID)
+			}
+		}
+	}
+	if len(sessionIDs) == 0 {
+		return nil
+	}
+	return func() tea.Msg {
+		costs := make(costsLoadedMsg, len(sessionIDs))
+		for _, id := range sessionIDs {
+			if s, err := a.sessions.Get(context.Background(), id); err == nil {
+				costs[id] = s.Cost
+			}
+		}
+		return costs
+	}
+}
+
+// startTicking starts a new tick loop, replacing one that may have stopped
+// while another dialog was on top
+func (a *agentsDialogCmp) startTicking() tea.Cmd {
+	a.tick++
+	return a.nextTick()
+}
+
+func (a *agentsDialogCmp) nextTick() tea.Cmd {
+	id := a.tick
+	return tea.Tick(time.Second, func(time.Time) tea.Msg {
+		return tickMsg{id: id}
+	})
+}
+
+func (a *agentsDialogCmp) running() bool {
+	return slices.ContainsFunc(a.instances, func(instance agentsystem.WorkflowInstance) bool {
+		return !instance.Status.IsFinished()
+	})
+}
+
+func (a *agentsDialogCmp) upsertInstance(instance agentsystem.WorkflowInstance) {
+	i := slices.IndexFunc(a.instances, func(existing agentsystem.WorkflowInstance) bool {
+		return existing.ID == instance.ID
+	})
+	if i == -1 {
+		a.instances = append(a.instances, instance)
+	} else {
+		a.instances[i] = instance
+	}
+	a.sortInstances()
+	if a.selected.instanceID == "" {
+		a.selected = row{instanceID: instance.ID}
+	}
+	a.scrollToSelected()
+}
+
+// sortInstances lists unfinished instances first, newest first
+func (a *agentsDialogCmp) sortInstances() {
+	slices.SortStableFunc(a.instances, func(x, y agentsystem.WorkflowInstance) int {
+		if xf, yf := x.Status.IsFinished(), y.Status.IsFinished(); xf != yf {
+			if xf {
+				return 1
+			}
+			return -1
+		}
+		return y.CreatedAt.Compare(x.CreatedAt)
+	})
+}
+
+func (a *agentsDialogCmp) rows() []row {
+	var rows []row
+	for _, instance := range a.instances {
+		rows = append(rows, row{instanceID: instance.ID})
+		for _, step := range instance.Steps {
+			rows = append(rows, row{instanceID: instance.ID, step: step.StepNumber})
+		}
+	}
+	return rows
+}
+
+func (a *agentsDialogCmp) move(delta int) {
+	rows := a.rows()
+	if len(rows) == 0 {
+		return
+	}
+	i := max(slices.Index(rows, a.selected), 0) + delta
+	a.selected = rows[(i+len(rows))%len(rows)]
+	a.scrollToSelected()
+}
+
+// scrollToSelected keeps the selected row inside the visible workflow list
+func (a *agentsDialogCmp) scrollToSelected() {
+	i := max(slices.Index(a.rows(), a.selected), 0)
+	height := max(a.visibleRows(), 1)
+	if i < a.offset {
+		a.offset = i
+	}
+	if i >= a.offset+height {
+		a.offset = i - height + 1
+	}
+}
+
+// openSession switches the chat to the session of the selected instance or
+// step
+func (a *agentsDialogCmp) openSession() tea.Cmd {
+	i := slices.IndexFunc(a.instances, func(instance agentsystem.WorkflowInstance) bool {
+		return instance.ID == a.selected.instanceID
+	})
+	if i == -1 {
+		return nil
+	}
+	instance := a.instances[i]
+	sessionID := instance.SessionID
+	if a.selected.step != 0 {
+		step := stepInstance(instance, a.selected.step)
+		if step.SessionID == "" {
+			return util.ReportWarn(fmt.Sprintf("Step %d has no session yet", step.StepNumber))
+		}
+		sessionID = step.SessionID
+	}
+	return tea.Sequence(
+		util.CmdHandler(dialogs.CloseDialogMsg{}),
+		func() tea.Msg {
+			s, err := a.sessions.Get(context.Background(), sessionID)
+			if err != nil {
+				return util.InfoMsg{Type: util.InfoTypeError, Msg: err.Error()}
+			}
+			return chat.SessionSelectedMsg(s)
+		},
+	)
+}
+
+func stepInstance(instance agentsystem.WorkflowInstance, stepNumber int) agentsystem.WorkflowStepInstance {
+	for _, step := range instance.Steps {
+		if step.StepNumber == stepNumber {
+			return step
+		}
+	}
+	return agentsystem.WorkflowStepInstance{StepNumber: stepNumber}
+}
+
+func (a *agentsDialogCmp) View() string {
+	t := styles.CurrentTheme()
+	height := a.listHeight()
+	company := t.S().Base.Width(companyWidth).Height(height).MaxHeight(height).Render(a.companyView(companyWidth - 2))
+	workflows := t.S().Base.Height(height).MaxHeight(height).Render(a.workflowsView(a.listWidth()))
+	content := lipgloss.JoinVertical(
+		lipgloss.Left,
+		t.S().Base.Padding(0, 1, 1, 1).Render(core.Title("Agents", a.width-4)),
+		t.S().Base.PaddingLeft(1).Render(lipgloss.JoinHorizontal(lipgloss.Top, company, workflows)),
+		"",
+		t.S().Base.Width(a.width-2).PaddingLeft(1).AlignHorizontal(lipgloss.Left).Render(a.help.View(a.keyMap)),
+	)
+	return a.style().Render(content)
+}
+
+// companyView renders the company structure, marking the roles that work on
+// a step right now
+func (a *agentsDialogCmp) companyView(width int) string {
+	t := styles.CurrentTheme()
+	busy := make(map[agentsystem.AgentRole]int)
+	for _, instance := range a.instances {
+		for _, step := range instance.Steps {
+			if step.Status == agentsystem.WorkflowStatusRunning {
+				busy[step.ResponsibleAgent]++
+			}
+		}
+	}
+	roleLine := func(role agentsystem.AgentRole, indent int) string {
+		icon := t.S().Base.Foreground(t.FgSubtle).Render(styles.ToolPending)
+		extra := ""
+		if n := busy[role]; n > 0 {
+			icon = t.S().Base.Foreground(t.Green).Render(styles.ToolPending)
+			extra = t.S().Base.Foreground(t.FgSubtle).Render(fmt.Sprintf("%d running", n))
+		}
+		return strings.Repeat(" ", indent) + core.Status(core.StatusOpts{
+			Icon:         icon,
+			Title:        string(role),
+			TitleColor:   t.FgBase,
+			ExtraContent: extra,
+		}, width-indent)
+	}
+
+	lines := []string{core.Section("Company", width)}
+	if len(a.structure.Departments) == 0 {
+		lines = append(lines, t.S().Subtle.Render("No company structure"))
+	}
+	for _, department := range a.structure.Departments {
+		lines = append(lines, t.S().Base.Foreground(t.FgHalfMuted).Render(ansi.Truncate(department.Name, width, "…")))
+		if department.HeadRole != "" {
+			lines = append(lines, roleLine(department.HeadRole, 1))
+		}
+		for _, team := range department.Teams {
+			lines = append(lines, " "+t.S().Muted.Render(ansi.Truncate(team.Name, width-1, "…")))
+			if team.LeadRole != "" {
+				lines = append(lines, roleLine(team.LeadRole, 2))
+			}
+			for _, member := range team.MemberRoles {
+				if member != team.LeadRole {
+					lines = append(lines, roleLine(member, 2))
+				}
+			}
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// workflowsView renders the visible part of the workflow list
+func (a *agentsDialogCmp) workflowsView(width int) string {
+	t := styles.CurrentTheme()
+	header := core.Section("Workflows", width)
+	if len(a.instances) == 0 {
+		return header + "\n" + t.S().Subtle.Render("No workflows have run yet. Start one with floss agents run.")
+	}
+	now := time.Now()
+	var lines []string
+	for _, instance := range a.instances {
+		var cost float64
+		var done int
+		for _, step := range instance.Steps {
+			cost += a.costs[step.SessionID]
+			if step.Status.IsFinished() {
+				done++
+			}
+		}
+		lines = append(lines, a.rowView(
+			row{instanceID: instance.ID},
+			statusIcon(instance.Status),
+			instance.Name,
+			fmt.Sprintf("%s %d/%d steps %s $%.2f", instance.Status, done, len(instance.Steps), elapsed(instance.StartedAt, instance.CompletedAt, now), cost),
+			width,
+		))
+		for _, step := range instance.Steps {
+			details := []string{string(step.Status)}
+			if step.ResponsibleAgent != "" {
+				details = append([]string{string(step.ResponsibleAgent)}, details...)
+			}
+			if step.StartedAt != nil {
+				details = append(details, elapsed(step.StartedAt, step.CompletedAt, now))
+			}
+			if step.SessionID != "" {
+				details = append(details, fmt.Sprintf("$%.2f", a.costs[step.SessionID]))
+			}
+			lines = append(lines, a.rowView(
+				row{instanceID: instance.ID, step: step.StepNumber},
+				statusIcon(step.Status),
+				fmt.Sprintf("  %d %s", step.StepNumber, step.Action),
+				strings.Join(details, " "),
+				width,
+			))
+		}
+	}
+	end := min(a.offset+a.visibleRows(), len(lines))
+	return strings.Join(append([]string{header}, lines[min(a.offset, end):end]...), "\n")
+}
+
+// rowView renders a line of the workflow list with its details right
+// aligned
+func (a *agentsDialogCmp) rowView(r row, icon, title, details string, width int) string {
+	t := styles.CurrentTheme()
+	title = ansi.Truncate(title, width-lipgloss.Width(details)-5, "…")
+	gap := max(width-lipgloss.Width(title)-lipgloss.Width(details)-4, 1)
+	if r == a.selected {
+		return t.S().TextSelected.Width(width).Render(styles.ToolPending + " " + title + strings.Repeat(" ", gap) + details + " ")
+	}
+	return icon + " " + t.S().Base.Foreground(t.FgBase).Render(title) + strings.Repeat(" ", gap) + t.S().Subtle.Render(details)
+}
+
+func statusIcon(status agentsystem.WorkflowStatus) string {
+	t := styles.CurrentTheme()
+	var c color.Color
+	icon := styles.ToolPending
+	switch status {
+	case agentsystem.WorkflowStatusRunning:
+		c = t.Green
+	case agentsystem.WorkflowStatusWaitingApproval, agentsystem.WorkflowStatusPaused:
+		c = t.Warning
+	case agentsystem.WorkflowStatusCompleted:
+		icon, c = styles.ToolSuccess, t.Success
+	case agentsystem.WorkflowStatusFailed, agentsystem.WorkflowStatusCancelled:
+		icon, c = styles.ToolError, t.Error
+	default:
+		c = t.FgSubtle
+	}
+	return t.S().Base.Foreground(c).Render(icon)
+}
+
+// elapsed formats how long something ran, up to now if it still runs
+func elapsed(startedAt, completedAt *time.Time, now time.Time) string {
+	if startedAt == nil {
+		return "-"
+	}
+	end := now
+	if completedAt != nil {
+		end = *completedAt
+	}
+	return end.Sub(*startedAt).Round(time.Second).String()
+}
+
+func (a *agentsDialogCmp) style() lipgloss.Style {
+	t := styles.CurrentTheme()
+	return t.S().Base.
+		Width(a.width).
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(t.BorderFocus)
+}
+
+func (a *agentsDialogCmp) listHeight() int {
+	return a.height - 6 // 6 for the border, title and help
+}
+
+// visibleRows is how many rows of the workflow list fit below its header
+func (a *agentsDialogCmp) visibleRows() int {
+	return a.listHeight() - 1
+}
+
+func (a *agentsDialogCmp) listWidth() int {
+	return a.width - companyWidth - 4 // 4 for the border and padding
+}
+
+func (a *agentsDialogCmp) Position() (int, int) {
+	row := (a.wHeight - a.height) / 2
+	col := a.wWidth / 2
+	col -= a.width / 2
+	return row, col
+}
+
+// ID implements AgentsDialog.
+func (a *agentsDialogCmp) ID() dialogs.DialogID {
+	return AgentsDialogID
+}
@@ -0,0 +1,205 @@
+package checkpoints
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/v2/help"
+	"github.com/charmbracelet/bubbles/v2/key"
+	tea "github.com/charmbracelet/bubbletea/v2"
+	"github.com/charmbracelet/lipgloss/v2"
+	"github.com/charmbracelet/x/ansi"
+
+	"github.com/nom-nom-hub/floss/internal/checkpoint"
+	"github.com/nom-nom-hub/floss/internal/history"
+	"github.com/nom-nom-hub/floss/internal/tui/components/chat"
+	"github.com/nom-nom-hub/floss/internal/tui/components/core"
+	"github.com/nom-nom-hub/floss/internal/tui/components/dialogs"
+	"github.com/nom-nom-hub/floss/internal/tui/styles"
+	"github.com/nom-nom-hub/floss/internal/tui/util"
+)
+
+const CheckpointsDialogID dialogs.DialogID = "checkpoints"
+
+// maxVisible is how many checkpoints the dialog shows at once, as one is
+// taken before every prompt
+const maxVisible = 10
+
+// CheckpointsDialog lists the checkpoints of the session, to restore the
+// session to one of them or fork a new session from it
+type CheckpointsDialog interface {
+	dialogs.DialogModel
+}
+
+// pendingAction is a restore or fork that would overwrite files modified
+// outside floss, done when asked again
+type pendingAction struct {
+	checkpointID string
+	fork         bool
+}
+
+type checkpointsDialogCmp struct {
+	wWidth, wHeight int
+	width           int
+	keyMap          KeyMap
+	help            help.Model
+	service         checkpoint.Service
+	sessionID       string
+	checkpoints     []checkpoint.Checkpoint
+	selected        int
+	overwrite       pendingAction
+	err             error
+}
+
+// NewCheckpointsDialogCmp creates a dialog for the checkpoints of the session
+func NewCheckpointsDialogCmp(service checkpoint.Service, sessionID string) CheckpointsDialog {
+	t := styles.CurrentTheme()
+	help := help.New()
+	help.Styles = t.S().Help
+	return &checkpointsDialogCmp{
+		keyMap:    DefaultKeyMap(),
+		help:      help,
+		service:   service,
+		sessionID: sessionID,
+	}
+}
+
+func (c *checkpointsDialogCmp) Init() tea.Cmd {
+	c.load()
+	return nil
+}
+
+func (c *checkpointsDialogCmp) load() {
+	c.checkpoints, c.err = c.service.List(context.Background(), c.sessionID)
+	c.selected = max(min(c.selected, len(c.checkpoints)-1), 0)
+}
+
+func (c *checkpointsDialogCmp) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		c.wWidth = msg.Width
+		c.wHeight = msg.Height
+		c.width = min(90, c.wWidth)
+	case tea.KeyPressMsg:
+		if key.Matches(msg, c.keyMap.Close) {
+			return c, util.CmdHandler(dialogs.CloseDialogMsg{})
+		}
+		if len(c.checkpoints) == 0 {
+			return c, nil
+		}
+		selected := c.checkpoints[c.selected]
+		switch {
+		case key.Matches(msg, c.keyMap.Previous):
+			c.selected = max(c.selected-1, 0)
+		case key.Matches(msg, c.keyMap.Next):
+			c.selected = min(c.selected+1, len(c.checkpoints)-1)
+		case key.Matches(msg, c.keyMap.Restore):
+			return c, c.restore(selected)
+		case key.Matches(msg, c.keyMap.Fork):
+			return c, c.fork(selected)
+		case key.Matches(msg, c.keyMap.Delete):
+			c.err = c.service.Delete(context.Background(), selected.ID)
+			if c.err == nil {
+				c.load()
+			}
+		default:
+			return c, nil
+		}
+		c.overwrite = pendingAction{}
+	}
+	return c, nil
+}
+
+// restore resets the session to the checkpoint, asking first to overwrite
+// changes made outside floss
+func (c *checkpointsDialogCmp) restore(selected checkpoint.Checkpoint) tea.Cmd {
+	action := pendingAction{checkpointID: selected.ID}
+	force := c.overwrite == action
+	c.overwrite, c.err = pendingAction{}, nil
+	err := c.service.Restore(context.Background(), selected.ID, force)
+	if errors.Is(err, history.ErrFileModified) {
+		c.overwrite = action
+		return nil
+	}
+	if err != nil {
+		c.err = err
+		return nil
+	}
+	return tea.Batch(
+		util.CmdHandler(dialogs.CloseDialogMsg{}),
+		util.ReportInfo(fmt.Sprintf("Restored %q", selected.Name)),
+	)
+}
+
+// fork starts a new session from the checkpoint and switches to it
+func (c *checkpointsDialogCmp) fork(selected checkpoint.Checkpoint) tea.Cmd {
+	action := pendingAction{checkpointID: selected.ID, fork: true}
+	force := c.overwrite == action
+	c.overwrite, c.err = pendingAction{}, nil
+	fork, err := c.service.Fork(context.Background(), selected.ID, force)
+	if errors.Is(err, history.ErrFileModified) {
+		c.overwrite = action
+		return nil
+	}
+	if err != nil {
+		c.err = err
+		return nil
+	}
+	return tea.Batch(
+		util.CmdHandler(dialogs.CloseDialogMsg{}),
+		util.CmdHandler(chat.SessionSelectedMsg(fork)),
+		util.ReportInfo(fmt.Sprintf("Forked from %q", selected.Name)),
+	)
+}
+
+func (c *checkpointsDialogCmp) View() string {
+	t := styles.CurrentTheme()
+
+	lines := []string{core.Title("Checkpoints", c.width-4), ""}
+	if len(c.checkpoints) == 0 {
+		lines = append(lines, t.S().Muted.Render("The session has no checkpoints"))
+	}
+	offset := max(min(c.selected-maxVisible/2, len(c.checkpoints)-maxVisible), 0)
+	for i := offset; i < min(offset+maxVisible, len(c.checkpoints)); i++ {
+		cp := c.checkpoints[i]
+		created := t.S().Subtle.Render(time.Unix(cp.CreatedAt, 0).Format("Jan 2 15:04"))
+		line := ansi.Truncate(cp.Name, c.width-8-lipgloss.Width(created), "…")
+		line += strings.Repeat(" ", max(c.width-6-lipgloss.Width(line)-lipgloss.Width(created), 1)) + created
+		if i == c.selected {
+			line = t.S().TextSelected.Width(c.width - 4).Render(ansi.Strip(line))
+		}
+		lines = append(lines, line)
+	}
+
+	switch {
+	case c.overwrite.fork:
+		lines = append(lines, "", t.S().Warning.Render("Some files were modified outside floss. Press f again to overwrite them."))
+	case c.overwrite.checkpointID != "":
+		lines = append(lines, "", t.S().Warning.Render("Some files were modified outside floss. Press enter again to overwrite them."))
+	case c.err != nil:
+		lines = append(lines, "", t.S().Error.Render(c.err.Error()))
+	}
+	lines = append(lines, "", c.help.View(c.keyMap))
+
+	return t.S().Base.
+		Padding(0, 1).
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(t.BorderFocus).
+		Width(c.width).
+		Render(lipgloss.JoinVertical(lipgloss.Left, lines...))
+}
+
+func (c *checkpointsDialogCmp) Position() (int, int) {
+	height := min(len(c.checkpoints), maxVisible) + 8
+	row := (c.wHeight / 2) - (height / 2)
+	col := (c.wWidth / 2) - (c.width / 2)
+	return row, col
+}
+
+// ID implements CheckpointsDialog.
+func (c *checkpointsDialogCmp) ID() dialogs.DialogID {
+	return CheckpointsDialogID
+}
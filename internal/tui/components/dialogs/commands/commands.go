@@ -71,9 +71,30 @@ type (
 	OpenReasoningDialogMsg struct{}
 	OpenExternalEditorMsg  struct{}
 	ToggleYoloModeMsg      struct{}
+	OpenAgentDashboardMsg  struct{}
 	CompactMsg             struct {
 		SessionID string
 	}
+	OpenPinsDialogMsg struct {
+		SessionID string
+	}
+	ToggleInterjectModeMsg struct{}
+	OpenPromptQueueMsg     struct {
+		SessionID string
+	}
+	UndoFileChangeMsg struct {
+		SessionID string
+	}
+	OpenRevertDialogMsg struct {
+		SessionID string
+	}
+	// CreateCheckpointMsg creates a checkpoint of the selected session
+	CreateCheckpointMsg struct {
+		Name string
+	}
+	OpenCheckpointsDialogMsg struct {
+		SessionID string
+	}
 )
 
 func NewCommandDialog(sessionID string) CommandsDialog {
@@ -277,6 +298,14 @@ func (c *commandDialogCmp) defaultCommands() []Command {
 				return util.CmdHandler(SwitchSessionsMsg{})
 			},
 		},
+		{
+			ID:          "agent_dashboard",
+			Title:       "Agent Dashboard",
+			Description: "Show the virtual company and its running workflows",
+			Handler: func(cmd Command) tea.Cmd {
+				return util.CmdHandler(OpenAgentDashboardMsg{})
+			},
+		},
 		{
 			ID:          "switch_model",
 			Title:       "Switch Model",
@@ -299,6 +328,64 @@ func (c *commandDialogCmp) defaultCommands() []Command {
 				})
 			},
 		})
+		commands = append(commands, Command{
+			ID:          "pin_messages",
+			Title:       "Pin Messages",
+			Description: "Choose the messages to keep verbatim when the session gets compacted",
+			Handler: func(cmd Command) tea.Cmd {
+				return util.CmdHandler(OpenPinsDialogMsg{
+					SessionID: c.sessionID,
+				})
+			},
+		})
+		commands = append(commands, Command{
+			ID:          "toggle_interject_mode",
+			Title:       "Toggle Interject Mode",
+			Description: "Send prompts to a busy session before its next model call instead of after its turn",
+			Handler: func(cmd Command) tea.Cmd {
+				return util.CmdHandler(ToggleInterjectModeMsg{})
+			},
+		})
+		commands = append(commands, Command{
+			ID:          "prompt_queue",
+			Title:       "Manage Prompt Queue",
+			Description: "Edit, delete or reorder the prompts waiting for the session",
+			Handler: func(cmd Command) tea.Cmd {
+				return util.CmdHandler(OpenPromptQueueMsg{
+					SessionID: c.sessionID,
+				})
+			},
+		})
+		commands = append(commands, Command{
+			ID:          "undo_file_change",
+			Title:       "Undo Last File Change",
+			Description: "Write back the file the agent changed last as it was before",
+			Handler: func(cmd Command) tea.Cmd {
+				return util.CmdHandler(UndoFileChangeMsg{
+					SessionID: c.sessionID,
+				})
+			},
+		})
+		commands = append(commands, Command{
+			ID:          "revert_files",
+			Title:       "Revert Files",
+			Description: "Revert the files the session changed to an earlier version",
+			Handler: func(cmd Command) tea.Cmd {
+				return util.CmdHandler(OpenRevertDialogMsg{
+					SessionID: c.sessionID,
+				})
+			},
+		})
+		commands = append(commands, Command{
+			ID:          "checkpoints",
+			Title:       "Checkpoints",
+			Description: "Restore or fork the session from a checkpoint",
+			Handler: func(cmd Command) tea.Cmd {
+				return util.CmdHandler(OpenCheckpointsDialogMsg{
+					SessionID: c.sessionID,
+				})
+			},
+		})
 	}
 
 	// Add reasoning toggle for models that support it
@@ -91,7 +91,11 @@ func (p *permissionDialogCmp) Init() tea.Cmd {
 }
 
 func (p *permissionDialogCmp) supportsDiffView() bool {
-	return p.permission.ToolName == tools.EditToolName || p.permission.ToolName == tools.WriteToolName || p.permission.ToolName == tools.MultiEditToolName
+	switch p.permission.ToolName {
+	case tools.EditToolName, tools.WriteToolName, tools.MultiEditToolName, tools.RenameToolName, tools.CodeActionToolName:
+		return true
+	}
+	return false
 }
 
 func (p *permissionDialogCmp) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
@@ -370,6 +374,30 @@ func (p *permissionDialogCmp) renderHeader() string {
 			),
 			baseStyle.Render(strings.Repeat(" ", p.width)),
 		)
+	case tools.RenameToolName, tools.CodeActionToolName:
+		params := p.permission.Params.(tools.WorkspaceEditPermissionsParams)
+		editKey := t.S().Muted.Render("Edit")
+		editValue := t.S().Text.
+			Width(p.width - lipgloss.Width(editKey)).
+			Render(fmt.Sprintf(" %s", params.Description))
+		filesKey := t.S().Muted.Render("Files")
+		filesValue := t.S().Text.
+			Width(p.width - lipgloss.Width(filesKey)).
+			Render(fmt.Sprintf(" %d", len(params.Changes)))
+		headerParts = append(headerParts,
+			lipgloss.JoinHorizontal(
+				lipgloss.Left,
+				editKey,
+				editValue,
+			),
+			baseStyle.Render(strings.Repeat(" ", p.width)),
+			lipgloss.JoinHorizontal(
+				lipgloss.Left,
+				filesKey,
+				filesValue,
+			),
+			baseStyle.Render(strings.Repeat(" ", p.width)),
+		)
 	case tools.FetchToolName:
 		headerParts = append(headerParts, t.S().Muted.Width(p.width).Bold(true).Render("URL"))
 	case tools.ViewToolName:
@@ -424,6 +452,8 @@ func (p *permissionDialogCmp) getOrGenerateContent() string {
 		content = p.generateWriteContent()
 	case tools.MultiEditToolName:
 		content = p.generateMultiEditContent()
+	case tools.RenameToolName, tools.CodeActionToolName:
+		content = p.generateWorkspaceEditContent()
 	case tools.FetchToolName:
 		content = p.generateFetchContent()
 	case tools.ViewToolName:
@@ -556,6 +586,47 @@ func (p *permissionDialogCmp) generateMultiEditContent() string {
 	return ""
 }
 
+// generateWorkspaceEditContent shows the diffs of every file the edit
+// changes one after the other, scrolling through them as one
+func (p *permissionDialogCmp) generateWorkspaceEditContent() string {
+	t := styles.CurrentTheme()
+	pr, ok := p.permission.Params.(tools.WorkspaceEditPermissionsParams)
+	if !ok {
+		return ""
+	}
+
+	var lines []string
+	for _, change := range pr.Changes {
+		path := fsext.PrettyPath(change.Path)
+		title := path
+		switch {
+		case change.Created:
+			title += " (new file)"
+		case change.Deleted:
+			title += " (deleted)"
+		}
+		formatter := core.DiffFormatter().
+			Before(path, change.OldContent).
+			After(path, change.NewContent).
+			Width(p.contentViewPort.Width()).
+			XOffset(p.diffXOffset)
+		if p.useDiffSplitMode() {
+			formatter = formatter.Split()
+		} else {
+			formatter = formatter.Unified()
+		}
+		lines = append(lines, t.S().Base.Bold(true).Width(p.contentViewPort.Width()).Render(title))
+		lines = append(lines, strings.Split(formatter.String(), "\n")...)
+	}
+
+	height := p.contentViewPort.Height()
+	if height <= 0 {
+		return strings.Join(lines, "\n")
+	}
+	p.diffYOffset = min(p.diffYOffset, max(len(lines)-height, 0))
+	return strings.Join(lines[p.diffYOffset:min(p.diffYOffset+height, len(lines))], "\n")
+}
+
 func (p *permissionDialogCmp) generateFetchContent() string {
 	t := styles.CurrentTheme()
 	baseStyle := t.S().Base.Background(t.BgSubtle)
@@ -743,6 +814,9 @@ func (p *permissionDialogCmp) SetSize() tea.Cmd {
 	case tools.MultiEditToolName:
 		p.width = int(float64(p.wWidth) * 0.8)
 		p.height = int(float64(p.wHeight) * 0.8)
+	case tools.RenameToolName, tools.CodeActionToolName:
+		p.width = int(float64(p.wWidth) * 0.8)
+		p.height = int(float64(p.wHeight) * 0.8)
 	case tools.FetchToolName:
 		p.width = int(float64(p.wWidth) * 0.8)
 		p.height = int(float64(p.wHeight) * 0.3)
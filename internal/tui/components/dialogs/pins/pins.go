@@ -0,0 +1,172 @@
+package pins
+
+import (
+	"context"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/v2/help"
+	"github.com/charmbracelet/bubbles/v2/key"
+	tea "github.com/charmbracelet/bubbletea/v2"
+	"github.com/charmbracelet/lipgloss/v2"
+	"github.com/charmbracelet/x/ansi"
+
+	"github.com/nom-nom-hub/floss/internal/message"
+	"github.com/nom-nom-hub/floss/internal/tui/components/core"
+	"github.com/nom-nom-hub/floss/internal/tui/components/dialogs"
+	"github.com/nom-nom-hub/floss/internal/tui/styles"
+	"github.com/nom-nom-hub/floss/internal/tui/util"
+)
+
+const PinsDialogID dialogs.DialogID = "pins"
+
+// maxVisible is how many messages the dialog shows at once
+const maxVisible = 10
+
+// PinsDialog lists the prompts and answers of the session, to pin the ones
+// compaction should keep verbatim
+type PinsDialog interface {
+	dialogs.DialogModel
+}
+
+type pinsDialogCmp struct {
+	wWidth, wHeight int
+	width           int
+	keyMap          KeyMap
+	help            help.Model
+	service         message.Service
+	sessionID       string
+	messages        []message.Message
+	selected        int
+	err             error
+}
+
+// NewPinsDialogCmp creates a dialog to pin the messages of the session
+func NewPinsDialogCmp(service message.Service, sessionID string) PinsDialog {
+	t := styles.CurrentTheme()
+	help := help.New()
+	help.Styles = t.S().Help
+	return &pinsDialogCmp{
+		keyMap:    DefaultKeyMap(),
+		help:      help,
+		service:   service,
+		sessionID: sessionID,
+	}
+}
+
+func (p *pinsDialogCmp) Init() tea.Cmd {
+	p.load()
+	// Start on the latest message, the one most likely to be pinned
+	p.selected = max(len(p.messages)-1, 0)
+	return nil
+}
+
+// load lists the messages with something to show, leaving out tool results
+// as they belong to the answer that called the tools
+func (p *pinsDialogCmp) load() {
+	msgs, err := p.service.List(context.Background(), p.sessionID)
+	p.messages, p.err = nil, err
+	for _, msg := range msgs {
+		if msg.Role != message.Tool && preview(msg) != "" {
+			p.messages = append(p.messages, msg)
+		}
+	}
+	p.selected = max(min(p.selected, len(p.messages)-1), 0)
+}
+
+func (p *pinsDialogCmp) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		p.wWidth = msg.Width
+		p.wHeight = msg.Height
+		p.width = min(90, p.wWidth)
+	case tea.KeyPressMsg:
+		if key.Matches(msg, p.keyMap.Close) {
+			return p, util.CmdHandler(dialogs.CloseDialogMsg{})
+		}
+		if len(p.messages) == 0 {
+			return p, nil
+		}
+		switch {
+		case key.Matches(msg, p.keyMap.Previous):
+			p.selected = max(p.selected-1, 0)
+		case key.Matches(msg, p.keyMap.Next):
+			p.selected = min(p.selected+1, len(p.messages)-1)
+		case key.Matches(msg, p.keyMap.Toggle):
+			selected := p.messages[p.selected]
+			p.err = p.service.SetPinned(context.Background(), selected.ID, !selected.Pinned)
+			if p.err == nil {
+				p.load()
+			}
+		}
+	}
+	return p, nil
+}
+
+// preview is the first line of the text of the message, or the tools it
+// called when it has no text
+func preview(msg message.Message) string {
+	for line := range strings.SplitSeq(msg.Content().Text, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			return line
+		}
+	}
+	var names []string
+	for _, call := range msg.ToolCalls() {
+		names = append(names, call.Name)
+	}
+	if len(names) > 0 {
+		return "Called " + strings.Join(names, ", ")
+	}
+	return ""
+}
+
+func (p *pinsDialogCmp) View() string {
+	t := styles.CurrentTheme()
+
+	lines := []string{core.Title("Pin Messages", p.width-4), ""}
+	if len(p.messages) == 0 {
+		lines = append(lines, t.S().Muted.Render("The session has no messages"))
+	}
+	offset := max(min(p.selected-maxVisible/2, len(p.messages)-maxVisible), 0)
+	for i := offset; i < min(offset+maxVisible, len(p.messages)); i++ {
+		msg := p.messages[i]
+		marker := "  "
+		if msg.Pinned {
+			marker = styles.PinIcon + " "
+		}
+		role := "You "
+		if msg.Role == message.Assistant {
+			role = "Assistant "
+		}
+		line := marker + t.S().Subtle.Render(role) + preview(msg)
+		line = ansi.Truncate(line, p.width-6, "…")
+		if i == p.selected {
+			line = t.S().TextSelected.Width(p.width - 4).Render(ansi.Strip(line))
+		}
+		lines = append(lines, line)
+	}
+
+	if p.err != nil {
+		lines = append(lines, "", t.S().Error.Render(p.err.Error()))
+	}
+	lines = append(lines, "", t.S().Muted.Render("Compaction keeps pinned messages verbatim."), "", p.help.View(p.keyMap))
+
+	return t.S().Base.
+		Padding(0, 1).
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(t.BorderFocus).
+		Width(p.width).
+		Render(lipgloss.JoinVertical(lipgloss.Left, lines...))
+}
+
+func (p *pinsDialogCmp) Position() (int, int) {
+	height := min(len(p.messages), maxVisible) + 10
+	row := (p.wHeight / 2) - (height / 2)
+	col := (p.wWidth / 2) - (p.width / 2)
+	return row, col
+}
+
+// ID implements PinsDialog.
+func (p *pinsDialogCmp) ID() dialogs.DialogID {
+	return PinsDialogID
+}
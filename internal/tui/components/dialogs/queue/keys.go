@@ -0,0 +1,90 @@
+package queue
+
+import (
+	"github.com/charmbracelet/bubbles/v2/key"
+)
+
+// KeyMap defines the keyboard bindings for the prompt queue dialog.
+type KeyMap struct {
+	Previous,
+	Next,
+	MoveUp,
+	MoveDown,
+	ToggleInterject,
+	Edit,
+	Delete,
+	Close key.Binding
+}
+
+func DefaultKeyMap() KeyMap {
+	return KeyMap{
+		Previous: key.NewBinding(
+			key.WithKeys("up", "k"),
+			key.WithHelp("↑", "previous"),
+		),
+		Next: key.NewBinding(
+			key.WithKeys("down", "j"),
+			key.WithHelp("↓", "next"),
+		),
+		MoveUp: key.NewBinding(
+			key.WithKeys("shift+up", "K"),
+			key.WithHelp("shift+↑", "move up"),
+		),
+		MoveDown: key.NewBinding(
+			key.WithKeys("shift+down", "J"),
+			key.WithHelp("shift+↓", "move down"),
+		),
+		ToggleInterject: key.NewBinding(
+			key.WithKeys("i"),
+			key.WithHelp("i", "interject"),
+		),
+		Edit: key.NewBinding(
+			key.WithKeys("enter", "e"),
+			key.WithHelp("enter", "edit"),
+		),
+		Delete: key.NewBinding(
+			key.WithKeys("d", "delete", "backspace"),
+			key.WithHelp("d", "delete"),
+		),
+		Close: key.NewBinding(
+			key.WithKeys("esc"),
+			key.WithHelp("esc", "close"),
+		),
+	}
+}
+
+// KeyBindings implements layout.KeyMapProvider
+func (k KeyMap) KeyBindings() []key.Binding {
+	return []key.Binding{
+		k.Previous,
+		k.Next,
+		k.MoveUp,
+		k.MoveDown,
+		k.ToggleInterject,
+		k.Edit,
+		k.Delete,
+		k.Close,
+	}
+}
+
+// FullHelp implements help.KeyMap.
+func (k KeyMap) FullHelp() [][]key.Binding {
+	m := [][]key.Binding{}
+	slice := k.KeyBindings()
+	for i := 0; i < len(slice); i += 4 {
+		end := min(i+4, len(slice))
+		m = append(m, slice[i:end])
+	}
+	return m
+}
+
+// ShortHelp implements help.KeyMap.
+func (k KeyMap) ShortHelp() []key.Binding {
+	return []key.Binding{
+		k.MoveUp,
+		k.ToggleInterject,
+		k.Edit,
+		k.Delete,
+		k.Close,
+	}
+}
@@ -0,0 +1,153 @@
+package queue
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/v2/help"
+	"github.com/charmbracelet/bubbles/v2/key"
+	tea "github.com/charmbracelet/bubbletea/v2"
+	"github.com/charmbracelet/lipgloss/v2"
+	"github.com/charmbracelet/x/ansi"
+
+	"github.com/nom-nom-hub/floss/internal/llm/agent"
+	"github.com/nom-nom-hub/floss/internal/tui/components/chat/editor"
+	"github.com/nom-nom-hub/floss/internal/tui/components/core"
+	"github.com/nom-nom-hub/floss/internal/tui/components/dialogs"
+	"github.com/nom-nom-hub/floss/internal/tui/styles"
+	"github.com/nom-nom-hub/floss/internal/tui/util"
+)
+
+const QueueDialogID dialogs.DialogID = "queue"
+
+// QueueDialog lists the prompts queued while the session is busy, to edit,
+// delete or reorder them and to choose which of them interject
+type QueueDialog interface {
+	dialogs.DialogModel
+}
+
+type queueDialogCmp struct {
+	wWidth, wHeight int
+	width           int
+	keyMap          KeyMap
+	help            help.Model
+	agent           agent.Service
+	sessionID       string
+	prompts         []agent.QueuedPrompt
+	selected        int
+}
+
+// NewQueueDialogCmp creates a dialog for the prompt queue of the session
+func NewQueueDialogCmp(agent agent.Service, sessionID string) QueueDialog {
+	t := styles.CurrentTheme()
+	help := help.New()
+	help.Styles = t.S().Help
+	return &queueDialogCmp{
+		keyMap:    DefaultKeyMap(),
+		help:      help,
+		agent:     agent,
+		sessionID: sessionID,
+	}
+}
+
+func (q *queueDialogCmp) Init() tea.Cmd {
+	q.refresh()
+	return nil
+}
+
+// refresh reads the queue again, as the agent takes prompts from it
+func (q *queueDialogCmp) refresh() {
+	q.prompts = q.agent.QueuedPrompts(q.sessionID)
+	q.selected = max(min(q.selected, len(q.prompts)-1), 0)
+}
+
+func (q *queueDialogCmp) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	q.refresh()
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		q.wWidth = msg.Width
+		q.wHeight = msg.Height
+		q.width = min(90, q.wWidth)
+	case tea.KeyPressMsg:
+		if key.Matches(msg, q.keyMap.Close) {
+			return q, util.CmdHandler(dialogs.CloseDialogMsg{})
+		}
+		if len(q.prompts) == 0 {
+			return q, nil
+		}
+		prompt := q.prompts[q.selected]
+		switch {
+		case key.Matches(msg, q.keyMap.Previous):
+			q.selected = max(q.selected-1, 0)
+		case key.Matches(msg, q.keyMap.Next):
+			q.selected = min(q.selected+1, len(q.prompts)-1)
+		case key.Matches(msg, q.keyMap.MoveUp):
+			if q.agent.MoveQueuedPrompt(q.sessionID, prompt.ID, -1) {
+				q.selected = max(q.selected-1, 0)
+			}
+		case key.Matches(msg, q.keyMap.MoveDown):
+			if q.agent.MoveQueuedPrompt(q.sessionID, prompt.ID, 1) {
+				q.selected = min(q.selected+1, len(q.prompts)-1)
+			}
+		case key.Matches(msg, q.keyMap.ToggleInterject):
+			prompt.Interject = !prompt.Interject
+			q.agent.UpdateQueuedPrompt(q.sessionID, prompt)
+		case key.Matches(msg, q.keyMap.Delete):
+			q.agent.RemoveQueuedPrompt(q.sessionID, prompt.ID)
+		case key.Matches(msg, q.keyMap.Edit):
+			// The prompt goes back to the editor, to send again once edited
+			if _, ok := q.agent.RemoveQueuedPrompt(q.sessionID, prompt.ID); !ok {
+				return q, util.ReportWarn("The prompt was already sent")
+			}
+			return q, tea.Sequence(
+				util.CmdHandler(dialogs.CloseDialogMsg{}),
+				util.CmdHandler(editor.OpenEditorMsg{Text: prompt.Content}),
+			)
+		}
+		q.refresh()
+	}
+	return q, nil
+}
+
+func (q *queueDialogCmp) View() string {
+	t := styles.CurrentTheme()
+	q.refresh()
+
+	lines := []string{core.Title("Prompt Queue", q.width-4), ""}
+	if len(q.prompts) == 0 {
+		lines = append(lines, t.S().Muted.Render("No prompts are queued"))
+	}
+	for i, prompt := range q.prompts {
+		tag := t.S().Subtle.Render("queued   ")
+		if prompt.Interject {
+			tag = t.S().Base.Foreground(t.Accent).Render("interject")
+		}
+		content := strings.Join(strings.Fields(prompt.Content), " ")
+		line := fmt.Sprintf("%d. %s %s", i+1, tag, content)
+		line = ansi.Truncate(line, q.width-6, "…")
+		if i == q.selected {
+			line = t.S().TextSelected.Width(q.width - 4).Render(ansi.Strip(line))
+		}
+		lines = append(lines, line)
+	}
+	lines = append(lines, "", q.help.View(q.keyMap))
+
+	return t.S().Base.
+		Padding(0, 1).
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(t.BorderFocus).
+		Width(q.width).
+		Render(lipgloss.JoinVertical(lipgloss.Left, lines...))
+}
+
+func (q *queueDialogCmp) Position() (int, int) {
+	height := len(q.prompts) + 6
+	row := (q.wHeight / 2) - (height / 2)
+	col := (q.wWidth / 2) - (q.width / 2)
+	return row, col
+}
+
+// ID implements QueueDialog.
+func (q *queueDialogCmp) ID() dialogs.DialogID {
+	return QueueDialogID
+}
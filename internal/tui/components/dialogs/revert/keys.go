@@ -0,0 +1,84 @@
+package revert
+
+import (
+	"github.com/charmbracelet/bubbles/v2/key"
+)
+
+// KeyMap defines the keyboard bindings for the revert dialog.
+type KeyMap struct {
+	Previous,
+	Next,
+	Older,
+	Newer,
+	Revert,
+	RevertAll,
+	Close key.Binding
+}
+
+func DefaultKeyMap() KeyMap {
+	return KeyMap{
+		Previous: key.NewBinding(
+			key.WithKeys("up", "k"),
+			key.WithHelp("↑", "previous"),
+		),
+		Next: key.NewBinding(
+			key.WithKeys("down", "j"),
+			key.WithHelp("↓", "next"),
+		),
+		Older: key.NewBinding(
+			key.WithKeys("left", "h"),
+			key.WithHelp("←", "older"),
+		),
+		Newer: key.NewBinding(
+			key.WithKeys("right", "l"),
+			key.WithHelp("→", "newer"),
+		),
+		Revert: key.NewBinding(
+			key.WithKeys("enter"),
+			key.WithHelp("enter", "revert"),
+		),
+		RevertAll: key.NewBinding(
+			key.WithKeys("a"),
+			key.WithHelp("a", "revert all"),
+		),
+		Close: key.NewBinding(
+			key.WithKeys("esc"),
+			key.WithHelp("esc", "close"),
+		),
+	}
+}
+
+// KeyBindings implements layout.KeyMapProvider
+func (k KeyMap) KeyBindings() []key.Binding {
+	return []key.Binding{
+		k.Previous,
+		k.Next,
+		k.Older,
+		k.Newer,
+		k.Revert,
+		k.RevertAll,
+		k.Close,
+	}
+}
+
+// FullHelp implements help.KeyMap.
+func (k KeyMap) FullHelp() [][]key.Binding {
+	m := [][]key.Binding{}
+	slice := k.KeyBindings()
+	for i := 0; i < len(slice); i += 4 {
+		end := min(i+4, len(slice))
+		m = append(m, slice[i:end])
+	}
+	return m
+}
+
+// ShortHelp implements help.KeyMap.
+func (k KeyMap) ShortHelp() []key.Binding {
+	return []key.Binding{
+		k.Older,
+		k.Newer,
+		k.Revert,
+		k.RevertAll,
+		k.Close,
+	}
+}
@@ -0,0 +1,246 @@
+package revert
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/v2/help"
+	"github.com/charmbracelet/bubbles/v2/key"
+	tea "github.com/charmbracelet/bubbletea/v2"
+	"github.com/charmbracelet/lipgloss/v2"
+	"github.com/charmbracelet/x/ansi"
+
+	"github.com/nom-nom-hub/floss/internal/config"
+	"github.com/nom-nom-hub/floss/internal/history"
+	"github.com/nom-nom-hub/floss/internal/tui/components/core"
+	"github.com/nom-nom-hub/floss/internal/tui/components/dialogs"
+	"github.com/nom-nom-hub/floss/internal/tui/styles"
+	"github.com/nom-nom-hub/floss/internal/tui/util"
+)
+
+const RevertDialogID dialogs.DialogID = "revert"
+
+// allFiles marks reverting every file to overwrite
+const allFiles = "*"
+
+// RevertDialog lists the files the session changed, to write back an
+// earlier version of one or all of them
+type RevertDialog interface {
+	dialogs.DialogModel
+}
+
+// changedFile is a file the session changed with its versions, oldest
+// first, and the one chosen to revert to
+type changedFile struct {
+	path     string
+	versions []history.File
+	chosen   int
+}
+
+type revertDialogCmp struct {
+	wWidth, wHeight int
+	width           int
+	keyMap          KeyMap
+	help            help.Model
+	history         history.Service
+	sessionID       string
+	files           []changedFile
+	selected        int
+	// overwrite is the path of the file modified outside floss to revert
+	// anyway when asked again, or allFiles
+	overwrite string
+	err       error
+}
+
+// NewRevertDialogCmp creates a dialog for the files the session changed
+func NewRevertDialogCmp(history history.Service, sessionID string) RevertDialog {
+	t := styles.CurrentTheme()
+	help := help.New()
+	help.Styles = t.S().Help
+	return &revertDialogCmp{
+		keyMap:    DefaultKeyMap(),
+		help:      help,
+		history:   history,
+		sessionID: sessionID,
+	}
+}
+
+func (r *revertDialogCmp) Init() tea.Cmd {
+	r.load()
+	return nil
+}
+
+// load lists the files the session changed, keeping the versions chosen
+func (r *revertDialogCmp) load() {
+	files, err := r.history.ListBySession(context.Background(), r.sessionID)
+	if err != nil {
+		r.err = err
+		return
+	}
+	versions := make(map[string][]history.File)
+	for _, file := range files {
+		versions[file.Path] = append(versions[file.Path], file)
+	}
+	chosen := make(map[string]int)
+	for _, file := range r.files {
+		chosen[file.path] = file.chosen
+	}
+	r.files = nil
+	for path, pathVersions := range versions {
+		if len(pathVersions) < 2 {
+			continue
+		}
+		r.files = append(r.files, changedFile{
+			path:     path,
+			versions: pathVersions,
+			chosen:   min(chosen[path], len(pathVersions)-2),
+		})
+	}
+	slices.SortFunc(r.files, func(a, b changedFile) int {
+		return strings.Compare(a.path, b.path)
+	})
+	r.selected = max(min(r.selected, len(r.files)-1), 0)
+}
+
+func (r *revertDialogCmp) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		r.wWidth = msg.Width
+		r.wHeight = msg.Height
+		r.width = min(90, r.wWidth)
+	case tea.KeyPressMsg:
+		if key.Matches(msg, r.keyMap.Close) {
+			return r, util.CmdHandler(dialogs.CloseDialogMsg{})
+		}
+		if len(r.files) == 0 {
+			return r, nil
+		}
+		file := &r.files[r.selected]
+		switch {
+		case key.Matches(msg, r.keyMap.Previous):
+			r.selected = max(r.selected-1, 0)
+		case key.Matches(msg, r.keyMap.Next):
+			r.selected = min(r.selected+1, len(r.files)-1)
+		case key.Matches(msg, r.keyMap.Older):
+			file.chosen = max(file.chosen-1, 0)
+		case key.Matches(msg, r.keyMap.Newer):
+			file.chosen = min(file.chosen+1, len(file.versions)-2)
+		case key.Matches(msg, r.keyMap.Revert):
+			return r, r.revert(file.path, file.versions[file.chosen].Version)
+		case key.Matches(msg, r.keyMap.RevertAll):
+			return r, r.revertAll()
+		default:
+			return r, nil
+		}
+		r.overwrite = ""
+	}
+	return r, nil
+}
+
+// revert writes back the version of the file, asking first to overwrite
+// changes made outside floss
+func (r *revertDialogCmp) revert(path string, version int64) tea.Cmd {
+	force := r.overwrite == path
+	r.overwrite, r.err = "", nil
+	_, err := r.history.Revert(context.Background(), r.sessionID, path, version, force)
+	if errors.Is(err, history.ErrFileModified) {
+		r.overwrite = path
+		return nil
+	}
+	if err != nil {
+		r.err = err
+		return nil
+	}
+	return r.reverted(fmt.Sprintf("Reverted %s", r.relPath(path)))
+}
+
+func (r *revertDialogCmp) revertAll() tea.Cmd {
+	force := r.overwrite == allFiles
+	r.overwrite, r.err = "", nil
+	files, err := r.history.RevertSession(context.Background(), r.sessionID, force)
+	if errors.Is(err, history.ErrFileModified) {
+		r.overwrite = allFiles
+		return nil
+	}
+	if err != nil {
+		r.err = err
+		return nil
+	}
+	return r.reverted(fmt.Sprintf("Reverted %d files", len(files)))
+}
+
+// reverted reports the revert, closing the dialog once nothing is left
+func (r *revertDialogCmp) reverted(info string) tea.Cmd {
+	r.load()
+	if len(r.files) == 0 {
+		return tea.Batch(util.CmdHandler(dialogs.CloseDialogMsg{}), util.ReportInfo(info))
+	}
+	return util.ReportInfo(info)
+}
+
+func (r *revertDialogCmp) relPath(path string) string {
+	if rel, err := filepath.Rel(config.Get().WorkingDir(), path); err == nil && !strings.HasPrefix(rel, "..") {
+		return rel
+	}
+	return path
+}
+
+// versionName names the version to revert to, counting the changes of the
+// session
+func versionName(file changedFile) string {
+	if file.chosen == 0 {
+		return "before the session"
+	}
+	return fmt.Sprintf("after change %d of %d", file.chosen, len(file.versions)-1)
+}
+
+func (r *revertDialogCmp) View() string {
+	t := styles.CurrentTheme()
+
+	lines := []string{core.Title("Revert Files", r.width-4), ""}
+	if len(r.files) == 0 {
+		lines = append(lines, t.S().Muted.Render("The session changed no files"))
+	}
+	for i, file := range r.files {
+		version := t.S().Subtle.Render("‹ " + versionName(file) + " ›")
+		line := ansi.Truncate(r.relPath(file.path), r.width-8-lipgloss.Width(version), "…")
+		line += strings.Repeat(" ", max(r.width-6-lipgloss.Width(line)-lipgloss.Width(version), 1)) + version
+		if i == r.selected {
+			line = t.S().TextSelected.Width(r.width - 4).Render(ansi.Strip(line))
+		}
+		lines = append(lines, line)
+	}
+
+	switch {
+	case r.overwrite == allFiles:
+		lines = append(lines, "", t.S().Warning.Render("Some files were modified outside floss. Press a again to overwrite them."))
+	case r.overwrite != "":
+		lines = append(lines, "", t.S().Warning.Render(fmt.Sprintf("%s was modified outside floss. Press enter again to overwrite it.", r.relPath(r.overwrite))))
+	case r.err != nil:
+		lines = append(lines, "", t.S().Error.Render(r.err.Error()))
+	}
+	lines = append(lines, "", r.help.View(r.keyMap))
+
+	return t.S().Base.
+		Padding(0, 1).
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(t.BorderFocus).
+		Width(r.width).
+		Render(lipgloss.JoinVertical(lipgloss.Left, lines...))
+}
+
+func (r *revertDialogCmp) Position() (int, int) {
+	height := len(r.files) + 8
+	row := (r.wHeight / 2) - (height / 2)
+	col := (r.wWidth / 2) - (r.width / 2)
+	return row, col
+}
+
+// ID implements RevertDialog.
+func (r *revertDialogCmp) ID() dialogs.DialogID {
+	return RevertDialogID
+}
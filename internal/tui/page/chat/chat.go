@@ -122,6 +122,8 @@ type chatPage struct {
 	splashFullScreen bool
 	isOnboarding     bool
 	isProjectInit    bool
+	// interject sends prompts to a busy session before its next model call
+	interject bool
 }
 
 func New(app *app.App) ChatPage {
@@ -265,6 +267,12 @@ func (p *chatPage) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return p, tea.Batch(p.SetSize(p.width, p.height), cmd)
 	case commands.ToggleThinkingMsg:
 		return p, p.toggleThinking()
+	case commands.ToggleInterjectModeMsg:
+		p.interject = !p.interject
+		if p.interject {
+			return p, util.ReportInfo("Interject mode on: prompts go in before the next model call")
+		}
+		return p, util.ReportInfo("Interject mode off: prompts wait for the turn to end")
 	case commands.OpenReasoningDialogMsg:
 		return p, p.openReasoningDialog()
 	case reasoning.ReasoningEffortSelectedMsg:
@@ -898,7 +906,7 @@ func (p *chatPage) cancel() tea.Cmd {
 		return nil
 	}
 
-	if p.app.CoderAgent != nil && p.app.CoderAgent.QueuedPrompts(p.session.ID) > 0 {
+	if p.app.CoderAgent != nil && len(p.app.CoderAgent.QueuedPrompts(p.session.ID)) > 0 {
 		p.app.CoderAgent.ClearQueue(p.session.ID)
 		return nil
 	}
@@ -947,7 +955,11 @@ func (p *chatPage) sendMessage(text string, attachments []message.Attachment) te
 	if p.app.CoderAgent == nil {
 		return util.ReportError(fmt.Errorf("coder agent is not initialized"))
 	}
-	_, err := p.app.CoderAgent.Run(context.Background(), session.ID, text, attachments...)
+	run := p.app.CoderAgent.Run
+	if p.interject {
+		run = p.app.CoderAgent.Interject
+	}
+	_, err := run(context.Background(), session.ID, text, attachments...)
 	if err != nil {
 		return util.ReportError(err)
 	}
@@ -1092,7 +1104,7 @@ func (p *chatPage) Help() help.KeyMap {
 					key.WithHelp("esc", "press again to cancel"),
 				)
 			}
-			if p.app.CoderAgent != nil && p.app.CoderAgent.QueuedPrompts(p.session.ID) > 0 {
+			if p.app.CoderAgent != nil && len(p.app.CoderAgent.QueuedPrompts(p.session.ID)) > 0 {
 				cancelBinding = key.NewBinding(
 					key.WithKeys("esc"),
 					key.WithHelp("esc", "clear queue"),
@@ -1157,6 +1169,7 @@ func (p *chatPage) Help() help.KeyMap {
 					key.WithHelp("↑↓", "scroll"),
 				),
 				messages.CopyKey,
+				messages.PinKey,
 			)
 			fullList = append(fullList,
 				[]key.Binding{
@@ -1197,6 +1210,7 @@ func (p *chatPage) Help() help.KeyMap {
 				},
 				[]key.Binding{
 					messages.CopyKey,
+					messages.PinKey,
 					messages.ClearSelectionKey,
 				},
 			)
@@ -2,6 +2,7 @@ package tui
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strings"
 	"time"
@@ -9,24 +10,33 @@ import (
 	"github.com/charmbracelet/bubbles/v2/key"
 	tea "github.com/charmbracelet/bubbletea/v2"
 	"github.com/charmbracelet/lipgloss/v2"
+	agentsystem "github.com/nom-nom-hub/floss/internal/agent"
 	"github.com/nom-nom-hub/floss/internal/app"
 	"github.com/nom-nom-hub/floss/internal/config"
+	"github.com/nom-nom-hub/floss/internal/fsext"
+	"github.com/nom-nom-hub/floss/internal/history"
 	"github.com/nom-nom-hub/floss/internal/llm/agent"
 	"github.com/nom-nom-hub/floss/internal/permission"
 	"github.com/nom-nom-hub/floss/internal/pubsub"
 	cmpChat "github.com/nom-nom-hub/floss/internal/tui/components/chat"
+	"github.com/nom-nom-hub/floss/internal/tui/components/chat/messages"
 	"github.com/nom-nom-hub/floss/internal/tui/components/chat/splash"
 	"github.com/nom-nom-hub/floss/internal/tui/components/completions"
 	"github.com/nom-nom-hub/floss/internal/tui/components/core"
 	"github.com/nom-nom-hub/floss/internal/tui/components/core/layout"
 	"github.com/nom-nom-hub/floss/internal/tui/components/core/status"
 	"github.com/nom-nom-hub/floss/internal/tui/components/dialogs"
+	"github.com/nom-nom-hub/floss/internal/tui/components/dialogs/agents"
+	"github.com/nom-nom-hub/floss/internal/tui/components/dialogs/checkpoints"
 	"github.com/nom-nom-hub/floss/internal/tui/components/dialogs/commands"
 	"github.com/nom-nom-hub/floss/internal/tui/components/dialogs/compact"
 	"github.com/nom-nom-hub/floss/internal/tui/components/dialogs/filepicker"
 	"github.com/nom-nom-hub/floss/internal/tui/components/dialogs/models"
 	"github.com/nom-nom-hub/floss/internal/tui/components/dialogs/permissions"
+	"github.com/nom-nom-hub/floss/internal/tui/components/dialogs/pins"
+	"github.com/nom-nom-hub/floss/internal/tui/components/dialogs/queue"
 	"github.com/nom-nom-hub/floss/internal/tui/components/dialogs/quit"
+	"github.com/nom-nom-hub/floss/internal/tui/components/dialogs/revert"
 	"github.com/nom-nom-hub/floss/internal/tui/components/dialogs/sessions"
 	"github.com/nom-nom-hub/floss/internal/tui/page"
 	"github.com/nom-nom-hub/floss/internal/tui/page/chat"
@@ -170,6 +180,24 @@ func (a *appModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 		}
 
+	case commands.OpenAgentDashboardMsg:
+		if a.app.Orchestrator == nil {
+			return a, util.ReportWarn("Agent system is not available")
+		}
+		return a, util.CmdHandler(dialogs.OpenDialogMsg{
+			Model: agents.NewAgentsDialogCmp(a.app.Orchestrator, a.app.Sessions),
+		})
+	// Workflows
+	case pubsub.Event[agentsystem.WorkflowInstance]:
+		// Keep the dashboard current even while another dialog is on top
+		for _, dialog := range a.dialog.Dialogs() {
+			if dialog.ID() == agents.AgentsDialogID {
+				_, dialogCmd := dialog.Update(msg)
+				return a, dialogCmd
+			}
+		}
+		return a, nil
+
 	case commands.SwitchModelMsg:
 		return a, util.CmdHandler(
 			dialogs.OpenDialogMsg{
@@ -181,6 +209,34 @@ func (a *appModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return a, util.CmdHandler(dialogs.OpenDialogMsg{
 			Model: compact.NewCompactDialogCmp(a.app.CoderAgent, msg.SessionID, true),
 		})
+	case commands.OpenPinsDialogMsg:
+		return a, util.CmdHandler(dialogs.OpenDialogMsg{
+			Model: pins.NewPinsDialogCmp(a.app.Messages, msg.SessionID),
+		})
+	case messages.TogglePinMsg:
+		return a, a.togglePin(msg.Message.ID)
+	case commands.UndoFileChangeMsg:
+		return a, a.undoFileChange(msg.SessionID)
+	case commands.OpenRevertDialogMsg:
+		if a.app.CoderAgent.IsBusy() {
+			return a, util.ReportWarn("Agent is busy, please wait...")
+		}
+		return a, util.CmdHandler(dialogs.OpenDialogMsg{
+			Model: revert.NewRevertDialogCmp(a.app.History, msg.SessionID),
+		})
+	case commands.CreateCheckpointMsg:
+		return a, a.createCheckpoint(msg.Name)
+	case commands.OpenCheckpointsDialogMsg:
+		if a.app.CoderAgent.IsBusy() {
+			return a, util.ReportWarn("Agent is busy, please wait...")
+		}
+		return a, util.CmdHandler(dialogs.OpenDialogMsg{
+			Model: checkpoints.NewCheckpointsDialogCmp(a.app.Checkpoints, msg.SessionID),
+		})
+	case commands.OpenPromptQueueMsg:
+		return a, util.CmdHandler(dialogs.OpenDialogMsg{
+			Model: queue.NewQueueDialogCmp(a.app.CoderAgent, msg.SessionID),
+		})
 	case commands.QuitMsg:
 		return a, util.CmdHandler(dialogs.OpenDialogMsg{
 			Model: quit.NewQuitDialog(),
@@ -262,20 +318,8 @@ func (a *appModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			cmds = append(cmds, dialogCmd)
 		}
 
-		// Handle auto-compact logic
-		if payload.Done && payload.Type == agent.AgentEventTypeResponse && a.selectedSessionID != "" {
-			// Get current session to check token usage
-			session, err := a.app.Sessions.Get(context.Background(), a.selectedSessionID)
-			if err == nil {
-				model := a.app.CoderAgent.Model()
-				contextWindow := model.ContextWindow
-				tokens := session.CompletionTokens + session.PromptTokens
-				if (tokens >= int64(float64(contextWindow)*0.95)) && !config.Get().Options.DisableAutoSummarize { // Show compact confirmation dialog
-					cmds = append(cmds, util.CmdHandler(dialogs.OpenDialogMsg{
-						Model: compact.NewCompactDialogCmp(a.app.CoderAgent, a.selectedSessionID, false),
-					}))
-				}
-			}
+		if payload.Type == agent.AgentEventTypeWarning {
+			cmds = append(cmds, util.ReportWarn(payload.Progress))
 		}
 
 		return a, tea.Batch(cmds...)
@@ -498,6 +542,65 @@ func (a *appModel) handleKeyPressMsg(msg tea.KeyPressMsg) tea.Cmd {
 	}
 }
 
+// togglePin pins the message selected in the chat, or unpins it, so that
+// compaction keeps it verbatim
+func (a *appModel) togglePin(messageID string) tea.Cmd {
+	return func() tea.Msg {
+		// The chat may hold an older copy of the message
+		msg, err := a.app.Messages.Get(context.Background(), messageID)
+		if err != nil {
+			return util.InfoMsg{Type: util.InfoTypeError, Msg: err.Error()}
+		}
+		if err := a.app.Messages.SetPinned(context.Background(), msg.ID, !msg.Pinned); err != nil {
+			return util.InfoMsg{Type: util.InfoTypeError, Msg: err.Error()}
+		}
+		if msg.Pinned {
+			return util.InfoMsg{Type: util.InfoTypeInfo, Msg: "Unpinned the message"}
+		}
+		return util.InfoMsg{Type: util.InfoTypeInfo, Msg: "Pinned the message"}
+	}
+}
+
+// undoFileChange writes back the file the session changed last as it was
+// before, leaving files modified outside floss to the revert dialog
+func (a *appModel) undoFileChange(sessionID string) tea.Cmd {
+	if a.app.CoderAgent.IsBusy() {
+		return util.ReportWarn("Agent is busy, please wait...")
+	}
+	return func() tea.Msg {
+		file, err := a.app.History.Undo(context.Background(), sessionID, false)
+		switch {
+		case errors.Is(err, history.ErrNothingToUndo):
+			return util.InfoMsg{Type: util.InfoTypeWarn, Msg: "There is no file change to undo"}
+		case errors.Is(err, history.ErrFileModified):
+			return util.InfoMsg{Type: util.InfoTypeWarn, Msg: err.Error() + ", use Revert Files to overwrite it"}
+		case err != nil:
+			return util.InfoMsg{Type: util.InfoTypeError, Msg: err.Error()}
+		}
+		return util.InfoMsg{Type: util.InfoTypeInfo, Msg: "Undid the last change to " + fsext.PrettyPath(file.Path)}
+	}
+}
+
+// createCheckpoint records where the selected session is now
+func (a *appModel) createCheckpoint(name string) tea.Cmd {
+	if a.selectedSessionID == "" {
+		return util.ReportWarn("There is no session to checkpoint")
+	}
+	if a.app.CoderAgent.IsSessionBusy(a.selectedSessionID) {
+		return util.ReportWarn("Agent is busy, please wait...")
+	}
+	if name == "" {
+		name = "Checkpoint"
+	}
+	sessionID := a.selectedSessionID
+	return func() tea.Msg {
+		if _, err := a.app.Checkpoints.Create(context.Background(), sessionID, name); err != nil {
+			return util.InfoMsg{Type: util.InfoTypeError, Msg: err.Error()}
+		}
+		return util.InfoMsg{Type: util.InfoTypeInfo, Msg: fmt.Sprintf("Created checkpoint %q", name)}
+	}
+}
+
 // moveToPage handles navigation between different pages in the application.
 func (a *appModel) moveToPage(pageID page.PageID) tea.Cmd {
 	if a.app.CoderAgent.IsBusy() {